@@ -0,0 +1,125 @@
+// Package audit provides sinks for the events providers record through
+// AuthOptions.AuditSink.
+//
+// Events describe logins, refreshes, revocations, new credentials, lockouts
+// and impersonation, with the subject, actor, client IP, user agent, reason
+// and token ID. They never carry passwords, API keys or tokens. A LogSink
+// writes them to a log/slog logger and a JSONLinesSink appends one JSON
+// object per line to a file; mysql.NewMySQLAuditSink stores them in a table.
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"sync"
+
+	"github.com/responsible-api/responsible-auth/auth"
+)
+
+// LogSink writes audit events to a slog logger: successes at Info and
+// failures at Warn.
+type LogSink struct {
+	logger *slog.Logger
+}
+
+// NewLogSink creates a sink logging to logger, or to slog.Default when nil.
+func NewLogSink(logger *slog.Logger) *LogSink {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &LogSink{logger: logger}
+}
+
+// Record logs the event as "audit" with one attribute per field that is set.
+func (s *LogSink) Record(event auth.AuditEvent) error {
+	level := slog.LevelInfo
+	if !event.Success {
+		level = slog.LevelWarn
+	}
+
+	attrs := []slog.Attr{
+		slog.String("type", event.Type),
+		slog.Time("time", event.Time),
+		slog.Bool("success", event.Success),
+	}
+	for _, field := range []struct{ key, value string }{
+		{"method", event.Method},
+		{"subject", event.Subject},
+		{"actor", event.Actor},
+		{"client_id", event.ClientID},
+		{"ip", event.IP},
+		{"user_agent", event.UserAgent},
+		{"reason", event.Reason},
+		{"jti", event.TokenID},
+	} {
+		if field.value != "" {
+			attrs = append(attrs, slog.String(field.key, field.value))
+		}
+	}
+	if event.TenantID != 0 {
+		attrs = append(attrs, slog.Uint64("tenant_id", event.TenantID))
+	}
+
+	s.logger.LogAttrs(context.Background(), level, "audit", attrs...)
+	return nil
+}
+
+// JSONLinesSink writes each audit event as a line of JSON.
+type JSONLinesSink struct {
+	mu      sync.Mutex
+	encoder *json.Encoder
+	closer  io.Closer
+}
+
+// NewJSONLinesSink creates a sink writing to w. Writes are serialized, so w
+// need not be safe for concurrent use.
+func NewJSONLinesSink(w io.Writer) *JSONLinesSink {
+	return &JSONLinesSink{encoder: json.NewEncoder(w)}
+}
+
+// OpenJSONLinesFile creates a sink appending to the named file, creating it
+// with mode 0600 when missing. Close the sink to close the file.
+func OpenJSONLinesFile(name string) (*JSONLinesSink, error) {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	sink := NewJSONLinesSink(f)
+	sink.closer = f
+	return sink, nil
+}
+
+// Record writes the event as one line.
+func (s *JSONLinesSink) Record(event auth.AuditEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.encoder.Encode(event)
+}
+
+// Close closes the file opened by OpenJSONLinesFile; it does nothing for
+// sinks created with NewJSONLinesSink.
+func (s *JSONLinesSink) Close() error {
+	if s.closer == nil {
+		return nil
+	}
+	return s.closer.Close()
+}
+
+// Multi returns a sink recording every event with each of sinks. All sinks
+// are tried and their errors joined, so one failing sink does not keep the
+// event from the others.
+func Multi(sinks ...auth.AuditSink) auth.AuditSink {
+	return auth.AuditSinkFunc(func(event auth.AuditEvent) error {
+		var errs []error
+		for _, sink := range sinks {
+			if err := sink.Record(event); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return errors.Join(errs...)
+	})
+}
@@ -0,0 +1,118 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/responsible-api/responsible-auth/auth"
+)
+
+func testEvent() auth.AuditEvent {
+	return auth.AuditEvent{
+		Type:      auth.EventLogin,
+		Time:      time.Unix(1700000000, 0).UTC(),
+		Method:    auth.GrantPassword,
+		Subject:   "alice",
+		IP:        "192.0.2.1",
+		UserAgent: "test-agent",
+		Reason:    "invalid credentials",
+	}
+}
+
+func TestLogSink(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewLogSink(slog.New(slog.NewJSONHandler(&buf, nil)))
+
+	if err := sink.Record(testEvent()); err != nil {
+		t.Fatalf("Record() unexpected error = %v", err)
+	}
+
+	var logged map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &logged); err != nil {
+		t.Fatalf("log output is not JSON: %v", err)
+	}
+	want := map[string]interface{}{
+		"level":   "WARN",
+		"msg":     "audit",
+		"type":    auth.EventLogin,
+		"success": false,
+		"subject": "alice",
+		"ip":      "192.0.2.1",
+		"reason":  "invalid credentials",
+	}
+	for key, value := range want {
+		if logged[key] != value {
+			t.Errorf("logged %s = %v, want %v", key, logged[key], value)
+		}
+	}
+	if _, ok := logged["actor"]; ok {
+		t.Error("logged an empty actor")
+	}
+}
+
+func TestJSONLinesSink(t *testing.T) {
+	name := filepath.Join(t.TempDir(), "audit.jsonl")
+	sink, err := OpenJSONLinesFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	success := testEvent()
+	success.Success = true
+	success.Reason = ""
+	success.TokenID = "abc123"
+	for _, event := range []auth.AuditEvent{testEvent(), success} {
+		if err := sink.Record(event); err != nil {
+			t.Fatalf("Record() unexpected error = %v", err)
+		}
+	}
+	if err := sink.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var events []auth.AuditEvent
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var event auth.AuditEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("line %q is not an event: %v", scanner.Text(), err)
+		}
+		events = append(events, event)
+	}
+	if len(events) != 2 {
+		t.Fatalf("read %d events, want 2", len(events))
+	}
+	if events[0] != testEvent() || events[1] != success {
+		t.Errorf("read events = %+v, want %+v and %+v", events, testEvent(), success)
+	}
+}
+
+func TestMulti(t *testing.T) {
+	var buf bytes.Buffer
+	failing := errors.New("disk full")
+	sink := Multi(
+		auth.AuditSinkFunc(func(auth.AuditEvent) error { return failing }),
+		NewJSONLinesSink(&buf),
+	)
+
+	if err := sink.Record(testEvent()); !errors.Is(err, failing) {
+		t.Errorf("Record() error = %v, want %v", err, failing)
+	}
+	if !strings.Contains(buf.String(), `"subject":"alice"`) {
+		t.Errorf("later sink did not receive the event: %q", buf.String())
+	}
+}
@@ -0,0 +1,86 @@
+package auth
+
+import "time"
+
+// Audit event types.
+const (
+	// EventLogin is a credential check: a password, API key, passkey,
+	// directory or upstream sign-in, MFA code or OAuth grant
+	EventLogin = "login"
+
+	// EventRefresh is a refresh token exchanged for a new access token
+	EventRefresh = "refresh"
+
+	// EventRevoke is a session or refresh token revoked, including logouts
+	EventRevoke = "revoke"
+
+	// EventKeyCreated is a new credential, such as a passkey, stored for a user
+	EventKeyCreated = "key_created"
+
+	// EventLockout is a login refused, or an account locked, by the lockout guard
+	EventLockout = "lockout"
+
+	// EventImpersonation is a token issued to staff acting as a user
+	EventImpersonation = "impersonation"
+)
+
+// AuditEvent records an authentication decision. It never holds secrets:
+// tokens are identified by their jti only.
+type AuditEvent struct {
+	// Type is one of the Event constants
+	Type string    `json:"type"`
+	Time time.Time `json:"time"`
+
+	// Success reports whether the action was allowed
+	Success bool `json:"success"`
+
+	// Method is the grant or login method, such as "password" or "api_key"
+	Method string `json:"method,omitempty"`
+
+	// Subject is the user the event is about and Actor, when set, the user
+	// who acted on the subject's behalf, such as an impersonating admin
+	Subject string `json:"subject,omitempty"`
+	Actor   string `json:"actor,omitempty"`
+
+	// ClientID is the OAuth client the request came from
+	ClientID string `json:"client_id,omitempty"`
+
+	TenantID  uint64 `json:"tenant_id,omitempty"`
+	IP        string `json:"ip,omitempty"`
+	UserAgent string `json:"user_agent,omitempty"`
+
+	// Reason explains a failure or, for impersonation, the justification given
+	Reason string `json:"reason,omitempty"`
+
+	// TokenID is the jti of the token the event issued or used
+	TokenID string `json:"jti,omitempty"`
+}
+
+// AuditSink receives audit events. An error from Record means the event was
+// not stored; actions that must be audited are refused when that happens.
+type AuditSink interface {
+	Record(event AuditEvent) error
+}
+
+// AuditSinkFunc adapts a function to the AuditSink interface.
+type AuditSinkFunc func(event AuditEvent) error
+
+// Record calls f(event).
+func (f AuditSinkFunc) Record(event AuditEvent) error {
+	return f(event)
+}
+
+// Audit passes event to the AuditSink, filling in its time and tenant when
+// unset. Without a sink the event is dropped.
+func (o AuthOptions) Audit(event AuditEvent) error {
+	if o.AuditSink == nil {
+		return nil
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now()
+	}
+	if event.TenantID == 0 {
+		event.TenantID = o.TenantID
+	}
+	return o.AuditSink.Record(event)
+}
@@ -1,9 +1,11 @@
 package auth
 
 import (
+	"log/slog"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/responsible-api/responsible-auth/concerns"
 	"github.com/responsible-api/responsible-auth/resource/access"
 	"github.com/responsible-api/responsible-auth/storage"
 )
@@ -27,9 +29,66 @@ type AuthOptions struct {
 	Subject   string `json:"subject,omitempty"`
 	Scopes    string `json:"scopes,omitempty"`
 	Role      string `json:"role,omitempty"`
+	AccountID uint64 `json:"account_id,omitempty"`
+
+	// TenantID binds tokens to a tenant: tokens issued carry it as the tid
+	// claim and Validate rejects tokens issued for another tenant
+	TenantID uint64 `json:"tenant_id,omitempty"`
+
+	// Roles lists the RBAC roles of the token's user, usually set per user by
+	// a ClaimsEnricher such as rbac.Authorizer
+	Roles []string `json:"roles,omitempty"`
+
+	// Audience restricts the token to the named recipients and Actor records
+	// who is acting on behalf of the subject; both are set by token exchange
+	Audience []string        `json:"audience,omitempty"`
+	Actor    *concerns.Actor `json:"act,omitempty"`
+
+	// AMR lists the authentication methods used to log in (RFC 8176)
+	AMR []string `json:"amr,omitempty"`
+
+	// ClientID names the OAuth client tokens are issued to, as the client_id
+	// claim, and binds refresh tokens to it so no other client can redeem
+	// them; set by the token endpoint
+	ClientID string `json:"client_id,omitempty"`
 
 	// Custom claims
 	CustomClaims map[string]interface{} `json:"custom_claims,omitempty"`
+
+	// ClaimsEnricher, when set, adjusts the role, scopes, audience and custom
+	// claims of each access token issued to a user
+	ClaimsEnricher ClaimsEnricher `json:"-"`
+
+	// RefreshChecker, when set, is consulted before every refresh token is
+	// redeemed; refresh tokens bound to a browser session are refused without one
+	RefreshChecker RefreshChecker `json:"-"`
+
+	// AuditSink, when set, receives audit events; impersonation requires one
+	AuditSink AuditSink `json:"-"`
+
+	// Logger receives the library's logs; nil uses slog.Default. Wrap its
+	// handler with logging.NewRedactHandler to keep secrets out of logs
+	Logger *slog.Logger `json:"-"`
+
+	// RejectImpersonation makes Validate reject tokens with an act claim, so
+	// neither impersonating staff nor delegated clients reach sensitive routes
+	RejectImpersonation bool `json:"reject_impersonation,omitempty"`
+}
+
+// Log returns the logger the library logs to.
+func (o AuthOptions) Log() *slog.Logger {
+	if o.Logger == nil {
+		return slog.Default()
+	}
+	return o.Logger
+}
+
+// RefreshChecker decides whether a verified refresh token may still be
+// redeemed. It is consulted once per redemption, so it may revoke single-use
+// tokens as it accepts them. session.Manager is one, refusing the tokens of
+// ended sessions and revoking each session token it accepts.
+type RefreshChecker interface {
+	CheckRefresh(refreshToken string, claims jwt.MapClaims) error
 }
 
 type AuthInterface interface {
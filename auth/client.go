@@ -0,0 +1,26 @@
+package auth
+
+import (
+	"net"
+	"net/http"
+)
+
+// ClientInfo describes the client making an authentication request.
+type ClientInfo struct {
+	IP        string
+	UserAgent string
+}
+
+// ClientInfoFromRequest reads the client IP from the connection's remote address.
+// Forwarding headers are ignored because clients can forge them; applications
+// behind a trusted proxy should fill ClientInfo themselves.
+func ClientInfoFromRequest(r *http.Request) ClientInfo {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+	return ClientInfo{
+		IP:        ip,
+		UserAgent: r.UserAgent(),
+	}
+}
@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"github.com/responsible-api/responsible-auth/resource/user"
+)
+
+// Grant types reported to a ClaimsEnricher. The OAuth grants use their
+// token endpoint grant_type values.
+const (
+	GrantPassword          = "password"
+	GrantAPIKey            = "api_key"
+	GrantLDAP              = "ldap"
+	GrantWebAuthn          = "webauthn"
+	GrantFederation        = "federation"
+	GrantMFA               = "mfa"
+	GrantRefreshToken      = "refresh_token"
+	GrantAuthorizationCode = "authorization_code"
+	GrantDeviceCode        = "urn:ietf:params:oauth:grant-type:device_code"
+	GrantImpersonation     = "impersonation"
+)
+
+// Grant describes why a token is being issued.
+type Grant struct {
+	// Type is one of the Grant constants
+	Type string
+
+	// ClientID is the OAuth client the token is issued to; empty for provider logins
+	ClientID string
+
+	// AMR lists the authentication methods of the login (RFC 8176)
+	AMR []string
+}
+
+// Claims are the claims of an access token that a ClaimsEnricher may change.
+type Claims struct {
+	Role         string
+	Roles        []string
+	Scopes       string
+	Audience     []string
+	CustomClaims map[string]interface{}
+}
+
+// ClaimsEnricher adjusts the claims of every access token issued to a user,
+// for example to load the user's roles from a database. It is called after
+// the user has authenticated and before the token is signed; an error aborts
+// issuance and is returned to the caller.
+type ClaimsEnricher interface {
+	Enrich(u *user.User, grant Grant, claims *Claims) error
+}
+
+// ClaimsEnricherFunc adapts a function to the ClaimsEnricher interface.
+type ClaimsEnricherFunc func(u *user.User, grant Grant, claims *Claims) error
+
+// Enrich calls f(u, grant, claims).
+func (f ClaimsEnricherFunc) Enrich(u *user.User, grant Grant, claims *Claims) error {
+	return f(u, grant, claims)
+}
+
+// Enrich returns the options with their role, roles, scopes, audience and
+// custom claims passed through the ClaimsEnricher for a token issued to u. Without
+// an enricher the options are returned unchanged.
+func (o AuthOptions) Enrich(u *user.User, grant Grant) (AuthOptions, error) {
+	if o.ClaimsEnricher == nil {
+		return o, nil
+	}
+
+	// Copies keep the enricher from changing the options shared by every token
+	claims := &Claims{
+		Role:         o.Role,
+		Roles:        append([]string(nil), o.Roles...),
+		Scopes:       o.Scopes,
+		Audience:     append([]string(nil), o.Audience...),
+		CustomClaims: make(map[string]interface{}, len(o.CustomClaims)),
+	}
+	for name, value := range o.CustomClaims {
+		claims.CustomClaims[name] = value
+	}
+	if grant.AMR == nil {
+		grant.AMR = append([]string(nil), o.AMR...)
+	}
+
+	if err := o.ClaimsEnricher.Enrich(u, grant, claims); err != nil {
+		return AuthOptions{}, err
+	}
+
+	o.Role = claims.Role
+	o.Roles = claims.Roles
+	o.Scopes = claims.Scopes
+	o.Audience = claims.Audience
+	o.CustomClaims = claims.CustomClaims
+	return o, nil
+}
@@ -0,0 +1,72 @@
+// Package claims issues and validates access tokens whose claims are the
+// caller's own struct, so custom claims keep their Go types instead of coming
+// back from AuthOptions.CustomClaims as float64 and interface{} values.
+//
+// A claims struct embeds concerns.ClaimsGeneric and declares its own fields
+// with JSON tags:
+//
+//	type OrderClaims struct {
+//		concerns.ClaimsGeneric
+//		OrderID  int64     `json:"order_id"`
+//		Reviewed time.Time `json:"reviewed"`
+//	}
+//
+//	token, err := claims.Issue(&OrderClaims{OrderID: 42}, options)
+//	validated, err := claims.Validate[OrderClaims](token.GetToken(), options)
+//
+// The tokens are ordinary access tokens: providers and middleware that use
+// the map form still validate them, reading the standard claims and ignoring
+// the typed ones.
+package claims
+
+import (
+	"errors"
+
+	"github.com/responsible-api/responsible-auth/auth"
+	"github.com/responsible-api/responsible-auth/concerns"
+	"github.com/responsible-api/responsible-auth/internal"
+	"github.com/responsible-api/responsible-auth/resource/access"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ErrNotValidated is returned by From for a token that has not been validated.
+var ErrNotValidated = errors.New("token has not been validated")
+
+// Pointer constrains PT to *T for claim structs T embedding concerns.ClaimsGeneric.
+type Pointer[T any] interface {
+	*T
+	concerns.Generic
+}
+
+// Issue signs claims as an access token. Registered and standard claims left
+// unset are filled from options as for the providers' access tokens; options'
+// CustomClaims are only used when claims has none of its own.
+func Issue[T any, PT Pointer[T]](claims PT, options auth.AuthOptions) (*access.RToken, error) {
+	return internal.IssueAccessToken(claims, options)
+}
+
+// Validate checks an access token's signature, expiry and not-before time and
+// returns its claims decoded into a new T.
+func Validate[T any, PT Pointer[T]](tokenString string, options auth.AuthOptions) (*T, error) {
+	claims := PT(new(T))
+	if _, err := internal.ValidateClaims(tokenString, claims, options); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// From decodes the claims of a token that has already been validated, such
+// as the one middleware.TokenFromContext returns, into a new T.
+func From[T any, PT Pointer[T]](token *jwt.Token) (*T, error) {
+	if token == nil || !token.Valid || token.Raw == "" {
+		return nil, ErrNotValidated
+	}
+
+	claims := PT(new(T))
+	// The signature was verified when the token was validated
+	if _, _, err := jwt.NewParser().ParseUnverified(token.Raw, claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
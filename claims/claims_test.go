@@ -0,0 +1,179 @@
+package claims
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/responsible-api/responsible-auth/concerns"
+	"github.com/responsible-api/responsible-auth/internal"
+	"github.com/responsible-api/responsible-auth/testutils"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type Address struct {
+	City    string `json:"city"`
+	Country string `json:"country"`
+}
+
+type OrderClaims struct {
+	concerns.ClaimsGeneric
+	OrderID  int64     `json:"order_id"`
+	Quantity uint8     `json:"quantity"`
+	Total    float64   `json:"total"`
+	Reviewed time.Time `json:"reviewed"`
+	Ship     Address   `json:"ship"`
+	Tags     []string  `json:"tags"`
+}
+
+func newOrderClaims() *OrderClaims {
+	return &OrderClaims{
+		// Beyond float64's 2^53 integer precision
+		OrderID:  9007199254740993,
+		Quantity: 3,
+		Total:    19.99,
+		Reviewed: time.Date(2026, 10, 18, 9, 30, 0, 0, time.UTC),
+		Ship:     Address{City: "Utrecht", Country: "NL"},
+		Tags:     []string{"gift", "express"},
+	}
+}
+
+func TestIssueAndValidate(t *testing.T) {
+	options := testutils.TestAuthOptions()
+	want := newOrderClaims()
+
+	token, err := Issue(newOrderClaims(), options)
+	if err != nil {
+		t.Fatalf("Issue() unexpected error = %v", err)
+	}
+
+	got, err := Validate[OrderClaims](token.GetToken(), options)
+	if err != nil {
+		t.Fatalf("Validate() unexpected error = %v", err)
+	}
+	if got.OrderID != want.OrderID || got.Quantity != want.Quantity || got.Total != want.Total ||
+		!got.Reviewed.Equal(want.Reviewed) || got.Ship != want.Ship || !reflect.DeepEqual(got.Tags, want.Tags) {
+		t.Errorf("Validate() typed claims = %+v, want %+v", got, want)
+	}
+
+	// The standard claims come from the options
+	if got.AccountID != options.AccountID || got.Role != options.Role || got.Issuer != options.Issuer {
+		t.Errorf("Validate() standard claims = %+v, want them filled from the options", got.ClaimsGeneric)
+	}
+	if got.ExpiresAt == nil || got.IssuedAt == nil || got.NotBefore == nil {
+		t.Errorf("Validate() registered claims = %+v, want exp, iat and nbf set", got.RegisteredClaims)
+	}
+	if !reflect.DeepEqual(got.CustomClaims, map[string]interface{}{"test_claim": "test_value", "number": float64(123)}) {
+		t.Errorf("Validate() custom claims = %v, want the options' custom claims, numbers as float64", got.CustomClaims)
+	}
+
+	// The map form still accepts the token
+	generic, err := internal.Validate(token.GetToken(), options)
+	if err != nil {
+		t.Fatalf("internal.Validate() unexpected error = %v", err)
+	}
+	if claims := generic.Claims.(*concerns.ClaimsGeneric); claims.AccountID != options.AccountID {
+		t.Errorf("internal.Validate() account_id = %d, want %d", claims.AccountID, options.AccountID)
+	}
+}
+
+func TestIssueKeepsSetClaims(t *testing.T) {
+	options := testutils.TestAuthOptions()
+	expires := time.Now().Add(5 * time.Minute).Truncate(time.Second)
+
+	claims := newOrderClaims()
+	claims.Subject = "order-service"
+	claims.Role = "fulfilment"
+	claims.ExpiresAt = jwt.NewNumericDate(expires)
+	claims.CustomClaims = map[string]interface{}{"channel": "web"}
+
+	token, err := Issue(claims, options)
+	if err != nil {
+		t.Fatalf("Issue() unexpected error = %v", err)
+	}
+	got, err := Validate[OrderClaims](token.GetToken(), options)
+	if err != nil {
+		t.Fatalf("Validate() unexpected error = %v", err)
+	}
+	if got.Subject != "order-service" || got.Role != "fulfilment" || !got.ExpiresAt.Time.Equal(expires) {
+		t.Errorf("Validate() = sub %q, role %q, exp %v, want the values set on the claims", got.Subject, got.Role, got.ExpiresAt)
+	}
+	if !reflect.DeepEqual(got.CustomClaims, map[string]interface{}{"channel": "web"}) {
+		t.Errorf("Validate() custom claims = %v, want only the claims' own", got.CustomClaims)
+	}
+}
+
+func TestValidateRejects(t *testing.T) {
+	options := testutils.TestAuthOptions()
+
+	expired := newOrderClaims()
+	expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Hour))
+	expiredToken, err := Issue(expired, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other := testutils.TestAuthOptions()
+	other.SecretKey = "another-secret-key-32-characters"
+	foreignToken, err := Issue(newOrderClaims(), other)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{name: "expired", token: expiredToken.GetToken()},
+		{name: "signed with another key", token: foreignToken.GetToken()},
+		{name: "malformed", token: "not-a-token"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := Validate[OrderClaims](tt.token, options); err == nil || got != nil {
+				t.Errorf("Validate() = %+v, %v, want an error", got, err)
+			}
+		})
+	}
+
+	missingSecret := testutils.TestAuthOptions()
+	missingSecret.SecretKey = ""
+	if _, err := Issue(newOrderClaims(), missingSecret); err == nil {
+		t.Errorf("Issue() without a secret key expected an error")
+	}
+}
+
+func TestFrom(t *testing.T) {
+	options := testutils.TestAuthOptions()
+	issued, err := Issue(newOrderClaims(), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// As validated by a provider and stored by the middleware
+	validated, err := internal.Validate(issued.GetToken(), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := From[OrderClaims](validated)
+	if err != nil {
+		t.Fatalf("From() unexpected error = %v", err)
+	}
+	if got.OrderID != 9007199254740993 || got.Ship.City != "Utrecht" {
+		t.Errorf("From() = %+v, want the typed claims", got)
+	}
+
+	unverified, _, err := jwt.NewParser().ParseUnverified(issued.GetToken(), &concerns.ClaimsGeneric{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := From[OrderClaims](unverified); !errors.Is(err, ErrNotValidated) {
+		t.Errorf("From() unvalidated token error = %v, want %v", err, ErrNotValidated)
+	}
+	if _, err := From[OrderClaims](nil); !errors.Is(err, ErrNotValidated) {
+		t.Errorf("From() nil token error = %v, want %v", err, ErrNotValidated)
+	}
+}
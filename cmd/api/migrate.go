@@ -0,0 +1,88 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/responsible-api/responsible-auth/logging"
+	"github.com/responsible-api/responsible-auth/migration"
+	"github.com/responsible-api/responsible-auth/tools"
+)
+
+const migrateUsage = `Usage: api migrate [flags] <up|down|status>
+
+Database settings are read from the DB_* environment variables or .env.
+
+Flags:
+`
+
+// runMigrate implements the "migrate" subcommand.
+func runMigrate(args []string) error {
+	flags := flag.NewFlagSet("migrate", flag.ExitOnError)
+	dialect := flags.String("dialect", migration.DialectMySQL, "SQL dialect of the target database")
+	steps := flags.Int("steps", 0, "number of migrations to apply or roll back (default: all for up, one for down)")
+	dryRun := flags.Bool("dry-run", false, "print the planned SQL without executing it")
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), migrateUsage)
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	// Logs go to stderr, redacted, so stdout only carries migration output
+	log := slog.New(logging.NewRedactHandler(slog.NewTextHandler(os.Stderr, nil)))
+
+	db, err := tools.NewDatabaseWithLogger(log)
+	if err != nil {
+		return err
+	}
+
+	migrator, err := migration.New(db, *dialect)
+	if err != nil {
+		return err
+	}
+
+	options := migration.Options{
+		Steps:  *steps,
+		DryRun: *dryRun,
+		Out:    os.Stdout,
+	}
+
+	switch flags.Arg(0) {
+	case "up":
+		applied, err := migrator.Up(options)
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			log.Info("database is up to date")
+		}
+	case "down":
+		if _, err := migrator.Down(options); err != nil {
+			return err
+		}
+	case "status":
+		statuses, err := migrator.Status()
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			state := "pending"
+			if status.Applied {
+				state = "applied " + time.Unix(status.AppliedAt, 0).UTC().Format(time.RFC3339)
+			}
+			fmt.Printf("%04d %-40s %s\n", status.Version, status.Name, state)
+		}
+	default:
+		flags.Usage()
+		os.Exit(2)
+	}
+	return nil
+}
@@ -13,5 +13,56 @@ type ClaimsGeneric struct {
 	jwt.RegisteredClaims
 	CustomClaims map[string]interface{} `json:"custom,omitempty"`
 	Role         string                 `json:"role,omitempty"`
+	Roles        []string               `json:"roles,omitempty"`
 	Scopes       string                 `json:"scopes,omitempty"`
+	AccountID    uint64                 `json:"account_id,omitempty"`
+	TenantID     uint64                 `json:"tid,omitempty"`
+	Actor        *Actor                 `json:"act,omitempty"`
+	AMR          []string               `json:"amr,omitempty"`
+
+	// ClientID names the OAuth client the token was issued to (RFC 9068)
+	ClientID string `json:"client_id,omitempty"`
+}
+
+// IsClient reports whether the token was issued to an OAuth client acting on
+// its own behalf, as by the client credentials grant, rather than to a user.
+func (c *ClaimsGeneric) IsClient() bool {
+	return c.ClientID != "" && c.Subject == c.ClientID
+}
+
+// Generic is implemented by claim structs embedding ClaimsGeneric, so callers
+// can declare their own typed claims next to the standard ones.
+type Generic interface {
+	jwt.Claims
+	GenericClaims() *ClaimsGeneric
+}
+
+// GenericClaims returns the standard claims, promoted to every struct embedding ClaimsGeneric.
+func (c *ClaimsGeneric) GenericClaims() *ClaimsGeneric {
+	return c
+}
+
+// Actor names the party acting on behalf of the token's subject (RFC 8693
+// section 4.1). A nested Actor records the earlier actors in a delegation chain.
+type Actor struct {
+	Subject string `json:"sub"`
+	Actor   *Actor `json:"act,omitempty"`
+}
+
+// IDClaims are the claims of an OpenID Connect ID token.
+type IDClaims struct {
+	jwt.RegisteredClaims
+	Nonce    string           `json:"nonce,omitempty"`
+	AuthTime *jwt.NumericDate `json:"auth_time,omitempty"`
+	Email    string           `json:"email,omitempty"`
+	Name     string           `json:"name,omitempty"`
+}
+
+// FederationClaims are the claims of the state token that carries an upstream
+// sign-in from its redirect to its callback.
+type FederationClaims struct {
+	jwt.RegisteredClaims
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
 }
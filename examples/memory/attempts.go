@@ -0,0 +1,62 @@
+package memory
+
+import (
+	"sync"
+
+	"github.com/responsible-api/responsible-auth/resource/attempt"
+	"github.com/responsible-api/responsible-auth/storage"
+)
+
+// InMemoryAttemptStorage is an in-memory implementation of AttemptStorage
+type InMemoryAttemptStorage struct {
+	mu       sync.Mutex
+	attempts map[string]*attempt.Attempt
+}
+
+// NewInMemoryAttemptStorage creates an empty in-memory attempt storage
+func NewInMemoryAttemptStorage() storage.AttemptStorage {
+	return &InMemoryAttemptStorage{
+		attempts: make(map[string]*attempt.Attempt),
+	}
+}
+
+// FindAttempt returns the record for key, or nil when none exists
+func (m *InMemoryAttemptStorage) FindAttempt(key string) (*attempt.Attempt, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	a, exists := m.attempts[key]
+	if !exists {
+		return nil, nil
+	}
+	c := *a
+	return &c, nil
+}
+
+// UpdateAttempt atomically applies update to the record for key
+func (m *InMemoryAttemptStorage) UpdateAttempt(key string, update func(a *attempt.Attempt)) (*attempt.Attempt, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	a, exists := m.attempts[key]
+	if !exists {
+		a = &attempt.Attempt{Key: key}
+	}
+
+	updated := *a
+	update(&updated)
+	updated.Key = key
+	m.attempts[key] = &updated
+
+	c := updated
+	return &c, nil
+}
+
+// DeleteAttempt removes the record for key
+func (m *InMemoryAttemptStorage) DeleteAttempt(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.attempts, key)
+	return nil
+}
@@ -0,0 +1,49 @@
+package memory
+
+import (
+	"sync"
+
+	"github.com/responsible-api/responsible-auth/resource/bucket"
+	"github.com/responsible-api/responsible-auth/storage"
+)
+
+// InMemoryBucketStorage is an in-memory implementation of BucketStorage
+type InMemoryBucketStorage struct {
+	mu      sync.Mutex
+	buckets map[bucket.Key]*bucket.Bucket
+}
+
+// NewInMemoryBucketStorage creates an empty in-memory bucket storage
+func NewInMemoryBucketStorage() storage.BucketStorage {
+	return &InMemoryBucketStorage{
+		buckets: make(map[bucket.Key]*bucket.Bucket),
+	}
+}
+
+// UpdateBucket atomically applies update to the bucket with the key
+func (m *InMemoryBucketStorage) UpdateBucket(key bucket.Key, update func(b *bucket.Bucket)) (*bucket.Bucket, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	b, exists := m.buckets[key]
+	if !exists {
+		b = &bucket.Bucket{Key: key}
+	}
+
+	updated := *b
+	update(&updated)
+	updated.Key = key
+	m.buckets[key] = &updated
+
+	c := updated
+	return &c, nil
+}
+
+// DeleteBucket removes the bucket with the key
+func (m *InMemoryBucketStorage) DeleteBucket(key bucket.Key) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.buckets, key)
+	return nil
+}
@@ -2,6 +2,8 @@ package memory
 
 import (
 	"errors"
+	"strconv"
+	"sync"
 
 	"github.com/responsible-api/responsible-auth/resource/user"
 	"github.com/responsible-api/responsible-auth/storage"
@@ -10,19 +12,15 @@ import (
 // InMemoryStorage is a simple in-memory implementation of UserStorage
 // This is useful for testing or applications that don't need persistent storage
 type InMemoryStorage struct {
-	users         map[string]*user.User // keyed by username/email
+	mu            sync.RWMutex
+	users         map[string]*user.User // keyed by mail and account_id
+	names         map[string]*user.User // keyed by unique name
 	apiKeys       map[string]*user.User // keyed by API key
 	refreshTokens map[string]*user.User // keyed by refresh token
 }
 
 // NewInMemoryStorage creates a new in-memory storage with some sample data
 func NewInMemoryStorage() storage.UserStorage {
-	storage := &InMemoryStorage{
-		users:         make(map[string]*user.User),
-		apiKeys:       make(map[string]*user.User),
-		refreshTokens: make(map[string]*user.User),
-	}
-
 	// Add sample users for demonstration
 	sampleUser := &user.User{
 		AccountID: 123456789,
@@ -33,55 +31,205 @@ func NewInMemoryStorage() storage.UserStorage {
 		Status:    1, // active
 	}
 
-	storage.users["test@example.com"] = sampleUser
-	storage.users["test-user"] = sampleUser
-	storage.users["123456789"] = sampleUser
-	storage.apiKeys["api_key_12345"] = sampleUser
+	return NewInMemoryStorageWithUsers(sampleUser)
+}
 
+// NewInMemoryStorageWithUsers creates a new in-memory storage holding only the given users
+func NewInMemoryStorageWithUsers(users ...*user.User) storage.UserStorage {
+	storage := &InMemoryStorage{
+		users:         make(map[string]*user.User),
+		names:         make(map[string]*user.User),
+		apiKeys:       make(map[string]*user.User),
+		refreshTokens: make(map[string]*user.User),
+	}
+
+	for _, u := range users {
+		stored := *u
+		storage.index(&stored)
+	}
 	return storage
 }
 
+// index registers the user under every identifier it can be looked up by.
+// Callers must hold the write lock or own the storage exclusively.
+func (m *InMemoryStorage) index(u *user.User) {
+	m.names[u.Name] = u
+	if u.Mail != "" {
+		m.users[u.Mail] = u
+	}
+	m.users[strconv.FormatUint(u.AccountID, 10)] = u
+	if u.APIKey != "" {
+		m.apiKeys[u.APIKey] = u
+	}
+	if u.Refresh != "" {
+		m.refreshTokens[u.Refresh] = u
+	}
+}
+
+// unindex removes every lookup key pointing at the user.
+// Callers must hold the write lock.
+func (m *InMemoryStorage) unindex(u *user.User) {
+	delete(m.names, u.Name)
+	if m.users[u.Mail] == u {
+		delete(m.users, u.Mail)
+	}
+	accountID := strconv.FormatUint(u.AccountID, 10)
+	if m.users[accountID] == u {
+		delete(m.users, accountID)
+	}
+	delete(m.apiKeys, u.APIKey)
+	delete(m.refreshTokens, u.Refresh)
+}
+
 // FindUserByCredentials retrieves a user by username/email and validates their credentials
 func (m *InMemoryStorage) FindUserByCredentials(username, credentials string) (*user.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
 	user, exists := m.users[username]
 	if !exists {
-		return nil, errors.New("user not found")
+		return nil, storage.ErrUserNotFound
 	}
 
-	if user.Secret != credentials {
-		return nil, errors.New("invalid credentials")
+	if !user.CheckSecret(credentials) {
+		return nil, storage.ErrInvalidCredentials
 	}
 
-	return user, nil
+	return copyUser(user), nil
 }
 
 // FindUserByAPIKey retrieves a user by their API key
 func (m *InMemoryStorage) FindUserByAPIKey(apiKey string) (*user.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
 	user, exists := m.apiKeys[apiKey]
 	if !exists {
 		return nil, errors.New("invalid API key")
 	}
-	return user, nil
+	return copyUser(user), nil
 }
 
-// UpdateRefreshToken stores a refresh token for a user
+// UpdateRefreshToken stores a refresh token for a user, replacing any previous one
 func (m *InMemoryStorage) UpdateRefreshToken(userID string, refreshToken string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	user, exists := m.users[userID]
 	if !exists {
-		return errors.New("user not found")
+		return storage.ErrUserNotFound
 	}
 
+	delete(m.refreshTokens, user.Refresh)
 	user.Refresh = refreshToken
-	m.refreshTokens[refreshToken] = user
+	if refreshToken != "" {
+		m.refreshTokens[refreshToken] = user
+	}
 	return nil
 }
 
 // ValidateRefreshToken checks if a refresh token is valid for a user
 func (m *InMemoryStorage) ValidateRefreshToken(refreshToken string) (*user.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
 	user, exists := m.refreshTokens[refreshToken]
 	if !exists {
 		return nil, errors.New("invalid refresh token")
 	}
 
-	return user, nil
+	return copyUser(user), nil
+}
+
+// FindUserByName retrieves a user by their unique name
+func (m *InMemoryStorage) FindUserByName(name string) (*user.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	user, exists := m.names[name]
+	if !exists {
+		return nil, storage.ErrUserNotFound
+	}
+	return copyUser(user), nil
+}
+
+// FindUserByMail retrieves a user by their mail address
+func (m *InMemoryStorage) FindUserByMail(mail string) (*user.User, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	// users is also keyed by account ID, so check the match is the mail
+	user, exists := m.users[mail]
+	if !exists || mail == "" || user.Mail != mail {
+		return nil, storage.ErrUserNotFound
+	}
+	return copyUser(user), nil
+}
+
+// CreateUser stores a new user
+func (m *InMemoryStorage) CreateUser(u *user.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.names[u.Name]; exists {
+		return storage.ErrUserExists
+	}
+	if _, exists := m.users[u.Mail]; exists && u.Mail != "" {
+		return storage.ErrUserExists
+	}
+
+	m.index(copyUser(u))
+	return nil
+}
+
+// UpdateUser replaces the stored user with the same name
+func (m *InMemoryStorage) UpdateUser(u *user.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current, exists := m.names[u.Name]
+	if !exists {
+		return storage.ErrUserNotFound
+	}
+	if other, exists := m.users[u.Mail]; exists && other != current && u.Mail != "" {
+		return storage.ErrUserExists
+	}
+
+	m.unindex(current)
+	m.index(copyUser(u))
+	return nil
+}
+
+// UpdateAccess records the user's last successful authentication
+func (m *InMemoryStorage) UpdateAccess(name string, access uint64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current, exists := m.names[name]
+	if !exists {
+		return storage.ErrUserNotFound
+	}
+
+	current.Access = access
+	return nil
+}
+
+// DeleteUser permanently removes the user with the given name
+func (m *InMemoryStorage) DeleteUser(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	current, exists := m.names[name]
+	if !exists {
+		return storage.ErrUserNotFound
+	}
+
+	m.unindex(current)
+	return nil
+}
+
+// copyUser returns a snapshot so callers cannot race with later updates
+func copyUser(u *user.User) *user.User {
+	c := *u
+	return &c
 }
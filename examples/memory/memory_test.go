@@ -3,7 +3,9 @@ package memory
 import (
 	"testing"
 
+	"github.com/responsible-api/responsible-auth/resource/user"
 	"github.com/responsible-api/responsible-auth/storage"
+	"github.com/responsible-api/responsible-auth/storage/storagetest"
 	"github.com/responsible-api/responsible-auth/testutils"
 )
 
@@ -162,7 +164,7 @@ func TestInMemoryStorage_UpdateRefreshToken(t *testing.T) {
 	}{
 		{
 			name:         "valid user",
-			userID:       "testuser",
+			userID:       "test@example.com",
 			refreshToken: "new_refresh_token",
 			expectError:  false,
 		},
@@ -207,7 +209,7 @@ func TestInMemoryStorage_ValidateRefreshToken(t *testing.T) {
 	memStorage := NewInMemoryStorage()
 
 	// First, add a refresh token
-	err := memStorage.UpdateRefreshToken("testuser", "valid_refresh_token")
+	err := memStorage.UpdateRefreshToken("test@example.com", "valid_refresh_token")
 	if err != nil {
 		t.Fatalf("Failed to set up test: %v", err)
 	}
@@ -279,7 +281,7 @@ func TestInMemoryStorage_Interface(t *testing.T) {
 	}
 
 	// Test UpdateRefreshToken
-	err = userStorage.UpdateRefreshToken(testUser.Name, "test_refresh_token")
+	err = userStorage.UpdateRefreshToken(testUser.Mail, "test_refresh_token")
 	if err != nil {
 		t.Errorf("Interface method UpdateRefreshToken failed: %v", err)
 	}
@@ -290,3 +292,63 @@ func TestInMemoryStorage_Interface(t *testing.T) {
 		t.Errorf("Interface method ValidateRefreshToken failed: %v", err)
 	}
 }
+
+func TestInMemoryStorage_Conformance(t *testing.T) {
+	storagetest.Run(t, func(t *testing.T, users ...*user.User) storage.UserStorage {
+		return NewInMemoryStorageWithUsers(users...)
+	})
+}
+
+func TestInMemoryAttemptStorage_Conformance(t *testing.T) {
+	storagetest.RunAttempts(t, func(t *testing.T) storage.AttemptStorage {
+		return NewInMemoryAttemptStorage()
+	})
+}
+
+func TestInMemoryBucketStorage_Conformance(t *testing.T) {
+	storagetest.RunBuckets(t, func(t *testing.T) storage.BucketStorage {
+		return NewInMemoryBucketStorage()
+	})
+}
+
+func TestInMemoryClientStorage_Conformance(t *testing.T) {
+	storagetest.RunClients(t, func(t *testing.T) storage.ClientStorage {
+		return NewInMemoryClientStorage()
+	})
+}
+
+func TestInMemoryAuthorizationCodeStorage_Conformance(t *testing.T) {
+	storagetest.RunAuthorizationCodes(t, func(t *testing.T) storage.AuthorizationCodeStorage {
+		return NewInMemoryAuthorizationCodeStorage()
+	})
+}
+
+func TestInMemoryDeviceCodeStorage_Conformance(t *testing.T) {
+	storagetest.RunDeviceCodes(t, func(t *testing.T) storage.DeviceCodeStorage {
+		return NewInMemoryDeviceCodeStorage()
+	})
+}
+
+func TestInMemoryMFAStorage_Conformance(t *testing.T) {
+	storagetest.RunMFA(t, func(t *testing.T) storage.MFAStorage {
+		return NewInMemoryMFAStorage()
+	})
+}
+
+func TestInMemoryCredentialStorage_Conformance(t *testing.T) {
+	storagetest.RunCredentials(t, func(t *testing.T) storage.CredentialStorage {
+		return NewInMemoryCredentialStorage()
+	})
+}
+
+func TestInMemorySessionStorage_Conformance(t *testing.T) {
+	storagetest.RunSessions(t, func(t *testing.T) storage.SessionStorage {
+		return NewInMemorySessionStorage()
+	})
+}
+
+func TestInMemoryRoleStorage_Conformance(t *testing.T) {
+	storagetest.RunRoles(t, func(t *testing.T) storage.RoleStorage {
+		return NewInMemoryRoleStorage()
+	})
+}
@@ -0,0 +1,81 @@
+package memory
+
+import (
+	"sync"
+
+	"github.com/responsible-api/responsible-auth/resource/totp"
+	"github.com/responsible-api/responsible-auth/storage"
+)
+
+// InMemoryMFAStorage is an in-memory implementation of MFAStorage
+type InMemoryMFAStorage struct {
+	mu         sync.Mutex
+	enrolments map[string]*totp.Enrolment // keyed by user name
+}
+
+// NewInMemoryMFAStorage creates an empty in-memory MFA storage
+func NewInMemoryMFAStorage() storage.MFAStorage {
+	return &InMemoryMFAStorage{
+		enrolments: make(map[string]*totp.Enrolment),
+	}
+}
+
+// FindEnrolment retrieves the enrolment of the named user
+func (m *InMemoryMFAStorage) FindEnrolment(userName string) (*totp.Enrolment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, exists := m.enrolments[userName]
+	if !exists {
+		return nil, storage.ErrEnrolmentNotFound
+	}
+	return copyEnrolment(e), nil
+}
+
+// CreateEnrolment stores a new enrolment
+func (m *InMemoryMFAStorage) CreateEnrolment(e *totp.Enrolment) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.enrolments[e.UserName]; exists {
+		return storage.ErrEnrolmentExists
+	}
+	m.enrolments[e.UserName] = copyEnrolment(e)
+	return nil
+}
+
+// UpdateEnrolment atomically applies update to the enrolment of the named user
+func (m *InMemoryMFAStorage) UpdateEnrolment(userName string, update func(e *totp.Enrolment)) (*totp.Enrolment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, exists := m.enrolments[userName]
+	if !exists {
+		return nil, storage.ErrEnrolmentNotFound
+	}
+
+	updated := copyEnrolment(e)
+	update(updated)
+	updated.UserName = userName
+	m.enrolments[userName] = updated
+	return copyEnrolment(updated), nil
+}
+
+// DeleteEnrolment removes the enrolment of the named user
+func (m *InMemoryMFAStorage) DeleteEnrolment(userName string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.enrolments[userName]; !exists {
+		return storage.ErrEnrolmentNotFound
+	}
+	delete(m.enrolments, userName)
+	return nil
+}
+
+// copyEnrolment returns a deep copy so callers cannot modify stored slices
+func copyEnrolment(e *totp.Enrolment) *totp.Enrolment {
+	c := *e
+	c.RecoveryCodes = append([]string(nil), e.RecoveryCodes...)
+	return &c
+}
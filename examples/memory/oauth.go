@@ -0,0 +1,197 @@
+package memory
+
+import (
+	"sync"
+
+	"github.com/responsible-api/responsible-auth/resource/authcode"
+	"github.com/responsible-api/responsible-auth/resource/client"
+	"github.com/responsible-api/responsible-auth/resource/devicecode"
+	"github.com/responsible-api/responsible-auth/storage"
+)
+
+// InMemoryClientStorage is an in-memory implementation of ClientStorage
+type InMemoryClientStorage struct {
+	mu      sync.RWMutex
+	clients map[string]*client.Client
+}
+
+// NewInMemoryClientStorage creates an in-memory client storage holding the given clients
+func NewInMemoryClientStorage(clients ...*client.Client) storage.ClientStorage {
+	m := &InMemoryClientStorage{
+		clients: make(map[string]*client.Client),
+	}
+	for _, c := range clients {
+		m.clients[c.ID] = copyClient(c)
+	}
+	return m
+}
+
+// FindClient retrieves a client by its client ID
+func (m *InMemoryClientStorage) FindClient(clientID string) (*client.Client, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	c, exists := m.clients[clientID]
+	if !exists {
+		return nil, storage.ErrClientNotFound
+	}
+	return copyClient(c), nil
+}
+
+// CreateClient stores a new client
+func (m *InMemoryClientStorage) CreateClient(c *client.Client) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.clients[c.ID]; exists {
+		return storage.ErrClientExists
+	}
+	m.clients[c.ID] = copyClient(c)
+	return nil
+}
+
+// UpdateClient replaces the stored client with the same ID
+func (m *InMemoryClientStorage) UpdateClient(c *client.Client) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.clients[c.ID]; !exists {
+		return storage.ErrClientNotFound
+	}
+	m.clients[c.ID] = copyClient(c)
+	return nil
+}
+
+// DeleteClient removes the client with the given ID
+func (m *InMemoryClientStorage) DeleteClient(clientID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.clients[clientID]; !exists {
+		return storage.ErrClientNotFound
+	}
+	delete(m.clients, clientID)
+	return nil
+}
+
+// copyClient returns a deep copy so callers cannot modify stored slices
+func copyClient(c *client.Client) *client.Client {
+	cp := *c
+	cp.RedirectURIs = append([]string(nil), c.RedirectURIs...)
+	cp.GrantTypes = append([]string(nil), c.GrantTypes...)
+	cp.Scopes = append([]string(nil), c.Scopes...)
+	return &cp
+}
+
+// InMemoryAuthorizationCodeStorage is an in-memory implementation of AuthorizationCodeStorage
+type InMemoryAuthorizationCodeStorage struct {
+	mu    sync.Mutex
+	codes map[string]*authcode.Code
+}
+
+// NewInMemoryAuthorizationCodeStorage creates an empty in-memory authorization code storage
+func NewInMemoryAuthorizationCodeStorage() storage.AuthorizationCodeStorage {
+	return &InMemoryAuthorizationCodeStorage{
+		codes: make(map[string]*authcode.Code),
+	}
+}
+
+// CreateAuthorizationCode stores a newly issued code
+func (m *InMemoryAuthorizationCodeStorage) CreateAuthorizationCode(code *authcode.Code) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c := *code
+	m.codes[code.Hash] = &c
+	return nil
+}
+
+// ConsumeAuthorizationCode removes and returns the code with the given hash
+func (m *InMemoryAuthorizationCodeStorage) ConsumeAuthorizationCode(hash string) (*authcode.Code, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	code, exists := m.codes[hash]
+	if !exists {
+		return nil, storage.ErrCodeNotFound
+	}
+	delete(m.codes, hash)
+	return code, nil
+}
+
+// InMemoryDeviceCodeStorage is an in-memory implementation of DeviceCodeStorage
+type InMemoryDeviceCodeStorage struct {
+	mu        sync.Mutex
+	codes     map[string]*devicecode.Code // keyed by device code hash
+	userCodes map[string]*devicecode.Code // keyed by user code
+}
+
+// NewInMemoryDeviceCodeStorage creates an empty in-memory device code storage
+func NewInMemoryDeviceCodeStorage() storage.DeviceCodeStorage {
+	return &InMemoryDeviceCodeStorage{
+		codes:     make(map[string]*devicecode.Code),
+		userCodes: make(map[string]*devicecode.Code),
+	}
+}
+
+// CreateDeviceCode stores a new device authorization
+func (m *InMemoryDeviceCodeStorage) CreateDeviceCode(code *devicecode.Code) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c := copyDeviceCode(code)
+	m.codes[c.Hash] = c
+	m.userCodes[c.UserCode] = c
+	return nil
+}
+
+// FindDeviceCodeByUserCode retrieves a device authorization by its user code
+func (m *InMemoryDeviceCodeStorage) FindDeviceCodeByUserCode(userCode string) (*devicecode.Code, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	code, exists := m.userCodes[userCode]
+	if !exists {
+		return nil, storage.ErrDeviceCodeNotFound
+	}
+	return copyDeviceCode(code), nil
+}
+
+// UpdateDeviceCode atomically applies update to the device authorization
+func (m *InMemoryDeviceCodeStorage) UpdateDeviceCode(hash string, update func(code *devicecode.Code)) (*devicecode.Code, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	code, exists := m.codes[hash]
+	if !exists {
+		return nil, storage.ErrDeviceCodeNotFound
+	}
+
+	updated := copyDeviceCode(code)
+	update(updated)
+	updated.Hash, updated.UserCode = code.Hash, code.UserCode
+	m.codes[hash] = updated
+	m.userCodes[updated.UserCode] = updated
+	return copyDeviceCode(updated), nil
+}
+
+// DeleteDeviceCode removes the device authorization
+func (m *InMemoryDeviceCodeStorage) DeleteDeviceCode(hash string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	code, exists := m.codes[hash]
+	if !exists {
+		return storage.ErrDeviceCodeNotFound
+	}
+	delete(m.codes, hash)
+	delete(m.userCodes, code.UserCode)
+	return nil
+}
+
+// copyDeviceCode returns a deep copy so callers cannot modify stored slices
+func copyDeviceCode(code *devicecode.Code) *devicecode.Code {
+	c := *code
+	c.Scopes = append([]string(nil), code.Scopes...)
+	return &c
+}
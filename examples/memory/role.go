@@ -0,0 +1,183 @@
+package memory
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/responsible-api/responsible-auth/resource/role"
+	"github.com/responsible-api/responsible-auth/storage"
+)
+
+// InMemoryRoleStorage is an in-memory implementation of RoleStorage
+type InMemoryRoleStorage struct {
+	mu          sync.Mutex
+	roles       map[string]*role.Role
+	permissions map[role.Permission]bool
+	assignments map[role.Assignment]bool
+}
+
+// NewInMemoryRoleStorage creates an empty in-memory role storage
+func NewInMemoryRoleStorage() storage.RoleStorage {
+	return &InMemoryRoleStorage{
+		roles:       make(map[string]*role.Role),
+		permissions: make(map[role.Permission]bool),
+		assignments: make(map[role.Assignment]bool),
+	}
+}
+
+// CreateRole stores a new role
+func (m *InMemoryRoleStorage) CreateRole(r *role.Role) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.roles[r.Name]; exists {
+		return storage.ErrRoleExists
+	}
+	if _, exists := m.roles[r.Inherits]; r.Inherits != "" && !exists {
+		return storage.ErrRoleNotFound
+	}
+	cp := *r
+	m.roles[r.Name] = &cp
+	return nil
+}
+
+// FindRole retrieves a role by name
+func (m *InMemoryRoleStorage) FindRole(name string) (*role.Role, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	r, exists := m.roles[name]
+	if !exists {
+		return nil, storage.ErrRoleNotFound
+	}
+	cp := *r
+	return &cp, nil
+}
+
+// FindRoles retrieves every role, ordered by name
+func (m *InMemoryRoleStorage) FindRoles() ([]*role.Role, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	found := make([]*role.Role, 0, len(m.roles))
+	for _, r := range m.roles {
+		cp := *r
+		found = append(found, &cp)
+	}
+	sort.Slice(found, func(i, j int) bool {
+		return found[i].Name < found[j].Name
+	})
+	return found, nil
+}
+
+// DeleteRole removes a role with its permissions and assignments
+func (m *InMemoryRoleStorage) DeleteRole(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.roles[name]; !exists {
+		return storage.ErrRoleNotFound
+	}
+	delete(m.roles, name)
+	for _, r := range m.roles {
+		if r.Inherits == name {
+			r.Inherits = ""
+		}
+	}
+	for p := range m.permissions {
+		if p.RoleName == name {
+			delete(m.permissions, p)
+		}
+	}
+	for a := range m.assignments {
+		if a.RoleName == name {
+			delete(m.assignments, a)
+		}
+	}
+	return nil
+}
+
+// GrantPermission adds a permission to its role
+func (m *InMemoryRoleStorage) GrantPermission(p *role.Permission) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.roles[p.RoleName]; !exists {
+		return storage.ErrRoleNotFound
+	}
+	m.permissions[*p] = true
+	return nil
+}
+
+// RevokePermission removes a permission from its role
+func (m *InMemoryRoleStorage) RevokePermission(p *role.Permission) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !m.permissions[*p] {
+		return storage.ErrPermissionNotFound
+	}
+	delete(m.permissions, *p)
+	return nil
+}
+
+// FindPermissions retrieves the permissions granted directly to the named role
+func (m *InMemoryRoleStorage) FindPermissions(roleName string) ([]*role.Permission, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	found := []*role.Permission{}
+	for p := range m.permissions {
+		if p.RoleName == roleName {
+			cp := p
+			found = append(found, &cp)
+		}
+	}
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].Permission != found[j].Permission {
+			return found[i].Permission < found[j].Permission
+		}
+		return found[i].Resource < found[j].Resource
+	})
+	return found, nil
+}
+
+// AssignRole gives the named user a role
+func (m *InMemoryRoleStorage) AssignRole(userName string, roleName string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.roles[roleName]; !exists {
+		return storage.ErrRoleNotFound
+	}
+	m.assignments[role.Assignment{UserName: userName, RoleName: roleName}] = true
+	return nil
+}
+
+// UnassignRole takes a role from the named user
+func (m *InMemoryRoleStorage) UnassignRole(userName string, roleName string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	a := role.Assignment{UserName: userName, RoleName: roleName}
+	if !m.assignments[a] {
+		return storage.ErrAssignmentNotFound
+	}
+	delete(m.assignments, a)
+	return nil
+}
+
+// FindUserRoles retrieves the names of the roles assigned to the named user
+func (m *InMemoryRoleStorage) FindUserRoles(userName string) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	found := []string{}
+	for a := range m.assignments {
+		if a.UserName == userName {
+			found = append(found, a.RoleName)
+		}
+	}
+	sort.Strings(found)
+	return found, nil
+}
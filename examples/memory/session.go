@@ -0,0 +1,110 @@
+package memory
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/responsible-api/responsible-auth/resource/session"
+	"github.com/responsible-api/responsible-auth/storage"
+)
+
+// InMemorySessionStorage is an in-memory implementation of SessionStorage
+type InMemorySessionStorage struct {
+	mu       sync.Mutex
+	sessions map[string]*session.Session // keyed by session ID
+}
+
+// NewInMemorySessionStorage creates an empty in-memory session storage
+func NewInMemorySessionStorage() storage.SessionStorage {
+	return &InMemorySessionStorage{
+		sessions: make(map[string]*session.Session),
+	}
+}
+
+// CreateSession stores a new session
+func (m *InMemorySessionStorage) CreateSession(s *session.Session) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.sessions[s.ID]; exists {
+		return storage.ErrSessionExists
+	}
+	cp := *s
+	m.sessions[s.ID] = &cp
+	return nil
+}
+
+// FindSession retrieves a session by its ID
+func (m *InMemorySessionStorage) FindSession(id string) (*session.Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, exists := m.sessions[id]
+	if !exists {
+		return nil, storage.ErrSessionNotFound
+	}
+	cp := *s
+	return &cp, nil
+}
+
+// FindSessionsByUser retrieves every session of the named user, oldest first
+func (m *InMemorySessionStorage) FindSessionsByUser(userName string) ([]*session.Session, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	found := []*session.Session{}
+	for _, s := range m.sessions {
+		if s.UserName == userName {
+			cp := *s
+			found = append(found, &cp)
+		}
+	}
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].Created != found[j].Created {
+			return found[i].Created < found[j].Created
+		}
+		return found[i].ID < found[j].ID
+	})
+	return found, nil
+}
+
+// TouchSession records when and from where the session was last used
+func (m *InMemorySessionStorage) TouchSession(id string, lastUsed int64, ip string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	s, exists := m.sessions[id]
+	if !exists {
+		return storage.ErrSessionNotFound
+	}
+	s.LastUsed = lastUsed
+	s.IP = ip
+	return nil
+}
+
+// DeleteSession removes the session with the given ID
+func (m *InMemorySessionStorage) DeleteSession(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.sessions[id]; !exists {
+		return storage.ErrSessionNotFound
+	}
+	delete(m.sessions, id)
+	return nil
+}
+
+// DeleteSessionsByUser removes the user's sessions other than except
+func (m *InMemorySessionStorage) DeleteSessionsByUser(userName string, except string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	deleted := 0
+	for id, s := range m.sessions {
+		if s.UserName == userName && id != except {
+			delete(m.sessions, id)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
@@ -0,0 +1,128 @@
+package memory
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/responsible-api/responsible-auth/resource/credential"
+	"github.com/responsible-api/responsible-auth/storage"
+)
+
+// InMemoryCredentialStorage is an in-memory implementation of CredentialStorage
+type InMemoryCredentialStorage struct {
+	mu          sync.Mutex
+	credentials map[string]*credential.Credential // keyed by credential ID
+	challenges  map[string]*credential.Challenge
+}
+
+// NewInMemoryCredentialStorage creates an empty in-memory credential storage
+func NewInMemoryCredentialStorage() storage.CredentialStorage {
+	return &InMemoryCredentialStorage{
+		credentials: make(map[string]*credential.Credential),
+		challenges:  make(map[string]*credential.Challenge),
+	}
+}
+
+// CreateCredential stores a new credential
+func (m *InMemoryCredentialStorage) CreateCredential(c *credential.Credential) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.credentials[c.ID]; exists {
+		return storage.ErrCredentialExists
+	}
+	m.credentials[c.ID] = copyCredential(c)
+	return nil
+}
+
+// FindCredential retrieves a credential by its ID
+func (m *InMemoryCredentialStorage) FindCredential(id string) (*credential.Credential, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c, exists := m.credentials[id]
+	if !exists {
+		return nil, storage.ErrCredentialNotFound
+	}
+	return copyCredential(c), nil
+}
+
+// FindCredentialsByUser retrieves every credential of the named user, oldest first
+func (m *InMemoryCredentialStorage) FindCredentialsByUser(userName string) ([]*credential.Credential, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	found := []*credential.Credential{}
+	for _, c := range m.credentials {
+		if c.UserName == userName {
+			found = append(found, copyCredential(c))
+		}
+	}
+	sort.Slice(found, func(i, j int) bool {
+		if found[i].Created != found[j].Created {
+			return found[i].Created < found[j].Created
+		}
+		return found[i].ID < found[j].ID
+	})
+	return found, nil
+}
+
+// UpdateCredential atomically applies update to the credential with the given ID
+func (m *InMemoryCredentialStorage) UpdateCredential(id string, update func(c *credential.Credential)) (*credential.Credential, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c, exists := m.credentials[id]
+	if !exists {
+		return nil, storage.ErrCredentialNotFound
+	}
+
+	updated := copyCredential(c)
+	update(updated)
+	updated.ID = id
+	m.credentials[id] = updated
+	return copyCredential(updated), nil
+}
+
+// DeleteCredential removes the credential with the given ID
+func (m *InMemoryCredentialStorage) DeleteCredential(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, exists := m.credentials[id]; !exists {
+		return storage.ErrCredentialNotFound
+	}
+	delete(m.credentials, id)
+	return nil
+}
+
+// CreateChallenge stores the challenge of a new ceremony
+func (m *InMemoryCredentialStorage) CreateChallenge(c *credential.Challenge) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	cp := *c
+	m.challenges[c.Challenge] = &cp
+	return nil
+}
+
+// ConsumeChallenge removes and returns the challenge
+func (m *InMemoryCredentialStorage) ConsumeChallenge(challenge string) (*credential.Challenge, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c, exists := m.challenges[challenge]
+	if !exists {
+		return nil, storage.ErrChallengeNotFound
+	}
+	delete(m.challenges, challenge)
+	return c, nil
+}
+
+// copyCredential returns a deep copy so callers cannot modify stored slices
+func copyCredential(c *credential.Credential) *credential.Credential {
+	cp := *c
+	cp.PublicKey = append([]byte(nil), c.PublicKey...)
+	cp.Transports = append([]string(nil), c.Transports...)
+	return &cp
+}
@@ -0,0 +1,316 @@
+// Package federation signs users in with an upstream OpenID Connect provider
+// such as Google, Azure AD or Keycloak.
+//
+// A Provider runs the authorization code flow with PKCE against the upstream
+// issuer: AuthCodeURL starts a sign-in and Exchange redeems the code returned
+// to the callback. The upstream ID token is verified with the keys published
+// at the issuer's jwks_uri, and its claims are returned as an Identity for the
+// application to link to a local user.
+package federation
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// DefaultTimeout bounds each request to the upstream provider when Config.HTTPClient is nil.
+const DefaultTimeout = 10 * time.Second
+
+// DefaultScopes are requested when Config.Scopes is empty.
+var DefaultScopes = []string{"openid", "email", "profile"}
+
+// clockSkew is the leeway allowed when checking the upstream token's times
+const clockSkew = time.Minute
+
+// maxResponseSize caps the upstream discovery, JWKS and token responses
+const maxResponseSize = 1 << 20
+
+var (
+	// ErrInvalidState is returned when the callback's state does not match the session.
+	ErrInvalidState = errors.New("invalid federation state")
+
+	// ErrInvalidIDToken is returned when the upstream ID token fails verification.
+	ErrInvalidIDToken = errors.New("invalid upstream id token")
+
+	// ErrUpstream is returned when the upstream provider reports an error or
+	// answers with something other than OpenID Connect.
+	ErrUpstream = errors.New("upstream provider error")
+)
+
+// Config describes the upstream provider and this application's client registration with it.
+type Config struct {
+	// Issuer is the upstream issuer URL; its metadata is discovered at
+	// Issuer/.well-known/openid-configuration and must name the same issuer
+	Issuer string
+
+	// ClientID and ClientSecret are the client registered with the upstream
+	// provider; an empty ClientSecret makes a public client
+	ClientID     string
+	ClientSecret string
+
+	// RedirectURL is the callback registered with the upstream provider
+	RedirectURL string
+
+	// Scopes are requested from the upstream provider; DefaultScopes when empty
+	Scopes []string
+
+	// ClaimMapping copies upstream ID token claims into Identity.CustomClaims,
+	// keyed by upstream claim name, e.g. {"groups": "groups", "hd": "domain"}
+	ClaimMapping map[string]string
+
+	// HTTPClient sends the requests to the upstream provider; a client with
+	// DefaultTimeout when nil
+	HTTPClient *http.Client
+}
+
+// Session is the state of one sign-in, kept by the application between
+// AuthCodeURL and Exchange.
+type Session struct {
+	State    string
+	Nonce    string
+	Verifier string
+}
+
+// Identity is a user authenticated by the upstream provider.
+type Identity struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+
+	// AMR lists the upstream authentication methods, when the provider reports them
+	AMR []string
+
+	// CustomClaims holds the upstream claims selected by Config.ClaimMapping
+	CustomClaims map[string]interface{}
+
+	// Claims holds every claim of the upstream ID token
+	Claims jwt.MapClaims
+}
+
+// Provider signs users in with one upstream issuer. It caches the issuer's
+// metadata and keys, and is safe for concurrent use.
+type Provider struct {
+	config Config
+	client *http.Client
+
+	mu       sync.Mutex
+	metadata *metadata
+	keys     map[string]interface{}
+	missed   map[string]time.Time
+}
+
+// NewProvider creates a provider for the given configuration. The upstream
+// metadata is fetched on first use.
+func NewProvider(config Config) *Provider {
+	if len(config.Scopes) == 0 {
+		config.Scopes = DefaultScopes
+	}
+	client := config.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: DefaultTimeout}
+	}
+	return &Provider{config: config, client: client}
+}
+
+// AuthCodeURL starts a sign-in. The user is redirected to the returned URL,
+// and the session must be kept until their callback arrives.
+func (p *Provider) AuthCodeURL() (string, *Session, error) {
+	m, err := p.discover()
+	if err != nil {
+		return "", nil, err
+	}
+
+	session := &Session{}
+	for _, value := range []*string{&session.State, &session.Nonce, &session.Verifier} {
+		if *value, err = randomToken(); err != nil {
+			return "", nil, err
+		}
+	}
+
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.config.ClientID},
+		"redirect_uri":          {p.config.RedirectURL},
+		"scope":                 {strings.Join(p.config.Scopes, " ")},
+		"state":                 {session.State},
+		"nonce":                 {session.Nonce},
+		"code_challenge":        {s256Challenge(session.Verifier)},
+		"code_challenge_method": {"S256"},
+	}
+
+	separator := "?"
+	if strings.Contains(m.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return m.AuthorizationEndpoint + separator + query.Encode(), session, nil
+}
+
+// Exchange completes a sign-in with the state and code from the callback. It
+// redeems the code at the upstream token endpoint and returns the identity
+// in the verified ID token.
+func (p *Provider) Exchange(session *Session, state, code string) (*Identity, error) {
+	if session == nil || session.State == "" || subtle.ConstantTimeCompare([]byte(state), []byte(session.State)) != 1 {
+		return nil, ErrInvalidState
+	}
+	if code == "" {
+		return nil, fmt.Errorf("%w: missing authorization code", ErrUpstream)
+	}
+
+	m, err := p.discover()
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.config.RedirectURL},
+		"code_verifier": {session.Verifier},
+	}
+	if p.config.ClientSecret == "" {
+		form.Set("client_id", p.config.ClientID)
+	}
+
+	request, err := http.NewRequest(http.MethodPost, m.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Accept", "application/json")
+	if p.config.ClientSecret != "" {
+		// RFC 6749 section 2.3.1 form-encodes the credentials before Basic encoding
+		request.SetBasicAuth(url.QueryEscape(p.config.ClientID), url.QueryEscape(p.config.ClientSecret))
+	}
+
+	var response struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	status, err := p.do(request, &response)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK || response.Error != "" {
+		if response.Error == "" {
+			return nil, fmt.Errorf("%w: token endpoint returned status %d", ErrUpstream, status)
+		}
+		return nil, fmt.Errorf("%w: %s: %s", ErrUpstream, response.Error, response.ErrorDescription)
+	}
+	if response.IDToken == "" {
+		return nil, fmt.Errorf("%w: token response has no id_token", ErrInvalidIDToken)
+	}
+
+	return p.verify(m, response.IDToken, session.Nonce)
+}
+
+// verify checks the ID token's signature, issuer, audience, lifetime and
+// nonce (OpenID Connect Core section 3.1.3.7) and returns its identity.
+func (p *Provider) verify(m *metadata, rawIDToken, nonce string) (*Identity, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(rawIDToken, claims, p.keyFunc,
+		jwt.WithValidMethods(signingMethods),
+		jwt.WithIssuer(m.Issuer),
+		jwt.WithAudience(p.config.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(clockSkew),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+
+	tokenNonce, _ := claims["nonce"].(string)
+	if subtle.ConstantTimeCompare([]byte(tokenNonce), []byte(nonce)) != 1 {
+		return nil, fmt.Errorf("%w: nonce mismatch", ErrInvalidIDToken)
+	}
+
+	// A token issued to several audiences must name this client as the authorized party
+	audience, _ := claims.GetAudience()
+	azp, hasAZP := claims["azp"].(string)
+	if (len(audience) > 1 || hasAZP) && azp != p.config.ClientID {
+		return nil, fmt.Errorf("%w: authorized party mismatch", ErrInvalidIDToken)
+	}
+
+	identity := &Identity{Issuer: m.Issuer, Claims: claims}
+	identity.Subject, _ = claims["sub"].(string)
+	if identity.Subject == "" {
+		return nil, fmt.Errorf("%w: missing subject", ErrInvalidIDToken)
+	}
+	identity.Email, _ = claims["email"].(string)
+	identity.Name, _ = claims["name"].(string)
+
+	// Some providers send email_verified as a string
+	switch verified := claims["email_verified"].(type) {
+	case bool:
+		identity.EmailVerified = verified
+	case string:
+		identity.EmailVerified = verified == "true"
+	}
+
+	if amr, ok := claims["amr"].([]interface{}); ok {
+		for _, method := range amr {
+			if m, ok := method.(string); ok {
+				identity.AMR = append(identity.AMR, m)
+			}
+		}
+	}
+
+	for upstream, custom := range p.config.ClaimMapping {
+		if value, ok := claims[upstream]; ok {
+			if identity.CustomClaims == nil {
+				identity.CustomClaims = make(map[string]interface{})
+			}
+			identity.CustomClaims[custom] = value
+		}
+	}
+	return identity, nil
+}
+
+// do sends a request and decodes its JSON response into v, returning the status code.
+func (p *Provider) do(request *http.Request, v interface{}) (int, error) {
+	response, err := p.client.Do(request)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrUpstream, err)
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(response.Body, maxResponseSize))
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrUpstream, err)
+	}
+	if err := json.Unmarshal(body, v); err != nil {
+		return response.StatusCode, fmt.Errorf("%w: %s returned status %d", ErrUpstream, request.URL.Path, response.StatusCode)
+	}
+	return response.StatusCode, nil
+}
+
+// s256Challenge derives the PKCE code_challenge for a code_verifier (RFC 7636 section 4.2).
+func s256Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// randomToken returns a URL-safe random string carrying 256 bits of entropy.
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,369 @@
+package federation_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/responsible-api/responsible-auth/federation"
+	"github.com/responsible-api/responsible-auth/federation/federationtest"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const redirectURL = "https://app.example.com/callback"
+
+func newProvider(t *testing.T) (*federation.Provider, *federationtest.IdP) {
+	t.Helper()
+	idp := federationtest.NewIdP(t, "app-client", "app-secret")
+	idp.SetUser(federationtest.User{
+		Subject:       "upstream-123",
+		Email:         "jane@example.com",
+		EmailVerified: true,
+		Name:          "Jane Doe",
+		Claims:        map[string]interface{}{"groups": []string{"staff"}, "hd": "example.com"},
+	})
+
+	provider := federation.NewProvider(federation.Config{
+		Issuer:       idp.URL,
+		ClientID:     "app-client",
+		ClientSecret: "app-secret",
+		RedirectURL:  redirectURL,
+		ClaimMapping: map[string]string{"groups": "groups", "hd": "domain"},
+	})
+	return provider, idp
+}
+
+// signIn runs the flow up to the callback and returns the session and callback query.
+func signIn(t *testing.T, provider *federation.Provider, idp *federationtest.IdP) (*federation.Session, url.Values) {
+	t.Helper()
+	authURL, session, err := provider.AuthCodeURL()
+	if err != nil {
+		t.Fatalf("AuthCodeURL() unexpected error = %v", err)
+	}
+	callback, err := idp.Authorize(authURL)
+	if err != nil {
+		t.Fatalf("Authorize() unexpected error = %v", err)
+	}
+	return session, callback
+}
+
+func TestProvider_AuthCodeURL(t *testing.T) {
+	provider, idp := newProvider(t)
+
+	authURL, session, err := provider.AuthCodeURL()
+	if err != nil {
+		t.Fatalf("AuthCodeURL() unexpected error = %v", err)
+	}
+	if !strings.HasPrefix(authURL, idp.URL+"/authorize?") {
+		t.Errorf("AuthCodeURL() = %s, want the upstream authorization endpoint", authURL)
+	}
+
+	u, _ := url.Parse(authURL)
+	query := u.Query()
+	want := map[string]string{
+		"response_type":         "code",
+		"client_id":             "app-client",
+		"redirect_uri":          redirectURL,
+		"scope":                 "openid email profile",
+		"state":                 session.State,
+		"nonce":                 session.Nonce,
+		"code_challenge_method": "S256",
+	}
+	for name, value := range want {
+		if got := query.Get(name); got != value {
+			t.Errorf("AuthCodeURL() %s = %q, want %q", name, got, value)
+		}
+	}
+	if query.Get("code_challenge") == "" || query.Get("code_challenge") == session.Verifier {
+		t.Errorf("AuthCodeURL() code_challenge = %q, want the S256 challenge of the verifier", query.Get("code_challenge"))
+	}
+
+	_, other, err := provider.AuthCodeURL()
+	if err != nil {
+		t.Fatalf("AuthCodeURL() unexpected error = %v", err)
+	}
+	if other.State == session.State || other.Nonce == session.Nonce || other.Verifier == session.Verifier {
+		t.Errorf("AuthCodeURL() reused session values across sign-ins")
+	}
+}
+
+func TestProvider_Exchange(t *testing.T) {
+	provider, idp := newProvider(t)
+	session, callback := signIn(t, provider, idp)
+
+	identity, err := provider.Exchange(session, callback.Get("state"), callback.Get("code"))
+	if err != nil {
+		t.Fatalf("Exchange() unexpected error = %v", err)
+	}
+
+	if identity.Issuer != idp.URL || identity.Subject != "upstream-123" {
+		t.Errorf("Exchange() identity = %s %s, want %s upstream-123", identity.Issuer, identity.Subject, idp.URL)
+	}
+	if identity.Email != "jane@example.com" || !identity.EmailVerified || identity.Name != "Jane Doe" {
+		t.Errorf("Exchange() identity = %+v, want Jane's verified mail and name", identity)
+	}
+	if identity.CustomClaims["domain"] != "example.com" {
+		t.Errorf("Exchange() CustomClaims[domain] = %v, want example.com", identity.CustomClaims["domain"])
+	}
+	if groups, _ := identity.CustomClaims["groups"].([]interface{}); len(groups) != 1 || groups[0] != "staff" {
+		t.Errorf("Exchange() CustomClaims[groups] = %v, want [staff]", identity.CustomClaims["groups"])
+	}
+	if _, mapped := identity.CustomClaims["email"]; mapped {
+		t.Errorf("Exchange() mapped a claim missing from ClaimMapping")
+	}
+
+	// Codes are single use
+	if _, err := provider.Exchange(session, callback.Get("state"), callback.Get("code")); !errors.Is(err, federation.ErrUpstream) {
+		t.Errorf("Exchange() replayed code error = %v, want %v", err, federation.ErrUpstream)
+	}
+}
+
+func TestProvider_ExchangeRejected(t *testing.T) {
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		setup   func(idp *federationtest.IdP)
+		session func(s *federation.Session)
+		state   func(state string) string
+		want    error
+	}{
+		{
+			name:  "state mismatch",
+			state: func(string) string { return "forged-state" },
+			want:  federation.ErrInvalidState,
+		},
+		{
+			name:  "missing state",
+			state: func(string) string { return "" },
+			want:  federation.ErrInvalidState,
+		},
+		{
+			name:    "wrong verifier",
+			session: func(s *federation.Session) { s.Verifier = strings.Repeat("v", 43) },
+			want:    federation.ErrUpstream,
+		},
+		{
+			name:    "nonce mismatch",
+			session: func(s *federation.Session) { s.Nonce = "other-nonce" },
+			want:    federation.ErrInvalidIDToken,
+		},
+		{
+			name:  "missing nonce",
+			setup: func(idp *federationtest.IdP) { idp.IDToken = func(c jwt.MapClaims) { delete(c, "nonce") } },
+			want:  federation.ErrInvalidIDToken,
+		},
+		{
+			name: "wrong issuer",
+			setup: func(idp *federationtest.IdP) {
+				idp.IDToken = func(c jwt.MapClaims) { c["iss"] = "https://evil.example.com" }
+			},
+			want: federation.ErrInvalidIDToken,
+		},
+		{
+			name:  "wrong audience",
+			setup: func(idp *federationtest.IdP) { idp.IDToken = func(c jwt.MapClaims) { c["aud"] = "other-client" } },
+			want:  federation.ErrInvalidIDToken,
+		},
+		{
+			name: "other authorized party",
+			setup: func(idp *federationtest.IdP) {
+				idp.IDToken = func(c jwt.MapClaims) {
+					c["aud"] = []string{"app-client", "other-client"}
+					c["azp"] = "other-client"
+				}
+			},
+			want: federation.ErrInvalidIDToken,
+		},
+		{
+			name: "expired",
+			setup: func(idp *federationtest.IdP) {
+				idp.IDToken = func(c jwt.MapClaims) { c["exp"] = time.Now().Add(-time.Hour).Unix() }
+			},
+			want: federation.ErrInvalidIDToken,
+		},
+		{
+			name:  "missing subject",
+			setup: func(idp *federationtest.IdP) { idp.IDToken = func(c jwt.MapClaims) { delete(c, "sub") } },
+			want:  federation.ErrInvalidIDToken,
+		},
+		{
+			name: "signed with an unpublished key",
+			setup: func(idp *federationtest.IdP) {
+				idp.Sign = func(c jwt.MapClaims) (string, error) {
+					token := jwt.NewWithClaims(jwt.SigningMethodRS256, c)
+					token.Header["kid"] = "key-1"
+					return token.SignedString(otherKey)
+				}
+			},
+			want: federation.ErrInvalidIDToken,
+		},
+		{
+			name: "hmac signed",
+			setup: func(idp *federationtest.IdP) {
+				idp.Sign = func(c jwt.MapClaims) (string, error) {
+					return jwt.NewWithClaims(jwt.SigningMethodHS256, c).SignedString([]byte("app-secret"))
+				}
+			},
+			want: federation.ErrInvalidIDToken,
+		},
+		{
+			name: "unsigned",
+			setup: func(idp *federationtest.IdP) {
+				idp.Sign = func(c jwt.MapClaims) (string, error) {
+					return jwt.NewWithClaims(jwt.SigningMethodNone, c).SignedString(jwt.UnsafeAllowNoneSignatureType)
+				}
+			},
+			want: federation.ErrInvalidIDToken,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider, idp := newProvider(t)
+			if tt.setup != nil {
+				tt.setup(idp)
+			}
+			session, callback := signIn(t, provider, idp)
+			if tt.session != nil {
+				tt.session(session)
+			}
+			state := callback.Get("state")
+			if tt.state != nil {
+				state = tt.state(state)
+			}
+
+			identity, err := provider.Exchange(session, state, callback.Get("code"))
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Exchange() error = %v, want %v", err, tt.want)
+			}
+			if identity != nil {
+				t.Errorf("Exchange() returned identity %+v for a rejected sign-in", identity)
+			}
+		})
+	}
+}
+
+func TestProvider_ExchangeWithoutRedeemingOnBadState(t *testing.T) {
+	provider, idp := newProvider(t)
+	session, callback := signIn(t, provider, idp)
+
+	if _, err := provider.Exchange(session, "forged-state", callback.Get("code")); !errors.Is(err, federation.ErrInvalidState) {
+		t.Fatalf("Exchange() error = %v, want %v", err, federation.ErrInvalidState)
+	}
+	if got := idp.TokenRequests(); got != 0 {
+		t.Errorf("Exchange() sent %d token requests for a forged state, want 0", got)
+	}
+}
+
+func TestProvider_KeyRotation(t *testing.T) {
+	provider, idp := newProvider(t)
+
+	session, callback := signIn(t, provider, idp)
+	if _, err := provider.Exchange(session, callback.Get("state"), callback.Get("code")); err != nil {
+		t.Fatalf("Exchange() unexpected error = %v", err)
+	}
+
+	if err := idp.RotateKey(); err != nil {
+		t.Fatal(err)
+	}
+	session, callback = signIn(t, provider, idp)
+	if _, err := provider.Exchange(session, callback.Get("state"), callback.Get("code")); err != nil {
+		t.Errorf("Exchange() after key rotation error = %v", err)
+	}
+}
+
+func TestProvider_Discovery(t *testing.T) {
+	t.Run("issuer mismatch", func(t *testing.T) {
+		idp := federationtest.NewIdP(t, "app-client", "app-secret")
+		provider := federation.NewProvider(federation.Config{
+			Issuer:      idp.URL + "/tenant",
+			ClientID:    "app-client",
+			RedirectURL: redirectURL,
+		})
+
+		if _, _, err := provider.AuthCodeURL(); !errors.Is(err, federation.ErrUpstream) {
+			t.Errorf("AuthCodeURL() error = %v, want %v", err, federation.ErrUpstream)
+		}
+	})
+
+	t.Run("unreachable", func(t *testing.T) {
+		server := httptest.NewServer(http.NotFoundHandler())
+		server.Close()
+		provider := federation.NewProvider(federation.Config{Issuer: server.URL, ClientID: "app-client"})
+
+		if _, _, err := provider.AuthCodeURL(); !errors.Is(err, federation.ErrUpstream) {
+			t.Errorf("AuthCodeURL() error = %v, want %v", err, federation.ErrUpstream)
+		}
+	})
+}
+
+func TestProvider_ECKeys(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var issuer string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"issuer":"` + issuer + `","authorization_endpoint":"` + issuer + `/authorize",` +
+			`"token_endpoint":"` + issuer + `/token","jwks_uri":"` + issuer + `/jwks"}`))
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		x := make([]byte, 32)
+		y := make([]byte, 32)
+		key.X.FillBytes(x)
+		key.Y.FillBytes(y)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"keys":[{"kty":"EC","crv":"P-256","kid":"ec-1",` +
+			`"x":"` + base64.RawURLEncoding.EncodeToString(x) + `","y":"` + base64.RawURLEncoding.EncodeToString(y) + `"}]}`))
+	})
+	var nonce string
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		token := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+			"iss":   issuer,
+			"sub":   "ec-user",
+			"aud":   "app-client",
+			"iat":   time.Now().Unix(),
+			"exp":   time.Now().Add(time.Hour).Unix(),
+			"nonce": nonce,
+		})
+		token.Header["kid"] = "ec-1"
+		signed, _ := token.SignedString(key)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id_token":"` + signed + `"}`))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+	issuer = server.URL
+
+	provider := federation.NewProvider(federation.Config{Issuer: issuer, ClientID: "app-client", RedirectURL: redirectURL})
+	_, session, err := provider.AuthCodeURL()
+	if err != nil {
+		t.Fatalf("AuthCodeURL() unexpected error = %v", err)
+	}
+	nonce = session.Nonce
+
+	identity, err := provider.Exchange(session, session.State, "code")
+	if err != nil {
+		t.Fatalf("Exchange() unexpected error = %v", err)
+	}
+	if identity.Subject != "ec-user" || identity.EmailVerified {
+		t.Errorf("Exchange() identity = %+v, want unverified ec-user", identity)
+	}
+}
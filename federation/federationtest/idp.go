@@ -0,0 +1,315 @@
+// Package federationtest runs an in-process OpenID Connect provider for
+// testing federated sign-in. It serves discovery, JWKS, authorization and
+// token endpoints, signs ID tokens with a generated RSA key, and signs in
+// whichever user the test has chosen.
+package federationtest
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// User is the upstream account that signs in at the authorization endpoint.
+type User struct {
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+
+	// Claims are added to the ID token, overriding the standard ones
+	Claims map[string]interface{}
+}
+
+// IdP is a running test provider. Its URL is the issuer.
+type IdP struct {
+	URL          string
+	ClientID     string
+	ClientSecret string
+
+	// IDToken, when set, can change the claims of each ID token before it is signed
+	IDToken func(claims jwt.MapClaims)
+
+	// Sign, when set, replaces the provider's own signing, so tests can forge tokens
+	Sign func(claims jwt.MapClaims) (string, error)
+
+	server *httptest.Server
+
+	mu     sync.Mutex
+	user   *User
+	key    *rsa.PrivateKey
+	kid    string
+	keys   int
+	codes  map[string]*grant
+	tokens int
+}
+
+// grant is an issued authorization code.
+type grant struct {
+	user        User
+	redirectURI string
+	nonce       string
+	challenge   string
+}
+
+// NewIdP starts a provider with one registered client. It is closed when the test ends.
+func NewIdP(t testing.TB, clientID, clientSecret string) *IdP {
+	t.Helper()
+	p := &IdP{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		codes:        make(map[string]*grant),
+	}
+	if err := p.RotateKey(); err != nil {
+		t.Fatalf("federationtest: key: %v", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", p.discovery)
+	mux.HandleFunc("/jwks", p.jwks)
+	mux.HandleFunc("/authorize", p.authorize)
+	mux.HandleFunc("/token", p.token)
+
+	p.server = httptest.NewServer(mux)
+	p.URL = p.server.URL
+	t.Cleanup(p.server.Close)
+	return p
+}
+
+// SetUser chooses the account signed in by the next authorization request.
+func (p *IdP) SetUser(u User) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.user = &u
+}
+
+// RotateKey replaces the signing key with a new one under a new key ID.
+func (p *IdP) RotateKey() error {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.keys++
+	p.key = key
+	p.kid = fmt.Sprintf("key-%d", p.keys)
+	return nil
+}
+
+// TokenRequests counts the requests the token endpoint has answered.
+func (p *IdP) TokenRequests() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.tokens
+}
+
+// Authorize plays the browser: it follows an authorization URL, signs in the
+// chosen user and returns the query the provider sent to the redirect URI.
+func (p *IdP) Authorize(authURL string) (url.Values, error) {
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	response, err := client.Get(authURL)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusFound {
+		return nil, fmt.Errorf("federationtest: authorize returned status %d", response.StatusCode)
+	}
+	location, err := url.Parse(response.Header.Get("Location"))
+	if err != nil {
+		return nil, err
+	}
+	return location.Query(), nil
+}
+
+func (p *IdP) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                                p.URL,
+		"authorization_endpoint":                p.URL + "/authorize",
+		"token_endpoint":                        p.URL + "/token",
+		"jwks_uri":                              p.URL + "/jwks",
+		"response_types_supported":              []string{"code"},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+		"code_challenge_methods_supported":      []string{"S256"},
+	})
+}
+
+func (p *IdP) jwks(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	public := p.key.PublicKey
+	kid := p.kid
+	p.mu.Unlock()
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"use": "sig",
+			"alg": "RS256",
+			"kid": kid,
+			"n":   base64.RawURLEncoding.EncodeToString(public.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes()),
+		}},
+	})
+}
+
+// authorize signs in the chosen user at once and redirects back with a code.
+func (p *IdP) authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	redirectURI := query.Get("redirect_uri")
+	if query.Get("client_id") != p.ClientID || redirectURI == "" {
+		http.Error(w, "unknown client", http.StatusBadRequest)
+		return
+	}
+	if query.Get("response_type") != "code" || query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+		http.Error(w, "invalid request", http.StatusBadRequest)
+		return
+	}
+
+	back, err := url.Parse(redirectURI)
+	if err != nil {
+		http.Error(w, "invalid redirect_uri", http.StatusBadRequest)
+		return
+	}
+	values := back.Query()
+	values.Set("state", query.Get("state"))
+
+	p.mu.Lock()
+	if p.user == nil {
+		p.mu.Unlock()
+		values.Set("error", "access_denied")
+		values.Set("error_description", "the user cancelled the sign-in")
+		back.RawQuery = values.Encode()
+		http.Redirect(w, r, back.String(), http.StatusFound)
+		return
+	}
+	code := randomString()
+	p.codes[code] = &grant{
+		user:        *p.user,
+		redirectURI: redirectURI,
+		nonce:       query.Get("nonce"),
+		challenge:   query.Get("code_challenge"),
+	}
+	p.mu.Unlock()
+
+	values.Set("code", code)
+	back.RawQuery = values.Encode()
+	http.Redirect(w, r, back.String(), http.StatusFound)
+}
+
+// token redeems a code once, checking the client, redirect URI and PKCE verifier.
+func (p *IdP) token(w http.ResponseWriter, r *http.Request) {
+	p.mu.Lock()
+	p.tokens++
+	p.mu.Unlock()
+
+	if r.Method != http.MethodPost || r.ParseForm() != nil || r.PostForm.Get("grant_type") != "authorization_code" {
+		writeJSON(w, http.StatusBadRequest, tokenError("invalid_request", "expected an authorization_code grant"))
+		return
+	}
+
+	id, secret, ok := r.BasicAuth()
+	if ok {
+		id, _ = url.QueryUnescape(id)
+		secret, _ = url.QueryUnescape(secret)
+	} else {
+		id = r.PostForm.Get("client_id")
+	}
+	if id != p.ClientID || secret != p.ClientSecret {
+		writeJSON(w, http.StatusUnauthorized, tokenError("invalid_client", "client authentication failed"))
+		return
+	}
+
+	p.mu.Lock()
+	code := r.PostForm.Get("code")
+	g, found := p.codes[code]
+	delete(p.codes, code)
+	key, kid := p.key, p.kid
+	p.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	switch {
+	case !found || g.redirectURI != r.PostForm.Get("redirect_uri"):
+		writeJSON(w, http.StatusBadRequest, tokenError("invalid_grant", "unknown code"))
+		return
+	case base64.RawURLEncoding.EncodeToString(sum[:]) != g.challenge:
+		writeJSON(w, http.StatusBadRequest, tokenError("invalid_grant", "code_verifier does not match"))
+		return
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":            p.URL,
+		"sub":            g.user.Subject,
+		"aud":            p.ClientID,
+		"iat":            now.Unix(),
+		"exp":            now.Add(time.Hour).Unix(),
+		"email":          g.user.Email,
+		"email_verified": g.user.EmailVerified,
+		"name":           g.user.Name,
+	}
+	if g.nonce != "" {
+		claims["nonce"] = g.nonce
+	}
+	for name, value := range g.user.Claims {
+		claims[name] = value
+	}
+	if p.IDToken != nil {
+		p.IDToken(claims)
+	}
+
+	var idToken string
+	var err error
+	if p.Sign != nil {
+		idToken, err = p.Sign(claims)
+	} else {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = kid
+		idToken, err = token.SignedString(key)
+	}
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, tokenError("server_error", err.Error()))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": randomString(),
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+		"id_token":     idToken,
+	})
+}
+
+func randomString() string {
+	b := make([]byte, 24)
+	_, _ = rand.Read(b)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func tokenError(code, description string) map[string]string {
+	return map[string]string{"error": code, "error_description": description}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,164 @@
+// Package impersonation lets support staff act as a customer without knowing
+// the customer's password.
+//
+// An Impersonator issues a short-lived access token for the target user to
+// an admin whose roles grant the impersonation permission. The token's act
+// claim names the admin, no refresh token is issued, and every attempt,
+// allowed or not, is recorded with the options' AuditSink. Routes that must
+// not be reached by impersonating staff can set AuthOptions.RejectImpersonation
+// on their provider or use middleware.RejectImpersonation.
+package impersonation
+
+import (
+	"errors"
+	"time"
+
+	"github.com/responsible-api/responsible-auth/auth"
+	"github.com/responsible-api/responsible-auth/concerns"
+	"github.com/responsible-api/responsible-auth/internal"
+	"github.com/responsible-api/responsible-auth/rbac"
+	"github.com/responsible-api/responsible-auth/resource/access"
+	"github.com/responsible-api/responsible-auth/resource/user"
+	"github.com/responsible-api/responsible-auth/storage"
+)
+
+// Permission is the default permission required to impersonate. It is
+// checked on the resource "users/<name>" of the target, so grants can be
+// limited to some users.
+const Permission = "users:impersonate"
+
+var (
+	// ErrForbidden is returned when the admin's roles do not grant the permission.
+	ErrForbidden = errors.New("impersonation not permitted")
+
+	// ErrReasonRequired is returned when no justification is given.
+	ErrReasonRequired = errors.New("impersonation reason is required")
+
+	// ErrSelf is returned when an admin tries to impersonate themselves.
+	ErrSelf = errors.New("cannot impersonate yourself")
+
+	// ErrTargetInactive is returned when the target user may not authenticate.
+	ErrTargetInactive = errors.New("target user is not active")
+
+	// ErrNoAuditSink is returned when the options have no AuditSink, since
+	// impersonation is only allowed when it can be recorded.
+	ErrNoAuditSink = errors.New("impersonation requires an audit sink")
+)
+
+// Config controls impersonation.
+type Config struct {
+	// Permission required to impersonate; defaults to Permission
+	Permission string
+
+	// TokenDuration is the lifetime of impersonation tokens; defaults to
+	// 15 minutes and never exceeds the options' TokenDuration
+	TokenDuration time.Duration
+}
+
+// Impersonator issues impersonation tokens.
+type Impersonator struct {
+	authorizer *rbac.Authorizer
+	storage    storage.UserStorage
+	options    auth.AuthOptions
+	config     Config
+}
+
+// NewImpersonator creates an impersonator that checks permissions with
+// authorizer, finds target users in storage and signs tokens with options.
+func NewImpersonator(authorizer *rbac.Authorizer, storage storage.UserStorage, options auth.AuthOptions, config Config) *Impersonator {
+	if config.Permission == "" {
+		config.Permission = Permission
+	}
+	if config.TokenDuration == 0 {
+		config.TokenDuration = 15 * time.Minute
+	}
+	if options.TokenDuration > 0 && options.TokenDuration < config.TokenDuration {
+		config.TokenDuration = options.TokenDuration
+	}
+	return &Impersonator{
+		authorizer: authorizer,
+		storage:    storage,
+		options:    options,
+		config:     config,
+	}
+}
+
+// Impersonate issues an access token for the target user to admin, the
+// principal of the admin's validated token. The reason, such as a support
+// ticket, is recorded with the event. If the event cannot be recorded no
+// token is issued.
+func (i *Impersonator) Impersonate(client auth.ClientInfo, admin rbac.Principal, target string, reason string) (*access.RToken, error) {
+	if i.options.AuditSink == nil {
+		return nil, ErrNoAuditSink
+	}
+
+	event := auth.AuditEvent{
+		Type:      auth.EventImpersonation,
+		Subject:   target,
+		Actor:     admin.Name,
+		IP:        client.IP,
+		UserAgent: client.UserAgent,
+		Reason:    reason,
+	}
+
+	u, err := i.authorize(admin, target, reason)
+	if err != nil {
+		event.Reason = err.Error()
+		if rerr := i.options.Audit(event); rerr != nil {
+			return nil, rerr
+		}
+		return nil, err
+	}
+
+	opts := i.options
+	opts.Subject = u.Name
+	opts.AccountID = u.AccountID
+	opts.TokenDuration = i.config.TokenDuration
+	opts.IssuedAt = 0
+	opts.NotBefore = 0
+	opts.AMR = nil
+	opts.Actor = &concerns.Actor{Subject: admin.Name}
+	opts, err = opts.Enrich(u, auth.Grant{Type: auth.GrantImpersonation})
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := internal.CreateAccessToken(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	event.Success = true
+	event.TokenID = token.GetID()
+	if err := i.options.Audit(event); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// authorize checks that admin may impersonate target and returns the target user.
+func (i *Impersonator) authorize(admin rbac.Principal, target string, reason string) (*user.User, error) {
+	if reason == "" {
+		return nil, ErrReasonRequired
+	}
+	if admin.Name == target {
+		return nil, ErrSelf
+	}
+
+	allowed, err := i.authorizer.Can(admin, i.config.Permission, "users/"+target)
+	if err != nil {
+		return nil, err
+	}
+	if !allowed {
+		return nil, ErrForbidden
+	}
+
+	u, err := i.storage.FindUserByName(target)
+	if err != nil {
+		return nil, err
+	}
+	if !u.IsActive() {
+		return nil, ErrTargetInactive
+	}
+	return u, nil
+}
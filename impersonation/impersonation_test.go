@@ -0,0 +1,146 @@
+package impersonation
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/responsible-api/responsible-auth/auth"
+	"github.com/responsible-api/responsible-auth/concerns"
+	"github.com/responsible-api/responsible-auth/examples/memory"
+	"github.com/responsible-api/responsible-auth/internal"
+	"github.com/responsible-api/responsible-auth/rbac"
+	"github.com/responsible-api/responsible-auth/resource/role"
+	"github.com/responsible-api/responsible-auth/resource/user"
+	"github.com/responsible-api/responsible-auth/testutils"
+)
+
+// newTestImpersonator returns an impersonator where support may impersonate
+// customers but not admins, and the events it records.
+func newTestImpersonator(t *testing.T) (*Impersonator, *[]auth.AuditEvent) {
+	t.Helper()
+	roles := memory.NewInMemoryRoleStorage()
+	for _, r := range []*role.Role{{Name: "support"}, {Name: "customer"}} {
+		if err := roles.CreateRole(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := roles.GrantPermission(&role.Permission{RoleName: "support", Permission: Permission, Resource: "users/customer-*"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := roles.AssignRole("customer-1", "customer"); err != nil {
+		t.Fatal(err)
+	}
+	authorizer := rbac.NewAuthorizer(roles)
+
+	users := memory.NewInMemoryStorageWithUsers(
+		&user.User{AccountID: 1, Name: "customer-1", Mail: "c1@example.com", Status: user.StatusActive},
+		&user.User{AccountID: 2, Name: "customer-2", Mail: "c2@example.com", Status: user.StatusSuspended},
+		&user.User{AccountID: 3, Name: "admin", Mail: "admin@example.com", Status: user.StatusActive},
+	)
+
+	var events []auth.AuditEvent
+	options := testutils.TestAuthOptions()
+	options.ClaimsEnricher = authorizer
+	options.AuditSink = auth.AuditSinkFunc(func(event auth.AuditEvent) error {
+		events = append(events, event)
+		return nil
+	})
+	return NewImpersonator(authorizer, users, options, Config{TokenDuration: 5 * time.Minute}), &events
+}
+
+var (
+	support = rbac.Principal{Name: "agent", Roles: []string{"support"}}
+	client  = auth.ClientInfo{IP: "192.0.2.1", UserAgent: "test-agent"}
+)
+
+func TestImpersonator_Impersonate(t *testing.T) {
+	impersonator, events := newTestImpersonator(t)
+
+	token, err := impersonator.Impersonate(client, support, "customer-1", "ticket 42")
+	if err != nil {
+		t.Fatalf("Impersonate() unexpected error = %v", err)
+	}
+
+	validated, err := internal.Validate(token.Raw, testutils.TestAuthOptions())
+	if err != nil {
+		t.Fatalf("Validate() unexpected error = %v", err)
+	}
+	claims := validated.Claims.(*concerns.ClaimsGeneric)
+	if claims.Subject != "customer-1" || claims.AccountID != 1 {
+		t.Errorf("token subject = %q account %d, want customer-1 account 1", claims.Subject, claims.AccountID)
+	}
+	if claims.Actor == nil || claims.Actor.Subject != "agent" {
+		t.Errorf("token act = %+v, want agent", claims.Actor)
+	}
+	if len(claims.Roles) != 1 || claims.Roles[0] != "customer" {
+		t.Errorf("token roles = %v, want the target's roles", claims.Roles)
+	}
+	if lifetime := time.Until(claims.ExpiresAt.Time); lifetime > 5*time.Minute {
+		t.Errorf("token lifetime = %v, want at most 5m", lifetime)
+	}
+	if claims.ID == "" {
+		t.Error("token has no jti")
+	}
+
+	rejecting := testutils.TestAuthOptions()
+	rejecting.RejectImpersonation = true
+	if _, err := internal.Validate(token.Raw, rejecting); err == nil {
+		t.Error("Validate() with RejectImpersonation accepted an impersonation token")
+	}
+
+	if len(*events) != 1 {
+		t.Fatalf("recorded %d events, want 1", len(*events))
+	}
+	event := (*events)[0]
+	if !event.Success || event.Type != auth.EventImpersonation || event.Actor != "agent" || event.Subject != "customer-1" ||
+		event.Reason != "ticket 42" || event.TokenID != claims.ID || event.IP != client.IP || event.UserAgent != client.UserAgent {
+		t.Errorf("recorded event = %+v", event)
+	}
+}
+
+func TestImpersonator_Denied(t *testing.T) {
+	tests := []struct {
+		name    string
+		admin   rbac.Principal
+		target  string
+		reason  string
+		wantErr error
+	}{
+		{name: "without permission", admin: rbac.Principal{Name: "agent", Roles: []string{"customer"}}, target: "customer-1", reason: "ticket", wantErr: ErrForbidden},
+		{name: "outside granted users", admin: support, target: "admin", reason: "ticket", wantErr: ErrForbidden},
+		{name: "without reason", admin: support, target: "customer-1", wantErr: ErrReasonRequired},
+		{name: "self", admin: rbac.Principal{Name: "customer-1", Roles: []string{"support"}}, target: "customer-1", reason: "ticket", wantErr: ErrSelf},
+		{name: "inactive target", admin: support, target: "customer-2", reason: "ticket", wantErr: ErrTargetInactive},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			impersonator, events := newTestImpersonator(t)
+			if _, err := impersonator.Impersonate(client, tt.admin, tt.target, tt.reason); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Impersonate() error = %v, want %v", err, tt.wantErr)
+			}
+			if len(*events) != 1 || (*events)[0].Success {
+				t.Errorf("recorded events = %+v, want one failure", *events)
+			}
+		})
+	}
+}
+
+func TestImpersonator_Audit(t *testing.T) {
+	options := testutils.TestAuthOptions()
+	impersonator := NewImpersonator(rbac.NewAuthorizer(memory.NewInMemoryRoleStorage()), testutils.NewMockStorage(), options, Config{})
+	if _, err := impersonator.Impersonate(client, support, "testuser", "ticket"); !errors.Is(err, ErrNoAuditSink) {
+		t.Errorf("Impersonate() without sink error = %v, want %v", err, ErrNoAuditSink)
+	}
+
+	failing := errors.New("audit log unavailable")
+	options.AuditSink = auth.AuditSinkFunc(func(auth.AuditEvent) error { return failing })
+	roles := memory.NewInMemoryRoleStorage()
+	roles.CreateRole(&role.Role{Name: "support"})
+	roles.GrantPermission(&role.Permission{RoleName: "support", Permission: Permission, Resource: role.Wildcard})
+	impersonator = NewImpersonator(rbac.NewAuthorizer(roles), testutils.NewMockStorage(), options, Config{})
+	if token, err := impersonator.Impersonate(client, support, "testuser", "ticket"); !errors.Is(err, failing) || token != nil {
+		t.Errorf("Impersonate() with failing sink = %v, %v, want no token and %v", token, err, failing)
+	}
+}
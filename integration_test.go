@@ -12,7 +12,7 @@ import (
 
 func TestBasicAuthIntegration(t *testing.T) {
 	// Setup
-	storage := memory.NewInMemoryStorage()
+	storage := memory.NewInMemoryStorageWithUsers(testutils.TestUser())
 	provider := service.NewBasicAuth()
 	options := testutils.TestAuthOptions()
 
@@ -105,15 +105,14 @@ func TestAPIKeyAuthIntegration(t *testing.T) {
 	authService := auth.NewAuth(provider, storage, options)
 
 	t.Run("complete api key auth flow", func(t *testing.T) {
-		// 1. Decode API key (current implementation returns static values)
+		// 1. Decode API key into the owning user's name
 		username, _, err := authService.Provider.Decode("api_key_12345")
 		if err != nil {
 			t.Fatalf("Failed to decode API key: %v", err)
 		}
 
-		// Current implementation returns static values
-		if username != "exampleUser" {
-			t.Errorf("Expected username exampleUser, got %s", username)
+		if username != "test-user" {
+			t.Errorf("Expected username test-user, got %s", username)
 		}
 
 		// 2. Create access token using valid API key
@@ -159,7 +158,7 @@ func TestAPIKeyAuthIntegration(t *testing.T) {
 
 func TestMultipleProvidersWithSameStorage(t *testing.T) {
 	// Test that different providers can use the same storage
-	storage := memory.NewInMemoryStorage()
+	storage := memory.NewInMemoryStorageWithUsers(testutils.TestUser())
 	options := testutils.TestAuthOptions()
 
 	// Basic Auth service
@@ -183,7 +182,7 @@ func TestMultipleProvidersWithSameStorage(t *testing.T) {
 		}
 
 		// API key auth flow
-		apiKeyToken, err := apiKeyAuthService.Provider.CreateAccessToken("exampleUser", "api_key_12345")
+		apiKeyToken, err := apiKeyAuthService.Provider.CreateAccessToken("testuser", "test-api-key-12345")
 		if err != nil {
 			t.Fatalf("API key auth token creation failed: %v", err)
 		}
@@ -212,11 +211,13 @@ func TestMultipleProvidersWithSameStorage(t *testing.T) {
 
 func TestTokenExpiration(t *testing.T) {
 	// Test with short token duration
-	storage := memory.NewInMemoryStorage()
+	storage := memory.NewInMemoryStorageWithUsers(testutils.TestUser())
 	provider := service.NewBasicAuth()
 
 	shortOptions := testutils.TestAuthOptions()
-	shortOptions.TokenDuration = 100 * time.Millisecond // Very short duration
+	// exp is whole seconds, so the token is valid for at least one second
+	shortOptions.TokenDuration = 2 * time.Second
+	shortOptions.TokenLeeway = 0
 
 	authService := auth.NewAuth(provider, storage, shortOptions)
 
@@ -244,7 +245,7 @@ func TestTokenExpiration(t *testing.T) {
 		}
 
 		// Wait for token to expire
-		time.Sleep(200 * time.Millisecond)
+		time.Sleep(3 * time.Second)
 
 		// Token should now be expired
 		expiredToken, err := authService.Provider.Validate(tokenString)
@@ -259,7 +260,7 @@ func TestTokenExpiration(t *testing.T) {
 }
 
 func TestCustomClaims(t *testing.T) {
-	storage := memory.NewInMemoryStorage()
+	storage := memory.NewInMemoryStorageWithUsers(testutils.TestUser())
 	provider := service.NewBasicAuth()
 
 	options := testutils.TestAuthOptions()
@@ -1,6 +1,8 @@
 package internal
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 	"time"
 
@@ -12,6 +14,13 @@ import (
 )
 
 func CreateAccessToken(options auth.AuthOptions) (*access.RToken, error) {
+	return IssueAccessToken(&concerns.ClaimsGeneric{}, options)
+}
+
+// IssueAccessToken signs claims as an access token. Registered and standard
+// claims the caller left unset are filled from options, so typed claim
+// structs get the same issuer, lifetime and account claims as CreateAccessToken.
+func IssueAccessToken(claims concerns.Generic, options auth.AuthOptions) (*access.RToken, error) {
 	if (options.SecretKey == "") || (options.SecretKey == "required") {
 		return nil, fmt.Errorf("secret key is required")
 	}
@@ -19,18 +28,59 @@ func CreateAccessToken(options auth.AuthOptions) (*access.RToken, error) {
 	// Generate a JWT token via the supplied options set
 	// Set the expiration time to the specified duration
 	// Return the generated token or an error if something goes wrong
-	claims := &concerns.ClaimsGeneric{
-		RegisteredClaims: jwt.RegisteredClaims{
-			Issuer:    setIssuer(options.Issuer),
-			Subject:   setSubject(options.Subject),
-			IssuedAt:  jwt.NewNumericDate(setIssuedAt(options.IssuedAt)),
-			ExpiresAt: jwt.NewNumericDate(setExpiresAt(options.TokenDuration)),
-			NotBefore: jwt.NewNumericDate(setNotBefore(options.NotBefore)),
-		},
-		Role:   options.Role,
-		Scopes: options.Scopes,
-		// Custom claims can be added here
-		CustomClaims: options.CustomClaims,
+	generic := claims.GenericClaims()
+	if generic.ID == "" {
+		id, err := newTokenID()
+		if err != nil {
+			return nil, err
+		}
+		generic.ID = id
+	}
+	if generic.Issuer == "" {
+		generic.Issuer = setIssuer(options.Issuer)
+	}
+	if generic.Subject == "" {
+		generic.Subject = setSubject(options.Subject)
+	}
+	if len(generic.Audience) == 0 {
+		generic.Audience = jwt.ClaimStrings(options.Audience)
+	}
+	if generic.IssuedAt == nil {
+		generic.IssuedAt = jwt.NewNumericDate(setIssuedAt(options.IssuedAt))
+	}
+	if generic.ExpiresAt == nil {
+		generic.ExpiresAt = jwt.NewNumericDate(setExpiresAt(options.TokenDuration))
+	}
+	if generic.NotBefore == nil {
+		generic.NotBefore = jwt.NewNumericDate(setNotBefore(options.NotBefore))
+	}
+	if generic.Role == "" {
+		generic.Role = options.Role
+	}
+	if generic.Roles == nil {
+		generic.Roles = options.Roles
+	}
+	if generic.Scopes == "" {
+		generic.Scopes = options.Scopes
+	}
+	if generic.AccountID == 0 {
+		generic.AccountID = options.AccountID
+	}
+	if generic.TenantID == 0 {
+		generic.TenantID = options.TenantID
+	}
+	if generic.Actor == nil {
+		generic.Actor = options.Actor
+	}
+	if generic.AMR == nil {
+		generic.AMR = options.AMR
+	}
+	if generic.ClientID == "" {
+		generic.ClientID = options.ClientID
+	}
+	// Custom claims can be added here
+	if generic.CustomClaims == nil {
+		generic.CustomClaims = options.CustomClaims
 	}
 
 	jwtToken := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
@@ -41,9 +91,20 @@ func CreateAccessToken(options auth.AuthOptions) (*access.RToken, error) {
 
 	// Set the raw token string to the JWT token from the signed process
 	jwtToken.Raw = tokenString
+	options.Log().Debug("access token issued", "subject", generic.Subject, "jti", generic.ID, "expires_at", generic.ExpiresAt.Time)
 	return access.NewToken(jwtToken), nil
 }
 
+// newTokenID returns a random jti, so every token can be told apart in audit
+// logs and revocation lists, even when issued in the same second.
+func newTokenID() (string, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(id), nil
+}
+
 // setIssuer sets the issuer for the token.
 // If issuer in the option is empty or doesn't exist, then default to "default-issuer".
 // Otherwise, it sets the issuer to the requested value.
@@ -0,0 +1,68 @@
+package internal
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"fmt"
+	"time"
+
+	"github.com/responsible-api/responsible-auth/auth"
+	"github.com/responsible-api/responsible-auth/concerns"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ChallengeTokenDuration is how long a user has to enter their second factor.
+const ChallengeTokenDuration = 5 * time.Minute
+
+// challengeAudience marks MFA challenge tokens in the aud claim
+const challengeAudience = "mfa_challenge"
+
+// CreateChallengeToken issues the token returned after the first login step,
+// proving the named user passed the methods in amr. It is signed with a key
+// derived from the secret key, so Validate never accepts it as an access token.
+func CreateChallengeToken(userName string, amr []string, options auth.AuthOptions) (string, error) {
+	if (options.SecretKey == "") || (options.SecretKey == "required") {
+		return "", fmt.Errorf("secret key is required")
+	}
+
+	now := time.Now()
+	claims := &concerns.ClaimsGeneric{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    setIssuer(options.Issuer),
+			Subject:   userName,
+			Audience:  jwt.ClaimStrings{challengeAudience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(ChallengeTokenDuration)),
+			NotBefore: jwt.NewNumericDate(now),
+		},
+		AMR: amr,
+	}
+
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(derivedKey(options, challengeAudience))
+}
+
+// ParseChallengeToken verifies a challenge token and returns its claims.
+func ParseChallengeToken(tokenString string, options auth.AuthOptions) (*concerns.ClaimsGeneric, error) {
+	claims := &concerns.ClaimsGeneric{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return derivedKey(options, challengeAudience), nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithAudience(challengeAudience),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(options.TokenLeeway),
+	)
+	if err != nil || claims.Subject == "" {
+		return nil, fmt.Errorf("invalid challenge token")
+	}
+	return claims, nil
+}
+
+// derivedKey returns the signing key for tokens with the given audience, so
+// tokens made for one purpose never verify as another.
+func derivedKey(options auth.AuthOptions, audience string) []byte {
+	mac := hmac.New(sha256.New, []byte(options.SecretKey))
+	mac.Write([]byte(audience))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,48 @@
+package internal
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"github.com/responsible-api/responsible-auth/auth"
+)
+
+// csrfPurpose separates the CSRF key from the other derived keys
+const csrfPurpose = "csrf"
+
+// CreateCSRFToken returns a random token bound to a session. The binding is a
+// value only the session holds, such as its refresh token, so a token taken
+// from one session is rejected by every other.
+func CreateCSRFToken(binding string, options auth.AuthOptions) (string, error) {
+	if (options.SecretKey == "") || (options.SecretKey == "required") {
+		return "", fmt.Errorf("secret key is required")
+	}
+
+	nonce := make([]byte, 32)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(nonce)
+	return encoded + "." + csrfSignature(encoded, binding, options), nil
+}
+
+// VerifyCSRFToken reports whether token was created for the session with binding.
+func VerifyCSRFToken(token, binding string, options auth.AuthOptions) bool {
+	nonce, signature, ok := strings.Cut(token, ".")
+	if !ok || nonce == "" || binding == "" {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(csrfSignature(nonce, binding, options)))
+}
+
+func csrfSignature(nonce, binding string, options auth.AuthOptions) string {
+	bound := sha256.Sum256([]byte(binding))
+	mac := hmac.New(sha256.New, derivedKey(options, csrfPurpose))
+	mac.Write([]byte(nonce))
+	mac.Write(bound[:])
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
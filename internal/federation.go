@@ -0,0 +1,60 @@
+package internal
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/responsible-api/responsible-auth/auth"
+	"github.com/responsible-api/responsible-auth/concerns"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// FederationStateDuration is how long a user has to sign in with the upstream provider.
+const FederationStateDuration = 10 * time.Minute
+
+// federationAudience marks federation state tokens in the aud claim
+const federationAudience = "federation_state"
+
+// CreateFederationState issues the token holding the state, nonce and PKCE
+// verifier of an upstream sign-in until its callback arrives. It is signed
+// with a key derived from the secret key, so Validate never accepts it as an
+// access token.
+func CreateFederationState(state, nonce, verifier string, options auth.AuthOptions) (string, error) {
+	if (options.SecretKey == "") || (options.SecretKey == "required") {
+		return "", fmt.Errorf("secret key is required")
+	}
+
+	now := time.Now()
+	claims := &concerns.FederationClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    setIssuer(options.Issuer),
+			Audience:  jwt.ClaimStrings{federationAudience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(FederationStateDuration)),
+			NotBefore: jwt.NewNumericDate(now),
+		},
+		State:    state,
+		Nonce:    nonce,
+		Verifier: verifier,
+	}
+
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(derivedKey(options, federationAudience))
+}
+
+// ParseFederationState verifies a federation state token and returns its claims.
+func ParseFederationState(tokenString string, options auth.AuthOptions) (*concerns.FederationClaims, error) {
+	claims := &concerns.FederationClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return derivedKey(options, federationAudience), nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithAudience(federationAudience),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(options.TokenLeeway),
+	)
+	if err != nil || claims.State == "" || claims.Nonce == "" || claims.Verifier == "" {
+		return nil, fmt.Errorf("invalid federation state")
+	}
+	return claims, nil
+}
@@ -0,0 +1,34 @@
+package internal
+
+import (
+	"crypto/rsa"
+	"fmt"
+
+	"github.com/responsible-api/responsible-auth/concerns"
+	"github.com/responsible-api/responsible-auth/resource/access"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// CreateIDToken signs OpenID Connect ID token claims with RS256.
+// ID tokens are verified by relying parties, so unlike access tokens they are
+// signed with a private key whose public half is published as a JWKS.
+func CreateIDToken(claims *concerns.IDClaims, key *rsa.PrivateKey, keyID string) (*access.RToken, error) {
+	if key == nil {
+		return nil, fmt.Errorf("signing key is required")
+	}
+
+	idToken := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	if keyID != "" {
+		idToken.Header["kid"] = keyID
+	}
+
+	tokenString, err := idToken.SignedString(key)
+	if err != nil {
+		return nil, err
+	}
+
+	// Set the raw token string to the JWT token from the signed process
+	idToken.Raw = tokenString
+	return access.NewToken(idToken), nil
+}
@@ -947,3 +947,52 @@ func TestValidateEdgeCases(t *testing.T) {
 		})
 	}
 }
+
+func TestValidateTenant(t *testing.T) {
+	issue := func(tenant uint64) string {
+		options := testutils.TestAuthOptions()
+		options.TenantID = tenant
+		token, err := CreateAccessToken(options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token.Raw
+	}
+
+	tests := []struct {
+		name        string
+		issuedFor   uint64
+		validatedBy uint64
+		expectError bool
+	}{
+		{name: "same tenant", issuedFor: 1, validatedBy: 1},
+		{name: "other tenant", issuedFor: 1, validatedBy: 2, expectError: true},
+		{name: "token without tenant", issuedFor: 0, validatedBy: 2, expectError: true},
+		{name: "options without tenant", issuedFor: 1, validatedBy: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			options := testutils.TestAuthOptions()
+			options.TenantID = tt.validatedBy
+			_, err := Validate(issue(tt.issuedFor), options)
+			if tt.expectError && err == nil {
+				t.Error("Validate() expected error but got none")
+			}
+			if !tt.expectError && err != nil {
+				t.Errorf("Validate() unexpected error = %v", err)
+			}
+		})
+	}
+
+	options := testutils.TestAuthOptions()
+	options.TenantID = 1
+	refresh, err := CreateRefreshToken("testuser", options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	options.TenantID = 2
+	if _, err := ParseRefreshToken(refresh.Raw, options); err == nil {
+		t.Error("ParseRefreshToken() accepted a refresh token issued for another tenant")
+	}
+}
@@ -2,7 +2,6 @@ package internal
 
 import (
 	"fmt"
-	"log"
 	"net/http"
 	"time"
 
@@ -13,10 +12,79 @@ import (
 )
 
 func CreateRefreshToken(username string, options auth.AuthOptions) (*access.RToken, error) {
-	refreshToken := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+	if (options.SecretKey == "") || (options.SecretKey == "required") {
+		return nil, fmt.Errorf("secret key is required")
+	}
+
+	id, err := newTokenID()
+	if err != nil {
+		return nil, err
+	}
+
+	claims := jwt.MapClaims{
+		"jti":      id,
 		"username": username,
-		"exp":      time.Now().Add(options.RefreshTokenDuration).Unix(),
-	})
+		// float64 matches what the claims hold once parsed, so GetExpirationTime works on new tokens too
+		"exp": float64(time.Now().Add(options.RefreshTokenDuration).Unix()),
+	}
+	// Carry the granted scopes so a refreshed access token cannot widen them
+	if options.Scopes != "" {
+		claims["scope"] = options.Scopes
+	}
+	// Bind the refresh token to the OAuth client it was issued to
+	if options.ClientID != "" {
+		claims["client_id"] = options.ClientID
+	}
+	// Bind the refresh token to the tenant it was issued in
+	if options.TenantID != 0 {
+		claims["tid"] = float64(options.TenantID)
+	}
+	// Carry the login methods so refreshed access tokens keep their amr claim
+	if len(options.AMR) > 0 {
+		claims["amr"] = options.AMR
+	}
+	// Carry the custom claims so claims added at login survive a refresh
+	if len(options.CustomClaims) > 0 {
+		claims["custom"] = options.CustomClaims
+	}
+	return signRefreshToken(claims, options)
+}
+
+// SessionRefreshToken reissues a refresh token bound to a browser session, so
+// that providers check the session before every refresh of it.
+func SessionRefreshToken(refreshTokenString string, options auth.AuthOptions) (*access.RToken, error) {
+	claims, err := ParseRefreshToken(refreshTokenString, options)
+	if err != nil {
+		return nil, err
+	}
+	claims["session"] = true
+	return signRefreshToken(claims, options)
+}
+
+// RotateRefreshToken reissues a refresh token under a new ID, keeping its
+// claims and expiry, so the token it replaces can be revoked.
+func RotateRefreshToken(refreshTokenString string, options auth.AuthOptions) (*access.RToken, error) {
+	claims, err := ParseRefreshToken(refreshTokenString, options)
+	if err != nil {
+		return nil, err
+	}
+	id, err := newTokenID()
+	if err != nil {
+		return nil, err
+	}
+	claims["jti"] = id
+	return signRefreshToken(claims, options)
+}
+
+// IsSessionRefreshToken reports whether the refresh token claims are bound to
+// a browser session.
+func IsSessionRefreshToken(claims jwt.MapClaims) bool {
+	session, _ := claims["session"].(bool)
+	return session
+}
+
+func signRefreshToken(claims jwt.MapClaims, options auth.AuthOptions) (*access.RToken, error) {
+	refreshToken := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 
 	tokenString, err := refreshToken.SignedString([]byte(options.SecretKey))
 	if err != nil {
@@ -29,8 +97,8 @@ func CreateRefreshToken(username string, options auth.AuthOptions) (*access.RTok
 	return access.NewToken(refreshToken), nil
 }
 
-func GrantRefreshToken(refreshTokenString string, options auth.AuthOptions) (*access.RToken, error) {
-	// Parse and verify the requested refresh token to grant a new access token
+// ParseRefreshToken verifies the signature and expiry of a refresh token and returns its claims.
+func ParseRefreshToken(refreshTokenString string, options auth.AuthOptions) (jwt.MapClaims, error) {
 	refreshToken, err := jwt.Parse(refreshTokenString, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, http.ErrAbortHandler
@@ -39,19 +107,34 @@ func GrantRefreshToken(refreshTokenString string, options auth.AuthOptions) (*ac
 	})
 
 	if err != nil || !refreshToken.Valid {
-		log.Println("Error parsing refresh token:", err)
+		options.Log().Debug("refresh token rejected", "error", err)
 		return nil, fmt.Errorf("invalid refresh token")
 	}
 
-	// Generate a new access token if refresh token is valid
-	if _, ok := refreshToken.Claims.(jwt.MapClaims); ok && refreshToken.Valid {
-		newAccessToken, err := CreateAccessToken(options)
-		if err != nil {
-			return nil, err
+	claims, ok := refreshToken.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, fmt.Errorf("invalid refresh token")
+	}
+
+	if options.TenantID != 0 {
+		if tid, _ := claims["tid"].(float64); tid != float64(options.TenantID) {
+			options.Log().Debug("refresh token rejected", "error", "issued for another tenant")
+			return nil, fmt.Errorf("invalid refresh token")
 		}
-		return newAccessToken, nil
 	}
+	return claims, nil
+}
 
-	// If the refresh token is not valid, return an error
-	return nil, fmt.Errorf("invalid refresh token")
+func GrantRefreshToken(refreshTokenString string, options auth.AuthOptions) (*access.RToken, error) {
+	// Parse and verify the requested refresh token to grant a new access token
+	if _, err := ParseRefreshToken(refreshTokenString, options); err != nil {
+		return nil, err
+	}
+
+	// Generate a new access token if refresh token is valid
+	newAccessToken, err := CreateAccessToken(options)
+	if err != nil {
+		return nil, err
+	}
+	return newAccessToken, nil
 }
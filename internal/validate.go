@@ -11,7 +11,21 @@ import (
 )
 
 func Validate(tokenString string, options auth.AuthOptions) (*jwt.Token, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &concerns.ClaimsGeneric{}, func(token *jwt.Token) (interface{}, error) {
+	return ValidateClaims(tokenString, &concerns.ClaimsGeneric{}, options)
+}
+
+// ValidateClaims validates an access token like Validate, decoding its claims
+// into the given struct, which the returned token's Claims then points to.
+func ValidateClaims(tokenString string, claims concerns.Generic, options auth.AuthOptions) (*jwt.Token, error) {
+	token, err := validateClaims(tokenString, claims, options)
+	if err != nil {
+		options.Log().Debug("access token rejected", "error", err)
+	}
+	return token, err
+}
+
+func validateClaims(tokenString string, claims concerns.Generic, options auth.AuthOptions) (*jwt.Token, error) {
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return token, nil
 		}
@@ -26,14 +40,22 @@ func Validate(tokenString string, options auth.AuthOptions) (*jwt.Token, error)
 		return nil, err
 	}
 
-	if claims, ok := token.Claims.(*concerns.ClaimsGeneric); ok && token.Valid {
-		if !validExpiry(claims) {
+	if claims, ok := token.Claims.(concerns.Generic); ok && token.Valid {
+		if !validExpiry(claims.GenericClaims()) {
 			return nil, fmt.Errorf("token expired")
 		}
 
-		if !validNotBefore(claims) {
+		if !validNotBefore(claims.GenericClaims()) {
 			return nil, fmt.Errorf("token not valid yet")
 		}
+
+		if !validTenant(claims.GenericClaims(), options.TenantID) {
+			return nil, fmt.Errorf("token issued for another tenant")
+		}
+
+		if options.RejectImpersonation && claims.GenericClaims().Actor != nil {
+			return nil, fmt.Errorf("token acts on behalf of another party")
+		}
 	}
 	return token, nil
 }
@@ -42,6 +64,12 @@ func validExpiry(claims *concerns.ClaimsGeneric) bool {
 	return !(claims.ExpiresAt == nil || claims.ExpiresAt.Time.Before(time.Now()))
 }
 
+// validTenant reports whether the token belongs to tenant. Options without a
+// tenant accept any token, so single-tenant setups are unaffected.
+func validTenant(claims *concerns.ClaimsGeneric, tenant uint64) bool {
+	return tenant == 0 || claims.TenantID == tenant
+}
+
 func validNotBefore(claims *concerns.ClaimsGeneric) bool {
 	return !(claims.NotBefore == nil || claims.NotBefore.Time.After(time.Now()))
 }
@@ -0,0 +1,377 @@
+package ldap
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/responsible-api/responsible-auth/ldap/internal/ber"
+	"github.com/responsible-api/responsible-auth/ldap/internal/filter"
+)
+
+// Protocol operations (RFC 4511 section 4.2 onwards)
+const (
+	opBindRequest        = 0
+	opBindResponse       = 1
+	opUnbindRequest      = 2
+	opSearchRequest      = 3
+	opSearchEntry        = 4
+	opSearchDone         = 5
+	opSearchReference    = 19
+	opExtendedRequest    = 23
+	opExtendedResponse   = 24
+	startTLSOID          = "1.3.6.1.4.1.1466.20037"
+	protocolVersion      = 3
+	defaultPort          = "389"
+	defaultPortTLS       = "636"
+	simpleAuthentication = 0
+)
+
+// Search scopes
+const (
+	ScopeBaseObject   = 0
+	ScopeSingleLevel  = 1
+	ScopeWholeSubtree = 2
+)
+
+// Result codes (RFC 4511 appendix A)
+const (
+	ResultSuccess                 = 0
+	ResultSizeLimitExceeded       = 4
+	ResultConfidentialityRequired = 13
+	ResultNoSuchObject            = 32
+	ResultInvalidCredentials      = 49
+	ResultInsufficientAccess      = 50
+	ResultUnwillingToPerform      = 53
+)
+
+var (
+	// ErrInvalidCredentials is returned when a bind is rejected, and is
+	// matched by an *Error with ResultInvalidCredentials.
+	ErrInvalidCredentials = errors.New("invalid credentials")
+
+	// ErrProtocol is returned for responses that do not follow RFC 4511.
+	ErrProtocol = errors.New("ldap protocol error")
+)
+
+// Error is a failed LDAP operation.
+type Error struct {
+	ResultCode int64
+	Message    string
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("ldap: result code %d", e.ResultCode)
+	}
+	return fmt.Sprintf("ldap: result code %d: %s", e.ResultCode, e.Message)
+}
+
+// Is makes errors.Is(err, ErrInvalidCredentials) true for rejected binds.
+func (e *Error) Is(target error) bool {
+	return target == ErrInvalidCredentials && e.ResultCode == ResultInvalidCredentials
+}
+
+// SearchRequest describes a search.
+type SearchRequest struct {
+	BaseDN     string
+	Scope      int
+	Filter     string // RFC 4515, e.g. "(uid=jane)"
+	Attributes []string
+	SizeLimit  int
+}
+
+// Entry is a search result.
+type Entry struct {
+	DN         string
+	Attributes map[string][]string
+}
+
+// Values returns the values of an attribute, matching its name case-insensitively.
+func (e *Entry) Values(name string) []string {
+	for key, values := range e.Attributes {
+		if strings.EqualFold(key, name) {
+			return values
+		}
+	}
+	return nil
+}
+
+// Value returns the first value of an attribute, or "" when it has none.
+func (e *Entry) Value(name string) string {
+	if values := e.Values(name); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// EscapeFilter escapes a value for use in a search filter, so user input
+// cannot change the structure of the filter.
+func EscapeFilter(s string) string {
+	return filter.Escape(s)
+}
+
+// Conn is a connection to an LDAP server. Operations run one at a time.
+type Conn struct {
+	mu      sync.Mutex
+	conn    net.Conn
+	host    string
+	timeout time.Duration
+	nextID  int64
+}
+
+// Dial connects to an ldap:// or ldaps:// URL. The TLS configuration is used
+// for ldaps:// and defaults to verifying the URL's host name. Every
+// operation must complete within timeout.
+func Dial(rawURL string, tlsConfig *tls.Config, timeout time.Duration) (*Conn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	host, port := u.Hostname(), u.Port()
+	dialer := &net.Dialer{Timeout: timeout}
+	var conn net.Conn
+
+	switch u.Scheme {
+	case "ldap":
+		if port == "" {
+			port = defaultPort
+		}
+		conn, err = dialer.Dial("tcp", net.JoinHostPort(host, port))
+	case "ldaps":
+		if port == "" {
+			port = defaultPortTLS
+		}
+		conn, err = tls.DialWithDialer(dialer, "tcp", net.JoinHostPort(host, port), clientTLSConfig(tlsConfig, host))
+	default:
+		return nil, fmt.Errorf("ldap: unsupported url scheme %q", u.Scheme)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &Conn{
+		conn:    conn,
+		host:    host,
+		timeout: timeout,
+	}, nil
+}
+
+// StartTLS upgrades a plain connection to TLS (RFC 4511 section 4.14).
+func (c *Conn) StartTLS(tlsConfig *tls.Config) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	request := ber.NewConstructed(ber.ClassApplication, opExtendedRequest,
+		ber.New(ber.ClassContext, 0, []byte(startTLSOID)),
+	)
+	responses, err := c.roundTrip(request, opExtendedResponse)
+	if err != nil {
+		return err
+	}
+	if err := result(responses[0]); err != nil {
+		return err
+	}
+
+	tlsConn := tls.Client(c.conn, clientTLSConfig(tlsConfig, c.host))
+	if err := tlsConn.SetDeadline(time.Now().Add(c.timeout)); err != nil {
+		return err
+	}
+	if err := tlsConn.Handshake(); err != nil {
+		return err
+	}
+	c.conn = tlsConn
+	return nil
+}
+
+// Bind authenticates the connection with a simple bind. Empty passwords are
+// refused without contacting the server, because RFC 4513 lets servers treat
+// them as a successful anonymous bind.
+func (c *Conn) Bind(dn, password string) error {
+	if password == "" {
+		return ErrInvalidCredentials
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	request := ber.NewConstructed(ber.ClassApplication, opBindRequest,
+		ber.Integer(protocolVersion),
+		ber.OctetString(dn),
+		ber.New(ber.ClassContext, simpleAuthentication, []byte(password)),
+	)
+	responses, err := c.roundTrip(request, opBindResponse)
+	if err != nil {
+		return err
+	}
+	return result(responses[0])
+}
+
+// Search returns the entries matching the request. Exceeding the size limit
+// is an error with ResultSizeLimitExceeded.
+func (c *Conn) Search(request SearchRequest) ([]*Entry, error) {
+	compiled, err := filter.Compile(request.Filter)
+	if err != nil {
+		return nil, err
+	}
+
+	attributes := ber.Sequence()
+	for _, attribute := range request.Attributes {
+		attributes.Append(ber.OctetString(attribute))
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	responses, err := c.roundTrip(ber.NewConstructed(ber.ClassApplication, opSearchRequest,
+		ber.OctetString(request.BaseDN),
+		ber.Enumerated(int64(request.Scope)),
+		ber.Enumerated(0), // never dereference aliases
+		ber.Integer(int64(request.SizeLimit)),
+		ber.Integer(int64(c.timeout/time.Second)),
+		ber.Boolean(false),
+		compiled,
+		attributes,
+	), opSearchDone)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*Entry
+	for _, response := range responses {
+		switch {
+		case response.Is(ber.ClassApplication, opSearchEntry):
+			entry, err := parseEntry(response)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, entry)
+		case response.Is(ber.ClassApplication, opSearchDone):
+			if err := result(response); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return entries, nil
+}
+
+// Close unbinds and closes the connection.
+func (c *Conn) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.nextID++
+	message := ber.Sequence(
+		ber.Integer(c.nextID),
+		ber.New(ber.ClassApplication, opUnbindRequest, nil),
+	)
+	_ = c.conn.SetDeadline(time.Now().Add(c.timeout))
+	_, _ = c.conn.Write(message.Bytes())
+	return c.conn.Close()
+}
+
+// roundTrip sends an operation and collects the responses up to and
+// including the one with the final tag. Callers must hold the lock.
+func (c *Conn) roundTrip(op *ber.Packet, final int) ([]*ber.Packet, error) {
+	if err := c.conn.SetDeadline(time.Now().Add(c.timeout)); err != nil {
+		return nil, err
+	}
+
+	c.nextID++
+	id := c.nextID
+	if _, err := c.conn.Write(ber.Sequence(ber.Integer(id), op).Bytes()); err != nil {
+		return nil, err
+	}
+
+	var responses []*ber.Packet
+	for {
+		message, err := ber.Read(c.conn)
+		if err != nil {
+			return nil, err
+		}
+		if len(message.Children) < 2 || !message.Children[1].Constructed {
+			return nil, ErrProtocol
+		}
+		messageID, err := message.Children[0].Int()
+		if err != nil {
+			return nil, ErrProtocol
+		}
+		response := message.Children[1]
+
+		switch {
+		case messageID == 0:
+			// Notice of disconnection
+			if err := result(response); err != nil {
+				return nil, err
+			}
+			return nil, ErrProtocol
+		case messageID != id:
+			return nil, ErrProtocol
+		}
+
+		if response.Class != ber.ClassApplication {
+			return nil, ErrProtocol
+		}
+		responses = append(responses, response)
+		if response.Tag == final {
+			return responses, nil
+		}
+		if final != opSearchDone || (response.Tag != opSearchEntry && response.Tag != opSearchReference) {
+			return nil, ErrProtocol
+		}
+	}
+}
+
+// result converts an LDAPResult into an error.
+func result(p *ber.Packet) error {
+	if len(p.Children) < 3 {
+		return ErrProtocol
+	}
+	code, err := p.Children[0].Int()
+	if err != nil {
+		return ErrProtocol
+	}
+	if code == ResultSuccess {
+		return nil
+	}
+	return &Error{ResultCode: code, Message: p.Children[2].String()}
+}
+
+func parseEntry(p *ber.Packet) (*Entry, error) {
+	if len(p.Children) != 2 {
+		return nil, ErrProtocol
+	}
+
+	entry := &Entry{
+		DN:         p.Children[0].String(),
+		Attributes: make(map[string][]string),
+	}
+	for _, attribute := range p.Children[1].Children {
+		if len(attribute.Children) != 2 {
+			return nil, ErrProtocol
+		}
+		name := attribute.Children[0].String()
+		for _, value := range attribute.Children[1].Children {
+			entry.Attributes[name] = append(entry.Attributes[name], value.String())
+		}
+	}
+	return entry, nil
+}
+
+// clientTLSConfig verifies the server name by default.
+func clientTLSConfig(config *tls.Config, host string) *tls.Config {
+	if config == nil {
+		config = &tls.Config{}
+	}
+	if config.ServerName == "" {
+		config = config.Clone()
+		config.ServerName = host
+	}
+	return config
+}
@@ -0,0 +1,246 @@
+// Package ldap authenticates users against an LDAP directory such as
+// OpenLDAP or Active Directory.
+//
+// A Directory uses the search-then-bind pattern: it binds with a service
+// account, searches for the entry matching the user name, then binds as that
+// entry with the user's password. Connections may use ldaps:// or be upgraded
+// with StartTLS before any password is sent. The groups the entry belongs to
+// are mapped to a role and scopes for the access token.
+//
+// The package includes a minimal LDAPv3 client implementing the simple bind,
+// search and StartTLS operations it needs.
+package ldap
+
+import (
+	"crypto/tls"
+	"errors"
+	"slices"
+	"strings"
+	"time"
+)
+
+// DefaultTimeout bounds each connection when Config.Timeout is zero.
+const DefaultTimeout = 10 * time.Second
+
+// DefaultUserFilter finds person entries by uid; {username} is replaced by the escaped user name.
+const DefaultUserFilter = "(&(objectClass=person)(uid={username}))"
+
+var (
+	// ErrUserNotFound is returned by Lookup when no entry matches the user name.
+	ErrUserNotFound = errors.New("ldap user not found")
+
+	// ErrNotAuthorised is returned when RequireGroup is set and the user is in none of the mapped groups.
+	ErrNotAuthorised = errors.New("ldap user is not a member of an authorised group")
+)
+
+// GroupMapping grants a role and scopes to the members of a group.
+type GroupMapping struct {
+	Group  string // DN as listed in the group attribute, matched case-insensitively
+	Role   string
+	Scopes []string
+}
+
+// Config describes the directory and how users are found in it.
+type Config struct {
+	// URL is ldap://host[:389] or ldaps://host[:636]
+	URL string
+
+	// StartTLS upgrades ldap:// connections to TLS before binding
+	StartTLS bool
+
+	// TLSConfig is used for ldaps:// and StartTLS; nil verifies the URL's host name
+	TLSConfig *tls.Config
+
+	// Timeout bounds each connection; DefaultTimeout when zero
+	Timeout time.Duration
+
+	// BindDN and BindPassword are the service account used to search for
+	// users; an empty BindDN searches anonymously
+	BindDN       string
+	BindPassword string
+
+	// BaseDN is the subtree users are searched in
+	BaseDN string
+
+	// UserFilter selects a user's entry; DefaultUserFilter when empty
+	UserFilter string
+
+	// UsernameAttribute holds the canonical user name; "uid" when empty
+	UsernameAttribute string
+
+	// MailAttribute holds the user's mail address; "mail" when empty
+	MailAttribute string
+
+	// GroupAttribute lists the DNs of the user's groups; "memberOf" when empty
+	GroupAttribute string
+
+	// Groups maps group memberships to roles and scopes. The role of the
+	// first matching mapping wins; scopes of every matching mapping are granted
+	Groups []GroupMapping
+
+	// RequireGroup rejects users who are in none of the mapped groups
+	RequireGroup bool
+}
+
+// Identity is an authenticated directory user.
+type Identity struct {
+	DN     string
+	Name   string
+	Mail   string
+	Groups []string
+	Role   string
+	Scopes []string
+}
+
+// Directory authenticates users against an LDAP server. It opens a new
+// connection for every operation, so it is safe for concurrent use.
+type Directory struct {
+	config Config
+}
+
+// NewDirectory creates a directory for the given configuration.
+func NewDirectory(config Config) *Directory {
+	if config.Timeout == 0 {
+		config.Timeout = DefaultTimeout
+	}
+	if config.UserFilter == "" {
+		config.UserFilter = DefaultUserFilter
+	}
+	if config.UsernameAttribute == "" {
+		config.UsernameAttribute = "uid"
+	}
+	if config.MailAttribute == "" {
+		config.MailAttribute = "mail"
+	}
+	if config.GroupAttribute == "" {
+		config.GroupAttribute = "memberOf"
+	}
+	return &Directory{config: config}
+}
+
+// Authenticate checks the user's password by binding as their entry.
+// Unknown users and wrong passwords both return ErrInvalidCredentials.
+func (d *Directory) Authenticate(username, password string) (*Identity, error) {
+	if username == "" || password == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	conn, err := d.connect()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	entry, err := d.find(conn, username)
+	if errors.Is(err, ErrUserNotFound) {
+		return nil, ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := conn.Bind(entry.DN, password); err != nil {
+		return nil, err
+	}
+	return d.identity(entry, username)
+}
+
+// Lookup returns the current identity of a user without checking a
+// password, so refreshed tokens reflect group changes in the directory.
+func (d *Directory) Lookup(username string) (*Identity, error) {
+	conn, err := d.connect()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	entry, err := d.find(conn, username)
+	if err != nil {
+		return nil, err
+	}
+	return d.identity(entry, username)
+}
+
+// connect dials the server, upgrades the connection to TLS when configured
+// and binds the service account.
+func (d *Directory) connect() (*Conn, error) {
+	conn, err := Dial(d.config.URL, d.config.TLSConfig, d.config.Timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	if d.config.StartTLS && strings.HasPrefix(d.config.URL, "ldap://") {
+		if err := conn.StartTLS(d.config.TLSConfig); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	if d.config.BindDN != "" {
+		if err := conn.Bind(d.config.BindDN, d.config.BindPassword); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+	return conn, nil
+}
+
+// find returns the single entry matching the user name.
+func (d *Directory) find(conn *Conn, username string) (*Entry, error) {
+	entries, err := conn.Search(SearchRequest{
+		BaseDN:     d.config.BaseDN,
+		Scope:      ScopeWholeSubtree,
+		Filter:     strings.ReplaceAll(d.config.UserFilter, "{username}", EscapeFilter(username)),
+		Attributes: []string{d.config.UsernameAttribute, d.config.MailAttribute, d.config.GroupAttribute},
+		SizeLimit:  2,
+	})
+
+	var ldapErr *Error
+	switch {
+	case errors.As(err, &ldapErr) && ldapErr.ResultCode == ResultSizeLimitExceeded:
+		// Ambiguous filters must not let one user log in as another
+		return nil, ErrUserNotFound
+	case err != nil:
+		return nil, err
+	case len(entries) != 1:
+		return nil, ErrUserNotFound
+	}
+	return entries[0], nil
+}
+
+// identity maps an entry's groups to its role and scopes.
+func (d *Directory) identity(entry *Entry, username string) (*Identity, error) {
+	identity := &Identity{
+		DN:     entry.DN,
+		Name:   entry.Value(d.config.UsernameAttribute),
+		Mail:   entry.Value(d.config.MailAttribute),
+		Groups: entry.Values(d.config.GroupAttribute),
+	}
+	if identity.Name == "" {
+		identity.Name = username
+	}
+
+	matched := false
+	for _, mapping := range d.config.Groups {
+		if !slices.ContainsFunc(identity.Groups, func(group string) bool {
+			return strings.EqualFold(group, mapping.Group)
+		}) {
+			continue
+		}
+
+		matched = true
+		if identity.Role == "" {
+			identity.Role = mapping.Role
+		}
+		for _, scope := range mapping.Scopes {
+			if !slices.Contains(identity.Scopes, scope) {
+				identity.Scopes = append(identity.Scopes, scope)
+			}
+		}
+	}
+
+	if d.config.RequireGroup && !matched {
+		return nil, ErrNotAuthorised
+	}
+	return identity, nil
+}
@@ -0,0 +1,180 @@
+package ldap_test
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/responsible-api/responsible-auth/ldap"
+	"github.com/responsible-api/responsible-auth/ldap/ldaptest"
+)
+
+const (
+	serviceDN = "cn=service,dc=example,dc=com"
+	adminsDN  = "cn=admins,ou=groups,dc=example,dc=com"
+	staffDN   = "cn=staff,ou=groups,dc=example,dc=com"
+)
+
+func entries() []*ldaptest.Entry {
+	return []*ldaptest.Entry{
+		{DN: serviceDN, Password: "service-secret"},
+		{
+			DN:       "uid=jane,ou=people,dc=example,dc=com",
+			Password: "jane-secret",
+			Attributes: map[string][]string{
+				"objectClass": {"person"},
+				"uid":         {"jane"},
+				"mail":        {"jane@example.com"},
+				"memberOf":    {staffDN, adminsDN},
+			},
+		},
+		{
+			DN:       "uid=joe,ou=people,dc=example,dc=com",
+			Password: "joe-secret",
+			Attributes: map[string][]string{
+				"objectClass": {"person"},
+				"uid":         {"joe"},
+				"mail":        {"joe@example.com"},
+			},
+		},
+	}
+}
+
+func config(url string) ldap.Config {
+	return ldap.Config{
+		URL:          url,
+		BindDN:       serviceDN,
+		BindPassword: "service-secret",
+		BaseDN:       "dc=example,dc=com",
+		Groups: []ldap.GroupMapping{
+			{Group: "CN=Admins,OU=Groups,DC=example,DC=com", Role: "admin", Scopes: []string{"read", "write"}},
+			{Group: staffDN, Role: "staff", Scopes: []string{"read", "profile"}},
+		},
+	}
+}
+
+func TestDirectory_Authenticate(t *testing.T) {
+	server := ldaptest.NewServer(t, entries()...)
+	server.RequireBind = true
+	directory := ldap.NewDirectory(config(server.URL))
+
+	identity, err := directory.Authenticate("JANE", "jane-secret")
+	if err != nil {
+		t.Fatalf("Authenticate() unexpected error = %v", err)
+	}
+	if identity.Name != "jane" || identity.Mail != "jane@example.com" {
+		t.Errorf("Authenticate() identity = %+v, want jane with mail", identity)
+	}
+	if identity.Role != "admin" {
+		t.Errorf("Authenticate() role = %v, want admin", identity.Role)
+	}
+	if want := []string{"read", "write", "profile"}; !slices.Equal(identity.Scopes, want) {
+		t.Errorf("Authenticate() scopes = %v, want %v", identity.Scopes, want)
+	}
+
+	// The service account searches, then the user's own entry binds
+	if want := []string{serviceDN, "uid=jane,ou=people,dc=example,dc=com"}; !slices.Equal(server.Binds(), want) {
+		t.Errorf("Binds() = %v, want %v", server.Binds(), want)
+	}
+
+	tests := []struct {
+		name     string
+		username string
+		password string
+	}{
+		{name: "wrong password", username: "jane", password: "wrong"},
+		{name: "unknown user", username: "nobody", password: "jane-secret"},
+		{name: "empty password", username: "jane", password: ""},
+		{name: "wildcard injection", username: "*", password: "jane-secret"},
+		{name: "filter injection", username: "jane)(uid=*", password: "jane-secret"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := directory.Authenticate(tt.username, tt.password); !errors.Is(err, ldap.ErrInvalidCredentials) {
+				t.Errorf("Authenticate() error = %v, want %v", err, ldap.ErrInvalidCredentials)
+			}
+		})
+	}
+}
+
+func TestDirectory_RequireGroup(t *testing.T) {
+	server := ldaptest.NewServer(t, entries()...)
+	cfg := config(server.URL)
+	cfg.RequireGroup = true
+	directory := ldap.NewDirectory(cfg)
+
+	if _, err := directory.Authenticate("joe", "joe-secret"); !errors.Is(err, ldap.ErrNotAuthorised) {
+		t.Errorf("Authenticate() without group error = %v, want %v", err, ldap.ErrNotAuthorised)
+	}
+
+	// Without RequireGroup joe logs in with no role or scopes
+	identity, err := ldap.NewDirectory(config(server.URL)).Authenticate("joe", "joe-secret")
+	if err != nil {
+		t.Fatalf("Authenticate() unexpected error = %v", err)
+	}
+	if identity.Role != "" || len(identity.Scopes) != 0 {
+		t.Errorf("Authenticate() identity = %+v, want no role or scopes", identity)
+	}
+}
+
+func TestDirectory_TLS(t *testing.T) {
+	t.Run("StartTLS", func(t *testing.T) {
+		server := ldaptest.NewServer(t, entries()...)
+		server.RequireTLS = true
+
+		cfg := config(server.URL)
+		if _, err := ldap.NewDirectory(cfg).Authenticate("jane", "jane-secret"); err == nil {
+			t.Fatalf("Authenticate() over plain ldap succeeded, want confidentiality required")
+		}
+
+		cfg.StartTLS = true
+		cfg.TLSConfig = server.ClientTLSConfig()
+		if _, err := ldap.NewDirectory(cfg).Authenticate("jane", "jane-secret"); err != nil {
+			t.Fatalf("Authenticate() with StartTLS unexpected error = %v", err)
+		}
+		if binds := server.Binds(); len(binds) != 2 || binds[1] != "tls:uid=jane,ou=people,dc=example,dc=com" {
+			t.Errorf("Binds() = %v, want user bind over TLS", binds)
+		}
+	})
+
+	t.Run("LDAPS", func(t *testing.T) {
+		server := ldaptest.NewTLSServer(t, entries()...)
+		cfg := config(server.URL)
+
+		// The self-signed certificate is not trusted by default
+		if _, err := ldap.NewDirectory(cfg).Authenticate("jane", "jane-secret"); err == nil {
+			t.Fatalf("Authenticate() with untrusted certificate succeeded")
+		}
+
+		cfg.TLSConfig = server.ClientTLSConfig()
+		if _, err := ldap.NewDirectory(cfg).Authenticate("jane", "jane-secret"); err != nil {
+			t.Fatalf("Authenticate() over ldaps unexpected error = %v", err)
+		}
+	})
+}
+
+func TestDirectory_Lookup(t *testing.T) {
+	server := ldaptest.NewServer(t, entries()...)
+	directory := ldap.NewDirectory(config(server.URL))
+
+	identity, err := directory.Lookup("jane")
+	if err != nil {
+		t.Fatalf("Lookup() unexpected error = %v", err)
+	}
+	if identity.Role != "admin" {
+		t.Errorf("Lookup() role = %v, want admin", identity.Role)
+	}
+
+	server.Remove("uid=jane,ou=people,dc=example,dc=com")
+	if _, err := directory.Lookup("jane"); !errors.Is(err, ldap.ErrUserNotFound) {
+		t.Errorf("Lookup() removed user error = %v, want %v", err, ldap.ErrUserNotFound)
+	}
+
+	// A filter matching several entries must not pick one of them
+	cfg := config(server.URL)
+	cfg.UserFilter = "(objectClass=person)"
+	server.Add(entries()[1])
+	if _, err := ldap.NewDirectory(cfg).Lookup("jane"); !errors.Is(err, ldap.ErrUserNotFound) {
+		t.Errorf("Lookup() ambiguous filter error = %v, want %v", err, ldap.ErrUserNotFound)
+	}
+}
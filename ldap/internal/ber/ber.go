@@ -0,0 +1,252 @@
+// Package ber encodes and decodes the subset of ASN.1 BER used by LDAP
+// (RFC 4511 section 5.1): definite lengths and tag numbers below 31.
+package ber
+
+import (
+	"errors"
+	"io"
+)
+
+// Tag classes
+const (
+	ClassUniversal   byte = 0x00
+	ClassApplication byte = 0x40
+	ClassContext     byte = 0x80
+)
+
+// Universal tags
+const (
+	TagBoolean     = 1
+	TagInteger     = 2
+	TagOctetString = 4
+	TagNull        = 5
+	TagEnumerated  = 10
+	TagSequence    = 16
+	TagSet         = 17
+)
+
+// MaxPacketSize bounds the packets Read accepts from the network.
+const MaxPacketSize = 1 << 20
+
+const maxDepth = 32
+
+// ErrInvalid is returned for malformed or unsupported encodings.
+var ErrInvalid = errors.New("invalid ber encoding")
+
+// Packet is a decoded BER element. Primitive elements carry Value,
+// constructed ones Children.
+type Packet struct {
+	Class       byte
+	Constructed bool
+	Tag         int
+	Value       []byte
+	Children    []*Packet
+}
+
+// New creates a primitive element.
+func New(class byte, tag int, value []byte) *Packet {
+	return &Packet{Class: class, Tag: tag, Value: value}
+}
+
+// NewConstructed creates a constructed element holding children.
+func NewConstructed(class byte, tag int, children ...*Packet) *Packet {
+	return &Packet{Class: class, Constructed: true, Tag: tag, Children: children}
+}
+
+// Sequence creates a universal SEQUENCE.
+func Sequence(children ...*Packet) *Packet {
+	return NewConstructed(ClassUniversal, TagSequence, children...)
+}
+
+// Set creates a universal SET.
+func Set(children ...*Packet) *Packet {
+	return NewConstructed(ClassUniversal, TagSet, children...)
+}
+
+// OctetString creates a universal OCTET STRING.
+func OctetString(s string) *Packet {
+	return New(ClassUniversal, TagOctetString, []byte(s))
+}
+
+// Integer creates a universal INTEGER.
+func Integer(v int64) *Packet {
+	return New(ClassUniversal, TagInteger, encodeInt(v))
+}
+
+// Enumerated creates a universal ENUMERATED.
+func Enumerated(v int64) *Packet {
+	return New(ClassUniversal, TagEnumerated, encodeInt(v))
+}
+
+// Boolean creates a universal BOOLEAN.
+func Boolean(b bool) *Packet {
+	if b {
+		return New(ClassUniversal, TagBoolean, []byte{0xff})
+	}
+	return New(ClassUniversal, TagBoolean, []byte{0})
+}
+
+// Append adds children to a constructed element and returns it.
+func (p *Packet) Append(children ...*Packet) *Packet {
+	p.Children = append(p.Children, children...)
+	return p
+}
+
+// Is reports whether the element has the given class and tag.
+func (p *Packet) Is(class byte, tag int) bool {
+	return p.Class == class && p.Tag == tag
+}
+
+// Int decodes an INTEGER or ENUMERATED value.
+func (p *Packet) Int() (int64, error) {
+	if p.Constructed || len(p.Value) == 0 || len(p.Value) > 8 {
+		return 0, ErrInvalid
+	}
+	v := int64(int8(p.Value[0]))
+	for _, b := range p.Value[1:] {
+		v = v<<8 | int64(b)
+	}
+	return v, nil
+}
+
+// Bool decodes a BOOLEAN value.
+func (p *Packet) Bool() bool {
+	return len(p.Value) == 1 && p.Value[0] != 0
+}
+
+// String returns the value of a primitive element as text.
+func (p *Packet) String() string {
+	return string(p.Value)
+}
+
+// Bytes encodes the element.
+func (p *Packet) Bytes() []byte {
+	content := p.Value
+	if p.Constructed {
+		content = nil
+		for _, child := range p.Children {
+			content = append(content, child.Bytes()...)
+		}
+	}
+
+	identifier := p.Class | byte(p.Tag)
+	if p.Constructed {
+		identifier |= 0x20
+	}
+	b := append([]byte{identifier}, encodeLength(len(content))...)
+	return append(b, content...)
+}
+
+// Read reads one element from r.
+func Read(r io.Reader) (*Packet, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, err
+	}
+
+	length := int(header[1])
+	if header[1]&0x80 != 0 {
+		n := int(header[1] & 0x7f)
+		if n == 0 || n > 4 {
+			return nil, ErrInvalid
+		}
+		extra := make([]byte, n)
+		if _, err := io.ReadFull(r, extra); err != nil {
+			return nil, err
+		}
+		header = append(header, extra...)
+		length = 0
+		for _, b := range extra {
+			length = length<<8 | int(b)
+		}
+	}
+	if length > MaxPacketSize {
+		return nil, ErrInvalid
+	}
+
+	b := make([]byte, len(header)+length)
+	copy(b, header)
+	if _, err := io.ReadFull(r, b[len(header):]); err != nil {
+		return nil, err
+	}
+
+	p, rest, err := Parse(b)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) != 0 {
+		return nil, ErrInvalid
+	}
+	return p, nil
+}
+
+// Parse decodes the first element of b and returns the bytes after it.
+func Parse(b []byte) (*Packet, []byte, error) {
+	return parse(b, 0)
+}
+
+func parse(b []byte, depth int) (*Packet, []byte, error) {
+	if depth > maxDepth || len(b) < 2 {
+		return nil, nil, ErrInvalid
+	}
+	p := &Packet{
+		Class:       b[0] & 0xc0,
+		Constructed: b[0]&0x20 != 0,
+		Tag:         int(b[0] & 0x1f),
+	}
+	if p.Tag == 0x1f {
+		return nil, nil, ErrInvalid
+	}
+
+	length, b := int(b[1]), b[2:]
+	if length&0x80 != 0 {
+		n := length & 0x7f
+		if n == 0 || n > 4 || len(b) < n {
+			return nil, nil, ErrInvalid
+		}
+		length = 0
+		for _, c := range b[:n] {
+			length = length<<8 | int(c)
+		}
+		b = b[n:]
+	}
+	if length > len(b) {
+		return nil, nil, ErrInvalid
+	}
+	content, rest := b[:length], b[length:]
+
+	if !p.Constructed {
+		p.Value = content
+		return p, rest, nil
+	}
+	for len(content) > 0 {
+		child, after, err := parse(content, depth+1)
+		if err != nil {
+			return nil, nil, err
+		}
+		p.Children = append(p.Children, child)
+		content = after
+	}
+	return p, rest, nil
+}
+
+func encodeLength(n int) []byte {
+	if n < 0x80 {
+		return []byte{byte(n)}
+	}
+	var b []byte
+	for ; n > 0; n >>= 8 {
+		b = append([]byte{byte(n)}, b...)
+	}
+	return append([]byte{0x80 | byte(len(b))}, b...)
+}
+
+// encodeInt returns the minimal two's complement encoding of v.
+func encodeInt(v int64) []byte {
+	b := []byte{byte(v)}
+	for v > 0x7f || v < -0x80 {
+		v >>= 8
+		b = append([]byte{byte(v)}, b...)
+	}
+	return b
+}
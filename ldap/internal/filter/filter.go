@@ -0,0 +1,285 @@
+// Package filter compiles RFC 4515 search filter strings to their RFC 4511
+// BER form and evaluates BER filters against directory entries.
+package filter
+
+import (
+	"encoding/hex"
+	"errors"
+	"strings"
+
+	"github.com/responsible-api/responsible-auth/ldap/internal/ber"
+)
+
+// Filter choices (RFC 4511 section 4.5.1)
+const (
+	TagAnd            = 0
+	TagOr             = 1
+	TagNot            = 2
+	TagEqualityMatch  = 3
+	TagSubstrings     = 4
+	TagGreaterOrEqual = 5
+	TagLessOrEqual    = 6
+	TagPresent        = 7
+	TagApproxMatch    = 8
+)
+
+// Substring choices
+const (
+	substringInitial = 0
+	substringAny     = 1
+	substringFinal   = 2
+)
+
+const maxDepth = 16
+
+// ErrInvalid is returned for filters that cannot be parsed.
+var ErrInvalid = errors.New("invalid ldap filter")
+
+// Compile parses a filter string such as "(&(objectClass=person)(uid=jane))".
+func Compile(s string) (*ber.Packet, error) {
+	p, rest, err := compile(s, 0)
+	if err != nil {
+		return nil, err
+	}
+	if rest != "" {
+		return nil, ErrInvalid
+	}
+	return p, nil
+}
+
+func compile(s string, depth int) (*ber.Packet, string, error) {
+	if depth > maxDepth || len(s) < 3 || s[0] != '(' {
+		return nil, "", ErrInvalid
+	}
+	s = s[1:]
+
+	switch s[0] {
+	case '&', '|':
+		tag := TagAnd
+		if s[0] == '|' {
+			tag = TagOr
+		}
+		set := ber.NewConstructed(ber.ClassContext, tag)
+		s = s[1:]
+		for strings.HasPrefix(s, "(") {
+			child, rest, err := compile(s, depth+1)
+			if err != nil {
+				return nil, "", err
+			}
+			set.Append(child)
+			s = rest
+		}
+		if len(set.Children) == 0 || !strings.HasPrefix(s, ")") {
+			return nil, "", ErrInvalid
+		}
+		return set, s[1:], nil
+
+	case '!':
+		child, rest, err := compile(s[1:], depth+1)
+		if err != nil || !strings.HasPrefix(rest, ")") {
+			return nil, "", ErrInvalid
+		}
+		return ber.NewConstructed(ber.ClassContext, TagNot, child), rest[1:], nil
+	}
+
+	end := strings.IndexByte(s, ')')
+	if end < 0 {
+		return nil, "", ErrInvalid
+	}
+	item, rest := s[:end], s[end+1:]
+	p, err := compileItem(item)
+	return p, rest, err
+}
+
+func compileItem(item string) (*ber.Packet, error) {
+	eq := strings.IndexByte(item, '=')
+	if eq < 1 {
+		return nil, ErrInvalid
+	}
+	attribute, value := item[:eq], item[eq+1:]
+
+	tag := TagEqualityMatch
+	switch attribute[len(attribute)-1] {
+	case '>':
+		tag = TagGreaterOrEqual
+	case '<':
+		tag = TagLessOrEqual
+	case '~':
+		tag = TagApproxMatch
+	}
+	if tag != TagEqualityMatch {
+		attribute = attribute[:len(attribute)-1]
+	}
+	if attribute == "" || strings.ContainsAny(attribute, "()*\\") {
+		return nil, ErrInvalid
+	}
+
+	if tag == TagEqualityMatch && value == "*" {
+		return ber.New(ber.ClassContext, TagPresent, []byte(attribute)), nil
+	}
+
+	parts := strings.Split(value, "*")
+	if tag == TagEqualityMatch && len(parts) > 1 {
+		substrings := ber.Sequence()
+		for i, part := range parts {
+			if part == "" {
+				continue
+			}
+			decoded, err := unescape(part)
+			if err != nil {
+				return nil, err
+			}
+			choice := substringAny
+			switch i {
+			case 0:
+				choice = substringInitial
+			case len(parts) - 1:
+				choice = substringFinal
+			}
+			substrings.Append(ber.New(ber.ClassContext, choice, []byte(decoded)))
+		}
+		return ber.NewConstructed(ber.ClassContext, TagSubstrings, ber.OctetString(attribute), substrings), nil
+	}
+	if len(parts) > 1 {
+		return nil, ErrInvalid
+	}
+
+	decoded, err := unescape(value)
+	if err != nil {
+		return nil, err
+	}
+	return ber.NewConstructed(ber.ClassContext, tag, ber.OctetString(attribute), ber.OctetString(decoded)), nil
+}
+
+// unescape decodes the \XX escapes of an assertion value.
+func unescape(s string) (string, error) {
+	if strings.ContainsAny(s, "()") {
+		return "", ErrInvalid
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			b.WriteByte(s[i])
+			continue
+		}
+		if i+2 >= len(s) {
+			return "", ErrInvalid
+		}
+		decoded, err := hex.DecodeString(s[i+1 : i+3])
+		if err != nil {
+			return "", ErrInvalid
+		}
+		b.Write(decoded)
+		i += 2
+	}
+	return b.String(), nil
+}
+
+// Escape escapes a value for use in a filter string, so user input cannot
+// change the structure of the filter.
+func Escape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '\\', '*', '(', ')', 0:
+			b.WriteString("\\" + hex.EncodeToString([]byte{c}))
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return b.String()
+}
+
+// Match evaluates a BER filter against the attributes of an entry. Attribute
+// names and values are compared case-insensitively, as for the common
+// caseIgnoreMatch syntaxes.
+func Match(f *ber.Packet, attributes map[string][]string) bool {
+	if f.Class != ber.ClassContext {
+		return false
+	}
+
+	switch f.Tag {
+	case TagAnd:
+		for _, child := range f.Children {
+			if !Match(child, attributes) {
+				return false
+			}
+		}
+		return len(f.Children) > 0
+	case TagOr:
+		for _, child := range f.Children {
+			if Match(child, attributes) {
+				return true
+			}
+		}
+		return false
+	case TagNot:
+		return len(f.Children) == 1 && !Match(f.Children[0], attributes)
+	case TagPresent:
+		return len(values(attributes, f.String())) > 0
+	}
+
+	if len(f.Children) != 2 {
+		return false
+	}
+	attribute := f.Children[0].String()
+
+	for _, v := range values(attributes, attribute) {
+		v = strings.ToLower(v)
+		switch f.Tag {
+		case TagEqualityMatch, TagApproxMatch:
+			if v == strings.ToLower(f.Children[1].String()) {
+				return true
+			}
+		case TagGreaterOrEqual:
+			if v >= strings.ToLower(f.Children[1].String()) {
+				return true
+			}
+		case TagLessOrEqual:
+			if v <= strings.ToLower(f.Children[1].String()) {
+				return true
+			}
+		case TagSubstrings:
+			if matchSubstrings(v, f.Children[1].Children) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func matchSubstrings(v string, parts []*ber.Packet) bool {
+	for _, part := range parts {
+		s := strings.ToLower(part.String())
+		switch part.Tag {
+		case substringInitial:
+			if !strings.HasPrefix(v, s) {
+				return false
+			}
+			v = v[len(s):]
+		case substringAny:
+			i := strings.Index(v, s)
+			if i < 0 {
+				return false
+			}
+			v = v[i+len(s):]
+		case substringFinal:
+			if !strings.HasSuffix(v, s) {
+				return false
+			}
+			v = ""
+		}
+	}
+	return true
+}
+
+// values returns the values of an attribute, matching its name case-insensitively.
+func values(attributes map[string][]string, name string) []string {
+	for key, v := range attributes {
+		if strings.EqualFold(key, name) {
+			return v
+		}
+	}
+	return nil
+}
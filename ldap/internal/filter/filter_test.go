@@ -0,0 +1,60 @@
+package filter
+
+import "testing"
+
+func TestCompileAndMatch(t *testing.T) {
+	attributes := map[string][]string{
+		"objectClass":    {"top", "person"},
+		"uid":            {"jane"},
+		"cn":             {"Jane (Admin) Doe"},
+		"employeeNumber": {"042"},
+	}
+
+	tests := []struct {
+		filter      string
+		expected    bool
+		expectError bool
+	}{
+		{filter: "(uid=jane)", expected: true},
+		{filter: "(UID=JANE)", expected: true},
+		{filter: "(uid=joe)", expected: false},
+		{filter: "(&(objectClass=person)(uid=jane))", expected: true},
+		{filter: "(&(objectClass=person)(uid=joe))", expected: false},
+		{filter: "(|(uid=joe)(uid=jane))", expected: true},
+		{filter: "(!(uid=joe))", expected: true},
+		{filter: "(mail=*)", expected: false},
+		{filter: "(uid=*)", expected: true},
+		{filter: "(cn=jane*doe)", expected: true},
+		{filter: "(cn=*\\28admin\\29*)", expected: true},
+		{filter: "(cn=*admin)", expected: false},
+		{filter: "(employeeNumber>=041)", expected: true},
+		{filter: "(employeeNumber<=041)", expected: false},
+		{filter: "(uid=" + Escape("jane)(uid=*") + ")", expected: false},
+		{filter: "uid=jane", expectError: true},
+		{filter: "(uid=jane", expectError: true},
+		{filter: "(&)", expectError: true},
+		{filter: "(uid=jane))", expectError: true},
+		{filter: "(uid=\\zz)", expectError: true},
+		{filter: "(uid=\\2)", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			compiled, err := Compile(tt.filter)
+
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("Compile() expected error, got none")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Compile() unexpected error = %v", err)
+			}
+			if got := Match(compiled, attributes); got != tt.expected {
+				t.Errorf("Match() = %v, want %v", got, tt.expected)
+			}
+		})
+	}
+}
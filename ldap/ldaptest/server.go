@@ -0,0 +1,397 @@
+// Package ldaptest runs an in-process LDAP server for testing directory
+// authentication. It supports simple binds, searches with RFC 4515 filters,
+// StartTLS and ldaps://, backed by a fixed set of entries.
+package ldaptest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/responsible-api/responsible-auth/ldap/internal/ber"
+	"github.com/responsible-api/responsible-auth/ldap/internal/filter"
+)
+
+const startTLSOID = "1.3.6.1.4.1.1466.20037"
+
+// Result codes sent by the server
+const (
+	resultSuccess                 = 0
+	resultProtocolError           = 2
+	resultSizeLimitExceeded       = 4
+	resultConfidentialityRequired = 13
+	resultInvalidCredentials      = 49
+	resultInsufficientAccess      = 50
+)
+
+// Entry is a directory entry. Entries with a password can bind.
+type Entry struct {
+	DN         string
+	Password   string
+	Attributes map[string][]string
+}
+
+// Server is a running test directory.
+type Server struct {
+	// URL is the ldap:// or ldaps:// address of the server
+	URL string
+
+	// RequireTLS rejects binds on connections that have not started TLS
+	RequireTLS bool
+
+	// RequireBind rejects searches on connections without an authenticated bind
+	RequireBind bool
+
+	listener  net.Listener
+	tlsConfig *tls.Config
+	roots     *x509.CertPool
+
+	mu      sync.Mutex
+	entries []*Entry
+	binds   []string
+	conns   map[net.Conn]struct{}
+	wg      sync.WaitGroup
+}
+
+// NewServer starts a plain ldap:// server that offers StartTLS. It is
+// closed when the test ends.
+func NewServer(t testing.TB, entries ...*Entry) *Server {
+	s := newServer(t, entries)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("ldaptest: listen: %v", err)
+	}
+	s.start(t, listener, "ldap://")
+	return s
+}
+
+// NewTLSServer starts an ldaps:// server. It is closed when the test ends.
+func NewTLSServer(t testing.TB, entries ...*Entry) *Server {
+	s := newServer(t, entries)
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", s.tlsConfig)
+	if err != nil {
+		t.Fatalf("ldaptest: listen: %v", err)
+	}
+	s.start(t, listener, "ldaps://")
+	return s
+}
+
+// ClientTLSConfig trusts the server's self-signed certificate.
+func (s *Server) ClientTLSConfig() *tls.Config {
+	return &tls.Config{RootCAs: s.roots}
+}
+
+// Add stores another entry.
+func (s *Server) Add(e *Entry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = append(s.entries, e)
+}
+
+// Remove deletes the entry with the given DN.
+func (s *Server) Remove(dn string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, e := range s.entries {
+		if strings.EqualFold(e.DN, dn) {
+			s.entries = append(s.entries[:i], s.entries[i+1:]...)
+			return
+		}
+	}
+}
+
+// Binds lists the DNs of every successful bind, with a "tls:" prefix for
+// binds on encrypted connections.
+func (s *Server) Binds() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.binds...)
+}
+
+// Close stops the server, closing open connections.
+func (s *Server) Close() {
+	s.listener.Close()
+	s.mu.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+func newServer(t testing.TB, entries []*Entry) *Server {
+	t.Helper()
+	certificate, roots, err := selfSigned()
+	if err != nil {
+		t.Fatalf("ldaptest: certificate: %v", err)
+	}
+	return &Server{
+		entries:   entries,
+		conns:     make(map[net.Conn]struct{}),
+		tlsConfig: &tls.Config{Certificates: []tls.Certificate{certificate}},
+		roots:     roots,
+	}
+}
+
+func (s *Server) start(t testing.TB, listener net.Listener, scheme string) {
+	s.listener = listener
+	s.URL = scheme + listener.Addr().String()
+	t.Cleanup(s.Close)
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.conns[conn] = struct{}{}
+			s.mu.Unlock()
+
+			s.wg.Add(1)
+			go func() {
+				defer s.wg.Done()
+				s.serve(conn)
+
+				s.mu.Lock()
+				delete(s.conns, conn)
+				s.mu.Unlock()
+			}()
+		}
+	}()
+}
+
+// session is the state of one client connection.
+type session struct {
+	conn  net.Conn
+	tls   bool
+	bound string
+}
+
+func (s *Server) serve(conn net.Conn) {
+	_, isTLS := conn.(*tls.Conn)
+	sess := &session{conn: conn, tls: isTLS}
+	defer func() { sess.conn.Close() }()
+
+	for {
+		if err := sess.conn.SetDeadline(time.Now().Add(time.Minute)); err != nil {
+			return
+		}
+		message, err := ber.Read(sess.conn)
+		if err != nil || len(message.Children) < 2 {
+			return
+		}
+		id, err := message.Children[0].Int()
+		if err != nil {
+			return
+		}
+		op := message.Children[1]
+		if op.Class != ber.ClassApplication {
+			return
+		}
+
+		switch op.Tag {
+		case 0:
+			s.bind(sess, id, op)
+		case 2:
+			return
+		case 3:
+			s.search(sess, id, op)
+		case 23:
+			if !s.extended(sess, id, op) {
+				return
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (s *Server) bind(sess *session, id int64, op *ber.Packet) {
+	if len(op.Children) != 3 || !op.Children[2].Is(ber.ClassContext, 0) {
+		reply(sess, id, 1, resultProtocolError)
+		return
+	}
+	dn, password := op.Children[1].String(), op.Children[2].String()
+
+	if s.RequireTLS && !sess.tls {
+		reply(sess, id, 1, resultConfidentialityRequired)
+		return
+	}
+
+	// An empty password is an unauthenticated bind, which succeeds anonymously (RFC 4513 5.1.2)
+	if password == "" {
+		sess.bound = ""
+		reply(sess, id, 1, resultSuccess)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range s.entries {
+		if strings.EqualFold(e.DN, dn) && e.Password != "" && e.Password == password {
+			sess.bound = e.DN
+			if sess.tls {
+				s.binds = append(s.binds, "tls:"+e.DN)
+			} else {
+				s.binds = append(s.binds, e.DN)
+			}
+			reply(sess, id, 1, resultSuccess)
+			return
+		}
+	}
+	sess.bound = ""
+	reply(sess, id, 1, resultInvalidCredentials)
+}
+
+func (s *Server) search(sess *session, id int64, op *ber.Packet) {
+	if len(op.Children) != 8 {
+		reply(sess, id, 5, resultProtocolError)
+		return
+	}
+	if s.RequireBind && sess.bound == "" {
+		reply(sess, id, 5, resultInsufficientAccess)
+		return
+	}
+
+	base := strings.ToLower(op.Children[0].String())
+	scope, _ := op.Children[1].Int()
+	sizeLimit, _ := op.Children[3].Int()
+	f := op.Children[6]
+	var requested []string
+	for _, attribute := range op.Children[7].Children {
+		requested = append(requested, attribute.String())
+	}
+
+	s.mu.Lock()
+	var matches []*Entry
+	for _, e := range s.entries {
+		if inScope(strings.ToLower(e.DN), base, scope) && filter.Match(f, e.Attributes) {
+			matches = append(matches, e)
+		}
+	}
+	s.mu.Unlock()
+
+	code := int64(resultSuccess)
+	if sizeLimit > 0 && int64(len(matches)) > sizeLimit {
+		matches = matches[:sizeLimit]
+		code = resultSizeLimitExceeded
+	}
+	for _, e := range matches {
+		write(sess, id, searchEntry(e, requested))
+	}
+	reply(sess, id, 5, code)
+}
+
+// extended handles StartTLS and reports whether the connection is still usable.
+func (s *Server) extended(sess *session, id int64, op *ber.Packet) bool {
+	if len(op.Children) == 0 || op.Children[0].String() != startTLSOID || sess.tls {
+		reply(sess, id, 24, resultProtocolError)
+		return true
+	}
+
+	reply(sess, id, 24, resultSuccess)
+	tlsConn := tls.Server(sess.conn, s.tlsConfig)
+	if err := tlsConn.Handshake(); err != nil {
+		return false
+	}
+	sess.conn = tlsConn
+	sess.tls = true
+	return true
+}
+
+// inScope reports whether dn lies within the search base and scope.
+func inScope(dn, base string, scope int64) bool {
+	switch scope {
+	case 0:
+		return dn == base
+	case 1:
+		parent := ""
+		if i := strings.IndexByte(dn, ','); i >= 0 {
+			parent = dn[i+1:]
+		}
+		return parent == base
+	}
+	return base == "" || dn == base || strings.HasSuffix(dn, ","+base)
+}
+
+func searchEntry(e *Entry, requested []string) *ber.Packet {
+	attributes := ber.Sequence()
+	for name, values := range e.Attributes {
+		if len(requested) > 0 && !containsFold(requested, name) {
+			continue
+		}
+		set := ber.Set()
+		for _, v := range values {
+			set.Append(ber.OctetString(v))
+		}
+		attributes.Append(ber.Sequence(ber.OctetString(name), set))
+	}
+	return ber.NewConstructed(ber.ClassApplication, 4, ber.OctetString(e.DN), attributes)
+}
+
+func containsFold(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// reply sends an LDAPResult with the given response tag.
+func reply(sess *session, id int64, tag int, code int64) {
+	write(sess, id, ber.NewConstructed(ber.ClassApplication, tag,
+		ber.Enumerated(code),
+		ber.OctetString(""),
+		ber.OctetString(""),
+	))
+}
+
+func write(sess *session, id int64, op *ber.Packet) {
+	_, _ = sess.conn.Write(ber.Sequence(ber.Integer(id), op).Bytes())
+}
+
+// selfSigned creates a certificate for 127.0.0.1 and localhost.
+func selfSigned() (tls.Certificate, *x509.CertPool, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ldaptest"},
+		NotBefore:             time.Now().Add(-time.Minute),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		DNSNames:              []string{"localhost"},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+	parsed, err := x509.ParseCertificate(der)
+	if err != nil {
+		return tls.Certificate{}, nil, err
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(parsed)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, roots, nil
+}
@@ -0,0 +1,189 @@
+// Package lockout protects credential checks against brute-force attacks.
+//
+// A Guard counts failed attempts per key, typically one key for the account
+// identifier and one for the client IP. MaxAttempts failures within Window
+// lock the key for LockDuration, doubling with every consecutive lockout up
+// to MaxLockDuration. Counters live in a storage.AttemptStorage so several
+// API instances can share them.
+package lockout
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/responsible-api/responsible-auth/resource/attempt"
+	"github.com/responsible-api/responsible-auth/storage"
+)
+
+// ErrAccountLocked is matched by every LockedError.
+var ErrAccountLocked = errors.New("account is locked")
+
+// LockedError is returned while a key is locked and says when the lock expires.
+type LockedError struct {
+	Until time.Time
+}
+
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("%s until %s", ErrAccountLocked, e.Until.UTC().Format(time.RFC3339))
+}
+
+// Is makes errors.Is(err, ErrAccountLocked) true for a LockedError.
+func (e *LockedError) Is(target error) bool {
+	return target == ErrAccountLocked
+}
+
+// Policy configures when keys are locked and for how long.
+type Policy struct {
+	// MaxAttempts failures within Window lock the key
+	MaxAttempts int
+	Window      time.Duration
+
+	// LockDuration is the first lock; each consecutive lockout doubles it up to MaxLockDuration
+	LockDuration    time.Duration
+	MaxLockDuration time.Duration
+}
+
+// DefaultPolicy locks a key for one minute after five failures in fifteen minutes,
+// backing off to at most one hour.
+func DefaultPolicy() Policy {
+	return Policy{
+		MaxAttempts:     5,
+		Window:          15 * time.Minute,
+		LockDuration:    time.Minute,
+		MaxLockDuration: time.Hour,
+	}
+}
+
+// Guard tracks failed attempts and enforces a Policy.
+type Guard struct {
+	store  storage.AttemptStorage
+	policy Policy
+	now    func() time.Time
+}
+
+// NewGuard creates a guard that keeps its counters in store.
+func NewGuard(store storage.AttemptStorage, policy Policy) *Guard {
+	return &Guard{
+		store:  store,
+		policy: policy,
+		now:    time.Now,
+	}
+}
+
+// AccountKey returns the attempt key for a login identifier such as a mail address.
+func AccountKey(identifier string) string {
+	identifier = strings.ToLower(strings.TrimSpace(identifier))
+	if identifier == "" {
+		return ""
+	}
+	return "account:" + identifier
+}
+
+// IPKey returns the attempt key for a client IP, or "" when the IP is unknown.
+func IPKey(ip string) string {
+	if ip == "" {
+		return ""
+	}
+	return "ip:" + ip
+}
+
+// Check returns a LockedError when any of the keys is locked.
+// Empty keys are ignored.
+func (g *Guard) Check(keys ...string) error {
+	now := g.now().Unix()
+
+	var until int64
+	for _, key := range keys {
+		if key == "" {
+			continue
+		}
+		a, err := g.store.FindAttempt(key)
+		if err != nil {
+			return err
+		}
+		if a != nil && a.IsLocked(now) && a.LockedUntil > until {
+			until = a.LockedUntil
+		}
+	}
+
+	if until != 0 {
+		return &LockedError{Until: time.Unix(until, 0)}
+	}
+	return nil
+}
+
+// Fail records a failed attempt against every key, locking those that reach MaxAttempts.
+func (g *Guard) Fail(keys ...string) error {
+	now := g.now().Unix()
+	window := int64(g.policy.Window / time.Second)
+
+	for _, key := range keys {
+		if key == "" {
+			continue
+		}
+		_, err := g.store.UpdateAttempt(key, func(a *attempt.Attempt) {
+			if a.IsLocked(now) {
+				return
+			}
+			// A quiet window after the last lock expired forgives earlier lockouts
+			if a.LockedUntil != 0 && now-a.LockedUntil >= window {
+				a.Lockouts = 0
+				a.LockedUntil = 0
+			}
+			if a.Failures == 0 || now-a.WindowStart >= window {
+				a.Failures = 0
+				a.WindowStart = now
+			}
+
+			a.Failures++
+			if a.Failures >= g.policy.MaxAttempts {
+				a.LockedUntil = now + int64(g.lockDuration(a.Lockouts)/time.Second)
+				a.Lockouts++
+				a.Failures = 0
+			}
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Succeed clears the counters for key after a successful login.
+// Only the account key should be cleared; an IP that guessed one password
+// must not have its failures against other accounts forgiven.
+func (g *Guard) Succeed(key string) error {
+	if key == "" {
+		return nil
+	}
+	return g.store.DeleteAttempt(key)
+}
+
+// UnlockAccount lifts any lock on the login identifier and resets its counters.
+// service.BasicAuth counts a user's failures under their mail address, or
+// their name when they have none, and failures by account ID under that ID.
+func (g *Guard) UnlockAccount(identifier string) error {
+	return g.store.DeleteAttempt(AccountKey(identifier))
+}
+
+// UnlockIP lifts any lock on the client IP and resets its counters.
+func (g *Guard) UnlockIP(ip string) error {
+	return g.store.DeleteAttempt(IPKey(ip))
+}
+
+// lockDuration returns LockDuration doubled for each previous lockout, capped at MaxLockDuration.
+func (g *Guard) lockDuration(lockouts int) time.Duration {
+	d := g.policy.LockDuration
+	for i := 0; i < lockouts; i++ {
+		if g.policy.MaxLockDuration > 0 && d >= g.policy.MaxLockDuration {
+			break
+		}
+		d *= 2
+	}
+	if g.policy.MaxLockDuration > 0 && d > g.policy.MaxLockDuration {
+		d = g.policy.MaxLockDuration
+	}
+	return d
+}
@@ -0,0 +1,172 @@
+package lockout
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/responsible-api/responsible-auth/examples/memory"
+)
+
+func newTestGuard() (*Guard, *time.Time) {
+	clock := time.Unix(1700000000, 0)
+	guard := NewGuard(memory.NewInMemoryAttemptStorage(), Policy{
+		MaxAttempts:     3,
+		Window:          10 * time.Minute,
+		LockDuration:    time.Minute,
+		MaxLockDuration: 4 * time.Minute,
+	})
+	guard.now = func() time.Time { return clock }
+	return guard, &clock
+}
+
+func failN(t *testing.T, g *Guard, n int, keys ...string) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		if err := g.Fail(keys...); err != nil {
+			t.Fatalf("Fail() unexpected error = %v", err)
+		}
+	}
+}
+
+func TestGuard_LocksAfterMaxAttempts(t *testing.T) {
+	guard, clock := newTestGuard()
+	key := AccountKey("alice@example.com")
+
+	failN(t, guard, 2, key)
+	if err := guard.Check(key); err != nil {
+		t.Fatalf("Check() before MaxAttempts error = %v, want nil", err)
+	}
+
+	failN(t, guard, 1, key)
+	err := guard.Check(key)
+	if !errors.Is(err, ErrAccountLocked) {
+		t.Fatalf("Check() error = %v, want %v", err, ErrAccountLocked)
+	}
+
+	var locked *LockedError
+	if !errors.As(err, &locked) || !locked.Until.Equal(clock.Add(time.Minute)) {
+		t.Errorf("Check() lock until = %v, want %v", locked, clock.Add(time.Minute))
+	}
+
+	*clock = clock.Add(time.Minute)
+	if err := guard.Check(key); err != nil {
+		t.Errorf("Check() after lock expired error = %v, want nil", err)
+	}
+}
+
+func TestGuard_WindowExpiry(t *testing.T) {
+	guard, clock := newTestGuard()
+	key := AccountKey("alice@example.com")
+
+	failN(t, guard, 2, key)
+	*clock = clock.Add(10 * time.Minute)
+	failN(t, guard, 2, key)
+
+	if err := guard.Check(key); err != nil {
+		t.Errorf("Check() with failures in separate windows error = %v, want nil", err)
+	}
+}
+
+func TestGuard_ExponentialBackoff(t *testing.T) {
+	guard, clock := newTestGuard()
+	key := AccountKey("alice@example.com")
+
+	want := []time.Duration{time.Minute, 2 * time.Minute, 4 * time.Minute, 4 * time.Minute}
+	for i, duration := range want {
+		failN(t, guard, 3, key)
+
+		var locked *LockedError
+		if err := guard.Check(key); !errors.As(err, &locked) {
+			t.Fatalf("Check() lockout %d error = %v, want LockedError", i+1, err)
+		}
+		if got := locked.Until.Sub(*clock); got != duration {
+			t.Errorf("lockout %d duration = %v, want %v", i+1, got, duration)
+		}
+		*clock = locked.Until
+	}
+
+	// Failures while locked do not extend the lock
+	failN(t, guard, 3, key)
+	until := *clock
+	failN(t, guard, 3, key)
+	var locked *LockedError
+	if err := guard.Check(key); !errors.As(err, &locked) || !locked.Until.Equal(until.Add(4*time.Minute)) {
+		t.Errorf("Check() after failing while locked = %v, want lock until %v", err, until.Add(4*time.Minute))
+	}
+
+	// A quiet window after the lock expires resets the backoff
+	*clock = locked.Until.Add(10 * time.Minute)
+	failN(t, guard, 3, key)
+	if err := guard.Check(key); !errors.As(err, &locked) || locked.Until.Sub(*clock) != time.Minute {
+		t.Errorf("Check() after quiet window = %v, want a one minute lock", err)
+	}
+}
+
+func TestGuard_IPKey(t *testing.T) {
+	guard, _ := newTestGuard()
+	ip := IPKey("192.0.2.1")
+
+	// Failures against different accounts from one IP lock the IP
+	failN(t, guard, 1, AccountKey("alice@example.com"), ip)
+	failN(t, guard, 1, AccountKey("bob@example.com"), ip)
+	failN(t, guard, 1, AccountKey("carol@example.com"), ip)
+
+	if err := guard.Check(AccountKey("dave@example.com"), ip); !errors.Is(err, ErrAccountLocked) {
+		t.Errorf("Check() from locked IP error = %v, want %v", err, ErrAccountLocked)
+	}
+	if err := guard.Check(AccountKey("dave@example.com"), IPKey("192.0.2.2")); err != nil {
+		t.Errorf("Check() from other IP error = %v, want nil", err)
+	}
+
+	// Success on one account does not forgive the IP
+	if err := guard.Succeed(AccountKey("alice@example.com")); err != nil {
+		t.Fatalf("Succeed() unexpected error = %v", err)
+	}
+	if err := guard.Check(ip); !errors.Is(err, ErrAccountLocked) {
+		t.Errorf("Check() IP after account success error = %v, want %v", err, ErrAccountLocked)
+	}
+}
+
+func TestGuard_Unlock(t *testing.T) {
+	guard, _ := newTestGuard()
+	account := AccountKey("Alice@Example.com")
+	ip := IPKey("192.0.2.1")
+
+	failN(t, guard, 3, account, ip)
+
+	if err := guard.UnlockAccount("alice@example.com"); err != nil {
+		t.Fatalf("UnlockAccount() unexpected error = %v", err)
+	}
+	if err := guard.Check(account); err != nil {
+		t.Errorf("Check() after UnlockAccount() error = %v, want nil", err)
+	}
+
+	if err := guard.UnlockIP("192.0.2.1"); err != nil {
+		t.Fatalf("UnlockIP() unexpected error = %v", err)
+	}
+	if err := guard.Check(ip); err != nil {
+		t.Errorf("Check() after UnlockIP() error = %v, want nil", err)
+	}
+}
+
+func TestKeys(t *testing.T) {
+	tests := []struct {
+		name string
+		got  string
+		want string
+	}{
+		{name: "account normalised", got: AccountKey("  Alice@Example.COM "), want: "account:alice@example.com"},
+		{name: "empty account", got: AccountKey(""), want: ""},
+		{name: "ip", got: IPKey("192.0.2.1"), want: "ip:192.0.2.1"},
+		{name: "empty ip", got: IPKey(""), want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.got != tt.want {
+				t.Errorf("key = %q, want %q", tt.got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,261 @@
+// Package mfa adds a TOTP (RFC 6238) second factor to password logins.
+//
+// A Manager enrols users by generating a shared secret, the otpauth:// URI
+// their authenticator app imports and a set of single-use recovery codes,
+// which are only stored hashed. The enrolment is enforced once the user has
+// confirmed it with a first code. After MaxAttempts invalid codes in a row
+// the enrolment refuses every code for LockDuration, so the six-digit codes
+// cannot be guessed even without a lockout guard on the password step.
+// Enrolments live in a storage.MFAStorage so several API instances can share them.
+package mfa
+
+import (
+	"crypto/subtle"
+	"errors"
+	"time"
+
+	"github.com/responsible-api/responsible-auth/resource/totp"
+	"github.com/responsible-api/responsible-auth/storage"
+)
+
+// Default limits on invalid codes, changed with Manager.SetAttemptLimit.
+const (
+	DefaultMaxAttempts  = 5
+	DefaultLockDuration = 15 * time.Minute
+)
+
+// Authentication method references (RFC 8176) recorded in the amr claim.
+const (
+	MethodPassword = "pwd"
+	MethodOTP      = "otp"
+	MethodMFA      = "mfa"
+)
+
+var (
+	// ErrNotEnrolled is returned when a user has no confirmed enrolment.
+	ErrNotEnrolled = errors.New("mfa is not enabled for this user")
+
+	// ErrAlreadyEnrolled is returned when enrolling a user whose enrolment is already confirmed.
+	ErrAlreadyEnrolled = errors.New("mfa is already enabled for this user")
+
+	// ErrInvalidCode is returned for wrong, expired or already used codes.
+	ErrInvalidCode = errors.New("invalid mfa code")
+
+	// ErrTooManyAttempts is returned, even for valid codes, while an
+	// enrolment is locked after too many invalid codes.
+	ErrTooManyAttempts = errors.New("too many invalid mfa codes")
+)
+
+// Setup is the result of an enrolment. It holds the plain secret and
+// recovery codes, which are shown to the user once and not retrievable later.
+type Setup struct {
+	Secret        string
+	URI           string
+	RecoveryCodes []string
+}
+
+// Manager enrols users and verifies their codes.
+type Manager struct {
+	store        storage.MFAStorage
+	issuer       string
+	maxAttempts  int
+	lockDuration time.Duration
+	now          func() time.Time
+}
+
+// NewManager creates a manager that keeps enrolments in store. The issuer
+// names the service in authenticator apps.
+func NewManager(store storage.MFAStorage, issuer string) *Manager {
+	return &Manager{
+		store:        store,
+		issuer:       issuer,
+		maxAttempts:  DefaultMaxAttempts,
+		lockDuration: DefaultLockDuration,
+		now:          time.Now,
+	}
+}
+
+// SetAttemptLimit locks an enrolment for lockDuration after maxAttempts
+// invalid codes in a row.
+func (m *Manager) SetAttemptLimit(maxAttempts int, lockDuration time.Duration) {
+	m.maxAttempts = maxAttempts
+	m.lockDuration = lockDuration
+}
+
+// Enroll starts an enrolment for the named user, replacing an unconfirmed one.
+func (m *Manager) Enroll(userName string) (*Setup, error) {
+	existing, err := m.store.FindEnrolment(userName)
+	switch {
+	case err == nil && existing.Confirmed:
+		return nil, ErrAlreadyEnrolled
+	case err == nil:
+		if err := m.store.DeleteEnrolment(userName); err != nil && !errors.Is(err, storage.ErrEnrolmentNotFound) {
+			return nil, err
+		}
+	case !errors.Is(err, storage.ErrEnrolmentNotFound):
+		return nil, err
+	}
+
+	secret, err := GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	codes, err := generateRecoveryCodes(RecoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+
+	err = m.store.CreateEnrolment(&totp.Enrolment{
+		UserName:      userName,
+		Secret:        secret,
+		RecoveryCodes: hashRecoveryCodes(codes),
+		Created:       m.now().Unix(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Setup{
+		Secret:        secret,
+		URI:           URI(m.issuer, userName, secret),
+		RecoveryCodes: codes,
+	}, nil
+}
+
+// Confirm enables MFA for the named user once code proves their authenticator
+// produces valid codes.
+func (m *Manager) Confirm(userName, code string) error {
+	now := m.now()
+	var result error
+	_, err := m.store.UpdateEnrolment(userName, func(e *totp.Enrolment) {
+		if e.Confirmed {
+			result = ErrAlreadyEnrolled
+			return
+		}
+		s, ok := validate(e.Secret, code, now, e.LastStep)
+		if !ok {
+			result = ErrInvalidCode
+			return
+		}
+		e.Confirmed = true
+		e.LastStep = s
+	})
+	if errors.Is(err, storage.ErrEnrolmentNotFound) {
+		return ErrNotEnrolled
+	}
+	if err != nil {
+		return err
+	}
+	return result
+}
+
+// Enabled reports whether the named user must pass a second factor to log in.
+func (m *Manager) Enabled(userName string) (bool, error) {
+	e, err := m.store.FindEnrolment(userName)
+	if errors.Is(err, storage.ErrEnrolmentNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return e.Confirmed, nil
+}
+
+// Verify checks a TOTP code or, failing that, a recovery code for the named
+// user. Each TOTP code and each recovery code is accepted at most once.
+// While the enrolment is locked after too many invalid codes, every code is
+// refused with ErrTooManyAttempts.
+func (m *Manager) Verify(userName, code string) error {
+	now := m.now()
+	var result error
+	_, err := m.store.UpdateEnrolment(userName, func(e *totp.Enrolment) {
+		if !e.Confirmed {
+			result = ErrNotEnrolled
+			return
+		}
+		if e.LockedUntil > now.Unix() {
+			result = ErrTooManyAttempts
+			return
+		}
+		if s, ok := validate(e.Secret, code, now, e.LastStep); ok {
+			e.LastStep = s
+			e.Failures = 0
+			result = nil
+			return
+		}
+		if i := findRecoveryCode(e.RecoveryCodes, code); i >= 0 {
+			e.RecoveryCodes = append(e.RecoveryCodes[:i], e.RecoveryCodes[i+1:]...)
+			e.Failures = 0
+			result = nil
+			return
+		}
+
+		e.Failures++
+		if e.Failures >= m.maxAttempts {
+			e.LockedUntil = now.Add(m.lockDuration).Unix()
+			e.Failures = 0
+		}
+		result = ErrInvalidCode
+	})
+	if errors.Is(err, storage.ErrEnrolmentNotFound) {
+		return ErrNotEnrolled
+	}
+	if err != nil {
+		return err
+	}
+	return result
+}
+
+// RemainingRecoveryCodes returns how many unused recovery codes the named user has.
+func (m *Manager) RemainingRecoveryCodes(userName string) (int, error) {
+	e, err := m.store.FindEnrolment(userName)
+	if errors.Is(err, storage.ErrEnrolmentNotFound) {
+		return 0, ErrNotEnrolled
+	}
+	if err != nil {
+		return 0, err
+	}
+	return len(e.RecoveryCodes), nil
+}
+
+// RegenerateRecoveryCodes replaces the named user's recovery codes with a new set.
+func (m *Manager) RegenerateRecoveryCodes(userName string) ([]string, error) {
+	codes, err := generateRecoveryCodes(RecoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = m.store.UpdateEnrolment(userName, func(e *totp.Enrolment) {
+		e.RecoveryCodes = hashRecoveryCodes(codes)
+	})
+	if errors.Is(err, storage.ErrEnrolmentNotFound) {
+		return nil, ErrNotEnrolled
+	}
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// Disable removes the named user's enrolment, so they log in with a password alone.
+func (m *Manager) Disable(userName string) error {
+	err := m.store.DeleteEnrolment(userName)
+	if errors.Is(err, storage.ErrEnrolmentNotFound) {
+		return ErrNotEnrolled
+	}
+	return err
+}
+
+// findRecoveryCode returns the index of code's hash in hashes, or -1.
+func findRecoveryCode(hashes []string, code string) int {
+	if normaliseRecoveryCode(code) == "" {
+		return -1
+	}
+	hash := hashRecoveryCode(code)
+	for i, h := range hashes {
+		if subtle.ConstantTimeCompare([]byte(h), []byte(hash)) == 1 {
+			return i
+		}
+	}
+	return -1
+}
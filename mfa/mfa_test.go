@@ -0,0 +1,235 @@
+package mfa
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/responsible-api/responsible-auth/examples/memory"
+)
+
+// rfcSecret is the SHA-1 key of the RFC 6238 test vectors, base32 encoded.
+const rfcSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func newTestManager() (*Manager, *time.Time) {
+	clock := time.Unix(1700000000, 0)
+	manager := NewManager(memory.NewInMemoryMFAStorage(), "Responsible API")
+	manager.now = func() time.Time { return clock }
+	return manager, &clock
+}
+
+// enrolled returns a manager with a confirmed enrolment for alice.
+func enrolled(t *testing.T) (*Manager, *time.Time, *Setup) {
+	t.Helper()
+	manager, clock := newTestManager()
+	setup, err := manager.Enroll("alice")
+	if err != nil {
+		t.Fatalf("Enroll() unexpected error = %v", err)
+	}
+	if err := manager.Confirm("alice", code(t, setup.Secret, *clock)); err != nil {
+		t.Fatalf("Confirm() unexpected error = %v", err)
+	}
+	*clock = clock.Add(Period)
+	return manager, clock, setup
+}
+
+func code(t *testing.T, secret string, at time.Time) string {
+	t.Helper()
+	c, err := Code(secret, at)
+	if err != nil {
+		t.Fatalf("Code() unexpected error = %v", err)
+	}
+	return c
+}
+
+func TestCode_RFC6238(t *testing.T) {
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{unix: 59, want: "287082"},
+		{unix: 1111111109, want: "081804"},
+		{unix: 1234567890, want: "005924"},
+		{unix: 2000000000, want: "279037"},
+	}
+
+	for _, tt := range tests {
+		if got := code(t, rfcSecret, time.Unix(tt.unix, 0)); got != tt.want {
+			t.Errorf("Code() at %d = %s, want %s", tt.unix, got, tt.want)
+		}
+	}
+
+	if _, err := Code("not base32!", time.Unix(59, 0)); err == nil {
+		t.Errorf("Code() with invalid secret error = nil, want error")
+	}
+}
+
+func TestURI(t *testing.T) {
+	uri, err := url.Parse(URI("Responsible API", "alice@example.com", rfcSecret))
+	if err != nil {
+		t.Fatalf("URI() is not a valid URL: %v", err)
+	}
+
+	if uri.Scheme != "otpauth" || uri.Host != "totp" || uri.Path != "/Responsible API:alice@example.com" {
+		t.Errorf("URI() = %s, want otpauth://totp/Responsible API:alice@example.com", uri)
+	}
+	query := uri.Query()
+	if query.Get("secret") != rfcSecret || query.Get("issuer") != "Responsible API" || query.Get("digits") != "6" || query.Get("period") != "30" {
+		t.Errorf("URI() parameters = %v", query)
+	}
+}
+
+func TestManager_Enroll(t *testing.T) {
+	manager, clock := newTestManager()
+
+	setup, err := manager.Enroll("alice")
+	if err != nil {
+		t.Fatalf("Enroll() unexpected error = %v", err)
+	}
+	if len(setup.RecoveryCodes) != RecoveryCodeCount || !strings.HasPrefix(setup.URI, "otpauth://totp/") {
+		t.Errorf("Enroll() = %+v, want %d recovery codes and an otpauth URI", setup, RecoveryCodeCount)
+	}
+
+	// MFA is not enforced until the enrolment is confirmed
+	if enabled, _ := manager.Enabled("alice"); enabled {
+		t.Errorf("Enabled() before Confirm() = true, want false")
+	}
+	if err := manager.Verify("alice", code(t, setup.Secret, *clock)); !errors.Is(err, ErrNotEnrolled) {
+		t.Errorf("Verify() before Confirm() error = %v, want %v", err, ErrNotEnrolled)
+	}
+
+	// Enrolling again replaces the unconfirmed secret
+	again, err := manager.Enroll("alice")
+	if err != nil {
+		t.Fatalf("Enroll() again unexpected error = %v", err)
+	}
+	if err := manager.Confirm("alice", code(t, setup.Secret, *clock)); !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("Confirm() with replaced secret error = %v, want %v", err, ErrInvalidCode)
+	}
+	if err := manager.Confirm("alice", code(t, again.Secret, *clock)); err != nil {
+		t.Fatalf("Confirm() unexpected error = %v", err)
+	}
+
+	if enabled, _ := manager.Enabled("alice"); !enabled {
+		t.Errorf("Enabled() after Confirm() = false, want true")
+	}
+	if _, err := manager.Enroll("alice"); !errors.Is(err, ErrAlreadyEnrolled) {
+		t.Errorf("Enroll() after Confirm() error = %v, want %v", err, ErrAlreadyEnrolled)
+	}
+	if err := manager.Confirm("bob", "123456"); !errors.Is(err, ErrNotEnrolled) {
+		t.Errorf("Confirm() for unknown user error = %v, want %v", err, ErrNotEnrolled)
+	}
+}
+
+func TestManager_Verify(t *testing.T) {
+	manager, clock, setup := enrolled(t)
+
+	tests := []struct {
+		name        string
+		at          time.Time
+		expectError error
+	}{
+		{name: "previous step", at: clock.Add(-Period), expectError: ErrInvalidCode}, // used to confirm
+		{name: "current step", at: *clock},
+		{name: "replayed", at: *clock, expectError: ErrInvalidCode},
+		{name: "next step", at: clock.Add(Period)},
+		{name: "too far ahead", at: clock.Add(3 * Period), expectError: ErrInvalidCode},
+	}
+
+	for _, tt := range tests {
+		err := manager.Verify("alice", code(t, setup.Secret, tt.at))
+		if !errors.Is(err, tt.expectError) {
+			t.Errorf("Verify() %s error = %v, want %v", tt.name, err, tt.expectError)
+		}
+	}
+
+	for _, wrong := range []string{"", "12345", "abcdef"} {
+		if err := manager.Verify("alice", wrong); !errors.Is(err, ErrInvalidCode) {
+			t.Errorf("Verify(%q) error = %v, want %v", wrong, err, ErrInvalidCode)
+		}
+	}
+	if err := manager.Verify("bob", "123456"); !errors.Is(err, ErrNotEnrolled) {
+		t.Errorf("Verify() for unknown user error = %v, want %v", err, ErrNotEnrolled)
+	}
+}
+
+func TestManager_AttemptLimit(t *testing.T) {
+	manager, clock, setup := enrolled(t)
+	manager.SetAttemptLimit(3, time.Minute)
+
+	for i := 0; i < 3; i++ {
+		if err := manager.Verify("alice", "000000"); !errors.Is(err, ErrInvalidCode) {
+			t.Fatalf("Verify() attempt %d error = %v, want %v", i+1, err, ErrInvalidCode)
+		}
+	}
+
+	// Locked: even the right code and recovery codes are refused
+	if err := manager.Verify("alice", code(t, setup.Secret, *clock)); !errors.Is(err, ErrTooManyAttempts) {
+		t.Errorf("Verify() while locked error = %v, want %v", err, ErrTooManyAttempts)
+	}
+	if err := manager.Verify("alice", setup.RecoveryCodes[0]); !errors.Is(err, ErrTooManyAttempts) {
+		t.Errorf("Verify() recovery code while locked error = %v, want %v", err, ErrTooManyAttempts)
+	}
+
+	*clock = clock.Add(time.Minute)
+	if err := manager.Verify("alice", code(t, setup.Secret, *clock)); err != nil {
+		t.Errorf("Verify() after the lock expired error = %v", err)
+	}
+
+	// A valid code starts the count again
+	for i := 0; i < 2; i++ {
+		manager.Verify("alice", "000000")
+	}
+	if err := manager.Verify("alice", setup.RecoveryCodes[0]); err != nil {
+		t.Errorf("Verify() recovery code before the limit error = %v", err)
+	}
+	for i := 0; i < 2; i++ {
+		manager.Verify("alice", "000000")
+	}
+	if err := manager.Verify("alice", setup.RecoveryCodes[1]); err != nil {
+		t.Errorf("Verify() after a reset count error = %v", err)
+	}
+}
+
+func TestManager_RecoveryCodes(t *testing.T) {
+	manager, _, setup := enrolled(t)
+
+	// Recovery codes are accepted in upper case and without the dash, once
+	typed := strings.ToUpper(strings.ReplaceAll(setup.RecoveryCodes[0], "-", ""))
+	if err := manager.Verify("alice", typed); err != nil {
+		t.Fatalf("Verify() with recovery code unexpected error = %v", err)
+	}
+	if err := manager.Verify("alice", setup.RecoveryCodes[0]); !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("Verify() with used recovery code error = %v, want %v", err, ErrInvalidCode)
+	}
+	if remaining, _ := manager.RemainingRecoveryCodes("alice"); remaining != RecoveryCodeCount-1 {
+		t.Errorf("RemainingRecoveryCodes() = %d, want %d", remaining, RecoveryCodeCount-1)
+	}
+
+	codes, err := manager.RegenerateRecoveryCodes("alice")
+	if err != nil {
+		t.Fatalf("RegenerateRecoveryCodes() unexpected error = %v", err)
+	}
+	if err := manager.Verify("alice", setup.RecoveryCodes[1]); !errors.Is(err, ErrInvalidCode) {
+		t.Errorf("Verify() with replaced recovery code error = %v, want %v", err, ErrInvalidCode)
+	}
+	if err := manager.Verify("alice", codes[1]); err != nil {
+		t.Errorf("Verify() with new recovery code unexpected error = %v", err)
+	}
+}
+
+func TestManager_Disable(t *testing.T) {
+	manager, _, _ := enrolled(t)
+
+	if err := manager.Disable("alice"); err != nil {
+		t.Fatalf("Disable() unexpected error = %v", err)
+	}
+	if enabled, _ := manager.Enabled("alice"); enabled {
+		t.Errorf("Enabled() after Disable() = true, want false")
+	}
+	if err := manager.Disable("alice"); !errors.Is(err, ErrNotEnrolled) {
+		t.Errorf("Disable() twice error = %v, want %v", err, ErrNotEnrolled)
+	}
+}
@@ -0,0 +1,113 @@
+// Package middleware provides net/http middleware for authenticating
+// requests with an auth provider and applying per-account policies.
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/responsible-api/responsible-auth/auth"
+	"github.com/responsible-api/responsible-auth/concerns"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type contextKey int
+
+const tokenKey contextKey = iota
+
+// WithToken returns a copy of ctx carrying the validated token.
+func WithToken(ctx context.Context, token *jwt.Token) context.Context {
+	return context.WithValue(ctx, tokenKey, token)
+}
+
+// TokenFromContext returns the token stored by Authenticate.
+func TokenFromContext(ctx context.Context) (*jwt.Token, bool) {
+	token, ok := ctx.Value(tokenKey).(*jwt.Token)
+	return token, ok
+}
+
+// ClaimsFromContext returns the claims of the token stored by Authenticate.
+func ClaimsFromContext(ctx context.Context) (*concerns.ClaimsGeneric, bool) {
+	token, ok := TokenFromContext(ctx)
+	if !ok {
+		return nil, false
+	}
+	claims, ok := token.Claims.(*concerns.ClaimsGeneric)
+	return claims, ok
+}
+
+// Authenticate rejects requests without a valid bearer access token and
+// stores the validated token in the request context for later handlers.
+func Authenticate(provider auth.AuthInterface) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tokenString, ok := bearerToken(r)
+			if !ok {
+				unauthorized(w)
+				return
+			}
+
+			token, err := provider.Validate(tokenString)
+			if err != nil || token == nil {
+				unauthorized(w)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(WithToken(r.Context(), token)))
+		})
+	}
+}
+
+// RequireAudience rejects tokens that are not addressed to audience, such as
+// tokens exchanged for another service. It must run after Authenticate.
+func RequireAudience(audience string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := ClaimsFromContext(r.Context())
+			if !ok || !slices.Contains(claims.Audience, audience) {
+				unauthorized(w)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RejectImpersonation rejects tokens with an act claim, issued to staff
+// impersonating the subject or to clients acting on the subject's behalf,
+// with 403 Forbidden. Use it on sensitive routes such as changing a password.
+// It must run after Authenticate.
+func RejectImpersonation() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := ClaimsFromContext(r.Context())
+			if !ok {
+				unauthorized(w)
+				return
+			}
+			if claims.Actor != nil {
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// bearerToken extracts the token from an "Authorization: Bearer <token>" header.
+func bearerToken(r *http.Request) (string, bool) {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
+
+func unauthorized(w http.ResponseWriter) {
+	w.Header().Set("WWW-Authenticate", `Bearer`)
+	http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+}
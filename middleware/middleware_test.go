@@ -0,0 +1,304 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/responsible-api/responsible-auth/concerns"
+	"github.com/responsible-api/responsible-auth/examples/memory"
+	"github.com/responsible-api/responsible-auth/internal"
+	"github.com/responsible-api/responsible-auth/ratelimit"
+	"github.com/responsible-api/responsible-auth/rbac"
+	"github.com/responsible-api/responsible-auth/resource/role"
+	"github.com/responsible-api/responsible-auth/resource/user"
+	"github.com/responsible-api/responsible-auth/service"
+	"github.com/responsible-api/responsible-auth/testutils"
+)
+
+func newTestProvider(t *testing.T) (*service.BasicAuth, string) {
+	t.Helper()
+	provider := service.NewBasicAuth().(*service.BasicAuth)
+	provider.SetStorage(testutils.NewMockStorage())
+	provider.SetOptions(testutils.TestAuthOptions())
+
+	token, err := provider.CreateAccessToken("test@example.com", "test-password-hash")
+	if err != nil {
+		t.Fatalf("CreateAccessToken() unexpected error = %v", err)
+	}
+	return provider, token.GetToken()
+}
+
+func okHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+}
+
+func serve(handler http.Handler, authorization string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAuthenticate(t *testing.T) {
+	provider, token := newTestProvider(t)
+
+	var accountID uint64
+	handler := Authenticate(provider)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := ClaimsFromContext(r.Context())
+		if !ok {
+			t.Errorf("ClaimsFromContext() found no claims")
+			return
+		}
+		accountID = claims.AccountID
+	}))
+
+	tests := []struct {
+		name          string
+		authorization string
+		expectStatus  int
+	}{
+		{name: "valid token", authorization: "Bearer " + token, expectStatus: http.StatusOK},
+		{name: "lowercase scheme", authorization: "bearer " + token, expectStatus: http.StatusOK},
+		{name: "missing header", expectStatus: http.StatusUnauthorized},
+		{name: "basic scheme", authorization: "Basic " + testutils.ValidBasicAuthCredentials(), expectStatus: http.StatusUnauthorized},
+		{name: "invalid token", authorization: "Bearer not-a-token", expectStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := serve(handler, tt.authorization)
+			if rec.Code != tt.expectStatus {
+				t.Errorf("Authenticate() status = %d, want %d", rec.Code, tt.expectStatus)
+			}
+			if tt.expectStatus == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") == "" {
+				t.Errorf("Authenticate() missing WWW-Authenticate header")
+			}
+		})
+	}
+
+	if accountID != testutils.TestUser().AccountID {
+		t.Errorf("token account_id = %d, want %d", accountID, testutils.TestUser().AccountID)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	provider, token := newTestProvider(t)
+
+	limiter := ratelimit.NewLimiter(memory.NewInMemoryBucketStorage(), ratelimit.Config{
+		Default: ratelimit.Limit{Capacity: 2, Rate: 0.5},
+	})
+	handler := Authenticate(provider)(RateLimit(limiter, nil)(okHandler()))
+
+	expectRemaining := []string{"1", "0"}
+	for _, remaining := range expectRemaining {
+		rec := serve(handler, "Bearer "+token)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("RateLimit() status = %d, want %d", rec.Code, http.StatusOK)
+		}
+		if got := rec.Header().Get("RateLimit-Remaining"); got != remaining {
+			t.Errorf("RateLimit-Remaining = %q, want %q", got, remaining)
+		}
+		if got := rec.Header().Get("RateLimit-Limit"); got != "2" {
+			t.Errorf("RateLimit-Limit = %q, want %q", got, "2")
+		}
+		if got := rec.Header().Get("RateLimit-Policy"); got != "2;w=4" {
+			t.Errorf("RateLimit-Policy = %q, want %q", got, "2;w=4")
+		}
+	}
+
+	rec := serve(handler, "Bearer "+token)
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("RateLimit() status = %d, want %d", rec.Code, http.StatusTooManyRequests)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "2" {
+		t.Errorf("Retry-After = %q, want %q", got, "2")
+	}
+	if got := rec.Header().Get("RateLimit-Reset"); got != "4" {
+		t.Errorf("RateLimit-Reset = %q, want %q", got, "4")
+	}
+}
+
+func TestRateLimit_Tier(t *testing.T) {
+	provider, token := newTestProvider(t)
+
+	limiter := ratelimit.NewLimiter(memory.NewInMemoryBucketStorage(), ratelimit.Config{
+		Default: ratelimit.Limit{Capacity: 1, Rate: 1},
+		Tiers:   map[string]ratelimit.Limit{"user": {Capacity: 50, Rate: 1}},
+	})
+	handler := Authenticate(provider)(RateLimit(limiter, nil)(okHandler()))
+
+	// testutils.TestAuthOptions issues tokens with role "user"
+	rec := serve(handler, "Bearer "+token)
+	if got := rec.Header().Get("RateLimit-Limit"); got != "50" {
+		t.Errorf("RateLimit-Limit = %q, want %q", got, "50")
+	}
+}
+
+func TestRateLimit_WithoutAccount(t *testing.T) {
+	provider, _ := newTestProvider(t)
+
+	// Client credentials tokens name the client as subject and carry no account ID
+	var clients []string
+	for _, clientID := range []string{"client-a", "client-b"} {
+		options := testutils.TestAuthOptions()
+		options.Subject = clientID
+		options.ClientID = clientID
+		token, err := internal.CreateAccessToken(options)
+		if err != nil {
+			t.Fatalf("CreateAccessToken() unexpected error = %v", err)
+		}
+		clients = append(clients, token.GetToken())
+	}
+
+	// Provider tokens of users without an account share the static subject
+	mockStorage := testutils.NewMockStorage()
+	u := &user.User{Name: "no-account", Mail: "no-account@example.com", Secret: "secret", Status: user.StatusActive}
+	if err := mockStorage.CreateUser(u); err != nil {
+		t.Fatal(err)
+	}
+	provider.SetStorage(mockStorage)
+	userToken, err := provider.CreateAccessToken(u.Mail, "secret")
+	if err != nil {
+		t.Fatalf("CreateAccessToken() unexpected error = %v", err)
+	}
+
+	limiter := ratelimit.NewLimiter(memory.NewInMemoryBucketStorage(), ratelimit.Config{
+		Default: ratelimit.Limit{Capacity: 1, Rate: 0.01},
+	})
+	handler := Authenticate(provider)(RateLimit(limiter, nil)(okHandler()))
+
+	// Clients are limited per client, not in one shared bucket
+	for _, token := range clients {
+		if rec := serve(handler, "Bearer "+token); rec.Code != http.StatusOK {
+			t.Errorf("RateLimit() client first request status = %d, want %d", rec.Code, http.StatusOK)
+		}
+	}
+	if rec := serve(handler, "Bearer "+clients[0]); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("RateLimit() client second request status = %d, want %d", rec.Code, http.StatusTooManyRequests)
+	}
+
+	// Other tokens without an account cannot be told apart, so they are refused
+	if rec := serve(handler, "Bearer "+userToken.GetToken()); rec.Code != http.StatusUnauthorized {
+		t.Errorf("RateLimit() user without account status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestRateLimit_WithoutAuthenticate(t *testing.T) {
+	limiter := ratelimit.NewLimiter(memory.NewInMemoryBucketStorage(), ratelimit.Config{
+		Default: ratelimit.Limit{Capacity: 1, Rate: 1},
+	})
+
+	rec := serve(RateLimit(limiter, nil)(okHandler()), "")
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("RateLimit() without token status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestRequireAudience(t *testing.T) {
+	provider, token := newTestProvider(t)
+
+	options := testutils.TestAuthOptions()
+	options.Audience = []string{"billing"}
+	provider.SetOptions(options)
+	restricted, err := provider.CreateAccessToken("test@example.com", "test-password-hash")
+	if err != nil {
+		t.Fatalf("CreateAccessToken() unexpected error = %v", err)
+	}
+
+	handler := Authenticate(provider)(RequireAudience("billing")(okHandler()))
+
+	tests := []struct {
+		name         string
+		token        string
+		expectStatus int
+	}{
+		{name: "addressed to audience", token: restricted.GetToken(), expectStatus: http.StatusOK},
+		{name: "no audience", token: token, expectStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := serve(handler, "Bearer "+tt.token); rec.Code != tt.expectStatus {
+				t.Errorf("RequireAudience() status = %d, want %d", rec.Code, tt.expectStatus)
+			}
+		})
+	}
+
+	if rec := serve(Authenticate(provider)(RequireAudience("reports")(okHandler())), "Bearer "+restricted.GetToken()); rec.Code != http.StatusUnauthorized {
+		t.Errorf("RequireAudience() other audience status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestRejectImpersonation(t *testing.T) {
+	provider, token := newTestProvider(t)
+
+	options := testutils.TestAuthOptions()
+	options.Actor = &concerns.Actor{Subject: "support-agent"}
+	provider.SetOptions(options)
+	impersonated, err := provider.CreateAccessToken("test@example.com", "test-password-hash")
+	if err != nil {
+		t.Fatalf("CreateAccessToken() unexpected error = %v", err)
+	}
+
+	handler := Authenticate(provider)(RejectImpersonation()(okHandler()))
+	if rec := serve(handler, "Bearer "+token); rec.Code != http.StatusOK {
+		t.Errorf("RejectImpersonation() own token status = %d, want %d", rec.Code, http.StatusOK)
+	}
+	if rec := serve(handler, "Bearer "+impersonated.GetToken()); rec.Code != http.StatusForbidden {
+		t.Errorf("RejectImpersonation() impersonated token status = %d, want %d", rec.Code, http.StatusForbidden)
+	}
+}
+
+func TestRequirePermission(t *testing.T) {
+	roles := memory.NewInMemoryRoleStorage()
+	if err := roles.CreateRole(&role.Role{Name: "editor"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := roles.GrantPermission(&role.Permission{RoleName: "editor", Permission: "orders:write", Resource: "orders/*"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := roles.AssignRole("testuser", "editor"); err != nil {
+		t.Fatal(err)
+	}
+	authorizer := rbac.NewAuthorizer(roles)
+
+	provider, plain := newTestProvider(t)
+	options := testutils.TestAuthOptions()
+	options.ClaimsEnricher = authorizer
+	provider.SetOptions(options)
+	enriched, err := provider.CreateAccessToken("test@example.com", "test-password-hash")
+	if err != nil {
+		t.Fatalf("CreateAccessToken() unexpected error = %v", err)
+	}
+
+	orderPath := func(r *http.Request) string { return "orders" + r.URL.Path }
+	tests := []struct {
+		name         string
+		handler      http.Handler
+		token        string
+		expectStatus int
+	}{
+		{name: "granted", handler: RequirePermission(authorizer, "orders:write", orderPath)(okHandler()), token: enriched.GetToken(), expectStatus: http.StatusOK},
+		{name: "other permission", handler: RequirePermission(authorizer, "orders:refund", orderPath)(okHandler()), token: enriched.GetToken(), expectStatus: http.StatusForbidden},
+		{name: "without resource", handler: RequirePermission(authorizer, "orders:write", nil)(okHandler()), token: enriched.GetToken(), expectStatus: http.StatusForbidden},
+		{name: "token without roles", handler: RequirePermission(authorizer, "orders:write", orderPath)(okHandler()), token: plain, expectStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := serve(Authenticate(provider)(tt.handler), "Bearer "+tt.token); rec.Code != tt.expectStatus {
+				t.Errorf("RequirePermission() status = %d, want %d", rec.Code, tt.expectStatus)
+			}
+		})
+	}
+
+	if rec := serve(RequirePermission(authorizer, "orders:write", nil)(okHandler()), ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("RequirePermission() without Authenticate status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+}
@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"net/http"
+
+	"github.com/responsible-api/responsible-auth/rbac"
+)
+
+// ResourceFunc names the resource a request acts on, such as "orders/42".
+type ResourceFunc func(r *http.Request) string
+
+// RequirePermission rejects requests whose token roles do not grant permission
+// on the resource named by resource, with 403 Forbidden. A nil resource checks
+// a permission that is not tied to a resource. It must run after Authenticate.
+func RequirePermission(authorizer *rbac.Authorizer, permission string, resource ResourceFunc) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := ClaimsFromContext(r.Context())
+			if !ok {
+				unauthorized(w)
+				return
+			}
+
+			target := ""
+			if resource != nil {
+				target = resource(r)
+			}
+			allowed, err := authorizer.Can(rbac.PrincipalFromClaims(claims), permission, target)
+			if err != nil {
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+			if !allowed {
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package middleware
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/responsible-api/responsible-auth/concerns"
+	"github.com/responsible-api/responsible-auth/ratelimit"
+	"github.com/responsible-api/responsible-auth/storage"
+)
+
+// TierFunc picks the rate limit tier for a token.
+type TierFunc func(claims *concerns.ClaimsGeneric) string
+
+// RoleTier uses the token's role as its tier.
+func RoleTier(claims *concerns.ClaimsGeneric) string {
+	return claims.Role
+}
+
+// RateLimit limits requests per account using the account ID of the token
+// stored by Authenticate, so it must be installed after Authenticate. Client
+// credentials tokens, which have no account ID, are limited per OAuth client.
+// Other tokens without an account ID are rejected with 401 Unauthorized, as
+// they would otherwise share one bucket. The tier defaults to the token's role
+// when tier is nil.
+//
+// Every response carries RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset
+// and RateLimit-Policy headers; rejected requests get 429 Too Many Requests
+// with Retry-After.
+func RateLimit(limiter *ratelimit.Limiter, tier TierFunc) func(http.Handler) http.Handler {
+	if tier == nil {
+		tier = RoleTier
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := ClaimsFromContext(r.Context())
+			if !ok {
+				unauthorized(w)
+				return
+			}
+
+			var result *ratelimit.Result
+			var err error
+			switch {
+			case claims.AccountID != 0:
+				result, err = limiter.Allow(claims.AccountID, tier(claims))
+			case claims.IsClient():
+				result, err = limiter.AllowClient(claims.ClientID, tier(claims))
+			default:
+				unauthorized(w)
+				return
+			}
+			if errors.Is(err, storage.ErrUserNotFound) {
+				unauthorized(w)
+				return
+			}
+			if err != nil {
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+
+			setRateLimitHeaders(w.Header(), result)
+			if !result.Allowed {
+				w.Header().Set("Retry-After", seconds(result.RetryAfter))
+				http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func setRateLimitHeaders(h http.Header, result *ratelimit.Result) {
+	capacity := strconv.FormatInt(int64(result.Limit.Capacity), 10)
+	h.Set("RateLimit-Limit", capacity)
+	h.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	h.Set("RateLimit-Reset", seconds(result.Reset))
+	h.Set("RateLimit-Policy", capacity+";w="+seconds(result.Limit.Window()))
+}
+
+// seconds formats d as whole seconds, rounding up so clients never retry early.
+func seconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
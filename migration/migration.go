@@ -0,0 +1,364 @@
+// Package migration applies the versioned SQL schema used by the SQL storage
+// backends.
+//
+// Migrations are embedded per dialect as pairs of files named
+// <version>_<name>.up.sql and <version>_<name>.down.sql, e.g.
+// mysql/0001_initial_schema.up.sql. Applied versions are recorded in the
+// schema_migrations table so upgrades can be run safely against live
+// databases. Statements within a file are separated by a semicolon at the end
+// of a line.
+package migration
+
+import (
+	"embed"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+//go:embed mysql/*.sql
+var embedded embed.FS
+
+// FS holds the bundled migrations, one directory per dialect.
+var FS fs.FS = embedded
+
+// DialectMySQL selects the MySQL migrations shipped with the library.
+const DialectMySQL = "mysql"
+
+const migrationsTable = "schema_migrations"
+
+// ErrIrreversible is returned when rolling back a migration without a down file.
+var ErrIrreversible = errors.New("migration has no down script")
+
+// Migration is a single versioned schema change.
+type Migration struct {
+	Version int64
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status reports whether a migration has been applied.
+type Status struct {
+	Version   int64
+	Name      string
+	Applied   bool
+	AppliedAt int64
+}
+
+// Options controls how Up and Down run.
+type Options struct {
+	// Steps limits how many migrations are applied or rolled back.
+	// Zero means all pending migrations for Up and a single one for Down.
+	Steps int
+	// DryRun prints the planned statements to Out without executing them.
+	DryRun bool
+	// Out receives progress and dry-run output. Defaults to io.Discard.
+	Out io.Writer
+}
+
+// Migrator runs migrations for one dialect against a database.
+type Migrator struct {
+	db         *gorm.DB
+	migrations []Migration
+}
+
+type appliedRow struct {
+	Version   int64
+	Name      string
+	AppliedAt int64
+}
+
+// New creates a migrator for the bundled migrations of the given dialect.
+func New(db *gorm.DB, dialect string) (*Migrator, error) {
+	return NewFromFS(db, FS, dialect)
+}
+
+// NewFromFS creates a migrator reading migrations from the dialect directory of fsys.
+func NewFromFS(db *gorm.DB, fsys fs.FS, dialect string) (*Migrator, error) {
+	migrations, err := Load(fsys, dialect)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{
+		db:         db,
+		migrations: migrations,
+	}, nil
+}
+
+// Migrations returns the known migrations in version order.
+func (m *Migrator) Migrations() []Migration {
+	return append([]Migration(nil), m.migrations...)
+}
+
+// Status lists every known migration and whether it has been applied.
+func (m *Migrator) Status() ([]Status, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, mig := range m.migrations {
+		row, ok := applied[mig.Version]
+		statuses = append(statuses, Status{
+			Version:   mig.Version,
+			Name:      mig.Name,
+			Applied:   ok,
+			AppliedAt: row.AppliedAt,
+		})
+	}
+	return statuses, nil
+}
+
+// Up applies pending migrations in ascending version order.
+func (m *Migrator) Up(options Options) ([]Migration, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	planned := planUp(m.migrations, applied, options.Steps)
+	out := writer(options)
+	for _, mig := range planned {
+		fmt.Fprintf(out, "up %04d %s\n", mig.Version, mig.Name)
+		if options.DryRun {
+			printStatements(out, mig.Up)
+			continue
+		}
+		if err := m.run(mig, mig.Up, true); err != nil {
+			return nil, fmt.Errorf("migration %04d %s up: %w", mig.Version, mig.Name, err)
+		}
+	}
+	return planned, nil
+}
+
+// Down rolls back applied migrations in descending version order.
+func (m *Migrator) Down(options Options) ([]Migration, error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	steps := options.Steps
+	if steps == 0 {
+		steps = 1
+	}
+
+	planned := planDown(m.migrations, applied, steps)
+	out := writer(options)
+	for _, mig := range planned {
+		if strings.TrimSpace(mig.Down) == "" {
+			return nil, fmt.Errorf("migration %04d %s: %w", mig.Version, mig.Name, ErrIrreversible)
+		}
+		fmt.Fprintf(out, "down %04d %s\n", mig.Version, mig.Name)
+		if options.DryRun {
+			printStatements(out, mig.Down)
+			continue
+		}
+		if err := m.run(mig, mig.Down, false); err != nil {
+			return nil, fmt.Errorf("migration %04d %s down: %w", mig.Version, mig.Name, err)
+		}
+	}
+	return planned, nil
+}
+
+// run executes a script and records the new version state.
+// MySQL commits DDL implicitly, so the transaction only guarantees atomicity
+// on dialects with transactional DDL; the version row is always written last.
+func (m *Migrator) run(mig Migration, script string, up bool) error {
+	if err := m.ensureTable(); err != nil {
+		return err
+	}
+
+	return m.db.Transaction(func(tx *gorm.DB) error {
+		for _, statement := range splitStatements(script) {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+
+		if up {
+			return tx.Table(migrationsTable).Create(&appliedRow{
+				Version:   mig.Version,
+				Name:      mig.Name,
+				AppliedAt: time.Now().Unix(),
+			}).Error
+		}
+		return tx.Table(migrationsTable).Where("version = ?", mig.Version).Delete(&appliedRow{}).Error
+	})
+}
+
+// applied returns the recorded versions. A missing table means nothing has run
+// yet; it is not created here so dry runs leave the database untouched.
+func (m *Migrator) applied() (map[int64]appliedRow, error) {
+	applied := make(map[int64]appliedRow)
+	if !m.db.Migrator().HasTable(migrationsTable) {
+		return applied, nil
+	}
+
+	var rows []appliedRow
+	if err := m.db.Table(migrationsTable).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+	return applied, nil
+}
+
+func (m *Migrator) ensureTable() error {
+	return m.db.Exec("CREATE TABLE IF NOT EXISTS " + migrationsTable + " (" +
+		"version BIGINT NOT NULL PRIMARY KEY, " +
+		"name VARCHAR(255) NOT NULL, " +
+		"applied_at BIGINT NOT NULL)").Error
+}
+
+// Load reads and pairs the migrations in the dialect directory of fsys.
+func Load(fsys fs.FS, dialect string) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, dialect)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported dialect %q: %w", dialect, err)
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".sql") {
+			continue
+		}
+
+		version, name, direction, err := parseFilename(entry.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		contents, err := fs.ReadFile(fsys, path.Join(dialect, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		mig, ok := byVersion[version]
+		if !ok {
+			mig = &Migration{Version: version, Name: name}
+			byVersion[version] = mig
+		} else if mig.Name != name {
+			return nil, fmt.Errorf("migration version %04d used by both %q and %q", version, mig.Name, name)
+		}
+
+		if direction == "up" {
+			mig.Up = string(contents)
+		} else {
+			mig.Down = string(contents)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if strings.TrimSpace(mig.Up) == "" {
+			return nil, fmt.Errorf("migration %04d %s has no up script", mig.Version, mig.Name)
+		}
+		migrations = append(migrations, *mig)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+	return migrations, nil
+}
+
+// parseFilename splits "0001_initial_schema.up.sql" into its parts.
+func parseFilename(filename string) (int64, string, string, error) {
+	base := strings.TrimSuffix(filename, ".sql")
+	direction := path.Ext(base)
+	if direction != ".up" && direction != ".down" {
+		return 0, "", "", fmt.Errorf("migration %q must end in .up.sql or .down.sql", filename)
+	}
+	base = strings.TrimSuffix(base, direction)
+
+	versionPart, name, ok := strings.Cut(base, "_")
+	if !ok || name == "" {
+		return 0, "", "", fmt.Errorf("migration %q must be named <version>_<name>", filename)
+	}
+
+	version, err := strconv.ParseInt(versionPart, 10, 64)
+	if err != nil || version <= 0 {
+		return 0, "", "", fmt.Errorf("migration %q has an invalid version", filename)
+	}
+	return version, name, strings.TrimPrefix(direction, "."), nil
+}
+
+// planUp selects pending migrations, oldest first.
+func planUp(migrations []Migration, applied map[int64]appliedRow, steps int) []Migration {
+	var planned []Migration
+	for _, mig := range migrations {
+		if _, ok := applied[mig.Version]; ok {
+			continue
+		}
+		if steps > 0 && len(planned) == steps {
+			break
+		}
+		planned = append(planned, mig)
+	}
+	return planned
+}
+
+// planDown selects applied migrations, newest first.
+func planDown(migrations []Migration, applied map[int64]appliedRow, steps int) []Migration {
+	var planned []Migration
+	for i := len(migrations) - 1; i >= 0; i-- {
+		if _, ok := applied[migrations[i].Version]; !ok {
+			continue
+		}
+		if steps > 0 && len(planned) == steps {
+			break
+		}
+		planned = append(planned, migrations[i])
+	}
+	return planned
+}
+
+// splitStatements breaks a script into statements on lines ending with ";",
+// dropping blank lines and "--" comments.
+func splitStatements(script string) []string {
+	var statements []string
+	var current strings.Builder
+
+	for _, line := range strings.Split(script, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+
+		current.WriteString(line)
+		current.WriteString("\n")
+		if strings.HasSuffix(trimmed, ";") {
+			statements = append(statements, strings.TrimSuffix(strings.TrimSpace(current.String()), ";"))
+			current.Reset()
+		}
+	}
+
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		statements = append(statements, rest)
+	}
+	return statements
+}
+
+func printStatements(out io.Writer, script string) {
+	for _, statement := range splitStatements(script) {
+		fmt.Fprintf(out, "%s;\n", statement)
+	}
+}
+
+func writer(options Options) io.Writer {
+	if options.Out == nil {
+		return io.Discard
+	}
+	return options.Out
+}
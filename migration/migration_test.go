@@ -0,0 +1,256 @@
+package migration
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	gormmysql "gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestLoadEmbedded(t *testing.T) {
+	migrations, err := Load(FS, DialectMySQL)
+	if err != nil {
+		t.Fatalf("Load() unexpected error = %v", err)
+	}
+
+	if len(migrations) == 0 {
+		t.Fatal("Load() returned no migrations")
+	}
+
+	for i, mig := range migrations {
+		if mig.Version != int64(i+1) {
+			t.Errorf("Load() migration %d has version %d, want contiguous versions", i, mig.Version)
+		}
+		if strings.TrimSpace(mig.Down) == "" {
+			t.Errorf("Load() migration %04d %s has no down script", mig.Version, mig.Name)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		name          string
+		files         fstest.MapFS
+		dialect       string
+		expectError   bool
+		expectVersion []int64
+	}{
+		{
+			name: "sorted by version",
+			files: fstest.MapFS{
+				"mysql/0010_later.up.sql":    {Data: []byte("SELECT 10;")},
+				"mysql/0002_second.up.sql":   {Data: []byte("SELECT 2;")},
+				"mysql/0002_second.down.sql": {Data: []byte("SELECT -2;")},
+				"mysql/0001_first.up.sql":    {Data: []byte("SELECT 1;")},
+				"mysql/README.md":            {Data: []byte("ignored")},
+			},
+			dialect:       "mysql",
+			expectVersion: []int64{1, 2, 10},
+		},
+		{
+			name:        "unknown dialect",
+			files:       fstest.MapFS{"mysql/0001_first.up.sql": {Data: []byte("SELECT 1;")}},
+			dialect:     "oracle",
+			expectError: true,
+		},
+		{
+			name: "duplicate version",
+			files: fstest.MapFS{
+				"mysql/0001_first.up.sql": {Data: []byte("SELECT 1;")},
+				"mysql/0001_other.up.sql": {Data: []byte("SELECT 1;")},
+			},
+			dialect:     "mysql",
+			expectError: true,
+		},
+		{
+			name:        "down without up",
+			files:       fstest.MapFS{"mysql/0001_first.down.sql": {Data: []byte("SELECT 1;")}},
+			dialect:     "mysql",
+			expectError: true,
+		},
+		{
+			name:        "missing direction",
+			files:       fstest.MapFS{"mysql/0001_first.sql": {Data: []byte("SELECT 1;")}},
+			dialect:     "mysql",
+			expectError: true,
+		},
+		{
+			name:        "invalid version",
+			files:       fstest.MapFS{"mysql/first_table.up.sql": {Data: []byte("SELECT 1;")}},
+			dialect:     "mysql",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			migrations, err := Load(tt.files, tt.dialect)
+
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("Load() expected error but got none")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Load() unexpected error = %v", err)
+			}
+
+			if len(migrations) != len(tt.expectVersion) {
+				t.Fatalf("Load() returned %d migrations, want %d", len(migrations), len(tt.expectVersion))
+			}
+			for i, version := range tt.expectVersion {
+				if migrations[i].Version != version {
+					t.Errorf("Load() migrations[%d].Version = %d, want %d", i, migrations[i].Version, version)
+				}
+			}
+		})
+	}
+}
+
+func TestPlan(t *testing.T) {
+	migrations := []Migration{
+		{Version: 1, Name: "one"},
+		{Version: 2, Name: "two"},
+		{Version: 3, Name: "three"},
+	}
+	applied := map[int64]appliedRow{1: {Version: 1}, 2: {Version: 2}}
+
+	tests := []struct {
+		name   string
+		up     bool
+		steps  int
+		expect []int64
+	}{
+		{name: "up all pending", up: true, steps: 0, expect: []int64{3}},
+		{name: "up limited", up: true, steps: 1, expect: []int64{3}},
+		{name: "down one", up: false, steps: 1, expect: []int64{2}},
+		{name: "down all", up: false, steps: 0, expect: []int64{2, 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var planned []Migration
+			if tt.up {
+				planned = planUp(migrations, applied, tt.steps)
+			} else {
+				planned = planDown(migrations, applied, tt.steps)
+			}
+
+			if len(planned) != len(tt.expect) {
+				t.Fatalf("plan returned %d migrations, want %d", len(planned), len(tt.expect))
+			}
+			for i, version := range tt.expect {
+				if planned[i].Version != version {
+					t.Errorf("plan[%d].Version = %d, want %d", i, planned[i].Version, version)
+				}
+			}
+		})
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	script := `-- leading comment
+CREATE TABLE a (
+  id int
+);
+
+-- another comment
+DROP TABLE b;
+SELECT 1`
+
+	statements := splitStatements(script)
+	if len(statements) != 3 {
+		t.Fatalf("splitStatements() returned %d statements, want 3: %q", len(statements), statements)
+	}
+
+	if !strings.HasPrefix(statements[0], "CREATE TABLE a (") || strings.HasSuffix(statements[0], ";") {
+		t.Errorf("splitStatements()[0] = %q", statements[0])
+	}
+	if statements[1] != "DROP TABLE b" {
+		t.Errorf("splitStatements()[1] = %q, want %q", statements[1], "DROP TABLE b")
+	}
+	if statements[2] != "SELECT 1" {
+		t.Errorf("splitStatements()[2] = %q, want %q", statements[2], "SELECT 1")
+	}
+}
+
+// TestMigrator_MySQL runs every bundled migration up and back down against the
+// database named by MYSQL_TEST_DSN. The database is wiped, so never point it
+// at real data.
+func TestMigrator_MySQL(t *testing.T) {
+	dsn := os.Getenv("MYSQL_TEST_DSN")
+	if dsn == "" {
+		t.Skip("MYSQL_TEST_DSN not set, skipping MySQL migration tests")
+	}
+
+	db, err := gorm.Open(gormmysql.Open(dsn), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("Failed to connect to MySQL: %v", err)
+	}
+
+	migrator, err := New(db, DialectMySQL)
+	if err != nil {
+		t.Fatalf("New() unexpected error = %v", err)
+	}
+	total := len(migrator.Migrations())
+
+	// Start from a clean slate in case a previous run was interrupted.
+	if _, err := migrator.Down(Options{Steps: total}); err != nil {
+		t.Fatalf("Down() cleanup error = %v", err)
+	}
+
+	var out bytes.Buffer
+	planned, err := migrator.Up(Options{DryRun: true, Out: &out})
+	if err != nil {
+		t.Fatalf("Up() dry run error = %v", err)
+	}
+	if len(planned) != total || !strings.Contains(out.String(), "CREATE TABLE") {
+		t.Errorf("Up() dry run planned %d migrations, output %q", len(planned), out.String())
+	}
+
+	statuses, err := migrator.Status()
+	if err != nil {
+		t.Fatalf("Status() unexpected error = %v", err)
+	}
+	for _, status := range statuses {
+		if status.Applied {
+			t.Errorf("Status() dry run applied migration %04d", status.Version)
+		}
+	}
+
+	if _, err := migrator.Up(Options{}); err != nil {
+		t.Fatalf("Up() unexpected error = %v", err)
+	}
+
+	statuses, err = migrator.Status()
+	if err != nil {
+		t.Fatalf("Status() unexpected error = %v", err)
+	}
+	for _, status := range statuses {
+		if !status.Applied {
+			t.Errorf("Status() migration %04d not applied after Up()", status.Version)
+		}
+	}
+
+	planned, err = migrator.Up(Options{})
+	if err != nil || len(planned) != 0 {
+		t.Errorf("Up() second run applied %d migrations, err = %v", len(planned), err)
+	}
+
+	planned, err = migrator.Down(Options{Steps: total})
+	if err != nil {
+		t.Fatalf("Down() unexpected error = %v", err)
+	}
+	if len(planned) != total {
+		t.Errorf("Down() rolled back %d migrations, want %d", len(planned), total)
+	}
+}
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS `responsible_token_bucket`;
+DROP TABLE IF EXISTS `responsible_api_users`;
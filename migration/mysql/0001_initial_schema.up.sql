@@ -0,0 +1,29 @@
+-- Baseline schema, equivalent to the tables created by migration/schema.sql.
+-- IF NOT EXISTS lets databases bootstrapped from that script adopt migrations.
+CREATE TABLE IF NOT EXISTS `responsible_api_users` (
+  `uid` int unsigned NOT NULL AUTO_INCREMENT,
+  `account_id` bigint NOT NULL DEFAULT '0',
+  `name` varchar(60) NOT NULL DEFAULT '',
+  `mail` varchar(254) DEFAULT '',
+  `created` int NOT NULL DEFAULT '0',
+  `access` int NOT NULL DEFAULT '0',
+  `status` tinyint NOT NULL DEFAULT '0',
+  `secret` varchar(32) NOT NULL DEFAULT '',
+  `apikey` varchar(64) DEFAULT '',
+  `refresh_token` varchar(128) DEFAULT '',
+  PRIMARY KEY (`uid`),
+  UNIQUE KEY `name` (`name`),
+  KEY `access` (`access`),
+  KEY `created` (`created`),
+  KEY `mail` (`mail`),
+  KEY `account_id` (`account_id`)
+) ENGINE = InnoDB;
+
+CREATE TABLE IF NOT EXISTS `responsible_token_bucket` (
+  `id` int unsigned NOT NULL AUTO_INCREMENT,
+  `bucket` varchar(128) NOT NULL DEFAULT '',
+  `account_id` bigint DEFAULT '0',
+  PRIMARY KEY (`id`),
+  KEY `Account ID Constraint` (`account_id`),
+  CONSTRAINT `Account ID Constraint` FOREIGN KEY (`account_id`) REFERENCES `responsible_api_users` (`account_id`)
+) ENGINE = InnoDB;
@@ -0,0 +1,2 @@
+-- Fails if any hashed secret is stored; clear or reset those users first.
+ALTER TABLE `responsible_api_users` MODIFY `secret` varchar(32) NOT NULL DEFAULT '';
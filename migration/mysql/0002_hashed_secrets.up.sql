@@ -0,0 +1,2 @@
+-- bcrypt hashes are 60 characters, longer than the original varchar(32).
+ALTER TABLE `responsible_api_users` MODIFY `secret` varchar(255) NOT NULL DEFAULT '';
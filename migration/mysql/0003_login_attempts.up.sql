@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS `responsible_login_attempts` (
+  `attempt_key` varchar(255) NOT NULL,
+  `failures` int NOT NULL DEFAULT '0',
+  `window_start` bigint NOT NULL DEFAULT '0',
+  `locked_until` bigint NOT NULL DEFAULT '0',
+  `lockouts` int NOT NULL DEFAULT '0',
+  PRIMARY KEY (`attempt_key`),
+  KEY `locked_until` (`locked_until`)
+) ENGINE = InnoDB;
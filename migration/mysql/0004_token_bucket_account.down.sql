@@ -0,0 +1 @@
+ALTER TABLE `responsible_token_bucket` DROP INDEX `account_id`;
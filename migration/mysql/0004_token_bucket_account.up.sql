@@ -0,0 +1,2 @@
+-- One bucket per account so concurrent requests share, rather than race to create, a row.
+ALTER TABLE `responsible_token_bucket` ADD UNIQUE KEY `account_id` (`account_id`);
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS `responsible_oauth_codes`;
+DROP TABLE IF EXISTS `responsible_oauth_clients`;
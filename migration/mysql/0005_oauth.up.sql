@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS `responsible_oauth_clients` (
+  `client_id` varchar(64) NOT NULL,
+  `name` varchar(255) NOT NULL DEFAULT '',
+  `redirect_uris` text,
+  `created` bigint NOT NULL DEFAULT '0',
+  PRIMARY KEY (`client_id`)
+) ENGINE = InnoDB;
+
+CREATE TABLE IF NOT EXISTS `responsible_oauth_codes` (
+  `code_hash` char(64) NOT NULL,
+  `client_id` varchar(64) NOT NULL,
+  `user_name` varchar(60) NOT NULL,
+  `redirect_uri` text,
+  `scopes` text,
+  `code_challenge` varchar(128) NOT NULL DEFAULT '',
+  `expires_at` bigint NOT NULL DEFAULT '0',
+  PRIMARY KEY (`code_hash`),
+  KEY `expires_at` (`expires_at`)
+) ENGINE = InnoDB;
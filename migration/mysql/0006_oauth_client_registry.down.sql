@@ -0,0 +1,6 @@
+ALTER TABLE `responsible_oauth_clients`
+  DROP COLUMN `refresh_token_lifetime`,
+  DROP COLUMN `access_token_lifetime`,
+  DROP COLUMN `scopes`,
+  DROP COLUMN `grant_types`,
+  DROP COLUMN `secret_hash`;
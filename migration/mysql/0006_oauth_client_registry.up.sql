@@ -0,0 +1,9 @@
+ALTER TABLE `responsible_oauth_clients`
+  ADD COLUMN `secret_hash` varchar(255) NOT NULL DEFAULT '' AFTER `name`,
+  ADD COLUMN `grant_types` text AFTER `redirect_uris`,
+  ADD COLUMN `scopes` text AFTER `grant_types`,
+  ADD COLUMN `access_token_lifetime` int NOT NULL DEFAULT '0' AFTER `scopes`,
+  ADD COLUMN `refresh_token_lifetime` int NOT NULL DEFAULT '0' AFTER `access_token_lifetime`;
+
+-- Clients registered before grant types existed keep the flow they were created for
+UPDATE `responsible_oauth_clients` SET `grant_types` = '["authorization_code","refresh_token"]' WHERE `grant_types` IS NULL;
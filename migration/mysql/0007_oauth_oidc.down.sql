@@ -0,0 +1,3 @@
+ALTER TABLE `responsible_oauth_codes`
+  DROP COLUMN `auth_time`,
+  DROP COLUMN `nonce`;
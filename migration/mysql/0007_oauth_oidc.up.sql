@@ -0,0 +1,3 @@
+ALTER TABLE `responsible_oauth_codes`
+  ADD COLUMN `nonce` varchar(255) NOT NULL DEFAULT '' AFTER `code_challenge`,
+  ADD COLUMN `auth_time` bigint NOT NULL DEFAULT '0' AFTER `nonce`;
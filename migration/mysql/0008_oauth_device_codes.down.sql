@@ -0,0 +1 @@
+DROP TABLE IF EXISTS `responsible_oauth_device_codes`;
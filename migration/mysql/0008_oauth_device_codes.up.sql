@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS `responsible_oauth_device_codes` (
+  `code_hash` char(64) NOT NULL,
+  `user_code` varchar(16) NOT NULL,
+  `client_id` varchar(64) NOT NULL,
+  `scopes` text,
+  `status` tinyint NOT NULL DEFAULT '0',
+  `user_name` varchar(60) NOT NULL DEFAULT '',
+  `auth_time` bigint NOT NULL DEFAULT '0',
+  `poll_interval` int NOT NULL DEFAULT '5',
+  `last_polled` bigint NOT NULL DEFAULT '0',
+  `expires_at` bigint NOT NULL DEFAULT '0',
+  PRIMARY KEY (`code_hash`),
+  UNIQUE KEY `user_code` (`user_code`),
+  KEY `expires_at` (`expires_at`)
+) ENGINE = InnoDB;
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS `responsible_mfa_totp`;
@@ -0,0 +1,11 @@
+-- TOTP enrolments; removed together with the user they belong to.
+CREATE TABLE IF NOT EXISTS `responsible_mfa_totp` (
+  `user_name` varchar(60) NOT NULL,
+  `secret` varchar(64) NOT NULL DEFAULT '',
+  `confirmed` tinyint(1) NOT NULL DEFAULT '0',
+  `recovery_codes` text,
+  `last_step` bigint NOT NULL DEFAULT '0',
+  `created` bigint NOT NULL DEFAULT '0',
+  PRIMARY KEY (`user_name`),
+  CONSTRAINT `MFA User Constraint` FOREIGN KEY (`user_name`) REFERENCES `responsible_api_users` (`name`) ON DELETE CASCADE
+) ENGINE = InnoDB;
@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS `responsible_webauthn_challenges`;
+DROP TABLE IF EXISTS `responsible_webauthn_credentials`;
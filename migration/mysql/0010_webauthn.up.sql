@@ -0,0 +1,22 @@
+CREATE TABLE IF NOT EXISTS `responsible_webauthn_credentials` (
+  `credential_id` varchar(255) NOT NULL,
+  `user_name` varchar(60) NOT NULL,
+  `public_key` blob NOT NULL,
+  `algorithm` int NOT NULL DEFAULT '0',
+  `sign_count` int unsigned NOT NULL DEFAULT '0',
+  `transports` text,
+  `created` bigint NOT NULL DEFAULT '0',
+  `last_used` bigint NOT NULL DEFAULT '0',
+  PRIMARY KEY (`credential_id`),
+  KEY `user_name` (`user_name`),
+  CONSTRAINT `WebAuthn User Constraint` FOREIGN KEY (`user_name`) REFERENCES `responsible_api_users` (`name`) ON DELETE CASCADE
+) ENGINE = InnoDB;
+
+CREATE TABLE IF NOT EXISTS `responsible_webauthn_challenges` (
+  `challenge` varchar(64) NOT NULL,
+  `ceremony` varchar(16) NOT NULL,
+  `user_name` varchar(60) NOT NULL DEFAULT '',
+  `expires_at` bigint NOT NULL DEFAULT '0',
+  PRIMARY KEY (`challenge`),
+  KEY `expires_at` (`expires_at`)
+) ENGINE = InnoDB;
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS `responsible_sessions` (
+  `session_id` char(64) NOT NULL,
+  `user_name` varchar(60) NOT NULL,
+  `device_name` varchar(255) NOT NULL DEFAULT '',
+  `user_agent` varchar(512) NOT NULL DEFAULT '',
+  `ip` varchar(45) NOT NULL DEFAULT '',
+  `created` bigint NOT NULL DEFAULT '0',
+  `last_used` bigint NOT NULL DEFAULT '0',
+  `expires_at` bigint NOT NULL DEFAULT '0',
+  PRIMARY KEY (`session_id`),
+  KEY `user_name` (`user_name`),
+  KEY `expires_at` (`expires_at`),
+  CONSTRAINT `Session User Constraint` FOREIGN KEY (`user_name`) REFERENCES `responsible_api_users` (`name`) ON DELETE CASCADE
+) ENGINE = InnoDB;
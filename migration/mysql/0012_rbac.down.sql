@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS `responsible_user_roles`;
+DROP TABLE IF EXISTS `responsible_role_permissions`;
+DROP TABLE IF EXISTS `responsible_roles`;
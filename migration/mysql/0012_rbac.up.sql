@@ -0,0 +1,24 @@
+CREATE TABLE IF NOT EXISTS `responsible_roles` (
+  `name` varchar(60) NOT NULL,
+  `description` varchar(255) NOT NULL DEFAULT '',
+  `inherits` varchar(60) NOT NULL DEFAULT '',
+  `created` bigint NOT NULL DEFAULT '0',
+  PRIMARY KEY (`name`)
+) ENGINE = InnoDB;
+
+CREATE TABLE IF NOT EXISTS `responsible_role_permissions` (
+  `role_name` varchar(60) NOT NULL,
+  `permission` varchar(120) NOT NULL,
+  `resource` varchar(255) NOT NULL DEFAULT '',
+  PRIMARY KEY (`role_name`, `permission`, `resource`),
+  CONSTRAINT `Permission Role Constraint` FOREIGN KEY (`role_name`) REFERENCES `responsible_roles` (`name`) ON DELETE CASCADE
+) ENGINE = InnoDB;
+
+CREATE TABLE IF NOT EXISTS `responsible_user_roles` (
+  `user_name` varchar(60) NOT NULL,
+  `role_name` varchar(60) NOT NULL,
+  PRIMARY KEY (`user_name`, `role_name`),
+  KEY `role_name` (`role_name`),
+  CONSTRAINT `Assignment User Constraint` FOREIGN KEY (`user_name`) REFERENCES `responsible_api_users` (`name`) ON DELETE CASCADE,
+  CONSTRAINT `Assignment Role Constraint` FOREIGN KEY (`role_name`) REFERENCES `responsible_roles` (`name`) ON DELETE CASCADE
+) ENGINE = InnoDB;
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS `responsible_audit_events`;
@@ -0,0 +1,19 @@
+CREATE TABLE IF NOT EXISTS `responsible_audit_events` (
+  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+  `type` varchar(32) NOT NULL,
+  `time` bigint NOT NULL DEFAULT '0',
+  `success` tinyint(1) NOT NULL DEFAULT '0',
+  `method` varchar(255) NOT NULL DEFAULT '',
+  `subject` varchar(255) NOT NULL DEFAULT '',
+  `actor` varchar(255) NOT NULL DEFAULT '',
+  `client_id` varchar(255) NOT NULL DEFAULT '',
+  `tenant_id` bigint unsigned NOT NULL DEFAULT '0',
+  `ip` varchar(45) NOT NULL DEFAULT '',
+  `user_agent` varchar(512) NOT NULL DEFAULT '',
+  `reason` varchar(512) NOT NULL DEFAULT '',
+  `token_id` varchar(64) NOT NULL DEFAULT '',
+  PRIMARY KEY (`id`),
+  KEY `subject` (`subject`),
+  KEY `time` (`time`),
+  KEY `token_id` (`token_id`)
+) ENGINE = InnoDB;
@@ -0,0 +1,4 @@
+DELETE FROM `responsible_token_bucket` WHERE `client_id` IS NOT NULL;
+ALTER TABLE `responsible_token_bucket` DROP INDEX `client_id`;
+ALTER TABLE `responsible_token_bucket` DROP COLUMN `client_id`;
+ALTER TABLE `responsible_token_bucket` MODIFY `account_id` bigint DEFAULT '0';
@@ -0,0 +1,5 @@
+-- Client credentials tokens have no account, so their buckets are keyed by
+-- OAuth client instead. Their account_id is NULL, which the foreign key allows.
+ALTER TABLE `responsible_token_bucket` MODIFY `account_id` bigint DEFAULT NULL;
+ALTER TABLE `responsible_token_bucket` ADD COLUMN `client_id` varchar(255) DEFAULT NULL AFTER `account_id`;
+ALTER TABLE `responsible_token_bucket` ADD UNIQUE KEY `client_id` (`client_id`);
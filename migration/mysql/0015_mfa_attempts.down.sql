@@ -0,0 +1,2 @@
+ALTER TABLE `responsible_mfa_totp` DROP COLUMN `locked_until`;
+ALTER TABLE `responsible_mfa_totp` DROP COLUMN `failures`;
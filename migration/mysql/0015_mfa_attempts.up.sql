@@ -0,0 +1,3 @@
+-- Invalid codes since the last accepted one, and the lock they trigger.
+ALTER TABLE `responsible_mfa_totp` ADD COLUMN `failures` int NOT NULL DEFAULT '0' AFTER `last_step`;
+ALTER TABLE `responsible_mfa_totp` ADD COLUMN `locked_until` bigint NOT NULL DEFAULT '0' AFTER `failures`;
@@ -0,0 +1,156 @@
+package oauth
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/responsible-api/responsible-auth/resource/authcode"
+)
+
+// challengePattern matches a base64url encoded SHA-256 digest.
+var challengePattern = regexp.MustCompile(`^[A-Za-z0-9\-_]{43}$`)
+
+// AuthorizeHandler serves the authorization endpoint.
+//
+// Errors about the client or redirect URI are shown to the user because the
+// redirect target cannot be trusted; every other error is sent back to the
+// client's redirect URI as described in RFC 6749 section 4.1.2.1.
+func (s *Server) AuthorizeHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "invalid request", http.StatusBadRequest)
+			return
+		}
+
+		c, err := s.config.Clients.FindClient(r.Form.Get("client_id"))
+		if err != nil {
+			http.Error(w, "unknown client", http.StatusBadRequest)
+			return
+		}
+
+		redirectURI := r.Form.Get("redirect_uri")
+		target := redirectURI
+		if target == "" && len(c.RedirectURIs) == 1 {
+			target = c.RedirectURIs[0]
+		}
+		if !c.AllowsRedirect(target) {
+			http.Error(w, "redirect URI is not registered for this client", http.StatusBadRequest)
+			return
+		}
+
+		state := r.Form.Get("state")
+		fail := func(err error) {
+			redirectError(w, r, target, state, err)
+		}
+
+		if r.Form.Get("response_type") != "code" {
+			fail(ErrUnsupportedResponseType)
+			return
+		}
+		if !c.AllowsGrant(GrantAuthorizationCode) {
+			fail(ErrUnauthorizedClient)
+			return
+		}
+
+		challenge := r.Form.Get("code_challenge")
+		if r.Form.Get("code_challenge_method") != CodeChallengeMethodS256 || !challengePattern.MatchString(challenge) {
+			fail(ErrInvalidRequest.WithDescription("PKCE code_challenge with method S256 is required"))
+			return
+		}
+
+		req := &AuthorizeRequest{
+			Client:      c,
+			RedirectURI: target,
+			Scopes:      c.AllowedScopes(strings.Fields(r.Form.Get("scope"))),
+			State:       state,
+			Nonce:       r.Form.Get("nonce"),
+		}
+
+		consent, err := s.config.Login(w, r, req)
+		if err != nil {
+			fail(err)
+			return
+		}
+		if consent == nil {
+			// The login callback has written its own response
+			return
+		}
+
+		scopes := req.Scopes
+		if consent.Scopes != nil {
+			if !subsetOf(consent.Scopes, req.Scopes) {
+				fail(ErrInvalidScope.WithDescription("consent granted scopes that were not requested"))
+				return
+			}
+			scopes = consent.Scopes
+		}
+		if consent.User == nil || !consent.User.IsActive() {
+			fail(ErrAccessDenied)
+			return
+		}
+
+		authTime := consent.AuthTime
+		if authTime.IsZero() {
+			authTime = s.now()
+		}
+
+		code, err := randomToken()
+		if err != nil {
+			fail(err)
+			return
+		}
+
+		err = s.config.Codes.CreateAuthorizationCode(&authcode.Code{
+			Hash:        hashToken(code),
+			ClientID:    c.ID,
+			UserName:    consent.User.Name,
+			RedirectURI: redirectURI,
+			Scopes:      scopes,
+			Challenge:   challenge,
+			Nonce:       req.Nonce,
+			AuthTime:    authTime.Unix(),
+			ExpiresAt:   s.now().Add(s.config.CodeLifetime).Unix(),
+		})
+		if err != nil {
+			fail(err)
+			return
+		}
+
+		redirect(w, r, target, url.Values{"code": {code}, "state": {state}})
+	})
+}
+
+// redirectError sends an OAuth error to the client's redirect URI.
+func redirectError(w http.ResponseWriter, r *http.Request, target, state string, err error) {
+	var oauthErr *Error
+	if !errors.As(err, &oauthErr) {
+		oauthErr = ErrServerError
+	}
+
+	params := url.Values{"error": {oauthErr.Code}, "state": {state}}
+	if oauthErr.Description != "" {
+		params.Set("error_description", oauthErr.Description)
+	}
+	redirect(w, r, target, params)
+}
+
+// redirect appends params to the target's query, dropping empty values.
+func redirect(w http.ResponseWriter, r *http.Request, target string, params url.Values) {
+	u, err := url.Parse(target)
+	if err != nil {
+		http.Error(w, "invalid redirect URI", http.StatusBadRequest)
+		return
+	}
+
+	query := u.Query()
+	for key, values := range params {
+		if len(values) > 0 && values[0] != "" {
+			query.Set(key, values[0])
+		}
+	}
+	u.RawQuery = query.Encode()
+	http.Redirect(w, r, u.String(), http.StatusFound)
+}
@@ -0,0 +1,315 @@
+package oauth
+
+import (
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/responsible-api/responsible-auth/resource/access"
+	"github.com/responsible-api/responsible-auth/resource/client"
+	"github.com/responsible-api/responsible-auth/resource/devicecode"
+	"github.com/responsible-api/responsible-auth/storage"
+)
+
+// Device flow defaults from RFC 8628.
+const (
+	DefaultDeviceCodeLifetime = 10 * time.Minute
+	DefaultDevicePollInterval = 5 * time.Second
+)
+
+// userCodeAlphabet avoids vowels, so codes never spell words, and characters
+// that are easy to confuse when typed on another device.
+const userCodeAlphabet = "BCDFGHJKLMNPQRSTVWXZ"
+
+const userCodeLength = 8
+
+// DeviceAuthorization is the response of the device authorization endpoint.
+type DeviceAuthorization struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int64  `json:"expires_in"`
+	Interval                int64  `json:"interval"`
+}
+
+// DeviceAuthorizationHandler serves the device authorization endpoint, where a
+// device starts the flow and receives the codes to show to the user.
+func (s *Server) DeviceAuthorizationHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeError(w, ErrInvalidRequest.WithDescription("the device authorization endpoint only accepts POST"))
+			return
+		}
+		if s.config.DeviceCodes == nil {
+			writeError(w, ErrUnsupportedGrantType)
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			writeError(w, ErrInvalidRequest)
+			return
+		}
+
+		c, err := s.authenticateClient(r)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		if !c.AllowsGrant(GrantDeviceCode) {
+			writeError(w, ErrUnauthorizedClient.WithDescription("client may not use the device flow"))
+			return
+		}
+
+		response, err := s.authorizeDevice(c, c.AllowedScopes(strings.Fields(r.PostForm.Get("scope"))))
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, response)
+	})
+}
+
+// authorizeDevice stores a pending device authorization and returns its codes.
+func (s *Server) authorizeDevice(c *client.Client, scopes []string) (*DeviceAuthorization, error) {
+	deviceCode, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	userCode, err := randomUserCode()
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	interval := int64(s.config.DevicePollInterval / time.Second)
+	err = s.config.DeviceCodes.CreateDeviceCode(&devicecode.Code{
+		Hash:      hashToken(deviceCode),
+		UserCode:  userCode,
+		ClientID:  c.ID,
+		Scopes:    scopes,
+		Status:    devicecode.StatusPending,
+		Interval:  interval,
+		ExpiresAt: now.Add(s.config.DeviceCodeLifetime).Unix(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	display := userCode[:userCodeLength/2] + "-" + userCode[userCodeLength/2:]
+	verificationURI := strings.TrimSuffix(s.config.Options.Issuer, "/") + s.config.Endpoints.Verification
+	return &DeviceAuthorization{
+		DeviceCode:              deviceCode,
+		UserCode:                display,
+		VerificationURI:         verificationURI,
+		VerificationURIComplete: verificationURI + "?" + url.Values{"user_code": {display}}.Encode(),
+		ExpiresIn:               int64(s.config.DeviceCodeLifetime / time.Second),
+		Interval:                interval,
+	}, nil
+}
+
+// VerificationHandler serves the page where a signed-in user approves a device.
+//
+// It calls DeviceLogin with the pending request; req.Client is nil until the
+// user has entered a user code, so the callback can render an entry form.
+// Approval and denial are answered with a short plain text confirmation.
+func (s *Server) VerificationHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, "invalid request", http.StatusBadRequest)
+			return
+		}
+
+		userCode := normaliseUserCode(r.Form.Get("user_code"))
+		if userCode == "" {
+			if _, err := s.config.DeviceLogin(w, r, &AuthorizeRequest{}); err != nil {
+				http.Error(w, "invalid request", http.StatusBadRequest)
+			}
+			return
+		}
+
+		req, err := s.deviceRequest(userCode)
+		if err != nil {
+			http.Error(w, "unknown or expired code", http.StatusBadRequest)
+			return
+		}
+
+		consent, err := s.config.DeviceLogin(w, r, req)
+		switch {
+		case errors.Is(err, ErrAccessDenied):
+			if err := s.DenyDevice(userCode); err != nil {
+				http.Error(w, "unknown or expired code", http.StatusBadRequest)
+				return
+			}
+			w.Write([]byte("Request denied. You can close this window.\n"))
+		case err != nil:
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		case consent == nil:
+			// The login callback has written its own response
+		default:
+			if err := s.ApproveDevice(userCode, consent); err != nil {
+				http.Error(w, "unknown or expired code", http.StatusBadRequest)
+				return
+			}
+			w.Write([]byte("Device approved. You can return to your device.\n"))
+		}
+	})
+}
+
+// deviceRequest describes the pending device authorization for a user code.
+func (s *Server) deviceRequest(userCode string) (*AuthorizeRequest, error) {
+	code, err := s.config.DeviceCodes.FindDeviceCodeByUserCode(userCode)
+	if err != nil {
+		return nil, err
+	}
+	if code.Status != devicecode.StatusPending || code.IsExpired(s.now().Unix()) {
+		return nil, storage.ErrDeviceCodeNotFound
+	}
+
+	c, err := s.config.Clients.FindClient(code.ClientID)
+	if err != nil {
+		return nil, err
+	}
+	return &AuthorizeRequest{Client: c, Scopes: code.Scopes, UserCode: userCode}, nil
+}
+
+// ApproveDevice grants a pending device authorization to the consenting user.
+// Applications with their own verification page call it directly.
+func (s *Server) ApproveDevice(userCode string, consent *Consent) error {
+	if consent == nil || consent.User == nil || !consent.User.IsActive() {
+		return ErrAccessDenied
+	}
+
+	return s.decideDevice(userCode, func(code *devicecode.Code) error {
+		if consent.Scopes != nil {
+			if !subsetOf(consent.Scopes, code.Scopes) {
+				return ErrInvalidScope.WithDescription("consent granted scopes that were not requested")
+			}
+			code.Scopes = consent.Scopes
+		}
+
+		authTime := consent.AuthTime
+		if authTime.IsZero() {
+			authTime = s.now()
+		}
+
+		code.Status = devicecode.StatusApproved
+		code.UserName = consent.User.Name
+		code.AuthTime = authTime.Unix()
+		return nil
+	})
+}
+
+// DenyDevice rejects a pending device authorization; the device's next poll fails with access_denied.
+func (s *Server) DenyDevice(userCode string) error {
+	return s.decideDevice(userCode, func(code *devicecode.Code) error {
+		code.Status = devicecode.StatusDenied
+		return nil
+	})
+}
+
+// decideDevice applies decide to a pending, unexpired device authorization.
+func (s *Server) decideDevice(userCode string, decide func(code *devicecode.Code) error) error {
+	found, err := s.config.DeviceCodes.FindDeviceCodeByUserCode(normaliseUserCode(userCode))
+	if err != nil {
+		return err
+	}
+
+	now := s.now().Unix()
+	var result error
+	_, err = s.config.DeviceCodes.UpdateDeviceCode(found.Hash, func(code *devicecode.Code) {
+		if code.Status != devicecode.StatusPending || code.IsExpired(now) {
+			result = storage.ErrDeviceCodeNotFound
+			return
+		}
+		result = decide(code)
+	})
+	if err != nil {
+		return err
+	}
+	return result
+}
+
+// deviceCodeGrant answers a device polling for its tokens.
+func (s *Server) deviceCodeGrant(r *http.Request, c *client.Client) (*access.ResponseDTO, error) {
+	hash := hashToken(r.PostForm.Get("device_code"))
+	now := s.now().Unix()
+
+	var result error
+	code, err := s.config.DeviceCodes.UpdateDeviceCode(hash, func(code *devicecode.Code) {
+		switch {
+		case code.ClientID != c.ID:
+			result = ErrInvalidGrant.WithDescription("device code was issued to another client")
+		case code.IsExpired(now):
+			result = ErrExpiredToken
+		case code.Status == devicecode.StatusDenied:
+			result = errDeviceAccessDenied
+		case code.Status == devicecode.StatusApproved:
+			result = nil
+		case code.LastPoll != 0 && now-code.LastPoll < code.Interval:
+			// Every early poll makes the device wait five seconds longer
+			code.Interval += 5
+			code.LastPoll = now
+			result = ErrSlowDown
+		default:
+			code.LastPoll = now
+			result = ErrAuthorizationPending
+		}
+	})
+	if errors.Is(err, storage.ErrDeviceCodeNotFound) {
+		return nil, ErrInvalidGrant.WithDescription("unknown or used device code")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if result != nil {
+		return nil, result
+	}
+
+	// Only the poll that deletes the approved code receives tokens
+	if err := s.config.DeviceCodes.DeleteDeviceCode(hash); err != nil {
+		if errors.Is(err, storage.ErrDeviceCodeNotFound) {
+			return nil, ErrInvalidGrant.WithDescription("unknown or used device code")
+		}
+		return nil, err
+	}
+
+	u, err := s.activeUser(code.UserName)
+	if err != nil {
+		return nil, err
+	}
+	opts, err := s.userOptions(c, u, code.Scopes, GrantDeviceCode)
+	if err != nil {
+		return nil, err
+	}
+	return s.issueTokens(opts, u.Name)
+}
+
+// randomUserCode returns a user code drawn uniformly from userCodeAlphabet.
+func randomUserCode() (string, error) {
+	max := big.NewInt(int64(len(userCodeAlphabet)))
+	code := make([]byte, userCodeLength)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		code[i] = userCodeAlphabet[n.Int64()]
+	}
+	return string(code), nil
+}
+
+// normaliseUserCode accepts codes typed in any case, with or without separators.
+func normaliseUserCode(userCode string) string {
+	var b strings.Builder
+	for _, r := range strings.ToUpper(userCode) {
+		if strings.ContainsRune(userCodeAlphabet, r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,228 @@
+package oauth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/responsible-api/responsible-auth/examples/memory"
+	"github.com/responsible-api/responsible-auth/resource/client"
+	"github.com/responsible-api/responsible-auth/testutils"
+)
+
+const testDeviceClientID = "tv-client"
+
+// newDeviceServer returns a server with the device flow enabled and a clock
+// that only moves when the returned function is called.
+func newDeviceServer(t *testing.T, login LoginFunc) (*Server, func(time.Duration)) {
+	t.Helper()
+	users := testutils.NewMockStorage()
+	testUser, err := users.FindUserByName("testuser")
+	if err != nil {
+		t.Fatalf("FindUserByName() unexpected error = %v", err)
+	}
+	if login == nil {
+		login = func(w http.ResponseWriter, r *http.Request, req *AuthorizeRequest) (*Consent, error) {
+			return &Consent{User: testUser}, nil
+		}
+	}
+
+	options := testutils.TestAuthOptions()
+	options.Issuer = "https://auth.example.com"
+	s := NewServer(Config{
+		Options: options,
+		Users:   users,
+		Clients: memory.NewInMemoryClientStorage(&client.Client{
+			ID:         testDeviceClientID,
+			Name:       "Test TV",
+			GrantTypes: []string{GrantDeviceCode, GrantRefreshToken},
+			Scopes:     []string{"read", "write"},
+		}, &client.Client{
+			ID:           testClientID,
+			Name:         "Test SPA",
+			RedirectURIs: []string{testRedirectURI},
+			GrantTypes:   []string{GrantAuthorizationCode},
+		}),
+		Codes:       memory.NewInMemoryAuthorizationCodeStorage(),
+		DeviceCodes: memory.NewInMemoryDeviceCodeStorage(),
+		Login:       login,
+	})
+
+	now := time.Unix(1700000000, 0)
+	s.now = func() time.Time { return now }
+	return s, func(d time.Duration) { now = now.Add(d) }
+}
+
+func authorizeDevice(t *testing.T, s *Server, clientID, scope string) *DeviceAuthorization {
+	t.Helper()
+	form := url.Values{"client_id": {clientID}, "scope": {scope}}
+	req := httptest.NewRequest(http.MethodPost, "/device_authorization", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	s.DeviceAuthorizationHandler().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("device authorization status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	response := &DeviceAuthorization{}
+	if err := json.NewDecoder(rec.Body).Decode(response); err != nil {
+		t.Fatalf("invalid device authorization response: %v", err)
+	}
+	return response
+}
+
+func verify(s *Server, userCode string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/device", strings.NewReader(url.Values{"user_code": {userCode}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	s.VerificationHandler().ServeHTTP(rec, req)
+	return rec
+}
+
+func pollDevice(s *Server, clientID, deviceCode string) *httptest.ResponseRecorder {
+	return postToken(s, url.Values{
+		"grant_type":  {GrantDeviceCode},
+		"client_id":   {clientID},
+		"device_code": {deviceCode},
+	})
+}
+
+func TestDeviceFlow(t *testing.T) {
+	s, advance := newDeviceServer(t, nil)
+
+	device := authorizeDevice(t, s, testDeviceClientID, "read admin")
+	if !regexp.MustCompile(`^[B-Z]{4}-[B-Z]{4}$`).MatchString(device.UserCode) {
+		t.Errorf("user_code = %q, want XXXX-XXXX", device.UserCode)
+	}
+	if device.VerificationURI != "https://auth.example.com/device" || device.ExpiresIn != 600 || device.Interval != 5 {
+		t.Errorf("device authorization = %+v, want default verification URI, lifetime and interval", device)
+	}
+	if !strings.HasPrefix(device.VerificationURIComplete, device.VerificationURI+"?user_code=") {
+		t.Errorf("verification_uri_complete = %q, want it to carry the user code", device.VerificationURIComplete)
+	}
+
+	if got := decodeError(t, pollDevice(s, testDeviceClientID, device.DeviceCode)); got != "authorization_pending" {
+		t.Errorf("first poll error = %q, want authorization_pending", got)
+	}
+	if got := decodeError(t, pollDevice(s, testDeviceClientID, device.DeviceCode)); got != "slow_down" {
+		t.Errorf("early poll error = %q, want slow_down", got)
+	}
+
+	// The interval was raised to 10 seconds by the early poll
+	advance(5 * time.Second)
+	if got := decodeError(t, pollDevice(s, testDeviceClientID, device.DeviceCode)); got != "slow_down" {
+		t.Errorf("poll within raised interval error = %q, want slow_down", got)
+	}
+
+	// Users may type the code in lower case and without the dash
+	typed := strings.ToLower(strings.ReplaceAll(device.UserCode, "-", ""))
+	if rec := verify(s, typed); rec.Code != http.StatusOK {
+		t.Fatalf("verification status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	if rec := verify(s, device.UserCode); rec.Code != http.StatusBadRequest {
+		t.Errorf("second verification status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+
+	advance(15 * time.Second)
+	response := decodeTokens(t, pollDevice(s, testDeviceClientID, device.DeviceCode))
+	if response.RefreshToken == "" || response.Scopes != "read" {
+		t.Errorf("token response = %+v, want refresh token and scope %q", response, "read")
+	}
+
+	advance(10 * time.Second)
+	if got := decodeError(t, pollDevice(s, testDeviceClientID, device.DeviceCode)); got != "invalid_grant" {
+		t.Errorf("poll after redemption error = %q, want invalid_grant", got)
+	}
+}
+
+func TestDeviceFlow_Denied(t *testing.T) {
+	s, _ := newDeviceServer(t, func(w http.ResponseWriter, r *http.Request, req *AuthorizeRequest) (*Consent, error) {
+		return nil, ErrAccessDenied
+	})
+
+	device := authorizeDevice(t, s, testDeviceClientID, "read")
+	if rec := verify(s, device.UserCode); rec.Code != http.StatusOK {
+		t.Fatalf("verification status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+
+	rec := pollDevice(s, testDeviceClientID, device.DeviceCode)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("denied poll status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+	if got := decodeError(t, rec); got != "access_denied" {
+		t.Errorf("denied poll error = %q, want access_denied", got)
+	}
+}
+
+func TestDeviceFlow_Expired(t *testing.T) {
+	s, advance := newDeviceServer(t, nil)
+
+	device := authorizeDevice(t, s, testDeviceClientID, "read")
+	advance(DefaultDeviceCodeLifetime)
+
+	if rec := verify(s, device.UserCode); rec.Code != http.StatusBadRequest {
+		t.Errorf("expired verification status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+	if got := decodeError(t, pollDevice(s, testDeviceClientID, device.DeviceCode)); got != "expired_token" {
+		t.Errorf("expired poll error = %q, want expired_token", got)
+	}
+}
+
+func TestDeviceFlow_Errors(t *testing.T) {
+	s, _ := newDeviceServer(t, nil)
+	device := authorizeDevice(t, s, testDeviceClientID, "read")
+
+	if got := decodeError(t, pollDevice(s, testClientID, device.DeviceCode)); got != "unauthorized_client" {
+		t.Errorf("poll by client without the grant error = %q, want unauthorized_client", got)
+	}
+	if got := decodeError(t, pollDevice(s, testDeviceClientID, "unknown")); got != "invalid_grant" {
+		t.Errorf("poll with unknown device code error = %q, want invalid_grant", got)
+	}
+	if rec := verify(s, "BCDF-GHJK"); rec.Code != http.StatusBadRequest {
+		t.Errorf("unknown user code verification status = %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+
+	form := url.Values{"client_id": {testClientID}}
+	req := httptest.NewRequest(http.MethodPost, "/device_authorization", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	s.DeviceAuthorizationHandler().ServeHTTP(rec, req)
+	if got := decodeError(t, rec); got != "unauthorized_client" {
+		t.Errorf("device authorization by client without the grant error = %q, want unauthorized_client", got)
+	}
+
+	// Servers without device code storage do not offer the grant
+	if got := decodeError(t, pollDevice(newTestServer(t), testClientID, device.DeviceCode)); got != "unsupported_grant_type" {
+		t.Errorf("poll without device storage error = %q, want unsupported_grant_type", got)
+	}
+}
+
+func TestVerificationHandler_EntryForm(t *testing.T) {
+	var got *AuthorizeRequest
+	s, _ := newDeviceServer(t, func(w http.ResponseWriter, r *http.Request, req *AuthorizeRequest) (*Consent, error) {
+		got = req
+		w.Write([]byte("enter your code"))
+		return nil, nil
+	})
+
+	rec := httptest.NewRecorder()
+	s.VerificationHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/device", nil))
+
+	if got == nil || got.Client != nil {
+		t.Errorf("DeviceLogin() request = %+v, want one without a client", got)
+	}
+	if rec.Body.String() != "enter your code" {
+		t.Errorf("verification body = %q, want the login callback's response", rec.Body.String())
+	}
+
+	device := authorizeDevice(t, s, testDeviceClientID, "read write")
+	verify(s, device.UserCode)
+	if got.Client == nil || got.Client.ID != testDeviceClientID || got.UserCode != strings.ReplaceAll(device.UserCode, "-", "") {
+		t.Errorf("DeviceLogin() request = %+v, want the pending device authorization", got)
+	}
+}
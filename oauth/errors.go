@@ -0,0 +1,83 @@
+package oauth
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// Error is an OAuth 2.0 error response (RFC 6749 section 5.2).
+type Error struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+	status      int
+}
+
+func (e *Error) Error() string {
+	if e.Description == "" {
+		return e.Code
+	}
+	return e.Code + ": " + e.Description
+}
+
+// Is matches errors with the same code, so errors.Is(err, ErrInvalidGrant)
+// holds for any invalid_grant error regardless of its description.
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// WithDescription returns a copy of the error carrying a human readable description.
+func (e *Error) WithDescription(description string) *Error {
+	c := *e
+	c.Description = description
+	return &c
+}
+
+// Errors defined by RFC 6749.
+var (
+	ErrInvalidRequest          = &Error{Code: "invalid_request", status: http.StatusBadRequest}
+	ErrInvalidClient           = &Error{Code: "invalid_client", status: http.StatusUnauthorized}
+	ErrInvalidGrant            = &Error{Code: "invalid_grant", status: http.StatusBadRequest}
+	ErrUnauthorizedClient      = &Error{Code: "unauthorized_client", status: http.StatusBadRequest}
+	ErrUnsupportedGrantType    = &Error{Code: "unsupported_grant_type", status: http.StatusBadRequest}
+	ErrUnsupportedResponseType = &Error{Code: "unsupported_response_type", status: http.StatusBadRequest}
+	ErrInvalidScope            = &Error{Code: "invalid_scope", status: http.StatusBadRequest}
+	ErrAccessDenied            = &Error{Code: "access_denied", status: http.StatusForbidden}
+	ErrServerError             = &Error{Code: "server_error", status: http.StatusInternalServerError}
+
+	// ErrInvalidTarget is defined by RFC 8693 for audiences the client may not request
+	ErrInvalidTarget = &Error{Code: "invalid_target", status: http.StatusBadRequest}
+)
+
+// Errors returned while a device polls the token endpoint (RFC 8628 section 3.5).
+var (
+	ErrAuthorizationPending = &Error{Code: "authorization_pending", status: http.StatusBadRequest}
+	ErrSlowDown             = &Error{Code: "slow_down", status: http.StatusBadRequest}
+	ErrExpiredToken         = &Error{Code: "expired_token", status: http.StatusBadRequest}
+
+	// errDeviceAccessDenied is access_denied as the token endpoint reports it
+	errDeviceAccessDenied = &Error{Code: "access_denied", status: http.StatusBadRequest}
+)
+
+// writeError sends err as a JSON error response. Errors that are not an
+// *Error are reported as server_error without leaking their message.
+func writeError(w http.ResponseWriter, err error) {
+	var oauthErr *Error
+	if !errors.As(err, &oauthErr) {
+		oauthErr = ErrServerError
+	}
+
+	if oauthErr.status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", `Basic realm="token"`)
+	}
+	writeJSON(w, oauthErr.status, oauthErr)
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
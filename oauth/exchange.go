@@ -0,0 +1,125 @@
+package oauth
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/responsible-api/responsible-auth/concerns"
+	"github.com/responsible-api/responsible-auth/internal"
+	"github.com/responsible-api/responsible-auth/resource/access"
+	"github.com/responsible-api/responsible-auth/resource/client"
+)
+
+// TokenTypeAccessToken identifies access tokens in token exchange (RFC 8693 section 3).
+const TokenTypeAccessToken = "urn:ietf:params:oauth:token-type:access_token"
+
+// ExchangePolicy approves the audiences a client asks for when exchanging the
+// subject's token. Returning an error rejects the exchange; errors other than
+// an *Error are reported as invalid_target.
+type ExchangePolicy func(c *client.Client, subject *concerns.ClaimsGeneric, audience []string) error
+
+// AllowAudiences returns a policy letting each client, by ID, request only
+// the audiences listed for it. Clients that are not listed may not exchange.
+func AllowAudiences(audiences map[string][]string) ExchangePolicy {
+	return func(c *client.Client, subject *concerns.ClaimsGeneric, audience []string) error {
+		allowed := audiences[c.ID]
+		for _, aud := range audience {
+			if !contains(allowed, aud) {
+				return fmt.Errorf("client may not request audience %q", aud)
+			}
+		}
+		return nil
+	}
+}
+
+// tokenExchangeGrant lets a confidential client, such as a gateway, trade a
+// user's access token for one it can forward to a backend service. The new
+// token keeps the subject's account, tenant, roles, amr and custom claims, is
+// restricted to the requested audience, carries only the requested scopes
+// that both the subject token and the client allow, and names the client in
+// its act claim. It never outlives the
+// subject token and no refresh token is issued. Exchanges are refused unless
+// Config.Exchange approves the audience.
+func (s *Server) tokenExchangeGrant(r *http.Request, c *client.Client) (*access.ResponseDTO, error) {
+	if !c.IsConfidential() {
+		return nil, ErrUnauthorizedClient.WithDescription("token exchange requires a confidential client")
+	}
+	if s.config.Exchange == nil {
+		return nil, ErrInvalidTarget.WithDescription("token exchange has no audience policy")
+	}
+
+	form := r.PostForm
+	if form.Get("subject_token_type") != TokenTypeAccessToken {
+		return nil, ErrInvalidRequest.WithDescription("subject_token_type must be " + TokenTypeAccessToken)
+	}
+	if requested := form.Get("requested_token_type"); requested != "" && requested != TokenTypeAccessToken {
+		return nil, ErrInvalidRequest.WithDescription("only access tokens can be requested")
+	}
+	if form.Get("actor_token") != "" {
+		return nil, ErrInvalidRequest.WithDescription("the actor is the authenticated client; actor_token is not supported")
+	}
+
+	audience := form["audience"]
+	if len(audience) == 0 {
+		return nil, ErrInvalidRequest.WithDescription("audience is required")
+	}
+
+	token, err := internal.Validate(form.Get("subject_token"), s.config.Options)
+	if err != nil || token == nil {
+		return nil, ErrInvalidGrant.WithDescription("invalid subject token")
+	}
+	subject, ok := token.Claims.(*concerns.ClaimsGeneric)
+	if !ok {
+		return nil, ErrInvalidGrant.WithDescription("invalid subject token")
+	}
+	// A token already restricted to other recipients was not meant for this client
+	if len(subject.Audience) > 0 && !contains(subject.Audience, c.ID) {
+		return nil, ErrInvalidGrant.WithDescription("subject token is not intended for this client")
+	}
+
+	if err := s.config.Exchange(c, subject, audience); err != nil {
+		var oauthErr *Error
+		if errors.As(err, &oauthErr) {
+			return nil, err
+		}
+		return nil, ErrInvalidTarget.WithDescription(err.Error())
+	}
+
+	scopes := strings.Fields(subject.Scopes)
+	if requested := strings.Fields(form.Get("scope")); len(requested) > 0 {
+		if !subsetOf(requested, scopes) {
+			return nil, ErrInvalidScope.WithDescription("scope exceeds the subject token")
+		}
+		scopes = requested
+	}
+	scopes = c.AllowedScopes(scopes)
+	if len(scopes) == 0 {
+		return nil, ErrInvalidScope.WithDescription("none of the requested scopes are allowed for this client")
+	}
+
+	opts := s.clientOptions(c, scopes)
+	opts.Subject = subject.Subject
+	opts.AccountID = subject.AccountID
+	opts.TenantID = subject.TenantID
+	opts.Role = subject.Role
+	opts.Roles = subject.Roles
+	opts.AMR = subject.AMR
+	opts.CustomClaims = subject.CustomClaims
+	opts.Audience = audience
+	opts.Actor = &concerns.Actor{Subject: c.ID, Actor: subject.Actor}
+
+	remaining := time.Until(subject.ExpiresAt.Time)
+	if opts.TokenDuration == 0 || remaining < opts.TokenDuration {
+		opts.TokenDuration = remaining
+	}
+
+	response, err := s.issueTokens(opts, "")
+	if err != nil {
+		return nil, err
+	}
+	response.IssuedTokenType = TokenTypeAccessToken
+	return response, nil
+}
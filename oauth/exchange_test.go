@@ -0,0 +1,228 @@
+package oauth
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/responsible-api/responsible-auth/concerns"
+	"github.com/responsible-api/responsible-auth/internal"
+	"github.com/responsible-api/responsible-auth/resource/client"
+	"github.com/responsible-api/responsible-auth/testutils"
+)
+
+// registerExchanger registers a confidential client allowed to use token exchange.
+func registerExchanger(t *testing.T, s *Server, name string) (string, string) {
+	t.Helper()
+	c, secret, err := s.RegisterClient(ClientRegistration{
+		Name:         name,
+		Confidential: true,
+		GrantTypes:   []string{GrantTokenExchange},
+		Scopes:       []string{"read", "write"},
+	})
+	if err != nil {
+		t.Fatalf("RegisterClient() unexpected error = %v", err)
+	}
+	return c.ID, secret
+}
+
+func exchangeForm(subjectToken, scope string, audience ...string) url.Values {
+	form := url.Values{
+		"grant_type":         {GrantTokenExchange},
+		"subject_token":      {subjectToken},
+		"subject_token_type": {TokenTypeAccessToken},
+		"audience":           audience,
+	}
+	if scope != "" {
+		form.Set("scope", scope)
+	}
+	return form
+}
+
+func exchange(s *Server, clientID, secret string, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/token", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(clientID, secret)
+	rec := httptest.NewRecorder()
+	s.TokenHandler().ServeHTTP(rec, req)
+	return rec
+}
+
+func exchangedClaims(t *testing.T, accessToken string) *concerns.ClaimsGeneric {
+	t.Helper()
+	token, err := internal.Validate(accessToken, testutils.TestAuthOptions())
+	if err != nil {
+		t.Fatalf("Validate() unexpected error = %v", err)
+	}
+	return token.Claims.(*concerns.ClaimsGeneric)
+}
+
+func TestTokenExchangeGrant(t *testing.T) {
+	s := newTestServer(t)
+	gatewayID, gatewaySecret := registerExchanger(t, s, "gateway")
+	s.config.Exchange = AllowAudiences(map[string][]string{gatewayID: {"billing"}})
+	userToken := decodeTokens(t, postToken(s, codeExchange(issueCode(t, s)))).AccessToken
+	subject := exchangedClaims(t, userToken)
+
+	response := decodeTokens(t, exchange(s, gatewayID, gatewaySecret, exchangeForm(userToken, "read", "billing")))
+	if response.Scopes != "read" || response.RefreshToken != "" || response.IssuedTokenType != TokenTypeAccessToken {
+		t.Errorf("exchange response = %+v, want scope %q, no refresh token and an access token type", response, "read")
+	}
+
+	claims := exchangedClaims(t, response.AccessToken)
+	if claims.Subject != subject.Subject || claims.AccountID != subject.AccountID {
+		t.Errorf("exchanged subject = %q/%d, want %q/%d", claims.Subject, claims.AccountID, subject.Subject, subject.AccountID)
+	}
+	if len(claims.Audience) != 1 || claims.Audience[0] != "billing" {
+		t.Errorf("exchanged audience = %v, want [billing]", claims.Audience)
+	}
+	if claims.Actor == nil || claims.Actor.Subject != gatewayID || claims.Actor.Actor != nil {
+		t.Errorf("exchanged act = %+v, want the gateway %q", claims.Actor, gatewayID)
+	}
+	if claims.ExpiresAt.After(subject.ExpiresAt.Time) {
+		t.Errorf("exchanged token expires at %v, after the subject token at %v", claims.ExpiresAt, subject.ExpiresAt)
+	}
+
+	// Without a scope parameter the subject token's scopes are kept
+	response = decodeTokens(t, exchange(s, gatewayID, gatewaySecret, exchangeForm(userToken, "", "billing")))
+	if response.Scopes != "read write" {
+		t.Errorf("exchange without scope = %q, want %q", response.Scopes, "read write")
+	}
+}
+
+func TestTokenExchangeGrant_KeepsPrincipal(t *testing.T) {
+	s := newTestServer(t)
+	gatewayID, gatewaySecret := registerExchanger(t, s, "gateway")
+	s.config.Exchange = AllowAudiences(map[string][]string{gatewayID: {"billing"}})
+
+	options := testutils.TestAuthOptions()
+	options.Subject = "testuser"
+	options.Scopes = "read write"
+	options.AccountID = 42
+	options.TenantID = 42
+	options.Roles = []string{"billing-admin", "support"}
+	options.AMR = []string{"pwd", "otp", "mfa"}
+	subjectToken, err := internal.CreateAccessToken(options)
+	if err != nil {
+		t.Fatalf("CreateAccessToken() unexpected error = %v", err)
+	}
+
+	response := decodeTokens(t, exchange(s, gatewayID, gatewaySecret, exchangeForm(subjectToken.GetToken(), "read", "billing")))
+	claims := exchangedClaims(t, response.AccessToken)
+	if claims.AccountID != 42 || claims.TenantID != 42 {
+		t.Errorf("exchanged account = %d tenant = %d, want 42 and 42", claims.AccountID, claims.TenantID)
+	}
+	if !slices.Equal(claims.Roles, options.Roles) || !slices.Equal(claims.AMR, options.AMR) {
+		t.Errorf("exchanged roles = %v amr = %v, want %v and %v", claims.Roles, claims.AMR, options.Roles, options.AMR)
+	}
+}
+
+func TestTokenExchangeGrant_Delegation(t *testing.T) {
+	s := newTestServer(t)
+	gatewayID, gatewaySecret := registerExchanger(t, s, "gateway")
+	ordersID, ordersSecret := registerExchanger(t, s, "orders")
+	s.config.Exchange = AllowAudiences(map[string][]string{
+		gatewayID: {"billing", ordersID},
+		ordersID:  {"billing"},
+	})
+	userToken := decodeTokens(t, postToken(s, codeExchange(issueCode(t, s)))).AccessToken
+
+	ordersToken := decodeTokens(t, exchange(s, gatewayID, gatewaySecret, exchangeForm(userToken, "read", ordersID))).AccessToken
+
+	// The orders service may exchange the token addressed to it, the gateway may not
+	if got := decodeError(t, exchange(s, gatewayID, gatewaySecret, exchangeForm(ordersToken, "", "billing"))); got != "invalid_grant" {
+		t.Errorf("exchange of a token for another audience error = %q, want invalid_grant", got)
+	}
+
+	response := decodeTokens(t, exchange(s, ordersID, ordersSecret, exchangeForm(ordersToken, "", "billing")))
+	act := exchangedClaims(t, response.AccessToken).Actor
+	if act == nil || act.Subject != ordersID || act.Actor == nil || act.Actor.Subject != gatewayID {
+		t.Errorf("delegated act = %+v, want orders acting for the gateway", act)
+	}
+	if response.Scopes != "read" {
+		t.Errorf("delegated scope = %q, want %q", response.Scopes, "read")
+	}
+}
+
+func TestTokenExchangeGrant_Errors(t *testing.T) {
+	s := newTestServer(t)
+	gatewayID, gatewaySecret := registerExchanger(t, s, "gateway")
+	policy := AllowAudiences(map[string][]string{gatewayID: {"billing"}})
+	s.config.Exchange = policy
+	userToken := decodeTokens(t, postToken(s, codeExchange(issueCode(t, s)))).AccessToken
+
+	wrongType := exchangeForm(userToken, "", "billing")
+	wrongType.Set("subject_token_type", "urn:ietf:params:oauth:token-type:refresh_token")
+
+	tests := []struct {
+		name        string
+		form        url.Values
+		expectError string
+	}{
+		{name: "widened scope", form: exchangeForm(userToken, "read admin", "billing"), expectError: "invalid_scope"},
+		{name: "missing audience", form: exchangeForm(userToken, "read"), expectError: "invalid_request"},
+		{name: "invalid subject token", form: exchangeForm("not-a-token", "read", "billing"), expectError: "invalid_grant"},
+		{name: "wrong subject token type", form: wrongType, expectError: "invalid_request"},
+		{name: "audience not allowed", form: exchangeForm(userToken, "read", "ledger"), expectError: "invalid_target"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := decodeError(t, exchange(s, gatewayID, gatewaySecret, tt.form)); got != tt.expectError {
+				t.Errorf("exchange error = %q, want %q", got, tt.expectError)
+			}
+		})
+	}
+
+	t.Run("client without the grant", func(t *testing.T) {
+		serviceID, serviceSecret := registerService(t, s, ClientRegistration{Scopes: []string{"read"}})
+		if got := decodeError(t, exchange(s, serviceID, serviceSecret, exchangeForm(userToken, "read", "billing"))); got != "unauthorized_client" {
+			t.Errorf("exchange error = %q, want unauthorized_client", got)
+		}
+	})
+
+	t.Run("rejected by policy", func(t *testing.T) {
+		s.config.Exchange = func(c *client.Client, subject *concerns.ClaimsGeneric, audience []string) error {
+			return errors.New("gateway may only call billing")
+		}
+		defer func() { s.config.Exchange = policy }()
+
+		if got := decodeError(t, exchange(s, gatewayID, gatewaySecret, exchangeForm(userToken, "read", "ledger"))); got != "invalid_target" {
+			t.Errorf("exchange error = %q, want invalid_target", got)
+		}
+	})
+
+	t.Run("without policy", func(t *testing.T) {
+		s.config.Exchange = nil
+		defer func() { s.config.Exchange = policy }()
+
+		if got := decodeError(t, exchange(s, gatewayID, gatewaySecret, exchangeForm(userToken, "read", "billing"))); got != "invalid_target" {
+			t.Errorf("exchange without policy error = %q, want invalid_target", got)
+		}
+	})
+}
+
+func TestTokenExchangeGrant_Lifetime(t *testing.T) {
+	s := newTestServer(t)
+	gatewayID, gatewaySecret := registerExchanger(t, s, "gateway")
+	s.config.Exchange = AllowAudiences(map[string][]string{gatewayID: {"billing"}})
+
+	options := testutils.TestAuthOptions()
+	options.Subject = "testuser"
+	options.Scopes = "read"
+	options.TokenDuration = 30 * time.Second
+	short, err := internal.CreateAccessToken(options)
+	if err != nil {
+		t.Fatalf("CreateAccessToken() unexpected error = %v", err)
+	}
+
+	response := decodeTokens(t, exchange(s, gatewayID, gatewaySecret, exchangeForm(short.GetToken(), "", "billing")))
+	if lifetime := time.Until(exchangedClaims(t, response.AccessToken).ExpiresAt.Time); lifetime > 30*time.Second {
+		t.Errorf("exchanged token lifetime = %v, want at most the subject token's 30s", lifetime)
+	}
+}
@@ -0,0 +1,386 @@
+// Package oauth implements an OAuth 2.0 authorization server on top of the
+// library's token minting.
+//
+// A Server exposes an authorization endpoint for the authorization code flow
+// with PKCE (RFC 7636) and a token endpoint issuing the same signed access and
+// refresh tokens as the service providers, so resource servers validate them
+// with the existing Validate path. Clients, codes and users live in pluggable
+// storage, and a LoginFunc lets the application authenticate the user and ask
+// for consent however it likes.
+package oauth
+
+import (
+	"crypto/rsa"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/responsible-api/responsible-auth/auth"
+	"github.com/responsible-api/responsible-auth/internal"
+	"github.com/responsible-api/responsible-auth/resource/access"
+	"github.com/responsible-api/responsible-auth/resource/client"
+	"github.com/responsible-api/responsible-auth/resource/user"
+	"github.com/responsible-api/responsible-auth/storage"
+)
+
+// Grant types understood by the token endpoint.
+const (
+	GrantAuthorizationCode = "authorization_code"
+	GrantRefreshToken      = "refresh_token"
+	GrantClientCredentials = "client_credentials"
+	GrantDeviceCode        = "urn:ietf:params:oauth:grant-type:device_code"
+	GrantTokenExchange     = "urn:ietf:params:oauth:grant-type:token-exchange"
+)
+
+// DefaultCodeLifetime is how long an authorization code can be exchanged.
+const DefaultCodeLifetime = time.Minute
+
+// AuthorizeRequest is a validated authorization request handed to the LoginFunc.
+type AuthorizeRequest struct {
+	Client      *client.Client
+	RedirectURI string
+	Scopes      []string // requested scopes the client is allowed to receive
+	State       string
+	Nonce       string // OpenID Connect nonce to echo in the ID token
+	UserCode    string // device user code being approved; empty for the authorization code flow
+}
+
+// Consent is the outcome of a successful login: the user and the scopes they granted.
+// A nil Scopes grants every requested scope. AuthTime is when the user
+// authenticated and defaults to the time of the request.
+type Consent struct {
+	User     *user.User
+	Scopes   []string
+	AuthTime time.Time
+}
+
+// LoginFunc authenticates the resource owner and asks for consent.
+//
+// It returns the consent once the user has logged in and approved the request.
+// While that has not happened yet it writes its own response, such as a login
+// form that posts back to the authorization endpoint, and returns nil, nil.
+// Returning ErrAccessDenied sends the user back to the client with access_denied.
+type LoginFunc func(w http.ResponseWriter, r *http.Request, req *AuthorizeRequest) (*Consent, error)
+
+// Config wires a Server to its storage and login flow.
+type Config struct {
+	// Options are the token options shared with the service providers
+	Options auth.AuthOptions
+
+	Users   storage.UserStorage
+	Clients storage.ClientStorage
+	Codes   storage.AuthorizationCodeStorage
+
+	Login LoginFunc
+
+	// DeviceCodes enables the device authorization grant (RFC 8628).
+	// DeviceLogin asks the user to approve a device and defaults to Login.
+	DeviceCodes        storage.DeviceCodeStorage
+	DeviceLogin        LoginFunc
+	DeviceCodeLifetime time.Duration // defaults to DefaultDeviceCodeLifetime
+	DevicePollInterval time.Duration // defaults to DefaultDevicePollInterval
+
+	// Exchange decides which audiences a client may request with token
+	// exchange, for example AllowAudiences. When nil token exchange is refused.
+	Exchange ExchangePolicy
+
+	// CodeLifetime defaults to DefaultCodeLifetime
+	CodeLifetime time.Duration
+
+	// IDTokenKey enables OpenID Connect: ID tokens are signed with it using
+	// RS256 and its public key is served by JWKSHandler. Options.Issuer must
+	// then be the server's base URL.
+	IDTokenKey   *rsa.PrivateKey
+	IDTokenKeyID string
+
+	// Endpoints are the paths advertised in the discovery document
+	Endpoints Endpoints
+}
+
+// Endpoints are the paths, relative to Options.Issuer, the handlers are mounted at.
+type Endpoints struct {
+	Authorize           string
+	Token               string
+	UserInfo            string
+	JWKS                string
+	DeviceAuthorization string
+	Verification        string
+}
+
+// DefaultEndpoints are used for any Endpoints field left empty.
+var DefaultEndpoints = Endpoints{
+	Authorize: "/authorize",
+	Token:     "/token",
+	UserInfo:  "/userinfo",
+	JWKS:      "/.well-known/jwks.json",
+
+	DeviceAuthorization: "/device_authorization",
+	Verification:        "/device",
+}
+
+// ClientRegistration describes a client to register.
+type ClientRegistration struct {
+	Name         string
+	RedirectURIs []string
+
+	// Confidential clients get a secret and must authenticate with it at the token endpoint
+	Confidential bool
+
+	// GrantTypes defaults to authorization_code and refresh_token
+	GrantTypes []string
+	Scopes     []string
+
+	// Zero lifetimes use the server's AuthOptions
+	AccessTokenLifetime  time.Duration
+	RefreshTokenLifetime time.Duration
+}
+
+type grantFunc func(r *http.Request, c *client.Client) (*access.ResponseDTO, error)
+
+// Server is an OAuth 2.0 authorization server.
+type Server struct {
+	config Config
+	grants map[string]grantFunc
+	now    func() time.Time
+}
+
+// NewServer creates an authorization server from config.
+func NewServer(config Config) *Server {
+	if config.CodeLifetime == 0 {
+		config.CodeLifetime = DefaultCodeLifetime
+	}
+	if config.DeviceCodeLifetime == 0 {
+		config.DeviceCodeLifetime = DefaultDeviceCodeLifetime
+	}
+	if config.DevicePollInterval == 0 {
+		config.DevicePollInterval = DefaultDevicePollInterval
+	}
+	if config.DeviceLogin == nil {
+		config.DeviceLogin = config.Login
+	}
+	config.Endpoints = config.Endpoints.withDefaults()
+
+	s := &Server{
+		config: config,
+		now:    time.Now,
+	}
+	s.grants = map[string]grantFunc{
+		GrantAuthorizationCode: s.authorizationCodeGrant,
+		GrantRefreshToken:      s.refreshTokenGrant,
+		GrantClientCredentials: s.clientCredentialsGrant,
+		GrantTokenExchange:     s.tokenExchangeGrant,
+	}
+	if config.DeviceCodes != nil {
+		s.grants[GrantDeviceCode] = s.deviceCodeGrant
+	}
+	return s
+}
+
+// RegisterClient validates the registration and stores a new client with a
+// random client ID. Confidential clients also get a random secret, returned
+// here once; only its hash is stored.
+func (s *Server) RegisterClient(reg ClientRegistration) (*client.Client, string, error) {
+	grantTypes := reg.GrantTypes
+	if len(grantTypes) == 0 {
+		grantTypes = []string{GrantAuthorizationCode, GrantRefreshToken}
+	}
+	for _, grantType := range grantTypes {
+		if _, ok := s.grants[grantType]; !ok {
+			return nil, "", ErrInvalidRequest.WithDescription("unsupported grant type " + grantType)
+		}
+	}
+	if contains(grantTypes, GrantClientCredentials) && !reg.Confidential {
+		return nil, "", ErrInvalidRequest.WithDescription("client_credentials requires a confidential client")
+	}
+
+	if contains(grantTypes, GrantAuthorizationCode) && len(reg.RedirectURIs) == 0 {
+		return nil, "", ErrInvalidRequest.WithDescription("at least one redirect URI is required")
+	}
+	for _, uri := range reg.RedirectURIs {
+		if err := validateRedirectURI(uri); err != nil {
+			return nil, "", err
+		}
+	}
+
+	id, err := randomToken()
+	if err != nil {
+		return nil, "", err
+	}
+
+	c := &client.Client{
+		ID:                   id,
+		Name:                 reg.Name,
+		RedirectURIs:         append([]string(nil), reg.RedirectURIs...),
+		GrantTypes:           append([]string(nil), grantTypes...),
+		Scopes:               append([]string(nil), reg.Scopes...),
+		AccessTokenLifetime:  int64(reg.AccessTokenLifetime / time.Second),
+		RefreshTokenLifetime: int64(reg.RefreshTokenLifetime / time.Second),
+		Created:              s.now().Unix(),
+	}
+
+	var secret string
+	if reg.Confidential {
+		if secret, err = s.newClientSecret(c); err != nil {
+			return nil, "", err
+		}
+	}
+
+	if err := s.config.Clients.CreateClient(c); err != nil {
+		return nil, "", err
+	}
+	return c, secret, nil
+}
+
+// RotateClientSecret replaces the secret of a confidential client and returns the new one.
+// The old secret stops working immediately.
+func (s *Server) RotateClientSecret(clientID string) (string, error) {
+	c, err := s.config.Clients.FindClient(clientID)
+	if err != nil {
+		return "", err
+	}
+	if !c.IsConfidential() {
+		return "", ErrInvalidRequest.WithDescription("public clients have no secret")
+	}
+
+	secret, err := s.newClientSecret(c)
+	if err != nil {
+		return "", err
+	}
+	if err := s.config.Clients.UpdateClient(c); err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+// DeleteClient removes a client. Tokens already issued to it stay valid until they expire.
+func (s *Server) DeleteClient(clientID string) error {
+	return s.config.Clients.DeleteClient(clientID)
+}
+
+// newClientSecret sets a new random secret hash on c and returns the plaintext secret.
+func (s *Server) newClientSecret(c *client.Client) (string, error) {
+	secret, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+	if c.SecretHash, err = client.HashSecret(secret); err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+// validateRedirectURI accepts absolute URIs without fragments. Plain http is
+// only allowed for loopback addresses used by native apps (RFC 8252).
+func validateRedirectURI(uri string) error {
+	u, err := url.Parse(uri)
+	if err != nil || !u.IsAbs() || u.Fragment != "" {
+		return ErrInvalidRequest.WithDescription("invalid redirect URI " + uri)
+	}
+
+	if u.Scheme == "http" {
+		switch u.Hostname() {
+		case "localhost", "127.0.0.1", "::1":
+		default:
+			return ErrInvalidRequest.WithDescription("redirect URI must use https: " + uri)
+		}
+	}
+	return nil
+}
+
+// clientOptions returns the token options for tokens issued to c with the
+// granted scopes, applying the client's token lifetimes. The subject is the
+// client itself until a user is set with userOptions.
+func (s *Server) clientOptions(c *client.Client, scopes []string) auth.AuthOptions {
+	opts := s.config.Options
+	opts.Subject = c.ID
+	opts.ClientID = c.ID
+	opts.Scopes = strings.Join(scopes, " ")
+	if c.AccessTokenLifetime > 0 {
+		opts.TokenDuration = time.Duration(c.AccessTokenLifetime) * time.Second
+	}
+	if c.RefreshTokenLifetime > 0 {
+		opts.RefreshTokenDuration = time.Duration(c.RefreshTokenLifetime) * time.Second
+	}
+	return opts
+}
+
+// userOptions returns the token options for tokens issued to c on behalf of u
+// with the given grant, passed through the ClaimsEnricher. Scopes the enricher
+// adds are still limited to those the client may request.
+func (s *Server) userOptions(c *client.Client, u *user.User, scopes []string, grantType string) (auth.AuthOptions, error) {
+	opts := s.clientOptions(c, scopes)
+	opts.Subject = u.Name
+	opts.AccountID = u.AccountID
+
+	opts, err := opts.Enrich(u, auth.Grant{Type: grantType, ClientID: c.ID})
+	if err != nil {
+		return auth.AuthOptions{}, err
+	}
+	opts.Scopes = strings.Join(c.AllowedScopes(strings.Fields(opts.Scopes)), " ")
+	return opts, nil
+}
+
+// issueTokens mints an access token from opts, and a refresh token for
+// refreshUser unless it is empty, reusing the providers' token minting.
+func (s *Server) issueTokens(opts auth.AuthOptions, refreshUser string) (*access.ResponseDTO, error) {
+	token, err := internal.CreateAccessToken(opts)
+	if err != nil {
+		return nil, err
+	}
+	expiresAt, err := token.GetExpirationTime()
+	if err != nil {
+		return nil, err
+	}
+
+	model := access.NewModel()
+	model.WithAccessToken(token.GetToken())
+	model.WithTokenType("Bearer")
+	model.WithExpiresIn(expiresAt.Unix())
+	model.WithCreatedAt(s.now().Unix())
+	model.WithScopesString(opts.Scopes)
+
+	if refreshUser != "" {
+		refreshToken, err := internal.CreateRefreshToken(refreshUser, opts)
+		if err != nil {
+			return nil, err
+		}
+		model.WithRefreshToken(refreshToken.GetToken())
+	}
+	return model.ToResponseDTO(), nil
+}
+
+// activeUser loads the user a grant was issued to, rejecting users that no longer may log in.
+func (s *Server) activeUser(name string) (*user.User, error) {
+	u, err := s.config.Users.FindUserByName(name)
+	if errors.Is(err, storage.ErrUserNotFound) {
+		return nil, ErrInvalidGrant.WithDescription("unknown user")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !u.IsActive() {
+		return nil, ErrInvalidGrant.WithDescription("user is not active")
+	}
+	return u, nil
+}
+
+// subsetOf reports whether every scope in requested is in allowed.
+func subsetOf(requested, allowed []string) bool {
+	for _, scope := range requested {
+		if !contains(allowed, scope) {
+			return false
+		}
+	}
+	return true
+}
+
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,680 @@
+package oauth
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/responsible-api/responsible-auth/auth"
+	"github.com/responsible-api/responsible-auth/concerns"
+	"github.com/responsible-api/responsible-auth/examples/memory"
+	"github.com/responsible-api/responsible-auth/internal"
+	"github.com/responsible-api/responsible-auth/resource/access"
+	"github.com/responsible-api/responsible-auth/resource/client"
+	"github.com/responsible-api/responsible-auth/resource/user"
+	"github.com/responsible-api/responsible-auth/service"
+	"github.com/responsible-api/responsible-auth/testutils"
+)
+
+const (
+	testClientID    = "spa-client"
+	testRedirectURI = "https://app.example.com/callback"
+	testVerifier    = "dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"
+)
+
+// newTestServer returns a server whose login callback approves every request as the test user.
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+	users := testutils.NewMockStorage()
+	testUser, err := users.FindUserByName("testuser")
+	if err != nil {
+		t.Fatalf("FindUserByName() unexpected error = %v", err)
+	}
+
+	return NewServer(Config{
+		Options: testutils.TestAuthOptions(),
+		Users:   users,
+		Clients: memory.NewInMemoryClientStorage(&client.Client{
+			ID:           testClientID,
+			Name:         "Test SPA",
+			RedirectURIs: []string{testRedirectURI},
+			GrantTypes:   []string{GrantAuthorizationCode, GrantRefreshToken},
+			Scopes:       []string{"read", "write", ScopeOpenID, ScopeProfile, ScopeEmail},
+		}),
+		Codes: memory.NewInMemoryAuthorizationCodeStorage(),
+		Login: func(w http.ResponseWriter, r *http.Request, req *AuthorizeRequest) (*Consent, error) {
+			return &Consent{User: testUser}, nil
+		},
+	})
+}
+
+func authorizeParams() url.Values {
+	return url.Values{
+		"response_type":         {"code"},
+		"client_id":             {testClientID},
+		"redirect_uri":          {testRedirectURI},
+		"scope":                 {"read write"},
+		"state":                 {"xyz"},
+		"code_challenge":        {S256Challenge(testVerifier)},
+		"code_challenge_method": {CodeChallengeMethodS256},
+	}
+}
+
+func authorize(s *Server, params url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/authorize?"+params.Encode(), nil)
+	rec := httptest.NewRecorder()
+	s.AuthorizeHandler().ServeHTTP(rec, req)
+	return rec
+}
+
+func postToken(s *Server, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/token", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	s.TokenHandler().ServeHTTP(rec, req)
+	return rec
+}
+
+// issueCode runs the authorization request and returns the code from the redirect.
+func issueCode(t *testing.T, s *Server) string {
+	t.Helper()
+	rec := authorize(s, authorizeParams())
+	if rec.Code != http.StatusFound {
+		t.Fatalf("authorize status = %d, want %d: %s", rec.Code, http.StatusFound, rec.Body.String())
+	}
+	location, err := url.Parse(rec.Header().Get("Location"))
+	if err != nil {
+		t.Fatalf("invalid redirect location: %v", err)
+	}
+	if got := location.Query().Get("state"); got != "xyz" {
+		t.Errorf("redirect state = %q, want %q", got, "xyz")
+	}
+	code := location.Query().Get("code")
+	if code == "" {
+		t.Fatalf("redirect has no code: %s", location)
+	}
+	return code
+}
+
+func codeExchange(code string) url.Values {
+	return url.Values{
+		"grant_type":    {GrantAuthorizationCode},
+		"client_id":     {testClientID},
+		"code":          {code},
+		"redirect_uri":  {testRedirectURI},
+		"code_verifier": {testVerifier},
+	}
+}
+
+func decodeTokens(t *testing.T, rec *httptest.ResponseRecorder) *access.ResponseDTO {
+	t.Helper()
+	if rec.Code != http.StatusOK {
+		t.Fatalf("token status = %d, want %d: %s", rec.Code, http.StatusOK, rec.Body.String())
+	}
+	response := &access.ResponseDTO{}
+	if err := json.NewDecoder(rec.Body).Decode(response); err != nil {
+		t.Fatalf("invalid token response: %v", err)
+	}
+	return response
+}
+
+func decodeError(t *testing.T, rec *httptest.ResponseRecorder) string {
+	t.Helper()
+	var body struct {
+		Error string `json:"error"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatalf("invalid error response: %v", err)
+	}
+	return body.Error
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	s := newTestServer(t)
+
+	response := decodeTokens(t, postToken(s, codeExchange(issueCode(t, s))))
+	if response.TokenType != "Bearer" || response.RefreshToken == "" || response.Scopes != "read write" {
+		t.Errorf("token response = %+v, want bearer token with refresh token and scope %q", response, "read write")
+	}
+
+	token, err := internal.Validate(response.AccessToken, testutils.TestAuthOptions())
+	if err != nil {
+		t.Fatalf("Validate() unexpected error = %v", err)
+	}
+	claims := token.Claims.(*concerns.ClaimsGeneric)
+	if claims.Subject != "testuser" || claims.Scopes != "read write" || claims.AccountID != testutils.TestUser().AccountID {
+		t.Errorf("access token claims = %+v, want subject testuser with scope %q", claims, "read write")
+	}
+	if claims.ClientID == "" || claims.IsClient() {
+		t.Errorf("access token client_id = %q, want the client the user authorised", claims.ClientID)
+	}
+}
+
+func TestAuthorizationCodeFlow_Errors(t *testing.T) {
+	tests := []struct {
+		name        string
+		modify      func(form url.Values)
+		expectError string
+	}{
+		{
+			name:        "wrong verifier",
+			modify:      func(form url.Values) { form.Set("code_verifier", strings.Repeat("a", 43)) },
+			expectError: "invalid_grant",
+		},
+		{
+			name:        "missing verifier",
+			modify:      func(form url.Values) { form.Del("code_verifier") },
+			expectError: "invalid_grant",
+		},
+		{
+			name:        "redirect mismatch",
+			modify:      func(form url.Values) { form.Set("redirect_uri", "https://evil.example.com/callback") },
+			expectError: "invalid_grant",
+		},
+		{
+			name:        "unknown client",
+			modify:      func(form url.Values) { form.Set("client_id", "other") },
+			expectError: "invalid_client",
+		},
+		{
+			name:        "unknown code",
+			modify:      func(form url.Values) { form.Set("code", "made-up") },
+			expectError: "invalid_grant",
+		},
+		{
+			name:        "unsupported grant",
+			modify:      func(form url.Values) { form.Set("grant_type", "password") },
+			expectError: "unsupported_grant_type",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			form := codeExchange(issueCode(t, s))
+			tt.modify(form)
+
+			rec := postToken(s, form)
+			if rec.Code == http.StatusOK {
+				t.Fatalf("token exchange succeeded, want %s", tt.expectError)
+			}
+			if got := decodeError(t, rec); got != tt.expectError {
+				t.Errorf("token error = %q, want %q", got, tt.expectError)
+			}
+		})
+	}
+}
+
+func TestAuthorizationCode_SingleUse(t *testing.T) {
+	s := newTestServer(t)
+	code := issueCode(t, s)
+
+	decodeTokens(t, postToken(s, codeExchange(code)))
+
+	rec := postToken(s, codeExchange(code))
+	if got := decodeError(t, rec); got != "invalid_grant" {
+		t.Errorf("second exchange error = %q, want %q", got, "invalid_grant")
+	}
+}
+
+func TestAuthorizationCode_Expired(t *testing.T) {
+	s := newTestServer(t)
+	code := issueCode(t, s)
+
+	now := s.now
+	s.now = func() time.Time { return now().Add(DefaultCodeLifetime) }
+
+	if got := decodeError(t, postToken(s, codeExchange(code))); got != "invalid_grant" {
+		t.Errorf("expired code error = %q, want %q", got, "invalid_grant")
+	}
+}
+
+func TestAuthorizeHandler_Errors(t *testing.T) {
+	tests := []struct {
+		name           string
+		modify         func(params url.Values)
+		expectStatus   int
+		expectRedirect string // error code expected in the redirect
+	}{
+		{
+			name:         "unknown client",
+			modify:       func(params url.Values) { params.Set("client_id", "other") },
+			expectStatus: http.StatusBadRequest,
+		},
+		{
+			name:         "unregistered redirect",
+			modify:       func(params url.Values) { params.Set("redirect_uri", "https://evil.example.com/callback") },
+			expectStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "missing challenge",
+			modify:         func(params url.Values) { params.Del("code_challenge") },
+			expectStatus:   http.StatusFound,
+			expectRedirect: "invalid_request",
+		},
+		{
+			name:           "plain challenge method",
+			modify:         func(params url.Values) { params.Set("code_challenge_method", "plain") },
+			expectStatus:   http.StatusFound,
+			expectRedirect: "invalid_request",
+		},
+		{
+			name:           "token response type",
+			modify:         func(params url.Values) { params.Set("response_type", "token") },
+			expectStatus:   http.StatusFound,
+			expectRedirect: "unsupported_response_type",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := authorizeParams()
+			tt.modify(params)
+
+			rec := authorize(newTestServer(t), params)
+			if rec.Code != tt.expectStatus {
+				t.Fatalf("authorize status = %d, want %d", rec.Code, tt.expectStatus)
+			}
+			if tt.expectRedirect == "" {
+				return
+			}
+
+			location, _ := url.Parse(rec.Header().Get("Location"))
+			if got := location.Query().Get("error"); got != tt.expectRedirect {
+				t.Errorf("redirect error = %q, want %q", got, tt.expectRedirect)
+			}
+			if !strings.HasPrefix(location.String(), testRedirectURI) {
+				t.Errorf("redirect location = %s, want %s", location, testRedirectURI)
+			}
+		})
+	}
+}
+
+func TestAuthorizeHandler_Login(t *testing.T) {
+	t.Run("login page", func(t *testing.T) {
+		s := newTestServer(t)
+		s.config.Login = func(w http.ResponseWriter, r *http.Request, req *AuthorizeRequest) (*Consent, error) {
+			w.Write([]byte("please log in"))
+			return nil, nil
+		}
+
+		rec := authorize(s, authorizeParams())
+		if rec.Code != http.StatusOK || rec.Body.String() != "please log in" {
+			t.Errorf("authorize response = %d %q, want the login page", rec.Code, rec.Body.String())
+		}
+	})
+
+	t.Run("access denied", func(t *testing.T) {
+		s := newTestServer(t)
+		s.config.Login = func(w http.ResponseWriter, r *http.Request, req *AuthorizeRequest) (*Consent, error) {
+			return nil, ErrAccessDenied
+		}
+
+		location, _ := url.Parse(authorize(s, authorizeParams()).Header().Get("Location"))
+		if got := location.Query().Get("error"); got != "access_denied" {
+			t.Errorf("redirect error = %q, want %q", got, "access_denied")
+		}
+	})
+
+	t.Run("partial consent", func(t *testing.T) {
+		s := newTestServer(t)
+		login := s.config.Login
+		s.config.Login = func(w http.ResponseWriter, r *http.Request, req *AuthorizeRequest) (*Consent, error) {
+			consent, err := login(w, r, req)
+			consent.Scopes = []string{"read"}
+			return consent, err
+		}
+
+		response := decodeTokens(t, postToken(s, codeExchange(issueCode(t, s))))
+		if response.Scopes != "read" {
+			t.Errorf("token scope = %q, want %q", response.Scopes, "read")
+		}
+	})
+}
+
+func TestRefreshTokenGrant(t *testing.T) {
+	s := newTestServer(t)
+	tokens := decodeTokens(t, postToken(s, codeExchange(issueCode(t, s))))
+
+	form := url.Values{
+		"grant_type":    {GrantRefreshToken},
+		"client_id":     {testClientID},
+		"refresh_token": {tokens.RefreshToken},
+		"scope":         {"read"},
+	}
+	refreshed := decodeTokens(t, postToken(s, form))
+	if refreshed.Scopes != "read" {
+		t.Errorf("refreshed scope = %q, want %q", refreshed.Scopes, "read")
+	}
+
+	form.Set("scope", "read admin")
+	if got := decodeError(t, postToken(s, form)); got != "invalid_scope" {
+		t.Errorf("widened refresh error = %q, want %q", got, "invalid_scope")
+	}
+}
+
+func TestRefreshTokenGrant_ClientBinding(t *testing.T) {
+	s := newTestServer(t)
+	tokens := decodeTokens(t, postToken(s, codeExchange(issueCode(t, s))))
+
+	other, _, err := s.RegisterClient(ClientRegistration{RedirectURIs: []string{"https://other.example.com/callback"}})
+	if err != nil {
+		t.Fatalf("RegisterClient() unexpected error = %v", err)
+	}
+	form := url.Values{
+		"grant_type":    {GrantRefreshToken},
+		"client_id":     {other.ID},
+		"refresh_token": {tokens.RefreshToken},
+	}
+	if got := decodeError(t, postToken(s, form)); got != "invalid_grant" {
+		t.Errorf("refresh as another client error = %q, want %q", got, "invalid_grant")
+	}
+
+	// Refresh tokens minted by a provider share the key but belong to no client
+	provider := service.NewBasicAuth()
+	provider.SetStorage(s.config.Users)
+	provider.SetOptions(s.config.Options)
+	minted, err := provider.CreateRefreshToken("test@example.com", "test-password-hash")
+	if err != nil {
+		t.Fatalf("CreateRefreshToken() unexpected error = %v", err)
+	}
+	form.Set("client_id", testClientID)
+	form.Set("refresh_token", minted.GetToken())
+	if got := decodeError(t, postToken(s, form)); got != "invalid_grant" {
+		t.Errorf("refresh of a provider token error = %q, want %q", got, "invalid_grant")
+	}
+
+	// Nor can a provider redeem the client's refresh token
+	if _, err := provider.GrantRefreshToken(tokens.RefreshToken); !errors.Is(err, service.ErrClientRefreshToken) {
+		t.Errorf("GrantRefreshToken() of a client token error = %v, want %v", err, service.ErrClientRefreshToken)
+	}
+}
+
+func TestClaimsEnricher(t *testing.T) {
+	s := newTestServer(t)
+	var grants []auth.Grant
+	s.config.Options.ClaimsEnricher = auth.ClaimsEnricherFunc(func(u *user.User, grant auth.Grant, claims *auth.Claims) error {
+		grants = append(grants, grant)
+		if u.Name != "testuser" {
+			return ErrAccessDenied
+		}
+		claims.Role = "editor"
+		// admin is not among the client's scopes, so it must be dropped
+		claims.Scopes += " admin"
+		return nil
+	})
+
+	tokens := decodeTokens(t, postToken(s, codeExchange(issueCode(t, s))))
+	token, err := internal.Validate(tokens.AccessToken, testutils.TestAuthOptions())
+	if err != nil {
+		t.Fatalf("Validate() unexpected error = %v", err)
+	}
+	claims := token.Claims.(*concerns.ClaimsGeneric)
+	if claims.Role != "editor" || claims.Scopes != "read write" {
+		t.Errorf("access token = role %q, scopes %q, want role editor limited to the client's scopes", claims.Role, claims.Scopes)
+	}
+	if len(grants) != 1 || grants[0].Type != auth.GrantAuthorizationCode || grants[0].ClientID != testClientID {
+		t.Errorf("enricher grants = %+v, want one authorization_code grant for %s", grants, testClientID)
+	}
+
+	// OAuth errors from the enricher reach the client; others become server_error
+	s.config.Options.ClaimsEnricher = auth.ClaimsEnricherFunc(func(*user.User, auth.Grant, *auth.Claims) error {
+		return ErrAccessDenied
+	})
+	form := url.Values{
+		"grant_type":    {GrantRefreshToken},
+		"client_id":     {testClientID},
+		"refresh_token": {tokens.RefreshToken},
+	}
+	if got := decodeError(t, postToken(s, form)); got != "access_denied" {
+		t.Errorf("refresh with denying enricher error = %q, want %q", got, "access_denied")
+	}
+	s.config.Options.ClaimsEnricher = auth.ClaimsEnricherFunc(func(*user.User, auth.Grant, *auth.Claims) error {
+		return errors.New("role store unavailable")
+	})
+	if got := decodeError(t, postToken(s, form)); got != "server_error" {
+		t.Errorf("refresh with failing enricher error = %q, want %q", got, "server_error")
+	}
+}
+
+func TestRegisterClient(t *testing.T) {
+	tests := []struct {
+		name        string
+		reg         ClientRegistration
+		expectError bool
+	}{
+		{name: "https", reg: ClientRegistration{RedirectURIs: []string{"https://app.example.com/callback"}}},
+		{name: "loopback", reg: ClientRegistration{RedirectURIs: []string{"http://127.0.0.1:8080/callback"}}},
+		{name: "native scheme", reg: ClientRegistration{RedirectURIs: []string{"com.example.app:/callback"}}},
+		{name: "service", reg: ClientRegistration{Confidential: true, GrantTypes: []string{GrantClientCredentials}}},
+		{name: "no redirect", reg: ClientRegistration{}, expectError: true},
+		{name: "plain http", reg: ClientRegistration{RedirectURIs: []string{"http://app.example.com/callback"}}, expectError: true},
+		{name: "relative", reg: ClientRegistration{RedirectURIs: []string{"/callback"}}, expectError: true},
+		{name: "fragment", reg: ClientRegistration{RedirectURIs: []string{"https://app.example.com/callback#frag"}}, expectError: true},
+		{name: "unknown grant", reg: ClientRegistration{Confidential: true, GrantTypes: []string{"password"}}, expectError: true},
+		{name: "public service", reg: ClientRegistration{GrantTypes: []string{GrantClientCredentials}}, expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+
+			c, secret, err := s.RegisterClient(tt.reg)
+			if tt.expectError {
+				if !errors.Is(err, ErrInvalidRequest) {
+					t.Errorf("RegisterClient() error = %v, want %v", err, ErrInvalidRequest)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("RegisterClient() unexpected error = %v", err)
+			}
+
+			stored, err := s.config.Clients.FindClient(c.ID)
+			if err != nil {
+				t.Fatalf("FindClient() after RegisterClient() error = %v", err)
+			}
+			if len(tt.reg.RedirectURIs) > 0 && !stored.AllowsRedirect(tt.reg.RedirectURIs[0]) {
+				t.Errorf("FindClient() redirect URIs = %v, want %v", stored.RedirectURIs, tt.reg.RedirectURIs)
+			}
+
+			if tt.reg.Confidential {
+				if secret == "" || stored.SecretHash == secret || !stored.CheckSecret(secret) {
+					t.Errorf("RegisterClient() secret %q does not match the stored hash", secret)
+				}
+			} else if secret != "" || stored.IsConfidential() {
+				t.Errorf("RegisterClient() gave a public client a secret")
+			}
+		})
+	}
+}
+
+// registerService registers a confidential client_credentials client and returns its ID and secret.
+func registerService(t *testing.T, s *Server, reg ClientRegistration) (string, string) {
+	t.Helper()
+	reg.Confidential = true
+	reg.GrantTypes = []string{GrantClientCredentials}
+
+	c, secret, err := s.RegisterClient(reg)
+	if err != nil {
+		t.Fatalf("RegisterClient() unexpected error = %v", err)
+	}
+	return c.ID, secret
+}
+
+func TestClientCredentialsGrant(t *testing.T) {
+	tests := []struct {
+		name        string
+		scope       string
+		expectScope string
+		expectError string
+	}{
+		{name: "all allowed scopes", expectScope: "reports:read reports:write"},
+		{name: "requested subset", scope: "reports:read", expectScope: "reports:read"},
+		{name: "intersected", scope: "reports:read admin", expectScope: "reports:read"},
+		{name: "nothing allowed", scope: "admin", expectError: "invalid_scope"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			clientID, secret := registerService(t, s, ClientRegistration{
+				Name:                "reporting",
+				Scopes:              []string{"reports:read", "reports:write"},
+				AccessTokenLifetime: 5 * time.Minute,
+			})
+
+			req := httptest.NewRequest(http.MethodPost, "/token", strings.NewReader(url.Values{
+				"grant_type": {GrantClientCredentials},
+				"scope":      {tt.scope},
+			}.Encode()))
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			req.SetBasicAuth(clientID, secret)
+			rec := httptest.NewRecorder()
+			s.TokenHandler().ServeHTTP(rec, req)
+
+			if tt.expectError != "" {
+				if got := decodeError(t, rec); got != tt.expectError {
+					t.Errorf("token error = %q, want %q", got, tt.expectError)
+				}
+				return
+			}
+
+			response := decodeTokens(t, rec)
+			if response.Scopes != tt.expectScope || response.RefreshToken != "" {
+				t.Errorf("token response = %+v, want scope %q and no refresh token", response, tt.expectScope)
+			}
+
+			token, err := internal.Validate(response.AccessToken, testutils.TestAuthOptions())
+			if err != nil {
+				t.Fatalf("Validate() unexpected error = %v", err)
+			}
+			claims := token.Claims.(*concerns.ClaimsGeneric)
+			if claims.Subject != clientID || !claims.IsClient() {
+				t.Errorf("access token subject = %q client_id = %q, want the client ID %q", claims.Subject, claims.ClientID, clientID)
+			}
+			if lifetime := claims.ExpiresAt.Sub(claims.IssuedAt.Time); lifetime > 5*time.Minute+time.Second {
+				t.Errorf("access token lifetime = %v, want the client's 5m", lifetime)
+			}
+		})
+	}
+}
+
+func TestClientAuthentication(t *testing.T) {
+	s := newTestServer(t)
+	clientID, secret := registerService(t, s, ClientRegistration{Scopes: []string{"read"}})
+
+	tests := []struct {
+		name         string
+		form         url.Values
+		expectStatus int
+		expectError  string
+	}{
+		{
+			name:         "client_secret_post",
+			form:         url.Values{"client_id": {clientID}, "client_secret": {secret}},
+			expectStatus: http.StatusOK,
+		},
+		{
+			name:         "wrong secret",
+			form:         url.Values{"client_id": {clientID}, "client_secret": {"wrong"}},
+			expectStatus: http.StatusUnauthorized,
+			expectError:  "invalid_client",
+		},
+		{
+			name:         "missing secret",
+			form:         url.Values{"client_id": {clientID}},
+			expectStatus: http.StatusUnauthorized,
+			expectError:  "invalid_client",
+		},
+		{
+			name:         "public client",
+			form:         url.Values{"client_id": {testClientID}},
+			expectStatus: http.StatusBadRequest,
+			expectError:  "unauthorized_client",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.form.Set("grant_type", GrantClientCredentials)
+			rec := postToken(s, tt.form)
+
+			if rec.Code != tt.expectStatus {
+				t.Fatalf("token status = %d, want %d", rec.Code, tt.expectStatus)
+			}
+			if tt.expectError != "" {
+				if got := decodeError(t, rec); got != tt.expectError {
+					t.Errorf("token error = %q, want %q", got, tt.expectError)
+				}
+			}
+		})
+	}
+}
+
+func TestRotateClientSecret(t *testing.T) {
+	s := newTestServer(t)
+	clientID, oldSecret := registerService(t, s, ClientRegistration{Scopes: []string{"read"}})
+
+	newSecret, err := s.RotateClientSecret(clientID)
+	if err != nil {
+		t.Fatalf("RotateClientSecret() unexpected error = %v", err)
+	}
+
+	exchange := func(secret string) int {
+		return postToken(s, url.Values{
+			"grant_type":    {GrantClientCredentials},
+			"client_id":     {clientID},
+			"client_secret": {secret},
+		}).Code
+	}
+	if code := exchange(oldSecret); code != http.StatusUnauthorized {
+		t.Errorf("old secret status = %d, want %d", code, http.StatusUnauthorized)
+	}
+	if code := exchange(newSecret); code != http.StatusOK {
+		t.Errorf("new secret status = %d, want %d", code, http.StatusOK)
+	}
+
+	if _, err := s.RotateClientSecret(testClientID); !errors.Is(err, ErrInvalidRequest) {
+		t.Errorf("RotateClientSecret() for public client error = %v, want %v", err, ErrInvalidRequest)
+	}
+
+	if err := s.DeleteClient(clientID); err != nil {
+		t.Fatalf("DeleteClient() unexpected error = %v", err)
+	}
+	if code := exchange(newSecret); code != http.StatusUnauthorized {
+		t.Errorf("deleted client status = %d, want %d", code, http.StatusUnauthorized)
+	}
+}
+
+func TestAuthorizeHandler_ClientAllowance(t *testing.T) {
+	s := newTestServer(t)
+
+	// Scopes outside the client's allowance are dropped from the request
+	params := authorizeParams()
+	params.Set("scope", "read admin")
+	var requested []string
+	login := s.config.Login
+	s.config.Login = func(w http.ResponseWriter, r *http.Request, req *AuthorizeRequest) (*Consent, error) {
+		requested = req.Scopes
+		return login(w, r, req)
+	}
+	authorize(s, params)
+	if strings.Join(requested, " ") != "read" {
+		t.Errorf("AuthorizeRequest scopes = %v, want [read]", requested)
+	}
+
+	// Clients without the authorization_code grant cannot start the flow
+	clientID, _ := registerService(t, s, ClientRegistration{RedirectURIs: []string{testRedirectURI}})
+	params = authorizeParams()
+	params.Set("client_id", clientID)
+	location, _ := url.Parse(authorize(s, params).Header().Get("Location"))
+	if got := location.Query().Get("error"); got != "unauthorized_client" {
+		t.Errorf("redirect error = %q, want %q", got, "unauthorized_client")
+	}
+}
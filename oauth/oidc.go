@@ -0,0 +1,251 @@
+package oauth
+
+import (
+	"encoding/base64"
+	"math/big"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/responsible-api/responsible-auth/concerns"
+	"github.com/responsible-api/responsible-auth/internal"
+	"github.com/responsible-api/responsible-auth/resource/authcode"
+	"github.com/responsible-api/responsible-auth/resource/client"
+	"github.com/responsible-api/responsible-auth/resource/user"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// OpenID Connect scopes. openid requests an ID token; profile and email add
+// the name and email claims to it and to the userinfo response.
+const (
+	ScopeOpenID  = "openid"
+	ScopeProfile = "profile"
+	ScopeEmail   = "email"
+)
+
+// Discovery is the OpenID Provider metadata served at /.well-known/openid-configuration.
+type Discovery struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserInfoEndpoint                  string   `json:"userinfo_endpoint,omitempty"`
+	JWKSURI                           string   `json:"jwks_uri,omitempty"`
+	DeviceAuthorizationEndpoint       string   `json:"device_authorization_endpoint,omitempty"`
+	ScopesSupported                   []string `json:"scopes_supported,omitempty"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported,omitempty"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported,omitempty"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	ClaimsSupported                   []string `json:"claims_supported,omitempty"`
+}
+
+// UserInfo is the response of the userinfo endpoint.
+type UserInfo struct {
+	Subject string `json:"sub"`
+	Name    string `json:"name,omitempty"`
+	Email   string `json:"email,omitempty"`
+}
+
+func (e Endpoints) withDefaults() Endpoints {
+	if e.Authorize == "" {
+		e.Authorize = DefaultEndpoints.Authorize
+	}
+	if e.Token == "" {
+		e.Token = DefaultEndpoints.Token
+	}
+	if e.UserInfo == "" {
+		e.UserInfo = DefaultEndpoints.UserInfo
+	}
+	if e.JWKS == "" {
+		e.JWKS = DefaultEndpoints.JWKS
+	}
+	if e.DeviceAuthorization == "" {
+		e.DeviceAuthorization = DefaultEndpoints.DeviceAuthorization
+	}
+	if e.Verification == "" {
+		e.Verification = DefaultEndpoints.Verification
+	}
+	return e
+}
+
+// oidcEnabled reports whether the server can issue ID tokens.
+func (s *Server) oidcEnabled() bool {
+	return s.config.IDTokenKey != nil
+}
+
+// Discovery describes the server from its AuthOptions and enabled grants.
+// OpenID Connect fields are only filled in when an ID token key is configured.
+func (s *Server) Discovery() *Discovery {
+	issuer := strings.TrimSuffix(s.config.Options.Issuer, "/")
+
+	grantTypes := make([]string, 0, len(s.grants))
+	for grantType := range s.grants {
+		grantTypes = append(grantTypes, grantType)
+	}
+	sort.Strings(grantTypes)
+
+	d := &Discovery{
+		Issuer:                            s.config.Options.Issuer,
+		AuthorizationEndpoint:             issuer + s.config.Endpoints.Authorize,
+		TokenEndpoint:                     issuer + s.config.Endpoints.Token,
+		ResponseTypesSupported:            []string{"code"},
+		GrantTypesSupported:               grantTypes,
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     []string{CodeChallengeMethodS256},
+	}
+
+	if _, ok := s.grants[GrantDeviceCode]; ok {
+		d.DeviceAuthorizationEndpoint = issuer + s.config.Endpoints.DeviceAuthorization
+	}
+
+	if s.oidcEnabled() {
+		d.UserInfoEndpoint = issuer + s.config.Endpoints.UserInfo
+		d.JWKSURI = issuer + s.config.Endpoints.JWKS
+		d.ScopesSupported = []string{ScopeOpenID, ScopeProfile, ScopeEmail}
+		d.SubjectTypesSupported = []string{"public"}
+		d.IDTokenSigningAlgValuesSupported = []string{jwt.SigningMethodRS256.Alg()}
+		d.ClaimsSupported = []string{"iss", "sub", "aud", "exp", "iat", "auth_time", "nonce", "name", "email"}
+	}
+	return d
+}
+
+// DiscoveryHandler serves the discovery document.
+func (s *Server) DiscoveryHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, s.Discovery())
+	})
+}
+
+// JWKSHandler serves the public key ID tokens are verified with.
+func (s *Server) JWKSHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		keys := []map[string]string{}
+		if s.oidcEnabled() {
+			public := s.config.IDTokenKey.PublicKey
+			key := map[string]string{
+				"kty": "RSA",
+				"use": "sig",
+				"alg": jwt.SigningMethodRS256.Alg(),
+				"n":   base64.RawURLEncoding.EncodeToString(public.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes()),
+			}
+			if s.config.IDTokenKeyID != "" {
+				key["kid"] = s.config.IDTokenKeyID
+			}
+			keys = append(keys, key)
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"keys": keys})
+	})
+}
+
+// UserInfoHandler returns the claims of the user an access token with the
+// openid scope was issued to. A token restricted to an audience must name
+// this issuer or the client it was issued to, so a token meant for another
+// service, such as one from token exchange, is refused.
+func (s *Server) UserInfoHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		scheme, tokenString, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+		if !strings.EqualFold(scheme, "Bearer") || tokenString == "" {
+			bearerError(w, http.StatusUnauthorized, "")
+			return
+		}
+
+		token, err := internal.Validate(tokenString, s.config.Options)
+		if err != nil || token == nil {
+			bearerError(w, http.StatusUnauthorized, "invalid_token")
+			return
+		}
+		claims, ok := token.Claims.(*concerns.ClaimsGeneric)
+		if !ok {
+			bearerError(w, http.StatusUnauthorized, "invalid_token")
+			return
+		}
+
+		if !s.userInfoAudience(claims) {
+			bearerError(w, http.StatusUnauthorized, "invalid_token")
+			return
+		}
+
+		scopes := strings.Fields(claims.Scopes)
+		if !contains(scopes, ScopeOpenID) {
+			bearerError(w, http.StatusForbidden, "insufficient_scope")
+			return
+		}
+
+		u, err := s.config.Users.FindUserByName(claims.Subject)
+		if err != nil || !u.IsActive() {
+			bearerError(w, http.StatusUnauthorized, "invalid_token")
+			return
+		}
+
+		info := &UserInfo{Subject: u.Name}
+		addProfileClaims(scopes, u, &info.Name, &info.Email)
+		writeJSON(w, http.StatusOK, info)
+	})
+}
+
+// userInfoAudience reports whether an access token may be presented to the
+// userinfo endpoint. Tokens without an audience are not restricted.
+func (s *Server) userInfoAudience(claims *concerns.ClaimsGeneric) bool {
+	if len(claims.Audience) == 0 {
+		return true
+	}
+	issuer := s.config.Options.Issuer
+	return (issuer != "" && contains(claims.Audience, issuer)) ||
+		(claims.ClientID != "" && contains(claims.Audience, claims.ClientID))
+}
+
+// idToken signs the ID token for an authorization code exchange.
+func (s *Server) idToken(c *client.Client, u *user.User, code *authcode.Code) (string, error) {
+	lifetime := s.config.Options.TokenDuration
+	if c.AccessTokenLifetime > 0 {
+		lifetime = time.Duration(c.AccessTokenLifetime) * time.Second
+	}
+	if lifetime == 0 {
+		lifetime = 15 * time.Minute
+	}
+
+	now := s.now()
+	claims := &concerns.IDClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    s.config.Options.Issuer,
+			Subject:   u.Name,
+			Audience:  jwt.ClaimStrings{c.ID},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(lifetime)),
+		},
+		Nonce:    code.Nonce,
+		AuthTime: jwt.NewNumericDate(time.Unix(code.AuthTime, 0)),
+	}
+	addProfileClaims(code.Scopes, u, &claims.Name, &claims.Email)
+
+	token, err := internal.CreateIDToken(claims, s.config.IDTokenKey, s.config.IDTokenKeyID)
+	if err != nil {
+		return "", err
+	}
+	return token.GetToken(), nil
+}
+
+// addProfileClaims fills in the claims released by the profile and email scopes.
+func addProfileClaims(scopes []string, u *user.User, name, email *string) {
+	if contains(scopes, ScopeProfile) {
+		*name = u.Name
+	}
+	if contains(scopes, ScopeEmail) {
+		*email = u.Mail
+	}
+}
+
+// bearerError answers a protected resource request as described in RFC 6750 section 3.
+func bearerError(w http.ResponseWriter, status int, code string) {
+	challenge := "Bearer"
+	if code != "" {
+		challenge += ` error="` + code + `"`
+	}
+	w.Header().Set("WWW-Authenticate", challenge)
+	http.Error(w, http.StatusText(status), status)
+}
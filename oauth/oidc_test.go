@@ -0,0 +1,228 @@
+package oauth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/responsible-api/responsible-auth/concerns"
+	"github.com/responsible-api/responsible-auth/internal"
+	"github.com/responsible-api/responsible-auth/testutils"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// newOIDCServer returns a test server with OpenID Connect enabled.
+func newOIDCServer(t *testing.T) *Server {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa.GenerateKey() unexpected error = %v", err)
+	}
+
+	s := newTestServer(t)
+	s.config.Options.Issuer = "https://auth.example.com"
+	s.config.IDTokenKey = key
+	s.config.IDTokenKeyID = "test-key"
+	return s
+}
+
+// oidcTokens runs the authorization code flow with the given scope and nonce.
+func oidcTokens(t *testing.T, s *Server, scope, nonce string) (string, string) {
+	t.Helper()
+	params := authorizeParams()
+	params.Set("scope", scope)
+	params.Set("nonce", nonce)
+
+	rec := authorize(s, params)
+	location, _ := url.Parse(rec.Header().Get("Location"))
+	code := location.Query().Get("code")
+	if code == "" {
+		t.Fatalf("authorize redirect has no code: %s", location)
+	}
+
+	response := decodeTokens(t, postToken(s, codeExchange(code)))
+	return response.AccessToken, response.IDToken
+}
+
+// jwksKey fetches the published key, proving relying parties can verify ID tokens with it.
+func jwksKey(t *testing.T, s *Server) *rsa.PublicKey {
+	t.Helper()
+	rec := httptest.NewRecorder()
+	s.JWKSHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/.well-known/jwks.json", nil))
+
+	var set struct {
+		Keys []map[string]string `json:"keys"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&set); err != nil || len(set.Keys) != 1 {
+		t.Fatalf("JWKS response = %v, %v, want one key", set, err)
+	}
+	key := set.Keys[0]
+	if key["kid"] != "test-key" || key["alg"] != "RS256" {
+		t.Errorf("JWKS key = %v, want kid test-key and alg RS256", key)
+	}
+
+	n, _ := base64.RawURLEncoding.DecodeString(key["n"])
+	e, _ := base64.RawURLEncoding.DecodeString(key["e"])
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+}
+
+func TestIDToken(t *testing.T) {
+	s := newOIDCServer(t)
+	_, idToken := oidcTokens(t, s, "openid profile email", "n-0S6_WzA2Mj")
+
+	claims := &concerns.IDClaims{}
+	_, err := jwt.ParseWithClaims(idToken, claims, func(token *jwt.Token) (interface{}, error) {
+		return jwksKey(t, s), nil
+	}, jwt.WithValidMethods([]string{"RS256"}), jwt.WithIssuer("https://auth.example.com"), jwt.WithAudience(testClientID))
+	if err != nil {
+		t.Fatalf("ID token does not verify: %v", err)
+	}
+
+	testUser := testutils.TestUser()
+	if claims.Subject != testUser.Name || claims.Nonce != "n-0S6_WzA2Mj" {
+		t.Errorf("ID token sub %q nonce %q, want %q and the request nonce", claims.Subject, claims.Nonce, testUser.Name)
+	}
+	if claims.Email != testUser.Mail || claims.Name != testUser.Name {
+		t.Errorf("ID token email %q name %q, want %q and %q", claims.Email, claims.Name, testUser.Mail, testUser.Name)
+	}
+	if claims.AuthTime == nil || time.Since(claims.AuthTime.Time) > time.Minute {
+		t.Errorf("ID token auth_time = %v, want the time of login", claims.AuthTime)
+	}
+}
+
+func TestIDToken_Scopes(t *testing.T) {
+	s := newOIDCServer(t)
+
+	_, idToken := oidcTokens(t, s, "openid", "")
+	claims := &concerns.IDClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(idToken, claims); err != nil {
+		t.Fatalf("ParseUnverified() unexpected error = %v", err)
+	}
+	if claims.Email != "" || claims.Name != "" {
+		t.Errorf("ID token without profile/email scopes has email %q name %q", claims.Email, claims.Name)
+	}
+
+	if _, idToken := oidcTokens(t, s, "read", ""); idToken != "" {
+		t.Errorf("token response without openid scope has an ID token")
+	}
+
+	// Servers without a signing key never issue ID tokens
+	if _, idToken := oidcTokens(t, newTestServer(t), "openid", ""); idToken != "" {
+		t.Errorf("server without IDTokenKey issued an ID token")
+	}
+}
+
+func TestUserInfoHandler(t *testing.T) {
+	s := newOIDCServer(t)
+	withEmail, _ := oidcTokens(t, s, "openid email", "")
+	withoutOpenID, _ := oidcTokens(t, s, "read", "")
+	forAudience := func(audience ...string) string {
+		t.Helper()
+		options := s.config.Options
+		options.Subject = "testuser"
+		options.Scopes = "openid email"
+		options.ClientID = "gateway"
+		options.Audience = audience
+		token, err := internal.CreateAccessToken(options)
+		if err != nil {
+			t.Fatalf("CreateAccessToken() unexpected error = %v", err)
+		}
+		return token.GetToken()
+	}
+
+	tests := []struct {
+		name          string
+		authorization string
+		expectStatus  int
+		expectInfo    UserInfo
+	}{
+		{
+			name:          "email scope",
+			authorization: "Bearer " + withEmail,
+			expectStatus:  http.StatusOK,
+			expectInfo:    UserInfo{Subject: "testuser", Email: "test@example.com"},
+		},
+		{
+			name:          "audience of the issuer",
+			authorization: "Bearer " + forAudience("https://auth.example.com"),
+			expectStatus:  http.StatusOK,
+			expectInfo:    UserInfo{Subject: "testuser", Email: "test@example.com"},
+		},
+		{
+			name:          "audience of its client",
+			authorization: "Bearer " + forAudience("billing", "gateway"),
+			expectStatus:  http.StatusOK,
+			expectInfo:    UserInfo{Subject: "testuser", Email: "test@example.com"},
+		},
+		{name: "audience of another service", authorization: "Bearer " + forAudience("billing"), expectStatus: http.StatusUnauthorized},
+		{name: "missing openid scope", authorization: "Bearer " + withoutOpenID, expectStatus: http.StatusForbidden},
+		{name: "invalid token", authorization: "Bearer not-a-token", expectStatus: http.StatusUnauthorized},
+		{name: "missing token", expectStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/userinfo", nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			rec := httptest.NewRecorder()
+			s.UserInfoHandler().ServeHTTP(rec, req)
+
+			if rec.Code != tt.expectStatus {
+				t.Fatalf("userinfo status = %d, want %d", rec.Code, tt.expectStatus)
+			}
+			if tt.expectStatus != http.StatusOK {
+				if rec.Header().Get("WWW-Authenticate") == "" {
+					t.Errorf("userinfo error missing WWW-Authenticate header")
+				}
+				return
+			}
+
+			var info UserInfo
+			if err := json.NewDecoder(rec.Body).Decode(&info); err != nil {
+				t.Fatalf("invalid userinfo response: %v", err)
+			}
+			if info != tt.expectInfo {
+				t.Errorf("userinfo = %+v, want %+v", info, tt.expectInfo)
+			}
+		})
+	}
+}
+
+func TestDiscoveryHandler(t *testing.T) {
+	s := newOIDCServer(t)
+
+	rec := httptest.NewRecorder()
+	s.DiscoveryHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/.well-known/openid-configuration", nil))
+
+	var d Discovery
+	if err := json.NewDecoder(rec.Body).Decode(&d); err != nil {
+		t.Fatalf("invalid discovery document: %v", err)
+	}
+
+	if d.Issuer != "https://auth.example.com" || d.TokenEndpoint != "https://auth.example.com/token" {
+		t.Errorf("discovery issuer %q token endpoint %q", d.Issuer, d.TokenEndpoint)
+	}
+	if d.JWKSURI != "https://auth.example.com/.well-known/jwks.json" || d.UserInfoEndpoint != "https://auth.example.com/userinfo" {
+		t.Errorf("discovery jwks_uri %q userinfo_endpoint %q", d.JWKSURI, d.UserInfoEndpoint)
+	}
+	for _, grantType := range []string{GrantAuthorizationCode, GrantRefreshToken, GrantClientCredentials} {
+		if !contains(d.GrantTypesSupported, grantType) {
+			t.Errorf("discovery grant_types_supported = %v, missing %s", d.GrantTypesSupported, grantType)
+		}
+	}
+
+	// Without an ID token key the server is a plain OAuth server
+	if plain := newTestServer(t).Discovery(); plain.JWKSURI != "" || len(plain.IDTokenSigningAlgValuesSupported) != 0 {
+		t.Errorf("discovery without IDTokenKey advertises OpenID Connect: %+v", plain)
+	}
+}
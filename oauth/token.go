@@ -0,0 +1,220 @@
+package oauth
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/responsible-api/responsible-auth/auth"
+	"github.com/responsible-api/responsible-auth/concerns"
+	"github.com/responsible-api/responsible-auth/internal"
+	"github.com/responsible-api/responsible-auth/resource/access"
+	"github.com/responsible-api/responsible-auth/resource/client"
+	"github.com/responsible-api/responsible-auth/storage"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// TokenHandler serves the token endpoint, dispatching on grant_type.
+func (s *Server) TokenHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			writeError(w, ErrInvalidRequest.WithDescription("the token endpoint only accepts POST"))
+			return
+		}
+		if err := r.ParseForm(); err != nil {
+			writeError(w, ErrInvalidRequest)
+			return
+		}
+
+		grantType := r.PostForm.Get("grant_type")
+		grant, ok := s.grants[grantType]
+		if !ok {
+			writeError(w, ErrUnsupportedGrantType)
+			return
+		}
+
+		c, err := s.authenticateClient(r)
+		if err != nil {
+			s.audit(r, grantType, r.PostForm.Get("client_id"), nil, err)
+			writeError(w, err)
+			return
+		}
+		if !c.AllowsGrant(grantType) {
+			err := ErrUnauthorizedClient.WithDescription("client may not use " + grantType)
+			s.audit(r, grantType, c.ID, nil, err)
+			writeError(w, err)
+			return
+		}
+
+		response, err := grant(r, c)
+		s.audit(r, grantType, c.ID, response, err)
+		if err != nil {
+			writeError(w, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, response)
+	})
+}
+
+// audit records the outcome of a token request: refresh token grants as
+// refresh events and every other grant as a login. Device code polls that
+// are still pending are not recorded.
+func (s *Server) audit(r *http.Request, grantType, clientID string, response *access.ResponseDTO, err error) {
+	if errors.Is(err, ErrAuthorizationPending) || errors.Is(err, ErrSlowDown) {
+		return
+	}
+
+	client := auth.ClientInfoFromRequest(r)
+	event := auth.AuditEvent{
+		Type:      auth.EventLogin,
+		Method:    grantType,
+		Success:   err == nil,
+		ClientID:  clientID,
+		IP:        client.IP,
+		UserAgent: client.UserAgent,
+	}
+	if grantType == GrantRefreshToken {
+		event.Type = auth.EventRefresh
+	}
+	if err != nil {
+		event.Reason = err.Error()
+	}
+	if response != nil {
+		// The token was just signed by this server, so its claims need no verification
+		claims := &concerns.ClaimsGeneric{}
+		if _, _, perr := jwt.NewParser().ParseUnverified(response.AccessToken, claims); perr == nil {
+			event.Subject = claims.Subject
+			event.TokenID = claims.ID
+		}
+	}
+
+	if err := s.config.Options.Audit(event); err != nil {
+		s.config.Options.Log().Error("failed to record audit event", "type", event.Type, "client_id", clientID, "error", err)
+	}
+}
+
+// authenticateClient identifies the client from HTTP Basic auth or the
+// client_id and client_secret form fields. Confidential clients must present
+// their secret; public clients only identify themselves.
+func (s *Server) authenticateClient(r *http.Request) (*client.Client, error) {
+	clientID, secret, ok := r.BasicAuth()
+	if ok {
+		// RFC 6749 section 2.3.1 form-encodes the credentials before Basic encoding
+		clientID, _ = url.QueryUnescape(clientID)
+		secret, _ = url.QueryUnescape(secret)
+	} else {
+		clientID = r.PostForm.Get("client_id")
+		secret = r.PostForm.Get("client_secret")
+	}
+
+	c, err := s.config.Clients.FindClient(clientID)
+	if errors.Is(err, storage.ErrClientNotFound) {
+		return nil, ErrInvalidClient
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if c.IsConfidential() && !c.CheckSecret(secret) {
+		return nil, ErrInvalidClient
+	}
+	return c, nil
+}
+
+// authorizationCodeGrant exchanges a code and its PKCE verifier for tokens.
+func (s *Server) authorizationCodeGrant(r *http.Request, c *client.Client) (*access.ResponseDTO, error) {
+	code, err := s.config.Codes.ConsumeAuthorizationCode(hashToken(r.PostForm.Get("code")))
+	if errors.Is(err, storage.ErrCodeNotFound) {
+		return nil, ErrInvalidGrant.WithDescription("unknown or used authorization code")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case code.ClientID != c.ID:
+		return nil, ErrInvalidGrant.WithDescription("code was issued to another client")
+	case code.IsExpired(s.now().Unix()):
+		return nil, ErrInvalidGrant.WithDescription("authorization code expired")
+	case code.RedirectURI != r.PostForm.Get("redirect_uri"):
+		return nil, ErrInvalidGrant.WithDescription("redirect_uri does not match the authorization request")
+	case !verifyChallenge(code.Challenge, r.PostForm.Get("code_verifier")):
+		return nil, ErrInvalidGrant.WithDescription("invalid code_verifier")
+	}
+
+	u, err := s.activeUser(code.UserName)
+	if err != nil {
+		return nil, err
+	}
+
+	opts, err := s.userOptions(c, u, code.Scopes, GrantAuthorizationCode)
+	if err != nil {
+		return nil, err
+	}
+	response, err := s.issueTokens(opts, u.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.oidcEnabled() && contains(code.Scopes, ScopeOpenID) {
+		if response.IDToken, err = s.idToken(c, u, code); err != nil {
+			return nil, err
+		}
+	}
+	return response, nil
+}
+
+// refreshTokenGrant issues new tokens from a refresh token issued to the same
+// client (RFC 6749 section 6). The optional scope parameter may narrow, but
+// never widen, the scopes of the original grant.
+func (s *Server) refreshTokenGrant(r *http.Request, c *client.Client) (*access.ResponseDTO, error) {
+	claims, err := internal.ParseRefreshToken(r.PostForm.Get("refresh_token"), s.config.Options)
+	if err != nil {
+		return nil, ErrInvalidGrant.WithDescription("invalid refresh token")
+	}
+	if clientID, _ := claims["client_id"].(string); clientID != c.ID {
+		return nil, ErrInvalidGrant.WithDescription("refresh token was issued to another client")
+	}
+
+	username, _ := claims["username"].(string)
+	u, err := s.activeUser(username)
+	if err != nil {
+		return nil, err
+	}
+
+	granted, _ := claims["scope"].(string)
+	scopes := strings.Fields(granted)
+	if requested := strings.Fields(r.PostForm.Get("scope")); len(requested) > 0 {
+		if !subsetOf(requested, scopes) {
+			return nil, ErrInvalidScope.WithDescription("scope exceeds the original grant")
+		}
+		scopes = requested
+	}
+	opts, err := s.userOptions(c, u, c.AllowedScopes(scopes), GrantRefreshToken)
+	if err != nil {
+		return nil, err
+	}
+	return s.issueTokens(opts, u.Name)
+}
+
+// clientCredentialsGrant issues a token to a confidential client acting on its
+// own behalf. The subject is the client ID and the scopes are the requested
+// ones, or all when none are requested, intersected with the client's allowance.
+// No refresh token is issued; the client can simply request a new token.
+func (s *Server) clientCredentialsGrant(r *http.Request, c *client.Client) (*access.ResponseDTO, error) {
+	if !c.IsConfidential() {
+		return nil, ErrUnauthorizedClient.WithDescription("client_credentials requires a confidential client")
+	}
+
+	scopes := c.Scopes
+	if requested := strings.Fields(r.PostForm.Get("scope")); len(requested) > 0 {
+		scopes = c.AllowedScopes(requested)
+		if len(scopes) == 0 {
+			return nil, ErrInvalidScope.WithDescription("none of the requested scopes are allowed for this client")
+		}
+	}
+	return s.issueTokens(s.clientOptions(c, scopes), "")
+}
@@ -0,0 +1,152 @@
+// Package ratelimit implements per-account token bucket rate limiting.
+//
+// Each account owns a bucket; client credentials tokens, which have no
+// account, get a bucket per OAuth client instead. A bucket holds up to
+// Capacity tokens and refills at Rate tokens per second. Every request takes
+// one token; requests arriving at an empty bucket are rejected until enough
+// time has passed to refill one.
+// Buckets live in a storage.BucketStorage so several API instances share them.
+package ratelimit
+
+import (
+	"math"
+	"time"
+
+	"github.com/responsible-api/responsible-auth/resource/bucket"
+	"github.com/responsible-api/responsible-auth/storage"
+)
+
+// Limit is the size and refill rate of a bucket.
+type Limit struct {
+	Capacity float64 // maximum burst size
+	Rate     float64 // tokens added per second
+}
+
+// Window is the time an empty bucket takes to refill completely.
+func (l Limit) Window() time.Duration {
+	if l.Rate <= 0 {
+		return 0
+	}
+	return time.Duration(l.Capacity / l.Rate * float64(time.Second))
+}
+
+// Config chooses the limit for each request. Account overrides take
+// precedence over tier overrides, which take precedence over Default.
+type Config struct {
+	Default  Limit
+	Accounts map[uint64]Limit // per account ID
+	Tiers    map[string]Limit // per tier, such as the role of an API key
+}
+
+// LimitFor returns the limit that applies to the account and tier.
+func (c Config) LimitFor(accountID uint64, tier string) Limit {
+	if l, ok := c.Accounts[accountID]; ok {
+		return l
+	}
+	return c.TierLimit(tier)
+}
+
+// TierLimit returns the limit that applies to the tier.
+func (c Config) TierLimit(tier string) Limit {
+	if l, ok := c.Tiers[tier]; ok {
+		return l
+	}
+	return c.Default
+}
+
+// AccountKey returns the bucket key of an account.
+func AccountKey(accountID uint64) bucket.Key {
+	return bucket.Key{AccountID: accountID}
+}
+
+// ClientKey returns the bucket key of an OAuth client acting on its own behalf.
+func ClientKey(clientID string) bucket.Key {
+	return bucket.Key{ClientID: clientID}
+}
+
+// Result describes the outcome of taking a token.
+type Result struct {
+	Allowed    bool
+	Limit      Limit
+	Remaining  int           // whole tokens left in the bucket
+	Reset      time.Duration // time until the bucket is full again
+	RetryAfter time.Duration // time until a token is available; zero when allowed
+}
+
+// Limiter takes tokens from account and client buckets.
+type Limiter struct {
+	store  storage.BucketStorage
+	config Config
+	now    func() time.Time
+}
+
+// NewLimiter creates a limiter that keeps its buckets in store.
+func NewLimiter(store storage.BucketStorage, config Config) *Limiter {
+	return &Limiter{
+		store:  store,
+		config: config,
+		now:    time.Now,
+	}
+}
+
+// Allow refills the account's bucket for the time elapsed since its last use
+// and takes one token from it when one is available.
+func (l *Limiter) Allow(accountID uint64, tier string) (*Result, error) {
+	return l.take(AccountKey(accountID), l.config.LimitFor(accountID, tier))
+}
+
+// AllowClient is Allow for a client credentials token, limited per OAuth
+// client by its tier.
+func (l *Limiter) AllowClient(clientID string, tier string) (*Result, error) {
+	return l.take(ClientKey(clientID), l.config.TierLimit(tier))
+}
+
+// take refills the bucket with the key and takes one token from it.
+func (l *Limiter) take(key bucket.Key, limit Limit) (*Result, error) {
+	now := l.now().UnixNano()
+
+	result := &Result{Limit: limit}
+	_, err := l.store.UpdateBucket(key, func(b *bucket.Bucket) {
+		if b.Updated == 0 {
+			b.Tokens = limit.Capacity
+		} else if elapsed := now - b.Updated; elapsed > 0 {
+			b.Tokens += float64(elapsed) / float64(time.Second) * limit.Rate
+		}
+		b.Tokens = math.Min(b.Tokens, limit.Capacity)
+		b.Updated = now
+
+		if b.Tokens >= 1 {
+			b.Tokens--
+			result.Allowed = true
+		} else {
+			result.RetryAfter = refillTime(1-b.Tokens, limit.Rate)
+		}
+		result.Remaining = int(b.Tokens)
+		result.Reset = refillTime(limit.Capacity-b.Tokens, limit.Rate)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Reset refills the account's bucket.
+func (l *Limiter) Reset(accountID uint64) error {
+	return l.store.DeleteBucket(AccountKey(accountID))
+}
+
+// ResetClient refills the bucket of an OAuth client.
+func (l *Limiter) ResetClient(clientID string) error {
+	return l.store.DeleteBucket(ClientKey(clientID))
+}
+
+// refillTime is how long it takes to add tokens at rate, rounded up to a whole second.
+func refillTime(tokens, rate float64) time.Duration {
+	if tokens <= 0 {
+		return 0
+	}
+	if rate <= 0 {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Duration(math.Ceil(tokens/rate)) * time.Second
+}
@@ -0,0 +1,102 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/responsible-api/responsible-auth/examples/memory"
+)
+
+func newTestLimiter(config Config) (*Limiter, *time.Time) {
+	clock := time.Unix(1700000000, 0)
+	limiter := NewLimiter(memory.NewInMemoryBucketStorage(), config)
+	limiter.now = func() time.Time { return clock }
+	return limiter, &clock
+}
+
+func allow(t *testing.T, l *Limiter, accountID uint64, tier string) *Result {
+	t.Helper()
+	result, err := l.Allow(accountID, tier)
+	if err != nil {
+		t.Fatalf("Allow() unexpected error = %v", err)
+	}
+	return result
+}
+
+func TestLimiter_Allow(t *testing.T) {
+	limiter, clock := newTestLimiter(Config{Default: Limit{Capacity: 3, Rate: 1}})
+
+	for i := 2; i >= 0; i-- {
+		result := allow(t, limiter, 1001, "")
+		if !result.Allowed || result.Remaining != i {
+			t.Fatalf("Allow() = %+v, want allowed with %d remaining", result, i)
+		}
+	}
+
+	result := allow(t, limiter, 1001, "")
+	if result.Allowed {
+		t.Fatalf("Allow() on empty bucket allowed the request")
+	}
+	if result.RetryAfter != time.Second || result.Reset != 3*time.Second {
+		t.Errorf("Allow() retry after %v reset %v, want 1s and 3s", result.RetryAfter, result.Reset)
+	}
+
+	// Other accounts have their own bucket
+	if result := allow(t, limiter, 1002, ""); !result.Allowed {
+		t.Errorf("Allow() for another account was rejected")
+	}
+
+	*clock = clock.Add(time.Second)
+	if result := allow(t, limiter, 1001, ""); !result.Allowed || result.Remaining != 0 {
+		t.Errorf("Allow() after refilling one token = %+v, want allowed with 0 remaining", result)
+	}
+
+	// Refill never exceeds capacity
+	*clock = clock.Add(time.Hour)
+	if result := allow(t, limiter, 1001, ""); result.Remaining != 2 {
+		t.Errorf("Allow() after long idle remaining = %d, want 2", result.Remaining)
+	}
+}
+
+func TestLimiter_Reset(t *testing.T) {
+	limiter, _ := newTestLimiter(Config{Default: Limit{Capacity: 1, Rate: 0.01}})
+
+	allow(t, limiter, 1001, "")
+	if result := allow(t, limiter, 1001, ""); result.Allowed {
+		t.Fatalf("Allow() on empty bucket allowed the request")
+	}
+
+	if err := limiter.Reset(1001); err != nil {
+		t.Fatalf("Reset() unexpected error = %v", err)
+	}
+	if result := allow(t, limiter, 1001, ""); !result.Allowed {
+		t.Errorf("Allow() after Reset() was rejected")
+	}
+}
+
+func TestConfig_LimitFor(t *testing.T) {
+	config := Config{
+		Default:  Limit{Capacity: 10, Rate: 1},
+		Accounts: map[uint64]Limit{1001: {Capacity: 1000, Rate: 100}},
+		Tiers:    map[string]Limit{"premium": {Capacity: 100, Rate: 10}},
+	}
+
+	tests := []struct {
+		name      string
+		accountID uint64
+		tier      string
+		want      Limit
+	}{
+		{name: "default", accountID: 1002, tier: "user", want: config.Default},
+		{name: "tier", accountID: 1002, tier: "premium", want: Limit{Capacity: 100, Rate: 10}},
+		{name: "account overrides tier", accountID: 1001, tier: "premium", want: Limit{Capacity: 1000, Rate: 100}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := config.LimitFor(tt.accountID, tt.tier); got != tt.want {
+				t.Errorf("LimitFor() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
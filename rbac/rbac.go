@@ -0,0 +1,128 @@
+// Package rbac resolves fine-grained permissions from roles.
+//
+// Roles, the permissions granted to them and the users they are assigned to
+// are kept in a storage.RoleStorage. A role inheriting another has all of its
+// permissions too. An Authorizer is also a ClaimsEnricher that writes a
+// user's roles into the roles claim of every access token, so permission
+// checks on a request need only the token and the role definitions.
+package rbac
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/responsible-api/responsible-auth/auth"
+	"github.com/responsible-api/responsible-auth/concerns"
+	"github.com/responsible-api/responsible-auth/resource/role"
+	"github.com/responsible-api/responsible-auth/resource/user"
+	"github.com/responsible-api/responsible-auth/storage"
+)
+
+// Principal is the user a permission check is made for, with the roles it holds.
+type Principal struct {
+	Name  string
+	Roles []string
+}
+
+// PrincipalFromClaims returns the principal of a validated access token. Tokens
+// without a roles claim fall back to their single role claim, so tokens issued
+// before roles were enriched keep the permissions of that role.
+func PrincipalFromClaims(claims *concerns.ClaimsGeneric) Principal {
+	roles := claims.Roles
+	if len(roles) == 0 && claims.Role != "" {
+		roles = []string{claims.Role}
+	}
+	return Principal{Name: claims.Subject, Roles: roles}
+}
+
+// Authorizer checks permissions against the roles in a storage.RoleStorage.
+type Authorizer struct {
+	storage storage.RoleStorage
+}
+
+// NewAuthorizer creates an authorizer reading roles from the given storage.
+func NewAuthorizer(storage storage.RoleStorage) *Authorizer {
+	return &Authorizer{storage: storage}
+}
+
+// Principal returns the named user with the roles assigned in storage.
+func (a *Authorizer) Principal(userName string) (Principal, error) {
+	roles, err := a.storage.FindUserRoles(userName)
+	if err != nil {
+		return Principal{}, err
+	}
+	return Principal{Name: userName, Roles: roles}, nil
+}
+
+// Can reports whether the principal's roles, or the roles they inherit, grant
+// permission on resource. Pass an empty resource for permissions that are not
+// tied to one; only grants for every resource allow those.
+func (a *Authorizer) Can(principal Principal, permission, resource string) (bool, error) {
+	permissions, err := a.Permissions(principal)
+	if err != nil {
+		return false, err
+	}
+	for _, p := range permissions {
+		if p.Matches(permission, resource) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Permissions returns every permission the principal holds through its roles
+// and the roles they inherit, ordered by role, permission and resource.
+func (a *Authorizer) Permissions(principal Principal) ([]*role.Permission, error) {
+	roles, err := a.expand(principal.Roles)
+	if err != nil {
+		return nil, err
+	}
+
+	permissions := []*role.Permission{}
+	for _, name := range roles {
+		granted, err := a.storage.FindPermissions(name)
+		if err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, granted...)
+	}
+	return permissions, nil
+}
+
+// Enrich writes the user's assigned roles into the roles claim, making the
+// Authorizer an auth.ClaimsEnricher.
+func (a *Authorizer) Enrich(u *user.User, grant auth.Grant, claims *auth.Claims) error {
+	roles, err := a.storage.FindUserRoles(u.Name)
+	if err != nil {
+		return err
+	}
+	claims.Roles = roles
+	return nil
+}
+
+// expand returns the roles with every role they inherit, sorted. Roles that no
+// longer exist are skipped, as a token may name a role deleted since it was
+// issued, and a cycle in the hierarchy ends the walk.
+func (a *Authorizer) expand(roles []string) ([]string, error) {
+	seen := make(map[string]bool)
+	for _, name := range roles {
+		for name != "" && !seen[name] {
+			r, err := a.storage.FindRole(name)
+			if errors.Is(err, storage.ErrRoleNotFound) {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			seen[name] = true
+			name = r.Inherits
+		}
+	}
+
+	expanded := make([]string, 0, len(seen))
+	for name := range seen {
+		expanded = append(expanded, name)
+	}
+	sort.Strings(expanded)
+	return expanded, nil
+}
@@ -0,0 +1,154 @@
+package rbac
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/responsible-api/responsible-auth/concerns"
+	"github.com/responsible-api/responsible-auth/examples/memory"
+	"github.com/responsible-api/responsible-auth/resource/role"
+	"github.com/responsible-api/responsible-auth/service"
+	"github.com/responsible-api/responsible-auth/storage"
+	"github.com/responsible-api/responsible-auth/testutils"
+)
+
+// newTestStorage returns roles viewer < editor < admin, plus an unrelated
+// auditor, with testuser assigned editor.
+func newTestStorage(t *testing.T) storage.RoleStorage {
+	t.Helper()
+	s := memory.NewInMemoryRoleStorage()
+	for _, r := range []*role.Role{
+		{Name: "viewer"},
+		{Name: "editor", Inherits: "viewer"},
+		{Name: "admin", Inherits: "editor"},
+		{Name: "auditor"},
+	} {
+		if err := s.CreateRole(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, p := range []*role.Permission{
+		{RoleName: "viewer", Permission: "orders:read"},
+		{RoleName: "editor", Permission: "orders:write", Resource: "orders/*"},
+		{RoleName: "admin", Permission: role.Wildcard, Resource: role.Wildcard},
+		{RoleName: "auditor", Permission: "audit:read", Resource: "audit/2026"},
+	} {
+		if err := s.GrantPermission(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.AssignRole("testuser", "editor"); err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestAuthorizer_Can(t *testing.T) {
+	authorizer := NewAuthorizer(newTestStorage(t))
+
+	tests := []struct {
+		name       string
+		roles      []string
+		permission string
+		resource   string
+		want       bool
+	}{
+		{name: "direct grant", roles: []string{"viewer"}, permission: "orders:read", resource: "orders/42", want: true},
+		{name: "inherited grant", roles: []string{"editor"}, permission: "orders:read", resource: "orders/42", want: true},
+		{name: "resource prefix", roles: []string{"editor"}, permission: "orders:write", resource: "orders/42", want: true},
+		{name: "resource outside prefix", roles: []string{"editor"}, permission: "orders:write", resource: "invoices/7", want: false},
+		{name: "resource-specific grant without resource", roles: []string{"editor"}, permission: "orders:write", want: false},
+		{name: "not inherited downwards", roles: []string{"viewer"}, permission: "orders:write", resource: "orders/42", want: false},
+		{name: "wildcard", roles: []string{"admin"}, permission: "users:delete", resource: "users/bob", want: true},
+		{name: "exact resource", roles: []string{"auditor"}, permission: "audit:read", resource: "audit/2026", want: true},
+		{name: "other exact resource", roles: []string{"auditor"}, permission: "audit:read", resource: "audit/2025", want: false},
+		{name: "several roles", roles: []string{"auditor", "viewer"}, permission: "orders:read", resource: "orders/1", want: true},
+		{name: "unknown role", roles: []string{"deleted"}, permission: "orders:read", resource: "orders/1", want: false},
+		{name: "no roles", permission: "orders:read", resource: "orders/1", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := authorizer.Can(Principal{Name: "someone", Roles: tt.roles}, tt.permission, tt.resource)
+			if err != nil {
+				t.Fatalf("Can() unexpected error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Can(%v, %s, %s) = %v, want %v", tt.roles, tt.permission, tt.resource, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthorizer_Permissions(t *testing.T) {
+	s := newTestStorage(t)
+	authorizer := NewAuthorizer(s)
+
+	principal, err := authorizer.Principal("testuser")
+	if err != nil {
+		t.Fatalf("Principal() unexpected error = %v", err)
+	}
+	if !reflect.DeepEqual(principal, Principal{Name: "testuser", Roles: []string{"editor"}}) {
+		t.Errorf("Principal() = %+v, want testuser with editor", principal)
+	}
+
+	got, err := authorizer.Permissions(principal)
+	if err != nil {
+		t.Fatalf("Permissions() unexpected error = %v", err)
+	}
+	want := []*role.Permission{
+		{RoleName: "editor", Permission: "orders:write", Resource: "orders/*"},
+		{RoleName: "viewer", Permission: "orders:read"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Permissions() = %+v, want %+v", got, want)
+	}
+
+	// Deleting the parent ends the inheritance
+	if err := s.DeleteRole("viewer"); err != nil {
+		t.Fatal(err)
+	}
+	if allowed, _ := authorizer.Can(principal, "orders:read", "orders/1"); allowed {
+		t.Errorf("Can() after deleting the inherited role = true, want false")
+	}
+}
+
+func TestAuthorizer_Enrich(t *testing.T) {
+	authorizer := NewAuthorizer(newTestStorage(t))
+
+	options := testutils.TestAuthOptions()
+	options.ClaimsEnricher = authorizer
+	provider := service.NewBasicAuth()
+	provider.SetStorage(testutils.NewMockStorage())
+	provider.SetOptions(options)
+
+	token, err := provider.CreateAccessToken("test@example.com", "test-password-hash")
+	if err != nil {
+		t.Fatalf("CreateAccessToken() unexpected error = %v", err)
+	}
+	validated, err := provider.Validate(token.GetToken())
+	if err != nil {
+		t.Fatalf("Validate() unexpected error = %v", err)
+	}
+
+	principal := PrincipalFromClaims(validated.Claims.(*concerns.ClaimsGeneric))
+	if !reflect.DeepEqual(principal.Roles, []string{"editor"}) {
+		t.Fatalf("token roles = %v, want [editor]", principal.Roles)
+	}
+	if allowed, err := authorizer.Can(principal, "orders:write", "orders/42"); err != nil || !allowed {
+		t.Errorf("Can() from token = %v, %v, want true", allowed, err)
+	}
+}
+
+func TestPrincipalFromClaims(t *testing.T) {
+	claims := &concerns.ClaimsGeneric{Role: "viewer"}
+	claims.Subject = "testuser"
+	if got := PrincipalFromClaims(claims); !reflect.DeepEqual(got, Principal{Name: "testuser", Roles: []string{"viewer"}}) {
+		t.Errorf("PrincipalFromClaims() single role = %+v, want the role claim as its roles", got)
+	}
+
+	claims.Roles = []string{"admin", "auditor"}
+	if got := PrincipalFromClaims(claims); !reflect.DeepEqual(got.Roles, []string{"admin", "auditor"}) {
+		t.Errorf("PrincipalFromClaims() roles = %v, want the roles claim", got.Roles)
+	}
+}
@@ -11,11 +11,15 @@ type Model struct {
 
 type ResponseDTO struct {
 	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type,omitempty"`
 	RefreshToken string `json:"refresh_token"`
-	ExpiresIn    int64  `json:"expires_in"`
-	Scopes       string `json:"scope,omitempty"`
-	CreatedAt    int64  `json:"created_at"`
-	UpdatedAt    int64  `json:"updated_at,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+
+	IssuedTokenType string `json:"issued_token_type,omitempty"`
+	ExpiresIn       int64  `json:"expires_in"`
+	Scopes          string `json:"scope,omitempty"`
+	CreatedAt       int64  `json:"created_at"`
+	UpdatedAt       int64  `json:"updated_at,omitempty"`
 }
 
 func NewModel() *Model {
@@ -28,10 +32,22 @@ func (b *Model) WithAccessToken(token string) {
 	b.DTO.AccessToken = token
 }
 
+func (b *Model) WithTokenType(tokenType string) {
+	b.DTO.TokenType = tokenType
+}
+
 func (b *Model) WithRefreshToken(token string) {
 	b.DTO.RefreshToken = token
 }
 
+func (b *Model) WithIDToken(token string) {
+	b.DTO.IDToken = token
+}
+
+func (b *Model) WithIssuedTokenType(tokenType string) {
+	b.DTO.IssuedTokenType = tokenType
+}
+
 func (b *Model) WithExpiresIn(expiresIn int64) {
 	b.DTO.ExpiresIn = expiresIn - time.Now().Unix()
 }
@@ -62,10 +78,14 @@ func (b *Model) WithScopes(scopes []string) {
 func (b *Model) ToResponseDTO() *ResponseDTO {
 	return &ResponseDTO{
 		AccessToken:  b.DTO.AccessToken,
+		TokenType:    b.DTO.TokenType,
 		RefreshToken: b.DTO.RefreshToken,
-		ExpiresIn:    b.DTO.ExpiresIn,
-		Scopes:       b.DTO.Scopes,
-		CreatedAt:    b.DTO.CreatedAt,
-		UpdatedAt:    b.DTO.UpdatedAt,
+		IDToken:      b.DTO.IDToken,
+
+		IssuedTokenType: b.DTO.IssuedTokenType,
+		ExpiresIn:       b.DTO.ExpiresIn,
+		Scopes:          b.DTO.Scopes,
+		CreatedAt:       b.DTO.CreatedAt,
+		UpdatedAt:       b.DTO.UpdatedAt,
 	}
 }
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/responsible-api/responsible-auth/concerns"
+
 	"github.com/golang-jwt/jwt/v5"
 )
 
@@ -21,6 +23,18 @@ func (r *RToken) GetToken() string {
 	return r.Token.Raw
 }
 
+// GetID returns the token's jti claim, or "" when it has none.
+func (r *RToken) GetID() string {
+	switch claims := r.Claims.(type) {
+	case jwt.MapClaims:
+		id, _ := claims["jti"].(string)
+		return id
+	case concerns.Generic:
+		return claims.GenericClaims().ID
+	}
+	return ""
+}
+
 func (r *RToken) GetExpirationTime() (*jwt.NumericDate, error) {
 	return r.Token.Claims.GetExpirationTime()
 }
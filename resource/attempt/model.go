@@ -0,0 +1,15 @@
+package attempt
+
+// Attempt tracks failed logins for one key, such as an account identifier or a client IP.
+type Attempt struct {
+	Key         string `gorm:"column:attempt_key;primaryKey"`
+	Failures    int    // failures since WindowStart
+	WindowStart int64  // unix time of the first failure in the current window
+	LockedUntil int64  // unix time the lock expires; zero when not locked
+	Lockouts    int    // consecutive lockouts, used for exponential backoff
+}
+
+// IsLocked reports whether the key is locked at the given unix time.
+func (a *Attempt) IsLocked(now int64) bool {
+	return a.LockedUntil > now
+}
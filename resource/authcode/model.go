@@ -0,0 +1,20 @@
+package authcode
+
+// Code is an issued authorization code waiting to be exchanged for tokens.
+// Only the SHA-256 hash of the code is stored, so a leaked table cannot be replayed.
+type Code struct {
+	Hash        string   `gorm:"column:code_hash;primaryKey"`
+	ClientID    string   `gorm:"column:client_id"`
+	UserName    string   `gorm:"column:user_name"` // unique user.User.Name
+	RedirectURI string   `gorm:"column:redirect_uri"`
+	Scopes      []string `gorm:"column:scopes;serializer:json"`
+	Challenge   string   `gorm:"column:code_challenge"` // PKCE S256 code challenge
+	Nonce       string   `gorm:"column:nonce"`          // OpenID Connect nonce echoed in the ID token
+	AuthTime    int64    `gorm:"column:auth_time"`      // unix time the user authenticated
+	ExpiresAt   int64    `gorm:"column:expires_at"`
+}
+
+// IsExpired reports whether the code can no longer be exchanged at the given unix time.
+func (c *Code) IsExpired(now int64) bool {
+	return now >= c.ExpiresAt
+}
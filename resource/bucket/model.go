@@ -0,0 +1,48 @@
+package bucket
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Key identifies a bucket: an account's or, for client credentials tokens,
+// which have no account, an OAuth client's.
+type Key struct {
+	AccountID uint64
+	ClientID  string
+}
+
+// Bucket is the state of one token bucket.
+type Bucket struct {
+	Key     Key
+	Tokens  float64 // tokens left after the last refill
+	Updated int64   // unix nanoseconds of the last refill; zero for a new bucket
+}
+
+// String encodes the state for the bucket column as "<tokens>:<updated>".
+func (b *Bucket) String() string {
+	return strconv.FormatFloat(b.Tokens, 'f', -1, 64) + ":" + strconv.FormatInt(b.Updated, 10)
+}
+
+// Parse decodes a state written by String. An empty value is a new bucket.
+func Parse(key Key, value string) (*Bucket, error) {
+	b := &Bucket{Key: key}
+	if value == "" {
+		return b, nil
+	}
+
+	tokens, updated, ok := strings.Cut(value, ":")
+	if !ok {
+		return nil, fmt.Errorf("invalid bucket state %q", value)
+	}
+
+	var err error
+	if b.Tokens, err = strconv.ParseFloat(tokens, 64); err != nil {
+		return nil, fmt.Errorf("invalid bucket tokens %q: %w", tokens, err)
+	}
+	if b.Updated, err = strconv.ParseInt(updated, 10, 64); err != nil {
+		return nil, fmt.Errorf("invalid bucket timestamp %q: %w", updated, err)
+	}
+	return b, nil
+}
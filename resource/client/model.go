@@ -0,0 +1,71 @@
+package client
+
+import "golang.org/x/crypto/bcrypt"
+
+// Client is an application registered with the authorization server.
+type Client struct {
+	ID           string `gorm:"column:client_id;primaryKey"`
+	Name         string
+	SecretHash   string   // bcrypt hash of the client secret; empty for public clients
+	RedirectURIs []string `gorm:"column:redirect_uris;serializer:json"`
+	GrantTypes   []string `gorm:"column:grant_types;serializer:json"`
+	Scopes       []string `gorm:"column:scopes;serializer:json"` // scopes the client may be granted
+
+	// Token lifetimes in seconds; zero uses the server's AuthOptions
+	AccessTokenLifetime  int64
+	RefreshTokenLifetime int64
+
+	Created int64
+}
+
+// HashSecret hashes a client secret for storage in SecretHash.
+func HashSecret(secret string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(secret), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// IsConfidential reports whether the client has a secret and must authenticate with it.
+func (c *Client) IsConfidential() bool {
+	return c.SecretHash != ""
+}
+
+// CheckSecret reports whether secret matches the stored hash. Public clients never match.
+func (c *Client) CheckSecret(secret string) bool {
+	if c.SecretHash == "" || secret == "" {
+		return false
+	}
+	return bcrypt.CompareHashAndPassword([]byte(c.SecretHash), []byte(secret)) == nil
+}
+
+// AllowsRedirect reports whether uri exactly matches one of the registered redirect URIs.
+func (c *Client) AllowsRedirect(uri string) bool {
+	return contains(c.RedirectURIs, uri)
+}
+
+// AllowsGrant reports whether the client may use the grant type.
+func (c *Client) AllowsGrant(grantType string) bool {
+	return contains(c.GrantTypes, grantType)
+}
+
+// AllowedScopes returns the requested scopes the client may be granted, in request order.
+func (c *Client) AllowedScopes(requested []string) []string {
+	allowed := []string{}
+	for _, scope := range requested {
+		if contains(c.Scopes, scope) && !contains(allowed, scope) {
+			allowed = append(allowed, scope)
+		}
+	}
+	return allowed
+}
+
+func contains(list []string, value string) bool {
+	for _, v := range list {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,34 @@
+package credential
+
+// Credential is a WebAuthn public key credential, such as a passkey or a
+// security key, registered to a user.
+type Credential struct {
+	ID         string   `gorm:"column:credential_id;primaryKey"` // base64url credential ID
+	UserName   string   `gorm:"column:user_name"`
+	PublicKey  []byte   `gorm:"column:public_key"` // COSE_Key as returned by the authenticator
+	Algorithm  int64    `gorm:"column:algorithm"`  // COSE algorithm identifier
+	SignCount  uint32   `gorm:"column:sign_count"`
+	Transports []string `gorm:"column:transports;serializer:json"`
+	Created    int64    `gorm:"column:created"`
+	LastUsed   int64    `gorm:"column:last_used"`
+}
+
+// Challenge types, matching the type member of the client data.
+const (
+	TypeCreate = "webauthn.create"
+	TypeGet    = "webauthn.get"
+)
+
+// Challenge is an outstanding registration or login ceremony. Each challenge
+// can complete exactly one ceremony.
+type Challenge struct {
+	Challenge string `gorm:"column:challenge;primaryKey"` // base64url challenge sent to the client
+	Type      string `gorm:"column:ceremony"`
+	UserName  string `gorm:"column:user_name"` // empty for discoverable logins
+	ExpiresAt int64  `gorm:"column:expires_at"`
+}
+
+// IsExpired reports whether the challenge can no longer be used at the given unix time.
+func (c *Challenge) IsExpired(now int64) bool {
+	return now >= c.ExpiresAt
+}
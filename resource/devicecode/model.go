@@ -0,0 +1,29 @@
+package devicecode
+
+// Device authorization states stored in Code.Status.
+const (
+	StatusPending  = 0 // waiting for the user to approve or deny
+	StatusApproved = 1
+	StatusDenied   = 2
+)
+
+// Code is a pending device authorization (RFC 8628). The device polls with the
+// device code, stored only as its SHA-256 hash, while the user approves the
+// request by entering the short user code on another device.
+type Code struct {
+	Hash      string   `gorm:"column:code_hash;primaryKey"`
+	UserCode  string   `gorm:"column:user_code"` // normalised, without the dash
+	ClientID  string   `gorm:"column:client_id"`
+	Scopes    []string `gorm:"column:scopes;serializer:json"`
+	Status    int      `gorm:"column:status"`
+	UserName  string   `gorm:"column:user_name"` // set once approved
+	AuthTime  int64    `gorm:"column:auth_time"`
+	Interval  int64    `gorm:"column:poll_interval"` // minimum seconds between polls
+	LastPoll  int64    `gorm:"column:last_polled"`
+	ExpiresAt int64    `gorm:"column:expires_at"`
+}
+
+// IsExpired reports whether the code can no longer be approved or polled at the given unix time.
+func (c *Code) IsExpired(now int64) bool {
+	return now >= c.ExpiresAt
+}
@@ -0,0 +1,46 @@
+package role
+
+import "strings"
+
+// Wildcard grants every permission, or access to every resource.
+const Wildcard = "*"
+
+// Role is a named set of permissions. A role inheriting another has all of
+// its permissions as well as its own, so roles form a hierarchy such as
+// admin > editor > viewer.
+type Role struct {
+	Name        string `gorm:"column:name;primaryKey"`
+	Description string `gorm:"column:description"`
+	Inherits    string `gorm:"column:inherits"` // parent role; empty for none
+	Created     int64  `gorm:"column:created"`
+}
+
+// Permission grants a role an action, such as "orders:refund", on the
+// resources matching Resource. An empty Resource or Wildcard matches every
+// resource, and a Resource ending in "*" matches by prefix, so "orders/*"
+// covers "orders/42".
+type Permission struct {
+	RoleName   string `gorm:"column:role_name;primaryKey"`
+	Permission string `gorm:"column:permission;primaryKey"`
+	Resource   string `gorm:"column:resource;primaryKey"`
+}
+
+// Matches reports whether the permission allows the action on the resource.
+func (p *Permission) Matches(permission, resource string) bool {
+	if p.Permission != permission && p.Permission != Wildcard {
+		return false
+	}
+	switch {
+	case p.Resource == "" || p.Resource == Wildcard:
+		return true
+	case strings.HasSuffix(p.Resource, Wildcard):
+		return strings.HasPrefix(resource, strings.TrimSuffix(p.Resource, Wildcard))
+	}
+	return p.Resource == resource
+}
+
+// Assignment gives a user a role.
+type Assignment struct {
+	UserName string `gorm:"column:user_name;primaryKey"`
+	RoleName string `gorm:"column:role_name;primaryKey"`
+}
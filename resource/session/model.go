@@ -0,0 +1,20 @@
+package session
+
+// Session is a browser or device a user is logged in on. Each session holds
+// one refresh token and is identified by the token's SHA-256 hash, so the
+// token itself is never stored.
+type Session struct {
+	ID         string `gorm:"column:session_id;primaryKey"` // hex SHA-256 of the refresh token
+	UserName   string `gorm:"column:user_name"`
+	DeviceName string `gorm:"column:device_name"`
+	UserAgent  string `gorm:"column:user_agent"`
+	IP         string `gorm:"column:ip"`
+	Created    int64  `gorm:"column:created"`
+	LastUsed   int64  `gorm:"column:last_used"`
+	ExpiresAt  int64  `gorm:"column:expires_at"` // expiry of the refresh token
+}
+
+// IsExpired reports whether the session's refresh token has expired at the given unix time.
+func (s *Session) IsExpired(now int64) bool {
+	return now >= s.ExpiresAt
+}
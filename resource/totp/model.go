@@ -0,0 +1,14 @@
+package totp
+
+// Enrolment is a user's TOTP (RFC 6238) second factor. It is only enforced
+// once Confirmed, after the user has proven their authenticator works.
+type Enrolment struct {
+	UserName      string   `gorm:"column:user_name;primaryKey"`
+	Secret        string   `gorm:"column:secret"` // base32 shared secret; needed in plain to compute codes
+	Confirmed     bool     `gorm:"column:confirmed"`
+	RecoveryCodes []string `gorm:"column:recovery_codes;serializer:json"` // SHA-256 hashes of unused codes
+	LastStep      int64    `gorm:"column:last_step"`                      // last accepted time step, so a code works only once
+	Failures      int      `gorm:"column:failures"`                       // invalid codes since the last accepted one
+	LockedUntil   int64    `gorm:"column:locked_until"`                   // unix time until which codes are refused
+	Created       int64    `gorm:"column:created"`
+}
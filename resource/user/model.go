@@ -1,9 +1,18 @@
 package user
 
 import (
+	"log/slog"
 	"time"
 )
 
+// User account states stored in User.Status.
+const (
+	StatusInactive  = 0 // created but not yet activated
+	StatusActive    = 1
+	StatusSuspended = 2 // temporarily blocked by an administrator
+	StatusDeleted   = 3 // soft deleted; kept for auditing
+)
+
 type User struct {
 	AccountID uint64
 	Name      string
@@ -12,8 +21,8 @@ type User struct {
 	Access    uint64
 	Status    int
 	Secret    string
-	APIKey    string
-	Refresh   string
+	APIKey    string `gorm:"column:apikey"`
+	Refresh   string `gorm:"column:refresh_token"`
 }
 
 type DTO struct {
@@ -36,6 +45,27 @@ type Form struct {
 	Mail      string `json:"mail"`
 }
 
+// RegisterForm is the payload for creating a new user with a password.
+type RegisterForm struct {
+	Form
+	Password string `json:"password"`
+}
+
+// LogValue logs the user without its secret, API key or refresh token.
+func (u User) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.String("name", u.Name),
+		slog.String("mail", u.Mail),
+		slog.Uint64("account_id", u.AccountID),
+		slog.Int("status", u.Status),
+	)
+}
+
+// IsActive reports whether the user may authenticate.
+func (u *User) IsActive() bool {
+	return u.Status == StatusActive
+}
+
 func (u *User) ToDto() *DTO {
 	return &DTO{
 		AccountID: u.AccountID,
@@ -57,6 +87,6 @@ func (f *Form) ToModel() *User {
 		Mail:      f.Mail,
 		Created:   uint64(time.Now().Unix()),
 		Access:    uint64(time.Now().Unix()),
-		Status:    1,
+		Status:    StatusActive,
 	}
 }
@@ -0,0 +1,33 @@
+package user
+
+import (
+	"crypto/subtle"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// HashSecret hashes a plaintext password for storage in User.Secret.
+func HashSecret(plain string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(plain), bcrypt.DefaultCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// CheckSecret reports whether plain matches the stored secret.
+// Secrets written before hashing was introduced are compared in constant time.
+func (u *User) CheckSecret(plain string) bool {
+	if plain == "" || u.Secret == "" {
+		return false
+	}
+	if isHashed(u.Secret) {
+		return bcrypt.CompareHashAndPassword([]byte(u.Secret), []byte(plain)) == nil
+	}
+	return subtle.ConstantTimeCompare([]byte(u.Secret), []byte(plain)) == 1
+}
+
+func isHashed(secret string) bool {
+	return strings.HasPrefix(secret, "$2a$") || strings.HasPrefix(secret, "$2b$") || strings.HasPrefix(secret, "$2y$")
+}
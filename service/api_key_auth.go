@@ -4,6 +4,7 @@ import (
 	"github.com/responsible-api/responsible-auth/auth"
 	"github.com/responsible-api/responsible-auth/internal"
 	"github.com/responsible-api/responsible-auth/resource/access"
+	"github.com/responsible-api/responsible-auth/resource/user"
 	"github.com/responsible-api/responsible-auth/storage"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -11,6 +12,7 @@ import (
 
 type APIKeyAuth struct {
 	auth.AuthProvider
+	options auth.AuthOptions
 	storage storage.UserStorage
 }
 
@@ -21,9 +23,15 @@ func NewApiKeyAuth() auth.AuthInterface {
 
 // SetOptions sets the options for the APIKeyAuth provider.
 func (d *APIKeyAuth) SetOptions(options auth.AuthOptions) {
+	d.options = options
 	Options = options
 }
 
+// Options returns the options set for the APIKeyAuth provider.
+func (d *APIKeyAuth) Options() auth.AuthOptions {
+	return d.options
+}
+
 // SetStorage sets the storage implementation for the APIKeyAuth provider.
 func (d *APIKeyAuth) SetStorage(storage storage.UserStorage) {
 	d.storage = storage
@@ -38,42 +46,91 @@ func (d *APIKeyAuth) Decode(APIKey string) (string, string, error) {
 	return unpackedUsername, unpackedPassword, nil
 }
 
+// CreateAccessToken issues a token for the active user owning the API key.
+// The key alone identifies the user, so userID is not consulted.
 func (a *APIKeyAuth) CreateAccessToken(userID string, APIKey string) (*access.RToken, error) {
-	token, err := internal.CreateAccessToken(Options)
+	user, err := a.findActiveUser(APIKey)
+	if err != nil {
+		auditLogin(a.options, auth.GrantAPIKey, auth.ClientInfo{}, "", nil, err)
+		return nil, err
+	}
+
+	opts, err := userOptions(a.options, user).Enrich(user, auth.Grant{Type: auth.GrantAPIKey})
+	if err != nil {
+		return nil, err
+	}
+	token, err := internal.CreateAccessToken(opts)
 	if err != nil {
 		return nil, err
 	}
+
+	recordAccess(a.options.Log(), a.storage, user)
+	auditLogin(a.options, auth.GrantAPIKey, auth.ClientInfo{}, user.Name, token, nil)
 	return token, nil
 }
 
-func (a *APIKeyAuth) CreateRefreshToken(userID string, hash string) (*access.RToken, error) {
-	refreshToken, err := internal.CreateRefreshToken(userID, Options)
+func (a *APIKeyAuth) CreateRefreshToken(userID string, APIKey string) (*access.RToken, error) {
+	user, err := a.findActiveUser(APIKey)
+	if err != nil {
+		auditLogin(a.options, auth.GrantAPIKey, auth.ClientInfo{}, "", nil, err)
+		return nil, err
+	}
+
+	refreshToken, err := internal.CreateRefreshToken(user.Name, a.options)
 	if err != nil {
 		return nil, err
 	}
+
+	auditLogin(a.options, auth.GrantAPIKey, auth.ClientInfo{}, user.Name, refreshToken, nil)
 	return refreshToken, nil
 }
 
 func (a *APIKeyAuth) GrantRefreshToken(refreshTokenString string) (*access.RToken, error) {
-	refreshToken, err := internal.GrantRefreshToken(refreshTokenString, Options)
+	user, opts, err := refreshTokenUser(a.storage, refreshTokenString, a.options)
 	if err != nil {
 		return nil, err
 	}
-	return refreshToken, nil
+
+	token, err := internal.CreateAccessToken(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	recordAccess(a.options.Log(), a.storage, user)
+	return token, nil
 }
 
 func (a *APIKeyAuth) Validate(tokenString string) (*jwt.Token, error) {
-	token, err := internal.Validate(tokenString, Options)
+	token, err := internal.Validate(tokenString, a.options)
 	if err != nil {
 		return nil, err
 	}
 	return token, nil
 }
 
+// validateAPIKey resolves the key to its user and returns the user's name and
+// the key itself, ready to pass on to CreateAccessToken.
 func (d *APIKeyAuth) validateAPIKey(APIKey string) (string, string, error) {
-	user, err := d.storage.FindUserByAPIKey(APIKey)
+	user, err := d.findActiveUser(APIKey)
 	if err != nil {
 		return "", "", err
 	}
-	return user.Name, user.Secret, nil
+	return user.Name, APIKey, nil
+}
+
+// findActiveUser looks up the owner of an API key and rejects inactive users.
+func (d *APIKeyAuth) findActiveUser(APIKey string) (*user.User, error) {
+	if d.storage == nil {
+		return nil, ErrNoStorage
+	}
+
+	user, err := d.storage.FindUserByAPIKey(APIKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkActive(user); err != nil {
+		return nil, err
+	}
+	return user, nil
 }
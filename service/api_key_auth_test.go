@@ -1,9 +1,12 @@
 package service
 
 import (
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/responsible-api/responsible-auth/auth"
+	"github.com/responsible-api/responsible-auth/resource/user"
 	"github.com/responsible-api/responsible-auth/testutils"
 )
 
@@ -47,6 +50,7 @@ func TestAPIKeyAuth_SetStorage(t *testing.T) {
 
 func TestAPIKeyAuth_Decode(t *testing.T) {
 	provider := NewApiKeyAuth()
+	provider.SetStorage(testutils.NewMockStorage())
 
 	tests := []struct {
 		name        string
@@ -58,23 +62,19 @@ func TestAPIKeyAuth_Decode(t *testing.T) {
 		{
 			name:        "valid api key",
 			input:       "test-api-key-12345",
-			expectUser:  "exampleUser",     // Based on the validateAPIKey implementation
-			expectPass:  "examplePassword", // Based on the validateAPIKey implementation
+			expectUser:  "testuser",           // Name of the key's owner
+			expectPass:  "test-api-key-12345", // The key, ready for CreateAccessToken
 			expectError: false,
 		},
 		{
-			name:        "any api key works with current implementation",
+			name:        "unknown api key",
 			input:       "any-key",
-			expectUser:  "exampleUser",
-			expectPass:  "examplePassword",
-			expectError: false,
+			expectError: true,
 		},
 		{
-			name:        "empty api key still works",
+			name:        "empty api key",
 			input:       "",
-			expectUser:  "exampleUser",
-			expectPass:  "examplePassword",
-			expectError: false,
+			expectError: true,
 		},
 	}
 
@@ -261,61 +261,110 @@ func TestAPIKeyAuth_GrantRefreshToken(t *testing.T) {
 }
 
 func TestValidateAPIKey(t *testing.T) {
-	provider := NewApiKeyAuth().(*APIKeyAuth)
-
 	tests := []struct {
 		name        string
 		input       string
+		status      int
+		noStorage   bool
 		expectUser  string
 		expectPass  string
-		expectError bool
+		expectError error
 	}{
 		{
-			name:        "valid api key",
-			input:       "test-api-key",
-			expectUser:  "exampleUser",
-			expectPass:  "examplePassword",
-			expectError: false,
+			name:       "valid api key",
+			input:      "test-api-key-12345",
+			status:     user.StatusActive,
+			expectUser: "testuser",
+			expectPass: "test-api-key-12345",
 		},
 		{
-			name:        "empty api key",
-			input:       "",
-			expectUser:  "exampleUser",
-			expectPass:  "examplePassword",
-			expectError: false,
+			name:        "inactive user",
+			input:       "test-api-key-12345",
+			status:      user.StatusInactive,
+			expectError: ErrUserInactive,
 		},
 		{
-			name:        "long api key",
-			input:       "very-long-api-key-with-many-characters",
-			expectUser:  "exampleUser",
-			expectPass:  "examplePassword",
-			expectError: false,
+			name:        "suspended user",
+			input:       "test-api-key-12345",
+			status:      user.StatusSuspended,
+			expectError: ErrUserInactive,
+		},
+		{
+			name:        "deleted user",
+			input:       "test-api-key-12345",
+			status:      user.StatusDeleted,
+			expectError: ErrUserInactive,
+		},
+		{
+			name:        "no storage configured",
+			input:       "test-api-key-12345",
+			noStorage:   true,
+			expectError: ErrNoStorage,
 		},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
+			provider := NewApiKeyAuth().(*APIKeyAuth)
+			if !tt.noStorage {
+				mockStorage := testutils.NewMockStorage()
+				mockStorage.APIKeys[tt.input].Status = tt.status
+				provider.SetStorage(mockStorage)
+			}
+
 			user, pass, err := provider.validateAPIKey(tt.input)
 
-			if tt.expectError && err == nil {
-				t.Errorf("validateAPIKey() expected error but got none")
+			if tt.expectError != nil {
+				if !errors.Is(err, tt.expectError) {
+					t.Errorf("validateAPIKey() error = %v, want %v", err, tt.expectError)
+				}
 				return
 			}
 
-			if !tt.expectError && err != nil {
+			if err != nil {
 				t.Errorf("validateAPIKey() unexpected error = %v", err)
 				return
 			}
 
-			if !tt.expectError {
-				if user != tt.expectUser {
-					t.Errorf("validateAPIKey() user = %v, want %v", user, tt.expectUser)
-				}
+			if user != tt.expectUser {
+				t.Errorf("validateAPIKey() user = %v, want %v", user, tt.expectUser)
+			}
 
-				if pass != tt.expectPass {
-					t.Errorf("validateAPIKey() password = %v, want %v", pass, tt.expectPass)
-				}
+			if pass != tt.expectPass {
+				t.Errorf("validateAPIKey() password = %v, want %v", pass, tt.expectPass)
 			}
 		})
 	}
 }
+
+func TestAPIKeyAuth_RecordsAccess(t *testing.T) {
+	mockStorage := testutils.NewMockStorage()
+	mockStorage.APIKeys["test-api-key-12345"].Access = 0
+
+	provider := NewApiKeyAuth().(*APIKeyAuth)
+	provider.SetStorage(mockStorage)
+	provider.SetOptions(testutils.TestAuthOptions())
+
+	if _, err := provider.CreateAccessToken("testuser", "test-api-key-12345"); err != nil {
+		t.Fatalf("CreateAccessToken() unexpected error = %v", err)
+	}
+
+	waitForAccess(t, mockStorage, "testuser")
+}
+
+// waitForAccess polls until the asynchronous access update lands in storage.
+func waitForAccess(t *testing.T, mockStorage *testutils.MockStorage, name string) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		stored, err := mockStorage.FindUserByName(name)
+		if err != nil {
+			t.Fatalf("FindUserByName() unexpected error = %v", err)
+		}
+		if stored.Access != 0 {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Errorf("access time for %s was not recorded", name)
+}
@@ -3,22 +3,29 @@ package service
 import (
 	"encoding/base64"
 	"errors"
-	"fmt"
 	"strings"
 
 	"github.com/responsible-api/responsible-auth/auth"
 	"github.com/responsible-api/responsible-auth/internal"
+	"github.com/responsible-api/responsible-auth/lockout"
+	"github.com/responsible-api/responsible-auth/mfa"
 	"github.com/responsible-api/responsible-auth/resource/access"
+	"github.com/responsible-api/responsible-auth/resource/user"
 	"github.com/responsible-api/responsible-auth/storage"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
+// Options holds the options most recently set on any provider. Each provider
+// keeps its own copy, so providers with different options can run side by side.
 var Options auth.AuthOptions
 
 type BasicAuth struct {
 	auth.AuthProvider
+	options auth.AuthOptions
 	storage storage.UserStorage
+	lockout *lockout.Guard
+	mfa     *mfa.Manager
 }
 
 type AuthOptions struct {
@@ -32,14 +39,33 @@ func NewBasicAuth() auth.AuthInterface {
 
 // SetOptions sets the options for the BasicAuth provider.
 func (d *BasicAuth) SetOptions(options auth.AuthOptions) {
+	d.options = options
 	Options = options
 }
 
+// Options returns the options set for the BasicAuth provider.
+func (d *BasicAuth) Options() auth.AuthOptions {
+	return d.options
+}
+
 // SetStorage sets the storage implementation for the BasicAuth provider.
 func (d *BasicAuth) SetStorage(storage storage.UserStorage) {
 	d.storage = storage
 }
 
+// SetLockout enables brute-force protection. Failed logins are counted per
+// account identifier and per client IP; pass nil to disable it again.
+func (d *BasicAuth) SetLockout(guard *lockout.Guard) {
+	d.lockout = guard
+}
+
+// SetMFA enables multi-factor authentication. Users with a confirmed
+// enrolment no longer receive tokens for their password alone; pass nil to
+// disable it again.
+func (d *BasicAuth) SetMFA(manager *mfa.Manager) {
+	d.mfa = manager
+}
+
 func (d *BasicAuth) Decode(hash string) (string, string, error) {
 	unpackedUsername, unpackedPassword, err := validateBasic(hash)
 	if err != nil {
@@ -51,48 +77,246 @@ func (d *BasicAuth) Decode(hash string) (string, string, error) {
 
 // Grant generates a token for the user with the given ID and password.
 func (a *BasicAuth) CreateAccessToken(userID string, hash string) (*access.RToken, error) {
-	user, err := a.storage.FindUserByCredentials(userID, hash)
+	return a.CreateAccessTokenFrom(auth.ClientInfo{}, userID, hash)
+}
+
+// CreateAccessTokenFrom is CreateAccessToken for a known client, whose IP is
+// counted by the lockout guard alongside the account.
+func (a *BasicAuth) CreateAccessTokenFrom(client auth.ClientInfo, userID string, hash string) (*access.RToken, error) {
+	user, err := a.authenticate(client, userID, hash)
 	if err != nil {
 		return nil, err
 	}
-	fmt.Println("User found:", user)
 
-	token, err := internal.CreateAccessToken(Options)
+	if err := a.requireMFA(user); err != nil {
+		return nil, err
+	}
+
+	opts := userOptions(a.options, user)
+	opts.AMR = []string{mfa.MethodPassword}
+	opts, err = opts.Enrich(user, auth.Grant{Type: auth.GrantPassword})
 	if err != nil {
 		return nil, err
 	}
+	token, err := internal.CreateAccessToken(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	recordAccess(a.options.Log(), a.storage, user)
+	auditLogin(a.options, auth.GrantPassword, client, user.Name, token, nil)
 	return token, nil
 }
 
 func (a *BasicAuth) CreateRefreshToken(userID string, hash string) (*access.RToken, error) {
-	user, err := a.storage.FindUserByCredentials(userID, hash)
+	return a.CreateRefreshTokenFrom(auth.ClientInfo{}, userID, hash)
+}
+
+// CreateRefreshTokenFrom is CreateRefreshToken for a known client.
+func (a *BasicAuth) CreateRefreshTokenFrom(client auth.ClientInfo, userID string, hash string) (*access.RToken, error) {
+	user, err := a.authenticate(client, userID, hash)
 	if err != nil {
 		return nil, err
 	}
 
-	refreshToken, err := internal.CreateRefreshToken(user.Name, Options)
+	if err := a.requireMFA(user); err != nil {
+		return nil, err
+	}
+
+	opts := a.options
+	opts.AMR = []string{mfa.MethodPassword}
+	refreshToken, err := internal.CreateRefreshToken(user.Name, opts)
 	if err != nil {
 		return nil, err
 	}
+
+	auditLogin(a.options, auth.GrantPassword, client, user.Name, refreshToken, nil)
 	return refreshToken, nil
 }
 
 func (a *BasicAuth) GrantRefreshToken(refreshTokenString string) (*access.RToken, error) {
-	refreshToken, err := internal.GrantRefreshToken(refreshTokenString, Options)
+	user, opts, err := refreshTokenUser(a.storage, refreshTokenString, a.options)
 	if err != nil {
 		return nil, err
 	}
-	return refreshToken, nil
+
+	token, err := internal.CreateAccessToken(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	recordAccess(a.options.Log(), a.storage, user)
+	return token, nil
+}
+
+// VerifyMFA completes a login that failed with an MFARequiredError. Given the
+// challenge and a TOTP or recovery code it returns the access and refresh
+// tokens, whose amr claim records both factors.
+func (a *BasicAuth) VerifyMFA(challenge string, code string) (*access.RToken, *access.RToken, error) {
+	if a.mfa == nil {
+		return nil, nil, ErrInvalidChallenge
+	}
+	claims, err := internal.ParseChallengeToken(challenge, a.options)
+	if err != nil {
+		return nil, nil, ErrInvalidChallenge
+	}
+
+	if a.storage == nil {
+		return nil, nil, ErrNoStorage
+	}
+	user, err := a.storage.FindUserByName(claims.Subject)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := checkActive(user); err != nil {
+		return nil, nil, err
+	}
+
+	// Codes are short, so wrong guesses count towards the user's lockout
+	key := userKey(user)
+	if a.lockout != nil {
+		if err := a.lockout.Check(key); err != nil {
+			auditLogin(a.options, auth.GrantMFA, auth.ClientInfo{}, user.Name, nil, err)
+			return nil, nil, err
+		}
+	}
+
+	if err := a.mfa.Verify(user.Name, code); err != nil {
+		auditLogin(a.options, auth.GrantMFA, auth.ClientInfo{}, user.Name, nil, err)
+		if a.lockout != nil && errors.Is(err, mfa.ErrInvalidCode) {
+			a.fail(auth.ClientInfo{}, user.Name, key)
+		}
+		return nil, nil, err
+	}
+	if a.lockout != nil {
+		if err := a.lockout.Succeed(key); err != nil {
+			a.options.Log().Warn("failed to reset login failures", "error", err)
+		}
+	}
+
+	opts := userOptions(a.options, user)
+	opts.AMR = append(claims.AMR, mfa.MethodOTP, mfa.MethodMFA)
+	opts, err = opts.Enrich(user, auth.Grant{Type: auth.GrantMFA})
+	if err != nil {
+		return nil, nil, err
+	}
+	token, err := internal.CreateAccessToken(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	refreshToken, err := internal.CreateRefreshToken(user.Name, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	recordAccess(a.options.Log(), a.storage, user)
+	auditLogin(a.options, auth.GrantMFA, auth.ClientInfo{}, user.Name, token, nil)
+	return token, refreshToken, nil
 }
 
 func (a *BasicAuth) Validate(tokenString string) (*jwt.Token, error) {
-	token, err := internal.Validate(tokenString, Options)
+	token, err := internal.Validate(tokenString, a.options)
 	if err != nil {
 		return nil, err
 	}
 	return token, nil
 }
 
+// authenticate checks the credentials of an active user, enforcing the lockout guard when one is set.
+func (a *BasicAuth) authenticate(client auth.ClientInfo, userID string, hash string) (*user.User, error) {
+	if a.storage == nil {
+		return nil, ErrNoStorage
+	}
+
+	var accountKey string
+	if a.lockout != nil {
+		accountKey = a.accountKey(userID)
+		if err := a.lockout.Check(accountKey, lockout.IPKey(client.IP)); err != nil {
+			auditLogin(a.options, auth.GrantPassword, client, userID, nil, err)
+			return nil, err
+		}
+	}
+
+	found, err := a.storage.FindUserByCredentials(userID, hash)
+	if err != nil {
+		auditLogin(a.options, auth.GrantPassword, client, userID, nil, err)
+		if a.lockout != nil {
+			a.fail(client, userID, accountKey, lockout.IPKey(client.IP))
+		}
+		return nil, err
+	}
+
+	if err := checkActive(found); err != nil {
+		auditLogin(a.options, auth.GrantPassword, client, found.Name, nil, err)
+		return nil, err
+	}
+
+	if a.lockout != nil {
+		if err := a.lockout.Succeed(accountKey); err != nil {
+			a.options.Log().Warn("failed to reset login failures", "error", err)
+		}
+	}
+	return found, nil
+}
+
+// accountKey returns the lockout key of a login identifier: the key of the
+// user whose mail it is, or the identifier itself. Account IDs are shared by
+// every user of a tenant, so failures by account ID only lock logins by that
+// ID and never the mail logins of the tenant's users.
+func (a *BasicAuth) accountKey(identifier string) string {
+	if u, err := a.storage.FindUserByMail(identifier); err == nil {
+		return userKey(u)
+	}
+	return lockout.AccountKey(identifier)
+}
+
+// userKey returns the lockout key counting a user's failed passwords and MFA
+// codes: their mail address, which Guard.UnlockAccount takes, or their name
+// when they have none.
+func userKey(u *user.User) string {
+	if u.Mail != "" {
+		return lockout.AccountKey(u.Mail)
+	}
+	return lockout.AccountKey(u.Name)
+}
+
+// fail counts a failed login against the lockout keys and records a lockout
+// event when the failure locks them.
+func (a *BasicAuth) fail(client auth.ClientInfo, subject string, keys ...string) {
+	if err := a.lockout.Fail(keys...); err != nil {
+		a.options.Log().Warn("failed to record login failure", "subject", subject, "error", err)
+		return
+	}
+	if err := a.lockout.Check(keys...); err != nil {
+		audit(a.options, auth.AuditEvent{
+			Type:      auth.EventLockout,
+			Subject:   subject,
+			IP:        client.IP,
+			UserAgent: client.UserAgent,
+			Reason:    err.Error(),
+		})
+	}
+}
+
+// requireMFA returns an MFARequiredError carrying a fresh challenge when the
+// user has a confirmed MFA enrolment.
+func (a *BasicAuth) requireMFA(u *user.User) error {
+	if a.mfa == nil {
+		return nil
+	}
+
+	enabled, err := a.mfa.Enabled(u.Name)
+	if err != nil || !enabled {
+		return err
+	}
+
+	challenge, err := internal.CreateChallengeToken(u.Name, []string{mfa.MethodPassword}, a.options)
+	if err != nil {
+		return err
+	}
+	return &MFARequiredError{Challenge: challenge}
+}
+
 // BasicAuth decodes a base64-encoded client credentials string and returns the username and password.
 func validateBasic(encodedCredentials string) (string, string, error) {
 	// Decode the base64-encoded string
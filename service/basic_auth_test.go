@@ -1,9 +1,21 @@
 package service
 
 import (
+	"bytes"
+	"errors"
+	"log/slog"
+	"slices"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/responsible-api/responsible-auth/auth"
+	"github.com/responsible-api/responsible-auth/concerns"
+	"github.com/responsible-api/responsible-auth/examples/memory"
+	"github.com/responsible-api/responsible-auth/lockout"
+	"github.com/responsible-api/responsible-auth/logging"
+	"github.com/responsible-api/responsible-auth/mfa"
+	"github.com/responsible-api/responsible-auth/resource/user"
 	"github.com/responsible-api/responsible-auth/testutils"
 )
 
@@ -338,3 +350,437 @@ func TestValidateBasic(t *testing.T) {
 		})
 	}
 }
+
+func TestBasicAuth_RecordsAccess(t *testing.T) {
+	mockStorage := testutils.NewMockStorage()
+	mockStorage.Users["test@example.com"].Access = 0
+
+	provider := NewBasicAuth().(*BasicAuth)
+	provider.SetStorage(mockStorage)
+	provider.SetOptions(testutils.TestAuthOptions())
+
+	if _, err := provider.CreateAccessToken("wrong@example.com", "test-password-hash"); err == nil {
+		t.Fatalf("CreateAccessToken() expected error but got none")
+	}
+
+	if _, err := provider.CreateAccessToken("test@example.com", "test-password-hash"); err != nil {
+		t.Fatalf("CreateAccessToken() unexpected error = %v", err)
+	}
+
+	waitForAccess(t, mockStorage, "testuser")
+}
+
+func TestBasicAuth_Lockout(t *testing.T) {
+	provider := NewBasicAuth().(*BasicAuth)
+	provider.SetStorage(testutils.NewMockStorage())
+	provider.SetOptions(testutils.TestAuthOptions())
+
+	guard := lockout.NewGuard(memory.NewInMemoryAttemptStorage(), lockout.Policy{
+		MaxAttempts:  3,
+		Window:       time.Minute,
+		LockDuration: time.Minute,
+	})
+	provider.SetLockout(guard)
+
+	client := auth.ClientInfo{IP: "192.0.2.1"}
+
+	for i := 0; i < 3; i++ {
+		_, err := provider.CreateAccessTokenFrom(client, "test@example.com", "wrong-password")
+		if err == nil || errors.Is(err, ErrAccountLocked) {
+			t.Fatalf("CreateAccessTokenFrom() attempt %d error = %v, want invalid credentials", i+1, err)
+		}
+	}
+
+	// The correct password is rejected while the account is locked
+	if _, err := provider.CreateAccessTokenFrom(client, "test@example.com", "test-password-hash"); !errors.Is(err, ErrAccountLocked) {
+		t.Errorf("CreateAccessTokenFrom() while locked error = %v, want %v", err, ErrAccountLocked)
+	}
+	if _, err := provider.CreateRefreshToken("test@example.com", "test-password-hash"); !errors.Is(err, ErrAccountLocked) {
+		t.Errorf("CreateRefreshToken() while locked error = %v, want %v", err, ErrAccountLocked)
+	}
+
+	// Other accounts from the same IP are locked too
+	if _, err := provider.CreateAccessTokenFrom(client, "123456789", "test-password-hash"); !errors.Is(err, ErrAccountLocked) {
+		t.Errorf("CreateAccessTokenFrom() from locked IP error = %v, want %v", err, ErrAccountLocked)
+	}
+
+	if err := guard.UnlockAccount("test@example.com"); err != nil {
+		t.Fatalf("UnlockAccount() unexpected error = %v", err)
+	}
+	if err := guard.UnlockIP(client.IP); err != nil {
+		t.Fatalf("UnlockIP() unexpected error = %v", err)
+	}
+
+	if _, err := provider.CreateAccessTokenFrom(client, "test@example.com", "test-password-hash"); err != nil {
+		t.Errorf("CreateAccessTokenFrom() after unlock error = %v", err)
+	}
+}
+
+func TestBasicAuth_LockoutPerUser(t *testing.T) {
+	// A colleague shares the test user's account, which is their tenant
+	colleague := testutils.TestUser()
+	colleague.Name = "colleague"
+	colleague.Mail = "colleague@example.com"
+	colleague.Secret = "colleague-password"
+	mockStorage := testutils.NewMockStorage()
+	mockStorage.Users[colleague.Mail] = colleague
+
+	provider := NewBasicAuth().(*BasicAuth)
+	provider.SetStorage(mockStorage)
+	provider.SetOptions(testutils.TestAuthOptions())
+	guard := lockout.NewGuard(memory.NewInMemoryAttemptStorage(), lockout.Policy{
+		MaxAttempts:  3,
+		Window:       time.Minute,
+		LockDuration: time.Minute,
+	})
+	provider.SetLockout(guard)
+
+	for i := 0; i < 3; i++ {
+		for _, identifier := range []string{"test@example.com", "123456789"} {
+			_, err := provider.CreateAccessTokenFrom(auth.ClientInfo{}, identifier, "wrong-password")
+			if err == nil || errors.Is(err, ErrAccountLocked) {
+				t.Fatalf("CreateAccessTokenFrom(%q) attempt %d error = %v, want invalid credentials", identifier, i+1, err)
+			}
+		}
+	}
+	for _, identifier := range []string{"test@example.com", "123456789"} {
+		if _, err := provider.CreateAccessTokenFrom(auth.ClientInfo{}, identifier, "test-password-hash"); !errors.Is(err, ErrAccountLocked) {
+			t.Errorf("CreateAccessTokenFrom(%q) while locked error = %v, want %v", identifier, err, ErrAccountLocked)
+		}
+	}
+
+	// Neither the user's failures nor those by account ID lock the rest of the tenant
+	if _, err := provider.CreateAccessTokenFrom(auth.ClientInfo{}, colleague.Mail, colleague.Secret); err != nil {
+		t.Errorf("CreateAccessTokenFrom() colleague error = %v, want the tenant's other users unaffected", err)
+	}
+
+	// Unlocking the mail clears every key a mail login records
+	if err := guard.UnlockAccount("test@example.com"); err != nil {
+		t.Fatalf("UnlockAccount() unexpected error = %v", err)
+	}
+	if _, err := provider.CreateAccessTokenFrom(auth.ClientInfo{}, "test@example.com", "test-password-hash"); err != nil {
+		t.Errorf("CreateAccessTokenFrom() after unlock error = %v", err)
+	}
+}
+
+func TestBasicAuth_MFA(t *testing.T) {
+	provider := NewBasicAuth().(*BasicAuth)
+	provider.SetStorage(testutils.NewMockStorage())
+	provider.SetOptions(testutils.TestAuthOptions())
+
+	manager := mfa.NewManager(memory.NewInMemoryMFAStorage(), "Responsible API")
+	provider.SetMFA(manager)
+
+	amr := func(token string) []string {
+		t.Helper()
+		validated, err := provider.Validate(token)
+		if err != nil {
+			t.Fatalf("Validate() unexpected error = %v", err)
+		}
+		return validated.Claims.(*concerns.ClaimsGeneric).AMR
+	}
+
+	// Without a confirmed enrolment the password is enough
+	token, err := provider.CreateAccessToken("test@example.com", "test-password-hash")
+	if err != nil {
+		t.Fatalf("CreateAccessToken() unexpected error = %v", err)
+	}
+	if got := amr(token.GetToken()); len(got) != 1 || got[0] != mfa.MethodPassword {
+		t.Errorf("password token amr = %v, want [pwd]", got)
+	}
+
+	setup, err := manager.Enroll("testuser")
+	if err != nil {
+		t.Fatalf("Enroll() unexpected error = %v", err)
+	}
+	code, _ := mfa.Code(setup.Secret, time.Now())
+	if err := manager.Confirm("testuser", code); err != nil {
+		t.Fatalf("Confirm() unexpected error = %v", err)
+	}
+
+	_, err = provider.CreateAccessToken("test@example.com", "test-password-hash")
+	var required *MFARequiredError
+	if !errors.Is(err, ErrMFARequired) || !errors.As(err, &required) || required.Challenge == "" {
+		t.Fatalf("CreateAccessToken() with MFA error = %v, want %v with a challenge", err, ErrMFARequired)
+	}
+	if _, err := provider.CreateRefreshToken("test@example.com", "test-password-hash"); !errors.Is(err, ErrMFARequired) {
+		t.Errorf("CreateRefreshToken() with MFA error = %v, want %v", err, ErrMFARequired)
+	}
+
+	// The challenge is not an access token
+	if _, err := provider.Validate(required.Challenge); err == nil {
+		t.Errorf("Validate() accepted the MFA challenge as an access token")
+	}
+
+	if _, _, err := provider.VerifyMFA(required.Challenge, "000000"); !errors.Is(err, ErrInvalidMFACode) {
+		t.Errorf("VerifyMFA() wrong code error = %v, want %v", err, ErrInvalidMFACode)
+	}
+	if _, _, err := provider.VerifyMFA("not-a-challenge", code); !errors.Is(err, ErrInvalidChallenge) {
+		t.Errorf("VerifyMFA() invalid challenge error = %v, want %v", err, ErrInvalidChallenge)
+	}
+
+	next, _ := mfa.Code(setup.Secret, time.Now().Add(mfa.Period))
+	accessToken, refreshToken, err := provider.VerifyMFA(required.Challenge, next)
+	if err != nil {
+		t.Fatalf("VerifyMFA() unexpected error = %v", err)
+	}
+	want := []string{mfa.MethodPassword, mfa.MethodOTP, mfa.MethodMFA}
+	if got := amr(accessToken.GetToken()); !slices.Equal(got, want) {
+		t.Errorf("VerifyMFA() access token amr = %v, want %v", got, want)
+	}
+
+	// Refreshed access tokens keep the methods of the original login
+	refreshed, err := provider.GrantRefreshToken(refreshToken.GetToken())
+	if err != nil {
+		t.Fatalf("GrantRefreshToken() unexpected error = %v", err)
+	}
+	if got := amr(refreshed.GetToken()); !slices.Equal(got, want) {
+		t.Errorf("refreshed access token amr = %v, want %v", got, want)
+	}
+
+	// A recovery code also completes the login
+	if _, _, err := provider.VerifyMFA(required.Challenge, setup.RecoveryCodes[0]); err != nil {
+		t.Errorf("VerifyMFA() with recovery code unexpected error = %v", err)
+	}
+}
+
+func TestBasicAuth_MFALockout(t *testing.T) {
+	provider := NewBasicAuth().(*BasicAuth)
+	provider.SetStorage(testutils.NewMockStorage())
+	provider.SetOptions(testutils.TestAuthOptions())
+	provider.SetLockout(lockout.NewGuard(memory.NewInMemoryAttemptStorage(), lockout.Policy{
+		MaxAttempts:  3,
+		Window:       time.Minute,
+		LockDuration: time.Minute,
+	}))
+
+	manager := mfa.NewManager(memory.NewInMemoryMFAStorage(), "")
+	provider.SetMFA(manager)
+	setup, _ := manager.Enroll("testuser")
+	code, _ := mfa.Code(setup.Secret, time.Now())
+	if err := manager.Confirm("testuser", code); err != nil {
+		t.Fatalf("Confirm() unexpected error = %v", err)
+	}
+
+	_, err := provider.CreateAccessToken("test@example.com", "test-password-hash")
+	var required *MFARequiredError
+	if !errors.As(err, &required) {
+		t.Fatalf("CreateAccessToken() error = %v, want %v", err, ErrMFARequired)
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, _, err := provider.VerifyMFA(required.Challenge, "000000"); !errors.Is(err, ErrInvalidMFACode) {
+			t.Fatalf("VerifyMFA() attempt %d error = %v, want %v", i+1, err, ErrInvalidMFACode)
+		}
+	}
+
+	// Guessing stops once the account is locked, even with the right code
+	if _, _, err := provider.VerifyMFA(required.Challenge, setup.RecoveryCodes[0]); !errors.Is(err, ErrAccountLocked) {
+		t.Errorf("VerifyMFA() while locked error = %v, want %v", err, ErrAccountLocked)
+	}
+}
+
+func TestBasicAuth_MFAAttemptLimit(t *testing.T) {
+	provider := NewBasicAuth().(*BasicAuth)
+	provider.SetStorage(testutils.NewMockStorage())
+	provider.SetOptions(testutils.TestAuthOptions())
+
+	manager := mfa.NewManager(memory.NewInMemoryMFAStorage(), "")
+	provider.SetMFA(manager)
+	setup, _ := manager.Enroll("testuser")
+	code, _ := mfa.Code(setup.Secret, time.Now())
+	if err := manager.Confirm("testuser", code); err != nil {
+		t.Fatalf("Confirm() unexpected error = %v", err)
+	}
+
+	// Without a lockout guard, fresh challenges still share the attempt limit
+	for i := 0; i < mfa.DefaultMaxAttempts; i++ {
+		_, err := provider.CreateAccessToken("test@example.com", "test-password-hash")
+		var required *MFARequiredError
+		if !errors.As(err, &required) {
+			t.Fatalf("CreateAccessToken() error = %v, want %v", err, ErrMFARequired)
+		}
+		if _, _, err := provider.VerifyMFA(required.Challenge, "000000"); !errors.Is(err, ErrInvalidMFACode) {
+			t.Fatalf("VerifyMFA() attempt %d error = %v, want %v", i+1, err, ErrInvalidMFACode)
+		}
+	}
+
+	_, err := provider.CreateAccessToken("test@example.com", "test-password-hash")
+	var required *MFARequiredError
+	if !errors.As(err, &required) {
+		t.Fatalf("CreateAccessToken() error = %v, want %v", err, ErrMFARequired)
+	}
+	if _, _, err := provider.VerifyMFA(required.Challenge, setup.RecoveryCodes[0]); !errors.Is(err, ErrTooManyMFAAttempts) {
+		t.Errorf("VerifyMFA() after the limit error = %v, want %v", err, ErrTooManyMFAAttempts)
+	}
+}
+
+func TestBasicAuth_ClaimsEnricher(t *testing.T) {
+	roles := map[string]string{"testuser": "admin"}
+	var grants []auth.Grant
+
+	options := testutils.TestAuthOptions()
+	options.ClaimsEnricher = auth.ClaimsEnricherFunc(func(u *user.User, grant auth.Grant, claims *auth.Claims) error {
+		grants = append(grants, grant)
+		role, ok := roles[u.Name]
+		if !ok {
+			return errors.New("roles unavailable")
+		}
+		claims.Role = role
+		claims.Scopes = "read write"
+		claims.Audience = []string{"orders-api"}
+		claims.CustomClaims["tenant"] = "acme"
+		return nil
+	})
+
+	provider := NewBasicAuth().(*BasicAuth)
+	provider.SetStorage(testutils.NewMockStorage())
+	provider.SetOptions(options)
+
+	token, err := provider.CreateAccessToken("test@example.com", "test-password-hash")
+	if err != nil {
+		t.Fatalf("CreateAccessToken() unexpected error = %v", err)
+	}
+	validated, err := provider.Validate(token.GetToken())
+	if err != nil {
+		t.Fatalf("Validate() unexpected error = %v", err)
+	}
+	claims := validated.Claims.(*concerns.ClaimsGeneric)
+	if claims.Role != "admin" || claims.Scopes != "read write" || !slices.Equal(claims.Audience, []string{"orders-api"}) {
+		t.Errorf("access token = role %q, scopes %q, aud %v, want the enriched claims", claims.Role, claims.Scopes, claims.Audience)
+	}
+	if claims.CustomClaims["tenant"] != "acme" || claims.CustomClaims["test_claim"] != "test_value" {
+		t.Errorf("access token custom claims = %v, want the configured claims plus tenant", claims.CustomClaims)
+	}
+	if _, shared := options.CustomClaims["tenant"]; shared {
+		t.Errorf("enricher changed the provider's shared custom claims")
+	}
+	if len(grants) != 1 || grants[0].Type != auth.GrantPassword || !slices.Equal(grants[0].AMR, []string{mfa.MethodPassword}) {
+		t.Errorf("enricher grants = %+v, want one password grant", grants)
+	}
+
+	// Refreshing enriches again, so a changed role applies to the next access token
+	refreshToken, err := provider.CreateRefreshToken("test@example.com", "test-password-hash")
+	if err != nil {
+		t.Fatalf("CreateRefreshToken() unexpected error = %v", err)
+	}
+	roles["testuser"] = "viewer"
+	refreshed, err := provider.GrantRefreshToken(refreshToken.GetToken())
+	if err != nil {
+		t.Fatalf("GrantRefreshToken() unexpected error = %v", err)
+	}
+	validated, err = provider.Validate(refreshed.GetToken())
+	if err != nil {
+		t.Fatalf("Validate() refreshed token unexpected error = %v", err)
+	}
+	if claims := validated.Claims.(*concerns.ClaimsGeneric); claims.Role != "viewer" {
+		t.Errorf("refreshed access token role = %q, want %q", claims.Role, "viewer")
+	}
+	if last := grants[len(grants)-1]; last.Type != auth.GrantRefreshToken {
+		t.Errorf("enricher grant on refresh = %+v, want %q", last, auth.GrantRefreshToken)
+	}
+
+	// An enricher error aborts issuance
+	delete(roles, "testuser")
+	if token, err := provider.CreateAccessToken("test@example.com", "test-password-hash"); err == nil || token != nil {
+		t.Errorf("CreateAccessToken() with failing enricher = %v, %v, want an error and no token", token, err)
+	}
+	if token, err := provider.GrantRefreshToken(refreshToken.GetToken()); err == nil || token != nil {
+		t.Errorf("GrantRefreshToken() with failing enricher = %v, %v, want an error and no token", token, err)
+	}
+}
+
+func TestBasicAuth_Audit(t *testing.T) {
+	var events []auth.AuditEvent
+	options := testutils.TestAuthOptions()
+	options.AuditSink = auth.AuditSinkFunc(func(event auth.AuditEvent) error {
+		events = append(events, event)
+		return nil
+	})
+
+	provider := NewBasicAuth().(*BasicAuth)
+	provider.SetStorage(testutils.NewMockStorage())
+	provider.SetOptions(options)
+	provider.SetLockout(lockout.NewGuard(memory.NewInMemoryAttemptStorage(), lockout.Policy{
+		MaxAttempts:  2,
+		Window:       time.Minute,
+		LockDuration: time.Minute,
+	}))
+	client := auth.ClientInfo{IP: "192.0.2.1", UserAgent: "test-agent"}
+
+	token, err := provider.CreateAccessTokenFrom(client, "test@example.com", "test-password-hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	refresh, err := provider.CreateRefreshTokenFrom(client, "test@example.com", "test-password-hash")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := provider.GrantRefreshToken(refresh.GetToken()); err != nil {
+		t.Fatal(err)
+	}
+	provider.CreateAccessTokenFrom(client, "test@example.com", "wrong-password")
+	provider.CreateAccessTokenFrom(client, "test@example.com", "wrong-password")
+	provider.CreateAccessTokenFrom(client, "test@example.com", "test-password-hash")
+
+	want := []struct {
+		typ     string
+		success bool
+		subject string
+		tokenID string
+	}{
+		{auth.EventLogin, true, "testuser", token.GetID()},
+		{auth.EventLogin, true, "testuser", refresh.GetID()},
+		{auth.EventRefresh, true, "testuser", refresh.GetID()},
+		{auth.EventLogin, false, "test@example.com", ""},
+		{auth.EventLogin, false, "test@example.com", ""},
+		{auth.EventLockout, false, "test@example.com", ""},
+		{auth.EventLockout, false, "test@example.com", ""},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("recorded %d events, want %d: %+v", len(events), len(want), events)
+	}
+	for i, w := range want {
+		e := events[i]
+		if e.Type != w.typ || e.Success != w.success || e.Subject != w.subject || e.TokenID != w.tokenID {
+			t.Errorf("event %d = %+v, want %+v", i, e, w)
+		}
+		if e.Time.IsZero() {
+			t.Errorf("event %d has no time", i)
+		}
+		if e.Type != auth.EventRefresh && (e.IP != client.IP || e.UserAgent != client.UserAgent) {
+			t.Errorf("event %d client = %q %q, want %q %q", i, e.IP, e.UserAgent, client.IP, client.UserAgent)
+		}
+		if strings.Contains(e.Reason, "password-hash") || strings.Contains(e.Reason, "wrong-password") {
+			t.Errorf("event %d reason leaks a secret: %q", i, e.Reason)
+		}
+	}
+	if token.GetID() == "" || refresh.GetID() == "" {
+		t.Error("issued tokens have no jti")
+	}
+}
+
+func TestBasicAuth_Logger(t *testing.T) {
+	var buf bytes.Buffer
+	options := testutils.TestAuthOptions()
+	options.Logger = slog.New(logging.NewRedactHandler(slog.NewTextHandler(&buf, nil)))
+	options.AuditSink = auth.AuditSinkFunc(func(auth.AuditEvent) error {
+		return errors.New("dial root:hunter2@tcp(db:3306)/audit")
+	})
+
+	provider := NewBasicAuth().(*BasicAuth)
+	provider.SetStorage(testutils.NewMockStorage())
+	provider.SetOptions(options)
+
+	provider.CreateAccessTokenFrom(auth.ClientInfo{}, "test@example.com", "wrong-password")
+
+	out := buf.String()
+	for _, want := range []string{"level=WARN msg=\"login failed\"", "level=ERROR msg=\"failed to record audit event\""} {
+		if !strings.Contains(out, want) {
+			t.Errorf("log output lacks %q: %s", want, out)
+		}
+	}
+	if strings.Contains(out, "hunter2") || strings.Contains(out, "wrong-password") {
+		t.Errorf("log output contains a secret: %s", out)
+	}
+}
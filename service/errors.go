@@ -0,0 +1,68 @@
+package service
+
+import (
+	"errors"
+
+	"github.com/responsible-api/responsible-auth/lockout"
+	"github.com/responsible-api/responsible-auth/mfa"
+)
+
+var (
+	// ErrUserInactive is returned when a user whose status is not active tries to authenticate.
+	ErrUserInactive = errors.New("user is not active")
+
+	// ErrNoStorage is returned when a provider that needs storage has none configured.
+	ErrNoStorage = errors.New("storage is not configured")
+
+	// ErrAccountLocked is returned while an account or client IP is locked after
+	// too many failed logins. The error is a *lockout.LockedError carrying the expiry.
+	ErrAccountLocked = lockout.ErrAccountLocked
+
+	// ErrMFARequired is matched by every MFARequiredError.
+	ErrMFARequired = errors.New("mfa required")
+
+	// ErrInvalidChallenge is returned for unknown or expired MFA challenges.
+	ErrInvalidChallenge = errors.New("invalid mfa challenge")
+
+	// ErrInvalidMFACode is returned when the second factor is wrong or already used.
+	ErrInvalidMFACode = mfa.ErrInvalidCode
+
+	// ErrTooManyMFAAttempts is returned while a user's second factor is locked
+	// after too many invalid codes.
+	ErrTooManyMFAAttempts = mfa.ErrTooManyAttempts
+
+	// ErrEmailNotVerified is returned when an upstream identity has no mail
+	// address its provider has verified, so it cannot be linked to a user.
+	ErrEmailNotVerified = errors.New("upstream email is not verified")
+
+	// ErrInvalidForm is returned when a user form fails validation.
+	ErrInvalidForm = errors.New("invalid user form")
+
+	// ErrClientRefreshToken is returned when a refresh token issued to an OAuth
+	// client is presented to a provider; only that client may redeem it.
+	ErrClientRefreshToken = errors.New("refresh token was issued to an oauth client")
+
+	// ErrSessionRefreshToken is returned when a refresh token bound to a
+	// browser session is presented to a provider without a RefreshChecker, as
+	// the session could not be checked.
+	ErrSessionRefreshToken = errors.New("refresh token is bound to a browser session")
+
+	// ErrInvalidStatus is returned when setting a status that is not one of the user.Status* constants.
+	ErrInvalidStatus = errors.New("invalid user status")
+)
+
+// MFARequiredError is returned instead of tokens when the password was
+// correct but the user must also pass their second factor. The challenge is
+// passed to BasicAuth.VerifyMFA together with the code.
+type MFARequiredError struct {
+	Challenge string
+}
+
+func (e *MFARequiredError) Error() string {
+	return ErrMFARequired.Error()
+}
+
+// Is makes errors.Is(err, ErrMFARequired) true for an MFARequiredError.
+func (e *MFARequiredError) Is(target error) bool {
+	return target == ErrMFARequired
+}
@@ -0,0 +1,225 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/responsible-api/responsible-auth/auth"
+	"github.com/responsible-api/responsible-auth/federation"
+	"github.com/responsible-api/responsible-auth/internal"
+	"github.com/responsible-api/responsible-auth/resource/access"
+	"github.com/responsible-api/responsible-auth/resource/user"
+	"github.com/responsible-api/responsible-auth/storage"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// FederatedAuth signs users in with an upstream OpenID Connect provider and
+// issues this library's own tokens. The upstream identity is linked to the
+// local user with the same verified mail address, and the upstream claims
+// selected by the provider's ClaimMapping are added to the custom claims.
+type FederatedAuth struct {
+	auth.AuthProvider
+	options   auth.AuthOptions
+	storage   storage.UserStorage
+	provider  *federation.Provider
+	provision bool
+}
+
+// NewFederatedAuth creates a provider signing users in with the given upstream provider.
+func NewFederatedAuth(provider *federation.Provider) auth.AuthInterface {
+	var federated auth.AuthInterface = &FederatedAuth{provider: provider}
+	return federated
+}
+
+// SetOptions sets the options for the FederatedAuth provider.
+func (d *FederatedAuth) SetOptions(options auth.AuthOptions) {
+	d.options = options
+	Options = options
+}
+
+// Options returns the options set for the FederatedAuth provider.
+func (d *FederatedAuth) Options() auth.AuthOptions {
+	return d.options
+}
+
+// SetStorage sets the storage implementation for the FederatedAuth provider.
+func (d *FederatedAuth) SetStorage(storage storage.UserStorage) {
+	d.storage = storage
+}
+
+// SetProvisioning controls whether upstream users without a local account
+// get one created on their first login. When disabled, which is the
+// default, only users whose mail address already exists locally can log in.
+func (d *FederatedAuth) SetProvisioning(enabled bool) {
+	d.provision = enabled
+}
+
+// Begin starts a sign-in. The user is redirected to authURL, and the state
+// token must be kept until the callback, typically in a short-lived HttpOnly
+// cookie, then passed to Login with the callback's query.
+func (d *FederatedAuth) Begin() (string, string, error) {
+	authURL, session, err := d.provider.AuthCodeURL()
+	if err != nil {
+		return "", "", err
+	}
+
+	state, err := internal.CreateFederationState(session.State, session.Nonce, session.Verifier, d.options)
+	if err != nil {
+		return "", "", err
+	}
+	return authURL, state, nil
+}
+
+// Decode checks the callback query for an error reported by the upstream
+// provider and returns the query unchanged. The user is only known once
+// Login has redeemed the code.
+func (d *FederatedAuth) Decode(callbackQuery string) (string, string, error) {
+	if _, err := parseCallback(callbackQuery); err != nil {
+		return "", "", err
+	}
+	return "", callbackQuery, nil
+}
+
+// CreateAccessToken completes the sign-in started with the given state token
+// and issues an access token for the linked user.
+func (a *FederatedAuth) CreateAccessToken(state string, callbackQuery string) (*access.RToken, error) {
+	token, _, err := a.Login(state, callbackQuery)
+	return token, err
+}
+
+// CreateRefreshToken completes the sign-in started with the given state token
+// and issues a refresh token for the linked user.
+// Each callback carries a single-use code, so use Login to obtain both tokens.
+func (a *FederatedAuth) CreateRefreshToken(state string, callbackQuery string) (*access.RToken, error) {
+	_, refreshToken, err := a.Login(state, callbackQuery)
+	return refreshToken, err
+}
+
+// Login redeems the callback's code with the upstream provider and returns
+// the access and refresh tokens for the user linked to the upstream identity.
+func (a *FederatedAuth) Login(state string, callbackQuery string) (*access.RToken, *access.RToken, error) {
+	if a.storage == nil {
+		return nil, nil, ErrNoStorage
+	}
+
+	claims, err := internal.ParseFederationState(state, a.options)
+	if err != nil {
+		return nil, nil, federation.ErrInvalidState
+	}
+	callback, err := parseCallback(callbackQuery)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	identity, err := a.provider.Exchange(&federation.Session{
+		State:    claims.State,
+		Nonce:    claims.Nonce,
+		Verifier: claims.Verifier,
+	}, callback.Get("state"), callback.Get("code"))
+	if err != nil {
+		auditLogin(a.options, auth.GrantFederation, auth.ClientInfo{}, "", nil, err)
+		return nil, nil, err
+	}
+
+	user, err := a.localUser(identity)
+	if err != nil {
+		auditLogin(a.options, auth.GrantFederation, auth.ClientInfo{}, identity.Email, nil, err)
+		return nil, nil, err
+	}
+	if err := checkActive(user); err != nil {
+		auditLogin(a.options, auth.GrantFederation, auth.ClientInfo{}, user.Name, nil, err)
+		return nil, nil, err
+	}
+
+	opts := userOptions(a.options, user)
+	opts.AMR = identity.AMR
+	if len(identity.CustomClaims) > 0 {
+		custom := make(map[string]interface{}, len(opts.CustomClaims)+len(identity.CustomClaims))
+		for name, value := range opts.CustomClaims {
+			custom[name] = value
+		}
+		for name, value := range identity.CustomClaims {
+			custom[name] = value
+		}
+		opts.CustomClaims = custom
+	}
+	opts, err = opts.Enrich(user, auth.Grant{Type: auth.GrantFederation})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	token, err := internal.CreateAccessToken(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	refreshToken, err := internal.CreateRefreshToken(user.Name, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	recordAccess(a.options.Log(), a.storage, user)
+	auditLogin(a.options, auth.GrantFederation, auth.ClientInfo{}, user.Name, token, nil)
+	return token, refreshToken, nil
+}
+
+// GrantRefreshToken issues an access token for a refresh token. The upstream
+// claims mapped at login are carried by the refresh token.
+func (a *FederatedAuth) GrantRefreshToken(refreshTokenString string) (*access.RToken, error) {
+	user, opts, err := refreshTokenUser(a.storage, refreshTokenString, a.options)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := internal.CreateAccessToken(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	recordAccess(a.options.Log(), a.storage, user)
+	return token, nil
+}
+
+func (a *FederatedAuth) Validate(tokenString string) (*jwt.Token, error) {
+	token, err := internal.Validate(tokenString, a.options)
+	if err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// localUser finds the local account with the identity's verified mail
+// address, creating it when provisioning is enabled. Provisioned accounts
+// are named after their mail address and have no password, so they can only
+// log in through the upstream provider.
+func (a *FederatedAuth) localUser(identity *federation.Identity) (*user.User, error) {
+	// An unverified address could belong to anyone, so it must never select an account
+	if identity.Email == "" || !identity.EmailVerified {
+		return nil, ErrEmailNotVerified
+	}
+
+	found, err := a.storage.FindUserByMail(identity.Email)
+	if err == nil || !errors.Is(err, storage.ErrUserNotFound) || !a.provision {
+		return found, err
+	}
+
+	provisioned := (&user.Form{Name: identity.Email, Mail: identity.Email}).ToModel()
+	if err := a.storage.CreateUser(provisioned); err != nil {
+		return nil, err
+	}
+	return provisioned, nil
+}
+
+// parseCallback parses the query the upstream provider sent to the redirect
+// URI, turning an error it reports into ErrUpstream.
+func parseCallback(callbackQuery string) (url.Values, error) {
+	callback, err := url.ParseQuery(callbackQuery)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid callback query", federation.ErrUpstream)
+	}
+	if upstreamErr := callback.Get("error"); upstreamErr != "" {
+		return nil, fmt.Errorf("%w: %s: %s", federation.ErrUpstream, upstreamErr, callback.Get("error_description"))
+	}
+	return callback, nil
+}
@@ -0,0 +1,220 @@
+package service
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/responsible-api/responsible-auth/concerns"
+	"github.com/responsible-api/responsible-auth/federation"
+	"github.com/responsible-api/responsible-auth/federation/federationtest"
+	"github.com/responsible-api/responsible-auth/internal"
+	"github.com/responsible-api/responsible-auth/resource/user"
+	"github.com/responsible-api/responsible-auth/storage"
+	"github.com/responsible-api/responsible-auth/testutils"
+)
+
+func newFederatedProvider(t *testing.T) (*FederatedAuth, *federationtest.IdP, *testutils.MockStorage) {
+	t.Helper()
+	idp := federationtest.NewIdP(t, "app-client", "app-secret")
+	idp.SetUser(federationtest.User{
+		Subject:       "upstream-123",
+		Email:         testutils.TestUser().Mail,
+		EmailVerified: true,
+		Name:          "Test User",
+		Claims:        map[string]interface{}{"hd": "example.com", "amr": []string{"pwd", "mfa"}},
+	})
+	upstream := federation.NewProvider(federation.Config{
+		Issuer:       idp.URL,
+		ClientID:     "app-client",
+		ClientSecret: "app-secret",
+		RedirectURL:  "https://app.example.com/callback",
+		ClaimMapping: map[string]string{"hd": "domain"},
+	})
+
+	mockStorage := testutils.NewMockStorage()
+	provider := NewFederatedAuth(upstream).(*FederatedAuth)
+	provider.SetStorage(mockStorage)
+	provider.SetOptions(testutils.TestAuthOptions())
+	return provider, idp, mockStorage
+}
+
+// federatedCallback starts a sign-in and returns its state token and the callback query.
+func federatedCallback(t *testing.T, provider *FederatedAuth, idp *federationtest.IdP) (string, string) {
+	t.Helper()
+	authURL, state, err := provider.Begin()
+	if err != nil {
+		t.Fatalf("Begin() unexpected error = %v", err)
+	}
+	callback, err := idp.Authorize(authURL)
+	if err != nil {
+		t.Fatalf("Authorize() unexpected error = %v", err)
+	}
+	return state, callback.Encode()
+}
+
+func TestFederatedAuth_Login(t *testing.T) {
+	provider, idp, _ := newFederatedProvider(t)
+	state, callback := federatedCallback(t, provider, idp)
+
+	if _, decoded, err := provider.Decode(callback); err != nil || decoded != callback {
+		t.Fatalf("Decode() = %q, %v, want the callback unchanged", decoded, err)
+	}
+
+	token, refreshToken, err := provider.Login(state, callback)
+	if err != nil {
+		t.Fatalf("Login() unexpected error = %v", err)
+	}
+
+	validated, err := provider.Validate(token.GetToken())
+	if err != nil {
+		t.Fatalf("Validate() unexpected error = %v", err)
+	}
+	claims := validated.Claims.(*concerns.ClaimsGeneric)
+	if claims.AccountID != testutils.TestUser().AccountID {
+		t.Errorf("access token account = %v, want the linked user %v", claims.AccountID, testutils.TestUser().AccountID)
+	}
+	if claims.CustomClaims["domain"] != "example.com" || claims.CustomClaims["test_claim"] != "test_value" {
+		t.Errorf("access token custom claims = %v, want the mapped domain and the configured claims", claims.CustomClaims)
+	}
+	if len(claims.AMR) != 2 || claims.AMR[0] != "pwd" || claims.AMR[1] != "mfa" {
+		t.Errorf("access token amr = %v, want the upstream [pwd mfa]", claims.AMR)
+	}
+
+	// The code has been redeemed, so the callback cannot be replayed
+	if _, _, err := provider.Login(state, callback); !errors.Is(err, federation.ErrUpstream) {
+		t.Errorf("Login() replayed callback error = %v, want %v", err, federation.ErrUpstream)
+	}
+
+	// Mapped claims survive a refresh
+	granted, err := provider.GrantRefreshToken(refreshToken.GetToken())
+	if err != nil {
+		t.Fatalf("GrantRefreshToken() unexpected error = %v", err)
+	}
+	validated, err = provider.Validate(granted.GetToken())
+	if err != nil {
+		t.Fatalf("Validate() granted token unexpected error = %v", err)
+	}
+	if claims := validated.Claims.(*concerns.ClaimsGeneric); claims.CustomClaims["domain"] != "example.com" {
+		t.Errorf("refreshed token custom claims = %v, want the mapped domain", claims.CustomClaims)
+	}
+}
+
+func TestFederatedAuth_Provisioning(t *testing.T) {
+	provider, idp, mockStorage := newFederatedProvider(t)
+	idp.SetUser(federationtest.User{Subject: "upstream-456", Email: "newcomer@example.com", EmailVerified: true})
+
+	state, callback := federatedCallback(t, provider, idp)
+	if _, _, err := provider.Login(state, callback); !errors.Is(err, storage.ErrUserNotFound) {
+		t.Errorf("Login() without provisioning error = %v, want %v", err, storage.ErrUserNotFound)
+	}
+
+	provider.SetProvisioning(true)
+	state, callback = federatedCallback(t, provider, idp)
+	if _, _, err := provider.Login(state, callback); err != nil {
+		t.Fatalf("Login() with provisioning unexpected error = %v", err)
+	}
+
+	provisioned, err := mockStorage.FindUserByMail("newcomer@example.com")
+	if err != nil {
+		t.Fatalf("FindUserByMail() unexpected error = %v", err)
+	}
+	if provisioned.Name != "newcomer@example.com" || provisioned.Status != user.StatusActive || provisioned.Secret != "" {
+		t.Errorf("provisioned user = %+v, want active, named after the mail, with no password", provisioned)
+	}
+
+	// The second login links to the provisioned account
+	state, callback = federatedCallback(t, provider, idp)
+	if _, _, err := provider.Login(state, callback); err != nil {
+		t.Errorf("Login() provisioned user unexpected error = %v", err)
+	}
+}
+
+func TestFederatedAuth_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		setup    func(provider *FederatedAuth, idp *federationtest.IdP, s *testutils.MockStorage)
+		state    func(state string) string
+		callback func(callback string) string
+		want     error
+	}{
+		{
+			name: "unverified email",
+			setup: func(_ *FederatedAuth, idp *federationtest.IdP, _ *testutils.MockStorage) {
+				idp.SetUser(federationtest.User{Subject: "upstream-123", Email: testutils.TestUser().Mail})
+			},
+			want: ErrEmailNotVerified,
+		},
+		{
+			name: "unverified email is not provisioned",
+			setup: func(provider *FederatedAuth, idp *federationtest.IdP, _ *testutils.MockStorage) {
+				provider.SetProvisioning(true)
+				idp.SetUser(federationtest.User{Subject: "upstream-456", Email: "newcomer@example.com"})
+			},
+			want: ErrEmailNotVerified,
+		},
+		{
+			name: "inactive user",
+			setup: func(_ *FederatedAuth, _ *federationtest.IdP, s *testutils.MockStorage) {
+				if _, err := NewUserService(s).Suspend(testutils.TestUser().Name); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: ErrUserInactive,
+		},
+		{
+			name:  "forged state token",
+			state: func(string) string { return "forged" },
+			want:  federation.ErrInvalidState,
+		},
+		{
+			name: "state token from another sign-in",
+			state: func(string) string {
+				forged, _ := internal.CreateFederationState("other-state", "other-nonce", "other-verifier", testutils.TestAuthOptions())
+				return forged
+			},
+			want: federation.ErrInvalidState,
+		},
+		{
+			name: "upstream error",
+			callback: func(string) string {
+				return url.Values{"error": {"access_denied"}, "error_description": {"cancelled"}}.Encode()
+			},
+			want: federation.ErrUpstream,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider, idp, mockStorage := newFederatedProvider(t)
+			if tt.setup != nil {
+				tt.setup(provider, idp, mockStorage)
+			}
+			state, callback := federatedCallback(t, provider, idp)
+			if tt.state != nil {
+				state = tt.state(state)
+			}
+			if tt.callback != nil {
+				callback = tt.callback(callback)
+			}
+
+			token, refreshToken, err := provider.Login(state, callback)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("Login() error = %v, want %v", err, tt.want)
+			}
+			if token != nil || refreshToken != nil {
+				t.Errorf("Login() issued tokens for a rejected sign-in")
+			}
+		})
+	}
+
+	// A state token is not an access token
+	provider, _, _ := newFederatedProvider(t)
+	_, state, err := provider.Begin()
+	if err != nil {
+		t.Fatalf("Begin() unexpected error = %v", err)
+	}
+	if _, err := provider.Validate(state); err == nil {
+		t.Errorf("Validate() accepted a federation state token")
+	}
+}
@@ -0,0 +1,186 @@
+package service
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/responsible-api/responsible-auth/auth"
+	"github.com/responsible-api/responsible-auth/internal"
+	"github.com/responsible-api/responsible-auth/ldap"
+	"github.com/responsible-api/responsible-auth/mfa"
+	"github.com/responsible-api/responsible-auth/resource/access"
+	"github.com/responsible-api/responsible-auth/resource/user"
+	"github.com/responsible-api/responsible-auth/storage"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// LDAPAuth authenticates users with Basic credentials checked against an
+// LDAP directory. The directory's group memberships set the role and scopes
+// of the tokens, and are looked up again whenever a refresh token is used.
+type LDAPAuth struct {
+	auth.AuthProvider
+	options   auth.AuthOptions
+	storage   storage.UserStorage
+	directory *ldap.Directory
+	provision bool
+}
+
+// NewLDAPAuth creates a provider authenticating against the given directory.
+func NewLDAPAuth(directory *ldap.Directory) auth.AuthInterface {
+	var provider auth.AuthInterface = &LDAPAuth{directory: directory}
+	return provider
+}
+
+// SetOptions sets the options for the LDAPAuth provider.
+func (d *LDAPAuth) SetOptions(options auth.AuthOptions) {
+	d.options = options
+	Options = options
+}
+
+// Options returns the options set for the LDAPAuth provider.
+func (d *LDAPAuth) Options() auth.AuthOptions {
+	return d.options
+}
+
+// SetStorage sets the storage implementation for the LDAPAuth provider.
+func (d *LDAPAuth) SetStorage(storage storage.UserStorage) {
+	d.storage = storage
+}
+
+// SetProvisioning controls whether directory users without a local account
+// get one created on their first login. When disabled, which is the
+// default, only users that already exist locally can log in.
+func (d *LDAPAuth) SetProvisioning(enabled bool) {
+	d.provision = enabled
+}
+
+func (d *LDAPAuth) Decode(hash string) (string, string, error) {
+	unpackedUsername, unpackedPassword, err := validateBasic(hash)
+	if err != nil {
+		return "", "", err
+	}
+	// Return the decoded username and password
+	return unpackedUsername, unpackedPassword, nil
+}
+
+// CreateAccessToken binds to the directory as the user and issues a token
+// carrying the role and scopes of their groups.
+func (a *LDAPAuth) CreateAccessToken(userID string, hash string) (*access.RToken, error) {
+	user, opts, err := a.authenticate(userID, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := internal.CreateAccessToken(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	recordAccess(a.options.Log(), a.storage, user)
+	auditLogin(a.options, auth.GrantLDAP, auth.ClientInfo{}, user.Name, token, nil)
+	return token, nil
+}
+
+func (a *LDAPAuth) CreateRefreshToken(userID string, hash string) (*access.RToken, error) {
+	user, opts, err := a.authenticate(userID, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := internal.CreateRefreshToken(user.Name, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	auditLogin(a.options, auth.GrantLDAP, auth.ClientInfo{}, user.Name, refreshToken, nil)
+	return refreshToken, nil
+}
+
+// GrantRefreshToken issues an access token for a refresh token. The user must
+// still exist in the directory, and their current groups decide the claims
+// before the ClaimsEnricher runs, as on login.
+func (a *LDAPAuth) GrantRefreshToken(refreshTokenString string) (*access.RToken, error) {
+	user, opts, err := refreshIdentityUser(a.storage, refreshTokenString, a.options, a.directoryIdentity)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := internal.CreateAccessToken(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	recordAccess(a.options.Log(), a.storage, user)
+	return token, nil
+}
+
+func (a *LDAPAuth) Validate(tokenString string) (*jwt.Token, error) {
+	token, err := internal.Validate(tokenString, a.options)
+	if err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// directoryIdentity sets the role and scopes from the user's current
+// directory groups.
+func (a *LDAPAuth) directoryIdentity(u *user.User, opts auth.AuthOptions) (auth.AuthOptions, error) {
+	identity, err := a.directory.Lookup(u.Name)
+	if err != nil {
+		return auth.AuthOptions{}, err
+	}
+	opts.Role = identity.Role
+	opts.Scopes = strings.Join(identity.Scopes, " ")
+	return opts, nil
+}
+
+// authenticate checks the password with the directory and returns the
+// active local user with the token options for their directory groups.
+func (a *LDAPAuth) authenticate(username string, password string) (*user.User, auth.AuthOptions, error) {
+	if a.storage == nil {
+		return nil, auth.AuthOptions{}, ErrNoStorage
+	}
+
+	identity, err := a.directory.Authenticate(username, password)
+	if err != nil {
+		auditLogin(a.options, auth.GrantLDAP, auth.ClientInfo{}, username, nil, err)
+		return nil, auth.AuthOptions{}, err
+	}
+
+	found, err := a.localUser(identity)
+	if err != nil {
+		auditLogin(a.options, auth.GrantLDAP, auth.ClientInfo{}, identity.Name, nil, err)
+		return nil, auth.AuthOptions{}, err
+	}
+	if err := checkActive(found); err != nil {
+		auditLogin(a.options, auth.GrantLDAP, auth.ClientInfo{}, found.Name, nil, err)
+		return nil, auth.AuthOptions{}, err
+	}
+
+	opts := userOptions(a.options, found)
+	opts.Role = identity.Role
+	opts.Scopes = strings.Join(identity.Scopes, " ")
+	opts.AMR = []string{mfa.MethodPassword}
+	opts, err = opts.Enrich(found, auth.Grant{Type: auth.GrantLDAP})
+	if err != nil {
+		return nil, auth.AuthOptions{}, err
+	}
+	return found, opts, nil
+}
+
+// localUser finds the local account named after the directory user,
+// creating it when provisioning is enabled. Provisioned accounts have no
+// password, so they can only log in through the directory.
+func (a *LDAPAuth) localUser(identity *ldap.Identity) (*user.User, error) {
+	found, err := a.storage.FindUserByName(identity.Name)
+	if err == nil || !errors.Is(err, storage.ErrUserNotFound) || !a.provision {
+		return found, err
+	}
+
+	provisioned := (&user.Form{Name: identity.Name, Mail: identity.Mail}).ToModel()
+	if err := a.storage.CreateUser(provisioned); err != nil {
+		return nil, err
+	}
+	return provisioned, nil
+}
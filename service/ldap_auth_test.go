@@ -0,0 +1,173 @@
+package service
+
+import (
+	"encoding/base64"
+	"errors"
+	"testing"
+
+	"github.com/responsible-api/responsible-auth/auth"
+	"github.com/responsible-api/responsible-auth/concerns"
+	"github.com/responsible-api/responsible-auth/ldap"
+	"github.com/responsible-api/responsible-auth/ldap/ldaptest"
+	"github.com/responsible-api/responsible-auth/resource/user"
+	"github.com/responsible-api/responsible-auth/storage"
+	"github.com/responsible-api/responsible-auth/testutils"
+)
+
+func newLDAPProvider(t *testing.T) (*LDAPAuth, *ldaptest.Server, *testutils.MockStorage) {
+	t.Helper()
+	server := ldaptest.NewServer(t,
+		&ldaptest.Entry{
+			DN:       "uid=testuser,ou=people,dc=example,dc=com",
+			Password: "directory-secret",
+			Attributes: map[string][]string{
+				"objectClass": {"person"},
+				"uid":         {"testuser"},
+				"memberOf":    {"cn=admins,dc=example,dc=com"},
+			},
+		},
+		&ldaptest.Entry{
+			DN:       "uid=newcomer,ou=people,dc=example,dc=com",
+			Password: "newcomer-secret",
+			Attributes: map[string][]string{
+				"objectClass": {"person"},
+				"uid":         {"newcomer"},
+				"mail":        {"newcomer@example.com"},
+			},
+		},
+	)
+	directory := ldap.NewDirectory(ldap.Config{
+		URL:    server.URL,
+		BaseDN: "dc=example,dc=com",
+		Groups: []ldap.GroupMapping{
+			{Group: "cn=admins,dc=example,dc=com", Role: "admin", Scopes: []string{"read", "write"}},
+		},
+	})
+
+	mockStorage := testutils.NewMockStorage()
+	provider := NewLDAPAuth(directory).(*LDAPAuth)
+	provider.SetStorage(mockStorage)
+	provider.SetOptions(testutils.TestAuthOptions())
+	return provider, server, mockStorage
+}
+
+func TestLDAPAuth_Login(t *testing.T) {
+	provider, server, _ := newLDAPProvider(t)
+
+	username, password, err := provider.Decode(base64.StdEncoding.EncodeToString([]byte("testuser:directory-secret")))
+	if err != nil {
+		t.Fatalf("Decode() unexpected error = %v", err)
+	}
+
+	token, err := provider.CreateAccessToken(username, password)
+	if err != nil {
+		t.Fatalf("CreateAccessToken() unexpected error = %v", err)
+	}
+	validated, err := provider.Validate(token.GetToken())
+	if err != nil {
+		t.Fatalf("Validate() unexpected error = %v", err)
+	}
+	claims := validated.Claims.(*concerns.ClaimsGeneric)
+	if claims.Role != "admin" || claims.Scopes != "read write" {
+		t.Errorf("access token role = %q scopes = %q, want admin with read write", claims.Role, claims.Scopes)
+	}
+	if claims.AccountID != testutils.TestUser().AccountID {
+		t.Errorf("access token account = %v, want %v", claims.AccountID, testutils.TestUser().AccountID)
+	}
+
+	// The local password does not work against the directory
+	if _, err := provider.CreateAccessToken("testuser", "test-password-hash"); !errors.Is(err, ldap.ErrInvalidCredentials) {
+		t.Errorf("CreateAccessToken() local password error = %v, want %v", err, ldap.ErrInvalidCredentials)
+	}
+
+	refreshToken, err := provider.CreateRefreshToken(username, password)
+	if err != nil {
+		t.Fatalf("CreateRefreshToken() unexpected error = %v", err)
+	}
+
+	// Group changes in the directory apply on refresh
+	server.Remove("uid=testuser,ou=people,dc=example,dc=com")
+	server.Add(&ldaptest.Entry{
+		DN:         "uid=testuser,ou=people,dc=example,dc=com",
+		Password:   "directory-secret",
+		Attributes: map[string][]string{"objectClass": {"person"}, "uid": {"testuser"}},
+	})
+	granted, err := provider.GrantRefreshToken(refreshToken.GetToken())
+	if err != nil {
+		t.Fatalf("GrantRefreshToken() unexpected error = %v", err)
+	}
+	validated, err = provider.Validate(granted.GetToken())
+	if err != nil {
+		t.Fatalf("Validate() granted token unexpected error = %v", err)
+	}
+	if claims := validated.Claims.(*concerns.ClaimsGeneric); claims.Role != "" || claims.Scopes != "" {
+		t.Errorf("refreshed token role = %q scopes = %q, want none", claims.Role, claims.Scopes)
+	}
+
+	// Users removed from the directory cannot refresh
+	server.Remove("uid=testuser,ou=people,dc=example,dc=com")
+	if _, err := provider.GrantRefreshToken(refreshToken.GetToken()); !errors.Is(err, ldap.ErrUserNotFound) {
+		t.Errorf("GrantRefreshToken() removed user error = %v, want %v", err, ldap.ErrUserNotFound)
+	}
+}
+
+func TestLDAPAuth_ClaimsEnricher(t *testing.T) {
+	provider, _, _ := newLDAPProvider(t)
+	var seen []string
+	options := testutils.TestAuthOptions()
+	options.ClaimsEnricher = auth.ClaimsEnricherFunc(func(u *user.User, grant auth.Grant, claims *auth.Claims) error {
+		// The enricher sees the directory groups and narrows their scopes
+		seen = append(seen, claims.Role)
+		claims.Scopes = "read"
+		return nil
+	})
+	provider.SetOptions(options)
+
+	refreshToken, err := provider.CreateRefreshToken("testuser", "directory-secret")
+	if err != nil {
+		t.Fatalf("CreateRefreshToken() unexpected error = %v", err)
+	}
+	granted, err := provider.GrantRefreshToken(refreshToken.GetToken())
+	if err != nil {
+		t.Fatalf("GrantRefreshToken() unexpected error = %v", err)
+	}
+	validated, err := provider.Validate(granted.GetToken())
+	if err != nil {
+		t.Fatalf("Validate() unexpected error = %v", err)
+	}
+	if claims := validated.Claims.(*concerns.ClaimsGeneric); claims.Role != "admin" || claims.Scopes != "read" {
+		t.Errorf("refreshed token role = %q scopes = %q, want admin with the enriched read", claims.Role, claims.Scopes)
+	}
+	if len(seen) != 2 || seen[1] != "admin" {
+		t.Errorf("enricher saw roles %q, want the directory role on refresh", seen)
+	}
+}
+
+func TestLDAPAuth_Provisioning(t *testing.T) {
+	provider, _, mockStorage := newLDAPProvider(t)
+
+	if _, err := provider.CreateAccessToken("newcomer", "newcomer-secret"); !errors.Is(err, storage.ErrUserNotFound) {
+		t.Errorf("CreateAccessToken() without provisioning error = %v, want %v", err, storage.ErrUserNotFound)
+	}
+
+	provider.SetProvisioning(true)
+	if _, err := provider.CreateAccessToken("newcomer", "newcomer-secret"); err != nil {
+		t.Fatalf("CreateAccessToken() with provisioning unexpected error = %v", err)
+	}
+
+	provisioned, err := mockStorage.FindUserByName("newcomer")
+	if err != nil {
+		t.Fatalf("FindUserByName() unexpected error = %v", err)
+	}
+	if provisioned.Mail != "newcomer@example.com" || provisioned.Status != user.StatusActive || provisioned.Secret != "" {
+		t.Errorf("provisioned user = %+v, want active with directory mail and no password", provisioned)
+	}
+
+	// Provisioned accounts follow the local status like any other
+	if _, err := NewUserService(mockStorage).Suspend("newcomer"); err != nil {
+		t.Fatalf("Suspend() unexpected error = %v", err)
+	}
+	if _, err := provider.CreateAccessToken("newcomer", "newcomer-secret"); !errors.Is(err, ErrUserInactive) {
+		t.Errorf("CreateAccessToken() suspended error = %v, want %v", err, ErrUserInactive)
+	}
+}
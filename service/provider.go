@@ -0,0 +1,183 @@
+package service
+
+import (
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/responsible-api/responsible-auth/auth"
+	"github.com/responsible-api/responsible-auth/internal"
+	"github.com/responsible-api/responsible-auth/resource/access"
+	"github.com/responsible-api/responsible-auth/resource/user"
+	"github.com/responsible-api/responsible-auth/storage"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// checkActive rejects users that are suspended, deleted or not yet activated.
+func checkActive(u *user.User) error {
+	if !u.IsActive() {
+		return ErrUserInactive
+	}
+	return nil
+}
+
+// recordAccess stores the time of a successful authentication in the background
+// so a slow storage write never delays issuing the token.
+func recordAccess(logger *slog.Logger, s storage.UserStorage, u *user.User) {
+	go func(name string, at uint64) {
+		if err := s.UpdateAccess(name, at); err != nil {
+			logger.Warn("failed to record access", "user", name, "error", err)
+		}
+	}(u.Name, uint64(time.Now().Unix()))
+}
+
+// audit records event with the options' AuditSink. A failure to record is
+// logged rather than returned, so a broken audit log never blocks a login.
+func audit(options auth.AuthOptions, event auth.AuditEvent) {
+	if err := options.Audit(event); err != nil {
+		options.Log().Error("failed to record audit event", "type", event.Type, "subject", event.Subject, "error", err)
+	}
+}
+
+// auditLogin records the outcome of a credential check for subject, the user
+// name or, for failures, the identifier that was presented. Logins refused
+// by the lockout guard or the MFA attempt limit are recorded as lockout events. Failures are also
+// logged at Warn.
+func auditLogin(options auth.AuthOptions, method string, client auth.ClientInfo, subject string, token *access.RToken, err error) {
+	event := auth.AuditEvent{
+		Type:      auth.EventLogin,
+		Method:    method,
+		Success:   err == nil,
+		Subject:   subject,
+		IP:        client.IP,
+		UserAgent: client.UserAgent,
+	}
+	if err != nil {
+		event.Reason = err.Error()
+		if errors.Is(err, ErrAccountLocked) || errors.Is(err, ErrTooManyMFAAttempts) {
+			event.Type = auth.EventLockout
+		}
+		options.Log().Warn("login failed", "method", method, "subject", subject, "ip", client.IP, "error", err)
+	}
+	if token != nil {
+		event.TokenID = token.GetID()
+	}
+	audit(options, event)
+}
+
+// userOptions returns the provider options with the claims that identify u,
+// so access tokens can be traced back to the account they were issued for.
+func userOptions(options auth.AuthOptions, u *user.User) auth.AuthOptions {
+	opts := options
+	opts.AccountID = u.AccountID
+	return opts
+}
+
+// refreshTokenUser verifies a refresh token and returns the active user it was
+// issued to, with the enriched token options for the access token it grants.
+// Every attempt is recorded as a refresh audit event.
+func refreshTokenUser(s storage.UserStorage, refreshTokenString string, options auth.AuthOptions) (*user.User, auth.AuthOptions, error) {
+	return refreshIdentityUser(s, refreshTokenString, options, nil)
+}
+
+// refreshIdentity sets the token options from the user's current identity
+// elsewhere, such as their directory groups, before the options are enriched.
+type refreshIdentity func(u *user.User, opts auth.AuthOptions) (auth.AuthOptions, error)
+
+// refreshIdentityUser is refreshTokenUser applying identify, when not nil,
+// before the ClaimsEnricher, so the enricher has the final say as on login.
+func refreshIdentityUser(s storage.UserStorage, refreshTokenString string, options auth.AuthOptions, identify refreshIdentity) (*user.User, auth.AuthOptions, error) {
+	claims, err := internal.ParseRefreshToken(refreshTokenString, options)
+	if err != nil {
+		audit(options, auth.AuditEvent{Type: auth.EventRefresh, Method: auth.GrantRefreshToken, Reason: err.Error()})
+		return nil, auth.AuthOptions{}, err
+	}
+
+	username, _ := claims["username"].(string)
+	tokenID, _ := claims["jti"].(string)
+	var u *user.User
+	var opts auth.AuthOptions
+	if err = checkRefresh(refreshTokenString, claims, options); err == nil {
+		u, opts, err = refreshClaimsUser(s, claims, options, identify)
+	}
+	event := auth.AuditEvent{
+		Type:    auth.EventRefresh,
+		Method:  auth.GrantRefreshToken,
+		Success: err == nil,
+		Subject: username,
+		TokenID: tokenID,
+	}
+	if err != nil {
+		event.Reason = err.Error()
+	}
+	audit(options, event)
+	return u, opts, err
+}
+
+// checkRefresh consults the options' RefreshChecker, so a refresh token whose
+// session has ended cannot be redeemed. Without a checker, tokens bound to a
+// browser session are refused.
+func checkRefresh(refreshTokenString string, claims jwt.MapClaims, options auth.AuthOptions) error {
+	if options.RefreshChecker != nil {
+		return options.RefreshChecker.CheckRefresh(refreshTokenString, claims)
+	}
+	if internal.IsSessionRefreshToken(claims) {
+		return ErrSessionRefreshToken
+	}
+	return nil
+}
+
+// refreshClaimsUser returns the active user named by verified refresh token
+// claims, with the enriched token options for the access token it grants.
+func refreshClaimsUser(s storage.UserStorage, claims jwt.MapClaims, options auth.AuthOptions, identify refreshIdentity) (*user.User, auth.AuthOptions, error) {
+	if s == nil {
+		return nil, auth.AuthOptions{}, ErrNoStorage
+	}
+	if clientID, _ := claims["client_id"].(string); clientID != "" {
+		return nil, auth.AuthOptions{}, ErrClientRefreshToken
+	}
+
+	username, _ := claims["username"].(string)
+	u, err := s.FindUserByName(username)
+	if err != nil {
+		return nil, auth.AuthOptions{}, err
+	}
+
+	if err := checkActive(u); err != nil {
+		return nil, auth.AuthOptions{}, err
+	}
+
+	opts := userOptions(options, u)
+	if amr, ok := claims["amr"].([]interface{}); ok {
+		for _, method := range amr {
+			if m, ok := method.(string); ok {
+				opts.AMR = append(opts.AMR, m)
+			}
+		}
+	}
+	if custom, ok := claims["custom"].(map[string]interface{}); ok {
+		merged := make(map[string]interface{}, len(opts.CustomClaims)+len(custom))
+		for name, value := range opts.CustomClaims {
+			merged[name] = value
+		}
+		for name, value := range custom {
+			merged[name] = value
+		}
+		opts.CustomClaims = merged
+	}
+
+	if identify != nil {
+		opts, err = identify(u, opts)
+		if err != nil {
+			return nil, auth.AuthOptions{}, err
+		}
+	}
+
+	// Enrich again so changes such as a revoked role apply from the next refresh
+	opts, err = opts.Enrich(u, auth.Grant{Type: auth.GrantRefreshToken})
+	if err != nil {
+		return nil, auth.AuthOptions{}, err
+	}
+	return u, opts, nil
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"fmt"
+	"net/mail"
+	"strings"
+
+	"github.com/responsible-api/responsible-auth/resource/user"
+	"github.com/responsible-api/responsible-auth/storage"
+)
+
+// MinPasswordLength is the shortest password Register accepts.
+const MinPasswordLength = 8
+
+// UserService manages user accounts: registration, profile updates, status changes and deletion.
+type UserService struct {
+	storage storage.UserStorage
+}
+
+// NewUserService creates a user service backed by the given storage.
+func NewUserService(storage storage.UserStorage) *UserService {
+	return &UserService{
+		storage: storage,
+	}
+}
+
+// Register creates an active user with a hashed password.
+func (s *UserService) Register(form *user.RegisterForm) (*user.User, error) {
+	name := strings.TrimSpace(form.Name)
+	if name == "" {
+		return nil, fmt.Errorf("%w: name is required", ErrInvalidForm)
+	}
+
+	address, err := validateMail(form.Mail)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(form.Password) < MinPasswordLength {
+		return nil, fmt.Errorf("%w: password must be at least %d characters", ErrInvalidForm, MinPasswordLength)
+	}
+
+	secret, err := user.HashSecret(form.Password)
+	if err != nil {
+		return nil, err
+	}
+
+	newUser := form.ToModel()
+	newUser.Name = name
+	newUser.Mail = address
+	newUser.Secret = secret
+
+	if err := s.storage.CreateUser(newUser); err != nil {
+		return nil, err
+	}
+	return newUser, nil
+}
+
+// Update changes the mail and account of an existing user.
+// Empty fields in the form are left unchanged; names cannot be changed.
+func (s *UserService) Update(name string, form *user.Form) (*user.User, error) {
+	if form.Name != "" && form.Name != name {
+		return nil, fmt.Errorf("%w: name cannot be changed", ErrInvalidForm)
+	}
+
+	existing, err := s.storage.FindUserByName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if form.Mail != "" {
+		address, err := validateMail(form.Mail)
+		if err != nil {
+			return nil, err
+		}
+		existing.Mail = address
+	}
+
+	if form.AccountID != 0 {
+		existing.AccountID = form.AccountID
+	}
+
+	if err := s.storage.UpdateUser(existing); err != nil {
+		return nil, err
+	}
+	return existing, nil
+}
+
+// SetStatus moves a user to one of the user.Status* states.
+// Any state other than active blocks login, refresh and API key access.
+func (s *UserService) SetStatus(name string, status int) (*user.User, error) {
+	switch status {
+	case user.StatusInactive, user.StatusActive, user.StatusSuspended, user.StatusDeleted:
+	default:
+		return nil, fmt.Errorf("%w: %d", ErrInvalidStatus, status)
+	}
+
+	existing, err := s.storage.FindUserByName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	existing.Status = status
+
+	if err := s.storage.UpdateUser(existing); err != nil {
+		return nil, err
+	}
+	return existing, nil
+}
+
+// Activate allows a user to authenticate.
+func (s *UserService) Activate(name string) (*user.User, error) {
+	return s.SetStatus(name, user.StatusActive)
+}
+
+// Suspend blocks a user from authenticating until they are activated again.
+func (s *UserService) Suspend(name string) (*user.User, error) {
+	return s.SetStatus(name, user.StatusSuspended)
+}
+
+// Delete removes a user. A soft delete keeps the record with StatusDeleted for
+// auditing; a hard delete removes it from storage entirely.
+func (s *UserService) Delete(name string, hard bool) error {
+	if hard {
+		return s.storage.DeleteUser(name)
+	}
+
+	_, err := s.SetStatus(name, user.StatusDeleted)
+	return err
+}
+
+func validateMail(address string) (string, error) {
+	parsed, err := mail.ParseAddress(strings.TrimSpace(address))
+	if err != nil || parsed.Name != "" {
+		return "", fmt.Errorf("%w: invalid mail address", ErrInvalidForm)
+	}
+	return parsed.Address, nil
+}
@@ -0,0 +1,282 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/responsible-api/responsible-auth/resource/user"
+	"github.com/responsible-api/responsible-auth/storage"
+	"github.com/responsible-api/responsible-auth/testutils"
+)
+
+func TestUserService_Register(t *testing.T) {
+	tests := []struct {
+		name        string
+		form        *user.RegisterForm
+		expectError error
+	}{
+		{
+			name: "valid registration",
+			form: &user.RegisterForm{
+				Form:     user.Form{AccountID: 42, Name: "newuser", Mail: "new@example.com"},
+				Password: "correct horse battery",
+			},
+		},
+		{
+			name: "missing name",
+			form: &user.RegisterForm{
+				Form:     user.Form{Mail: "new@example.com"},
+				Password: "correct horse battery",
+			},
+			expectError: ErrInvalidForm,
+		},
+		{
+			name: "invalid mail",
+			form: &user.RegisterForm{
+				Form:     user.Form{Name: "newuser", Mail: "not-an-address"},
+				Password: "correct horse battery",
+			},
+			expectError: ErrInvalidForm,
+		},
+		{
+			name: "short password",
+			form: &user.RegisterForm{
+				Form:     user.Form{Name: "newuser", Mail: "new@example.com"},
+				Password: "short",
+			},
+			expectError: ErrInvalidForm,
+		},
+		{
+			name: "duplicate name",
+			form: &user.RegisterForm{
+				Form:     user.Form{Name: "testuser", Mail: "other@example.com"},
+				Password: "correct horse battery",
+			},
+			expectError: storage.ErrUserExists,
+		},
+		{
+			name: "duplicate mail",
+			form: &user.RegisterForm{
+				Form:     user.Form{Name: "other", Mail: "test@example.com"},
+				Password: "correct horse battery",
+			},
+			expectError: storage.ErrUserExists,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStorage := testutils.NewMockStorage()
+			service := NewUserService(mockStorage)
+
+			created, err := service.Register(tt.form)
+
+			if tt.expectError != nil {
+				if !errors.Is(err, tt.expectError) {
+					t.Errorf("Register() error = %v, want %v", err, tt.expectError)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Register() unexpected error = %v", err)
+			}
+
+			if created.Secret == tt.form.Password {
+				t.Errorf("Register() stored the plaintext password")
+			}
+
+			if created.Status != user.StatusActive {
+				t.Errorf("Register() status = %v, want %v", created.Status, user.StatusActive)
+			}
+
+			// The new user can log in with the original password
+			found, err := mockStorage.FindUserByCredentials(tt.form.Mail, tt.form.Password)
+			if err != nil {
+				t.Fatalf("FindUserByCredentials() after Register() error = %v", err)
+			}
+			if found.Name != tt.form.Name {
+				t.Errorf("FindUserByCredentials() name = %v, want %v", found.Name, tt.form.Name)
+			}
+		})
+	}
+}
+
+func TestUserService_Update(t *testing.T) {
+	tests := []struct {
+		name        string
+		userName    string
+		form        *user.Form
+		expectMail  string
+		expectError error
+	}{
+		{
+			name:       "change mail",
+			userName:   "testuser",
+			form:       &user.Form{Mail: "changed@example.com"},
+			expectMail: "changed@example.com",
+		},
+		{
+			name:       "empty fields unchanged",
+			userName:   "testuser",
+			form:       &user.Form{},
+			expectMail: "test@example.com",
+		},
+		{
+			name:        "rename rejected",
+			userName:    "testuser",
+			form:        &user.Form{Name: "renamed"},
+			expectError: ErrInvalidForm,
+		},
+		{
+			name:        "invalid mail",
+			userName:    "testuser",
+			form:        &user.Form{Mail: "nope"},
+			expectError: ErrInvalidForm,
+		},
+		{
+			name:        "unknown user",
+			userName:    "nobody",
+			form:        &user.Form{Mail: "changed@example.com"},
+			expectError: storage.ErrUserNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := NewUserService(testutils.NewMockStorage())
+
+			updated, err := service.Update(tt.userName, tt.form)
+
+			if tt.expectError != nil {
+				if !errors.Is(err, tt.expectError) {
+					t.Errorf("Update() error = %v, want %v", err, tt.expectError)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Update() unexpected error = %v", err)
+			}
+
+			if updated.Mail != tt.expectMail {
+				t.Errorf("Update() mail = %v, want %v", updated.Mail, tt.expectMail)
+			}
+		})
+	}
+}
+
+func TestUserService_SetStatus(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		expectError error
+	}{
+		{name: "suspend", status: user.StatusSuspended},
+		{name: "deactivate", status: user.StatusInactive},
+		{name: "soft delete", status: user.StatusDeleted},
+		{name: "activate", status: user.StatusActive},
+		{name: "unknown status", status: 99, expectError: ErrInvalidStatus},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockStorage := testutils.NewMockStorage()
+			service := NewUserService(mockStorage)
+
+			_, err := service.SetStatus("testuser", tt.status)
+
+			if tt.expectError != nil {
+				if !errors.Is(err, tt.expectError) {
+					t.Errorf("SetStatus() error = %v, want %v", err, tt.expectError)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("SetStatus() unexpected error = %v", err)
+			}
+
+			stored, err := mockStorage.FindUserByName("testuser")
+			if err != nil {
+				t.Fatalf("FindUserByName() unexpected error = %v", err)
+			}
+			if stored.Status != tt.status {
+				t.Errorf("SetStatus() stored status = %v, want %v", stored.Status, tt.status)
+			}
+		})
+	}
+}
+
+func TestUserService_Delete(t *testing.T) {
+	t.Run("soft delete keeps record", func(t *testing.T) {
+		mockStorage := testutils.NewMockStorage()
+		service := NewUserService(mockStorage)
+
+		if err := service.Delete("testuser", false); err != nil {
+			t.Fatalf("Delete() unexpected error = %v", err)
+		}
+
+		stored, err := mockStorage.FindUserByName("testuser")
+		if err != nil {
+			t.Fatalf("FindUserByName() after soft delete error = %v", err)
+		}
+		if stored.Status != user.StatusDeleted {
+			t.Errorf("Delete() status = %v, want %v", stored.Status, user.StatusDeleted)
+		}
+	})
+
+	t.Run("hard delete removes record", func(t *testing.T) {
+		mockStorage := testutils.NewMockStorage()
+		service := NewUserService(mockStorage)
+
+		if err := service.Delete("testuser", true); err != nil {
+			t.Fatalf("Delete() unexpected error = %v", err)
+		}
+
+		if _, err := mockStorage.FindUserByName("testuser"); !errors.Is(err, storage.ErrUserNotFound) {
+			t.Errorf("FindUserByName() after hard delete error = %v, want %v", err, storage.ErrUserNotFound)
+		}
+	})
+
+	t.Run("unknown user", func(t *testing.T) {
+		service := NewUserService(testutils.NewMockStorage())
+
+		if err := service.Delete("nobody", true); !errors.Is(err, storage.ErrUserNotFound) {
+			t.Errorf("Delete() error = %v, want %v", err, storage.ErrUserNotFound)
+		}
+	})
+}
+
+func TestBasicAuth_InactiveUser(t *testing.T) {
+	statuses := []int{user.StatusInactive, user.StatusSuspended, user.StatusDeleted}
+
+	for _, status := range statuses {
+		mockStorage := testutils.NewMockStorage()
+		provider := NewBasicAuth().(*BasicAuth)
+		provider.SetStorage(mockStorage)
+		provider.SetOptions(testutils.TestAuthOptions())
+
+		// Issue a refresh token while the user is still active
+		refreshToken, err := provider.CreateRefreshToken("test@example.com", "test-password-hash")
+		if err != nil {
+			t.Fatalf("CreateRefreshToken() unexpected error = %v", err)
+		}
+
+		if _, err := NewUserService(mockStorage).SetStatus("testuser", status); err != nil {
+			t.Fatalf("SetStatus() unexpected error = %v", err)
+		}
+
+		if _, err := provider.CreateAccessToken("test@example.com", "test-password-hash"); !errors.Is(err, ErrUserInactive) {
+			t.Errorf("CreateAccessToken() status %d error = %v, want %v", status, err, ErrUserInactive)
+		}
+
+		if _, err := provider.CreateRefreshToken("test@example.com", "test-password-hash"); !errors.Is(err, ErrUserInactive) {
+			t.Errorf("CreateRefreshToken() status %d error = %v, want %v", status, err, ErrUserInactive)
+		}
+
+		if _, err := provider.GrantRefreshToken(refreshToken.GetToken()); !errors.Is(err, ErrUserInactive) {
+			t.Errorf("GrantRefreshToken() status %d error = %v, want %v", status, err, ErrUserInactive)
+		}
+	}
+}
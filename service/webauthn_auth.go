@@ -0,0 +1,206 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/responsible-api/responsible-auth/auth"
+	"github.com/responsible-api/responsible-auth/internal"
+	"github.com/responsible-api/responsible-auth/resource/access"
+	"github.com/responsible-api/responsible-auth/resource/credential"
+	"github.com/responsible-api/responsible-auth/resource/user"
+	"github.com/responsible-api/responsible-auth/storage"
+	"github.com/responsible-api/responsible-auth/webauthn"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// WebAuthnAuth authenticates users with passkeys and security keys. The
+// assertion JSON returned by navigator.credentials.get() takes the place of
+// the password, and a verified assertion issues the same access and refresh
+// tokens as the other providers.
+type WebAuthnAuth struct {
+	auth.AuthProvider
+	options      auth.AuthOptions
+	storage      storage.UserStorage
+	relyingParty *webauthn.RelyingParty
+}
+
+// NewWebAuthnAuth creates a provider running ceremonies with the given relying party.
+func NewWebAuthnAuth(relyingParty *webauthn.RelyingParty) auth.AuthInterface {
+	var provider auth.AuthInterface = &WebAuthnAuth{relyingParty: relyingParty}
+	return provider
+}
+
+// SetOptions sets the options for the WebAuthnAuth provider.
+func (d *WebAuthnAuth) SetOptions(options auth.AuthOptions) {
+	d.options = options
+	Options = options
+}
+
+// Options returns the options set for the WebAuthnAuth provider.
+func (d *WebAuthnAuth) Options() auth.AuthOptions {
+	return d.options
+}
+
+// SetStorage sets the storage implementation for the WebAuthnAuth provider.
+func (d *WebAuthnAuth) SetStorage(storage storage.UserStorage) {
+	d.storage = storage
+}
+
+// Decode returns the name of the user whose credential signed the assertion,
+// and the assertion itself, ready to pass on to CreateAccessToken. The
+// signature is not verified until then.
+func (d *WebAuthnAuth) Decode(assertion string) (string, string, error) {
+	response, err := parseAssertion(assertion)
+	if err != nil {
+		return "", "", err
+	}
+
+	c, err := d.relyingParty.Credential(response.RawID)
+	if err != nil {
+		return "", "", err
+	}
+	return c.UserName, assertion, nil
+}
+
+// BeginRegistration starts registering a passkey for an active user.
+func (d *WebAuthnAuth) BeginRegistration(userName string) (*webauthn.CreationOptions, error) {
+	if _, err := d.findActiveUser(userName); err != nil {
+		return nil, err
+	}
+	return d.relyingParty.BeginRegistration(userName)
+}
+
+// FinishRegistration stores the passkey created in response to BeginRegistration.
+// The attestation is the JSON returned by navigator.credentials.create().
+func (d *WebAuthnAuth) FinishRegistration(userName string, attestation string) (*credential.Credential, error) {
+	if _, err := d.findActiveUser(userName); err != nil {
+		return nil, err
+	}
+
+	var response webauthn.AttestationResponse
+	if err := json.Unmarshal([]byte(attestation), &response); err != nil {
+		return nil, fmt.Errorf("%w: %v", webauthn.ErrInvalidResponse, err)
+	}
+
+	c, err := d.relyingParty.FinishRegistration(userName, &response)
+	event := auth.AuditEvent{Type: auth.EventKeyCreated, Method: auth.GrantWebAuthn, Success: err == nil, Subject: userName}
+	if err != nil {
+		event.Reason = err.Error()
+	}
+	audit(d.options, event)
+	return c, err
+}
+
+// BeginLogin starts a login, for the named user or, with an empty name, for
+// whichever passkey the authenticator offers.
+func (d *WebAuthnAuth) BeginLogin(userName string) (*webauthn.RequestOptions, error) {
+	return d.relyingParty.BeginLogin(userName)
+}
+
+// CreateAccessToken verifies the assertion and issues an access token for its user.
+// The credential alone identifies the user, so userID is not consulted.
+func (a *WebAuthnAuth) CreateAccessToken(userID string, assertion string) (*access.RToken, error) {
+	token, _, err := a.Login(assertion)
+	return token, err
+}
+
+// CreateRefreshToken verifies the assertion and issues a refresh token for its user.
+// Each assertion answers a single challenge, so use Login to obtain both tokens.
+func (a *WebAuthnAuth) CreateRefreshToken(userID string, assertion string) (*access.RToken, error) {
+	_, refreshToken, err := a.Login(assertion)
+	return refreshToken, err
+}
+
+// Login verifies the assertion and returns the access and refresh tokens,
+// whose amr claim records the passkey and whether the user was verified.
+func (a *WebAuthnAuth) Login(assertion string) (*access.RToken, *access.RToken, error) {
+	response, err := parseAssertion(assertion)
+	if err != nil {
+		return nil, nil, err
+	}
+	if a.storage == nil {
+		return nil, nil, ErrNoStorage
+	}
+
+	result, err := a.relyingParty.FinishLogin(response)
+	if err != nil {
+		auditLogin(a.options, auth.GrantWebAuthn, auth.ClientInfo{}, "", nil, err)
+		return nil, nil, err
+	}
+
+	user, err := a.findActiveUser(result.Credential.UserName)
+	if err != nil {
+		auditLogin(a.options, auth.GrantWebAuthn, auth.ClientInfo{}, result.Credential.UserName, nil, err)
+		return nil, nil, err
+	}
+
+	opts := userOptions(a.options, user)
+	opts.AMR = result.AMR()
+	opts, err = opts.Enrich(user, auth.Grant{Type: auth.GrantWebAuthn})
+	if err != nil {
+		return nil, nil, err
+	}
+	token, err := internal.CreateAccessToken(opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	refreshToken, err := internal.CreateRefreshToken(user.Name, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	recordAccess(a.options.Log(), a.storage, user)
+	auditLogin(a.options, auth.GrantWebAuthn, auth.ClientInfo{}, user.Name, token, nil)
+	return token, refreshToken, nil
+}
+
+func (a *WebAuthnAuth) GrantRefreshToken(refreshTokenString string) (*access.RToken, error) {
+	user, opts, err := refreshTokenUser(a.storage, refreshTokenString, a.options)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := internal.CreateAccessToken(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	recordAccess(a.options.Log(), a.storage, user)
+	return token, nil
+}
+
+func (a *WebAuthnAuth) Validate(tokenString string) (*jwt.Token, error) {
+	token, err := internal.Validate(tokenString, a.options)
+	if err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// findActiveUser looks up a user by name and rejects inactive users.
+func (d *WebAuthnAuth) findActiveUser(userName string) (*user.User, error) {
+	if d.storage == nil {
+		return nil, ErrNoStorage
+	}
+
+	user, err := d.storage.FindUserByName(userName)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := checkActive(user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// parseAssertion decodes the JSON returned by navigator.credentials.get().
+func parseAssertion(assertion string) (*webauthn.AssertionResponse, error) {
+	var response webauthn.AssertionResponse
+	if err := json.Unmarshal([]byte(assertion), &response); err != nil {
+		return nil, fmt.Errorf("%w: %v", webauthn.ErrInvalidResponse, err)
+	}
+	return &response, nil
+}
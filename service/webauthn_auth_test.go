@@ -0,0 +1,160 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/responsible-api/responsible-auth/concerns"
+	"github.com/responsible-api/responsible-auth/examples/memory"
+	"github.com/responsible-api/responsible-auth/storage"
+	"github.com/responsible-api/responsible-auth/testutils"
+	"github.com/responsible-api/responsible-auth/webauthn"
+	"github.com/responsible-api/responsible-auth/webauthn/webauthntest"
+)
+
+func newWebAuthnProvider(t *testing.T) (*WebAuthnAuth, *testutils.MockStorage, *webauthntest.Authenticator) {
+	t.Helper()
+	rp := webauthn.NewRelyingParty(memory.NewInMemoryCredentialStorage(), webauthn.Config{
+		RPID:    "example.com",
+		Origins: []string{"https://example.com"},
+	})
+	mockStorage := testutils.NewMockStorage()
+	provider := NewWebAuthnAuth(rp).(*WebAuthnAuth)
+	provider.SetStorage(mockStorage)
+	provider.SetOptions(testutils.TestAuthOptions())
+
+	authenticator := webauthntest.New("example.com", "https://example.com")
+	options, err := provider.BeginRegistration("testuser")
+	if err != nil {
+		t.Fatalf("BeginRegistration() unexpected error = %v", err)
+	}
+	response, err := authenticator.Register(options)
+	if err != nil {
+		t.Fatalf("Register() unexpected error = %v", err)
+	}
+	if _, err := provider.FinishRegistration("testuser", toJSON(t, response)); err != nil {
+		t.Fatalf("FinishRegistration() unexpected error = %v", err)
+	}
+	return provider, mockStorage, authenticator
+}
+
+func toJSON(t *testing.T, v interface{}) string {
+	t.Helper()
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatalf("json.Marshal() unexpected error = %v", err)
+	}
+	return string(b)
+}
+
+// assertion runs a login ceremony with the authenticator and returns its JSON response.
+func assertion(t *testing.T, provider *WebAuthnAuth, authenticator *webauthntest.Authenticator) string {
+	t.Helper()
+	options, err := provider.BeginLogin("")
+	if err != nil {
+		t.Fatalf("BeginLogin() unexpected error = %v", err)
+	}
+	response, err := authenticator.Login(options)
+	if err != nil {
+		t.Fatalf("Login() unexpected error = %v", err)
+	}
+	return toJSON(t, response)
+}
+
+func TestWebAuthnAuth_Login(t *testing.T) {
+	provider, _, authenticator := newWebAuthnProvider(t)
+
+	signed := assertion(t, provider, authenticator)
+	userName, _, err := provider.Decode(signed)
+	if err != nil || userName != "testuser" {
+		t.Errorf("Decode() = %v, %v, want testuser", userName, err)
+	}
+
+	token, refreshToken, err := provider.Login(signed)
+	if err != nil {
+		t.Fatalf("Login() unexpected error = %v", err)
+	}
+
+	validated, err := provider.Validate(token.GetToken())
+	if err != nil {
+		t.Fatalf("Validate() unexpected error = %v", err)
+	}
+	claims := validated.Claims.(*concerns.ClaimsGeneric)
+	if want := []string{"hwk", "user", "mfa"}; !slices.Equal(claims.AMR, want) {
+		t.Errorf("access token amr = %v, want %v", claims.AMR, want)
+	}
+	if claims.AccountID != testutils.TestUser().AccountID {
+		t.Errorf("access token account = %v, want %v", claims.AccountID, testutils.TestUser().AccountID)
+	}
+
+	granted, err := provider.GrantRefreshToken(refreshToken.GetToken())
+	if err != nil {
+		t.Fatalf("GrantRefreshToken() unexpected error = %v", err)
+	}
+	validated, err = provider.Validate(granted.GetToken())
+	if err != nil {
+		t.Fatalf("Validate() granted token unexpected error = %v", err)
+	}
+	if got := validated.Claims.(*concerns.ClaimsGeneric).AMR; !slices.Equal(got, claims.AMR) {
+		t.Errorf("refreshed token amr = %v, want %v", got, claims.AMR)
+	}
+
+	// The assertion answered its challenge and cannot be used again
+	if _, err := provider.CreateAccessToken("", signed); !errors.Is(err, webauthn.ErrInvalidChallenge) {
+		t.Errorf("CreateAccessToken() replay error = %v, want %v", err, webauthn.ErrInvalidChallenge)
+	}
+
+	if _, err := provider.CreateRefreshToken("", assertion(t, provider, authenticator)); err != nil {
+		t.Errorf("CreateRefreshToken() unexpected error = %v", err)
+	}
+}
+
+func TestWebAuthnAuth_Errors(t *testing.T) {
+	t.Run("inactive user", func(t *testing.T) {
+		provider, mockStorage, authenticator := newWebAuthnProvider(t)
+		if _, err := NewUserService(mockStorage).Suspend("testuser"); err != nil {
+			t.Fatalf("Suspend() unexpected error = %v", err)
+		}
+
+		if _, _, err := provider.Login(assertion(t, provider, authenticator)); !errors.Is(err, ErrUserInactive) {
+			t.Errorf("Login() error = %v, want %v", err, ErrUserInactive)
+		}
+		if _, err := provider.BeginRegistration("testuser"); !errors.Is(err, ErrUserInactive) {
+			t.Errorf("BeginRegistration() error = %v, want %v", err, ErrUserInactive)
+		}
+	})
+
+	t.Run("unknown user", func(t *testing.T) {
+		provider, _, _ := newWebAuthnProvider(t)
+		if _, err := provider.BeginRegistration("nobody"); !errors.Is(err, storage.ErrUserNotFound) {
+			t.Errorf("BeginRegistration() error = %v, want %v", err, storage.ErrUserNotFound)
+		}
+	})
+
+	t.Run("malformed assertion", func(t *testing.T) {
+		provider, _, _ := newWebAuthnProvider(t)
+		if _, _, err := provider.Login("not json"); !errors.Is(err, webauthn.ErrInvalidResponse) {
+			t.Errorf("Login() error = %v, want %v", err, webauthn.ErrInvalidResponse)
+		}
+	})
+
+	t.Run("unregistered authenticator", func(t *testing.T) {
+		provider, _, _ := newWebAuthnProvider(t)
+		stranger := webauthntest.New("example.com", "https://example.com")
+		other := webauthn.NewRelyingParty(memory.NewInMemoryCredentialStorage(), webauthn.Config{
+			RPID:    "example.com",
+			Origins: []string{"https://example.com"},
+		})
+		options, _ := other.BeginRegistration("testuser")
+		response, _ := stranger.Register(options)
+		if _, err := other.FinishRegistration("testuser", response); err != nil {
+			t.Fatalf("FinishRegistration() unexpected error = %v", err)
+		}
+
+		if _, _, err := provider.Login(assertion(t, provider, stranger)); !errors.Is(err, webauthn.ErrUnknownCredential) {
+			t.Errorf("Login() error = %v, want %v", err, webauthn.ErrUnknownCredential)
+		}
+	})
+}
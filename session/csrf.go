@@ -0,0 +1,98 @@
+package session
+
+import (
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/responsible-api/responsible-auth/internal"
+)
+
+// ErrInvalidCSRFToken is returned when an unsafe request does not echo the
+// CSRF token of its session.
+var ErrInvalidCSRFToken = errors.New("invalid csrf token")
+
+// CSRFToken returns the CSRF token of the request's session, for embedding
+// in server-rendered forms.
+func (m *Manager) CSRFToken(r *http.Request) (string, error) {
+	cookie, err := r.Cookie(m.config.CSRFCookie)
+	if err != nil || cookie.Value == "" {
+		return "", ErrNoSession
+	}
+	return cookie.Value, nil
+}
+
+// CSRF returns middleware rejecting POST, PUT, PATCH and DELETE requests
+// that do not echo the session's CSRF token in the CSRFHeader header or the
+// CSRFField form field. The token must match the CSRF cookie and be signed
+// for the session's refresh cookie, so a token planted by a sibling subdomain
+// or taken from another session is refused.
+//
+// Requests with a bearer Authorization header and no session cookies are let
+// through, since browsers never attach that header on their own, as are
+// paths under Config.CSRFExempt.
+func (m *Manager) CSRF() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if err := m.checkCSRF(r); err != nil {
+				http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// checkCSRF validates the CSRF token of an unsafe request.
+func (m *Manager) checkCSRF(r *http.Request) error {
+	switch r.Method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+	default:
+		return nil
+	}
+
+	for _, prefix := range m.config.CSRFExempt {
+		if strings.HasPrefix(r.URL.Path, prefix) {
+			return nil
+		}
+	}
+	if m.bearerOnly(r) {
+		return nil
+	}
+
+	cookie, err := r.Cookie(m.config.CSRFCookie)
+	if err != nil || cookie.Value == "" {
+		return ErrInvalidCSRFToken
+	}
+	refresh, err := r.Cookie(m.config.RefreshCookie)
+	if err != nil || refresh.Value == "" {
+		return ErrInvalidCSRFToken
+	}
+
+	echoed := r.Header.Get(m.config.CSRFHeader)
+	if echoed == "" {
+		echoed = r.PostFormValue(m.config.CSRFField)
+	}
+
+	if subtle.ConstantTimeCompare([]byte(echoed), []byte(cookie.Value)) != 1 ||
+		!internal.VerifyCSRFToken(echoed, refresh.Value, m.options) {
+		return ErrInvalidCSRFToken
+	}
+	return nil
+}
+
+// bearerOnly reports whether the request authenticates with a bearer header
+// and carries no session cookies.
+func (m *Manager) bearerOnly(r *http.Request) bool {
+	scheme, _, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return false
+	}
+	for _, name := range []string{m.config.AccessCookie, m.config.RefreshCookie} {
+		if _, err := r.Cookie(name); err == nil {
+			return false
+		}
+	}
+	return true
+}
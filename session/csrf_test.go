@@ -0,0 +1,129 @@
+package session
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/responsible-api/responsible-auth/internal"
+	"github.com/responsible-api/responsible-auth/testutils"
+)
+
+func TestManager_CSRFCookie(t *testing.T) {
+	m, wrapper := newTestManager(t, Config{})
+	cookies := login(t, m, wrapper)
+
+	c := cookieNamed(cookies, DefaultCSRFCookie)
+	if c == nil || c.Value == "" {
+		t.Fatalf("Start() did not set %s", DefaultCSRFCookie)
+	}
+	if c.HttpOnly || !c.Secure || c.SameSite != http.SameSiteLaxMode {
+		t.Errorf("Start() %s = %+v, want a Secure, SameSite=Lax cookie readable by scripts", DefaultCSRFCookie, c)
+	}
+
+	token, err := m.CSRFToken(request(cookies...))
+	if err != nil || token != c.Value {
+		t.Errorf("CSRFToken() = %q, %v, want the cookie value", token, err)
+	}
+	if _, err := m.CSRFToken(request()); !errors.Is(err, ErrNoSession) {
+		t.Errorf("CSRFToken() without cookies error = %v, want %v", err, ErrNoSession)
+	}
+
+	rec := httptest.NewRecorder()
+	_ = m.Logout(rec, request(cookies...))
+	if c := cookieNamed(rec.Result().Cookies(), DefaultCSRFCookie); c == nil || c.MaxAge >= 0 {
+		t.Errorf("Logout() did not clear %s", DefaultCSRFCookie)
+	}
+}
+
+func TestManager_CSRF(t *testing.T) {
+	m, wrapper := newTestManager(t, Config{CSRFExempt: []string{"/webhooks/"}})
+	cookies := login(t, m, wrapper)
+	token := cookieNamed(cookies, DefaultCSRFCookie).Value
+	refresh := cookieNamed(cookies, DefaultRefreshCookie)
+
+	// A correctly signed token for a different session
+	otherSession, err := internal.CreateCSRFToken("another-refresh-token", testutils.TestAuthOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := m.CSRF()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name         string
+		method       string
+		path         string
+		cookies      []*http.Cookie
+		header       string
+		form         string
+		bearer       bool
+		expectStatus int
+	}{
+		{name: "safe method", method: http.MethodGet, cookies: cookies, expectStatus: http.StatusOK},
+		{name: "header token", method: http.MethodPost, cookies: cookies, header: token, expectStatus: http.StatusOK},
+		{name: "form token", method: http.MethodPost, cookies: cookies, form: token, expectStatus: http.StatusOK},
+		{name: "put", method: http.MethodPut, cookies: cookies, header: token, expectStatus: http.StatusOK},
+		{name: "missing token", method: http.MethodPost, cookies: cookies, expectStatus: http.StatusForbidden},
+		{name: "patch missing token", method: http.MethodPatch, cookies: cookies, expectStatus: http.StatusForbidden},
+		{name: "delete missing token", method: http.MethodDelete, cookies: cookies, expectStatus: http.StatusForbidden},
+		{name: "wrong token", method: http.MethodPost, cookies: cookies, header: token + "x", expectStatus: http.StatusForbidden},
+		{
+			name:         "token from another session",
+			method:       http.MethodPost,
+			cookies:      []*http.Cookie{refresh, {Name: DefaultCSRFCookie, Value: otherSession}},
+			header:       otherSession,
+			expectStatus: http.StatusForbidden,
+		},
+		{
+			name:         "unsigned double submit",
+			method:       http.MethodPost,
+			cookies:      []*http.Cookie{refresh, {Name: DefaultCSRFCookie, Value: "planted.value"}},
+			header:       "planted.value",
+			expectStatus: http.StatusForbidden,
+		},
+		{name: "bearer without cookies", method: http.MethodPost, bearer: true, expectStatus: http.StatusOK},
+		{name: "bearer with session cookies", method: http.MethodPost, cookies: cookies, bearer: true, expectStatus: http.StatusForbidden},
+		{name: "exempt path", method: http.MethodPost, path: "/webhooks/payment", cookies: cookies, expectStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := tt.path
+			if path == "" {
+				path = "/account"
+			}
+			var body *strings.Reader
+			if tt.form != "" {
+				body = strings.NewReader(url.Values{DefaultCSRFField: {tt.form}}.Encode())
+			} else {
+				body = strings.NewReader("")
+			}
+
+			req := httptest.NewRequest(tt.method, path, body)
+			if tt.form != "" {
+				req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			}
+			for _, c := range tt.cookies {
+				req.AddCookie(&http.Cookie{Name: c.Name, Value: c.Value})
+			}
+			if tt.header != "" {
+				req.Header.Set(DefaultCSRFHeader, tt.header)
+			}
+			if tt.bearer {
+				req.Header.Set("Authorization", "Bearer api-token")
+			}
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.expectStatus {
+				t.Errorf("CSRF() status = %d, want %d", rec.Code, tt.expectStatus)
+			}
+		})
+	}
+}
@@ -0,0 +1,522 @@
+// Package session keeps browser users logged in with cookies instead of
+// bearer tokens.
+//
+// A Manager writes the access and refresh tokens issued by a provider into
+// HttpOnly, Secure, SameSite cookies that live for AuthOptions.CookieDuration.
+// Its middleware authenticates requests from the access cookie and, once the
+// access token has expired, silently grants a new one from the refresh cookie.
+// Every login is recorded as a session in a storage.SessionStorage, so users
+// can list the devices they are logged in on and log any of them out
+// remotely. Logging out clears both cookies and deletes the session. The
+// manager checks the session before the provider redeems a session's refresh
+// token, so a copied refresh cookie stops working too, even when presented
+// straight to the provider's GrantRefreshToken.
+//
+// Because browsers send cookies with cross-site requests, the CSRF middleware
+// protects unsafe methods with signed double-submit tokens.
+package session
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/responsible-api/responsible-auth/auth"
+	"github.com/responsible-api/responsible-auth/internal"
+	"github.com/responsible-api/responsible-auth/middleware"
+	"github.com/responsible-api/responsible-auth/resource/access"
+	record "github.com/responsible-api/responsible-auth/resource/session"
+	"github.com/responsible-api/responsible-auth/storage"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Default cookie names. The __Host- prefix makes browsers insist the cookies
+// are Secure, host-only and scoped to the whole site.
+const (
+	DefaultAccessCookie  = "__Host-access_token"
+	DefaultRefreshCookie = "__Host-refresh_token"
+	DefaultCSRFCookie    = "__Host-csrf_token"
+)
+
+// DefaultCSRFHeader is the request header carrying the CSRF token.
+const DefaultCSRFHeader = "X-CSRF-Token"
+
+// DefaultCSRFField is the form field carrying the CSRF token in HTML form posts.
+const DefaultCSRFField = "csrf_token"
+
+var (
+	// ErrNoSession is returned when the request carries no usable session cookie.
+	ErrNoSession = errors.New("no session")
+
+	// ErrSessionRevoked is returned for a refresh cookie whose session has
+	// been logged out, revoked from another device or has expired.
+	ErrSessionRevoked = errors.New("session revoked")
+)
+
+// Config controls the session cookies.
+type Config struct {
+	// AccessCookie and RefreshCookie name the cookies; DefaultAccessCookie
+	// and DefaultRefreshCookie when empty
+	AccessCookie  string
+	RefreshCookie string
+
+	// CSRFCookie names the cookie holding the CSRF token, readable by scripts
+	// so they can echo it; DefaultCSRFCookie when empty
+	CSRFCookie string
+
+	// CSRFHeader and CSRFField are where the CSRF middleware looks for the
+	// echoed token; DefaultCSRFHeader and DefaultCSRFField when empty
+	CSRFHeader string
+	CSRFField  string
+
+	// CSRFExempt lists path prefixes the CSRF middleware skips, such as
+	// webhooks authenticated by other means
+	CSRFExempt []string
+
+	// Domain shares the cookies with subdomains; host-only when empty, which
+	// the default __Host- names require
+	Domain string
+
+	// SameSite restricts cross-site sending; http.SameSiteLaxMode when zero
+	SameSite http.SameSite
+
+	// Insecure drops the Secure attribute so cookies work over plain HTTP
+	// during local development. Never set it in production
+	Insecure bool
+
+	// DeviceName names the device a session is started from, as shown when
+	// listing sessions; derived from the User-Agent header when nil
+	DeviceName func(r *http.Request) string
+}
+
+// Manager runs cookie sessions for one auth provider. Each login becomes a
+// session record holding the hash of its refresh token, so a user can be
+// logged in on several devices at once and end any of them remotely.
+type Manager struct {
+	provider auth.AuthInterface
+	options  auth.AuthOptions
+	sessions storage.SessionStorage
+	config   Config
+}
+
+// NewManager creates a session manager for the wrapped provider, keeping
+// session records in the given storage. The manager becomes the provider's
+// RefreshChecker, so the provider refuses the refresh tokens of ended sessions.
+func NewManager(a *auth.AuthWrapper, sessions storage.SessionStorage, config Config) *Manager {
+	if config.AccessCookie == "" {
+		config.AccessCookie = DefaultAccessCookie
+	}
+	if config.RefreshCookie == "" {
+		config.RefreshCookie = DefaultRefreshCookie
+	}
+	if config.CSRFCookie == "" {
+		config.CSRFCookie = DefaultCSRFCookie
+	}
+	if config.CSRFHeader == "" {
+		config.CSRFHeader = DefaultCSRFHeader
+	}
+	if config.CSRFField == "" {
+		config.CSRFField = DefaultCSRFField
+	}
+	if config.SameSite == 0 {
+		config.SameSite = http.SameSiteLaxMode
+	}
+	if config.DeviceName == nil {
+		config.DeviceName = func(r *http.Request) string {
+			return DeviceName(r.UserAgent())
+		}
+	}
+	m := &Manager{
+		provider: a.Provider,
+		options:  a.Options,
+		sessions: sessions,
+		config:   config,
+	}
+
+	options := a.Provider.Options()
+	options.RefreshChecker = m
+	a.Provider.SetOptions(options)
+	return m
+}
+
+// Start begins a session with the tokens from a successful login, recording
+// the device and client of the login request r and writing the token and
+// CSRF cookies. The refresh cookie holds a copy of refreshToken bound to the
+// session; the original is not checked against the session store and should
+// be discarded.
+func (m *Manager) Start(w http.ResponseWriter, r *http.Request, accessToken, refreshToken *access.RToken) error {
+	refreshToken, err := internal.SessionRefreshToken(refreshToken.GetToken(), m.options)
+	if err != nil {
+		return err
+	}
+	claims, err := internal.ParseRefreshToken(refreshToken.GetToken(), m.options)
+	if err != nil {
+		return err
+	}
+	userName, _ := claims["username"].(string)
+	expiresAt, err := claims.GetExpirationTime()
+	if err != nil || expiresAt == nil {
+		return ErrNoSession
+	}
+
+	m.pruneExpired(userName)
+
+	now := time.Now().Unix()
+	client := auth.ClientInfoFromRequest(r)
+	err = m.sessions.CreateSession(&record.Session{
+		ID:         sessionID(refreshToken.GetToken()),
+		UserName:   userName,
+		DeviceName: m.config.DeviceName(r),
+		UserAgent:  client.UserAgent,
+		IP:         client.IP,
+		Created:    now,
+		LastUsed:   now,
+		ExpiresAt:  expiresAt.Unix(),
+	})
+	if err != nil {
+		return err
+	}
+
+	csrfToken, err := internal.CreateCSRFToken(refreshToken.GetToken(), m.options)
+	if err != nil {
+		return err
+	}
+
+	m.setCookie(w, m.config.AccessCookie, accessToken.GetToken())
+	m.setCookie(w, m.config.RefreshCookie, refreshToken.GetToken())
+	m.setCSRFCookie(w, csrfToken)
+	return nil
+}
+
+// Authenticate returns middleware that rejects requests without a valid
+// session and stores the validated access token in the request context, where
+// middleware.ClaimsFromContext finds it. An expired or missing access cookie
+// is replaced from the refresh cookie without interrupting the request.
+// The session record is checked on every request, so a session revoked from
+// another device is rejected at once rather than when its access token expires.
+func (m *Manager) Authenticate() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if _, err := m.Current(r); err != nil {
+				if errors.Is(err, ErrSessionRevoked) {
+					m.clear(w)
+				}
+				unauthorized(w)
+				return
+			}
+
+			token, err := m.validate(r)
+			if err != nil {
+				token, err = m.Refresh(w, r)
+			}
+			if err != nil {
+				unauthorized(w)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(middleware.WithToken(r.Context(), token)))
+		})
+	}
+}
+
+// Refresh grants a new access token from the refresh cookie, writes it to
+// the access cookie and returns it validated. The refresh token is redeemed
+// once: the session continues under a new refresh token, written to the
+// refresh cookie with a new CSRF token, and the old one is refused from then
+// on. The session's last use and client IP are updated.
+func (m *Manager) Refresh(w http.ResponseWriter, r *http.Request) (*jwt.Token, error) {
+	current, err := m.Current(r)
+	if err != nil {
+		if errors.Is(err, ErrSessionRevoked) {
+			m.clear(w)
+		}
+		return nil, err
+	}
+
+	cookie, _ := r.Cookie(m.config.RefreshCookie)
+	accessToken, err := m.provider.GrantRefreshToken(cookie.Value)
+	if err != nil {
+		m.clear(w)
+		return nil, err
+	}
+	token, err := m.provider.Validate(accessToken.GetToken())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.rotate(w, r, current, cookie.Value); err != nil {
+		m.clear(w)
+		return nil, err
+	}
+
+	m.setCookie(w, m.config.AccessCookie, accessToken.GetToken())
+	return token, nil
+}
+
+// rotate continues the session, whose refresh token was just redeemed, under
+// a new refresh token and writes the refresh and CSRF cookies for it.
+func (m *Manager) rotate(w http.ResponseWriter, r *http.Request, current *record.Session, refreshToken string) error {
+	rotated, err := internal.RotateRefreshToken(refreshToken, m.options)
+	if err != nil {
+		return err
+	}
+
+	next := *current
+	next.ID = sessionID(rotated.GetToken())
+	next.LastUsed = time.Now().Unix()
+	next.IP = auth.ClientInfoFromRequest(r).IP
+	if err := m.sessions.CreateSession(&next); err != nil {
+		return err
+	}
+
+	csrfToken, err := internal.CreateCSRFToken(rotated.GetToken(), m.options)
+	if err != nil {
+		return err
+	}
+
+	m.setCookie(w, m.config.RefreshCookie, rotated.GetToken())
+	m.setCSRFCookie(w, csrfToken)
+	return nil
+}
+
+// Logout clears the session and CSRF cookies and deletes the session.
+// The cookies are cleared even when the session was already gone.
+func (m *Manager) Logout(w http.ResponseWriter, r *http.Request) error {
+	m.clear(w)
+
+	cookie, err := r.Cookie(m.config.RefreshCookie)
+	if err != nil || cookie.Value == "" {
+		return ErrNoSession
+	}
+
+	s, err := m.sessions.FindSession(sessionID(cookie.Value))
+	if err == nil {
+		err = m.sessions.DeleteSession(s.ID)
+	}
+	if errors.Is(err, storage.ErrSessionNotFound) {
+		// Already logged out or revoked from another device
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	m.auditRevoke(auth.ClientInfoFromRequest(r), s.UserName, "logout")
+	return nil
+}
+
+// Current returns the session of the request's refresh cookie. It returns
+// ErrNoSession without a refresh cookie and ErrSessionRevoked when the
+// session no longer exists or has expired.
+func (m *Manager) Current(r *http.Request) (*record.Session, error) {
+	cookie, err := r.Cookie(m.config.RefreshCookie)
+	if err != nil || cookie.Value == "" {
+		return nil, ErrNoSession
+	}
+
+	return m.find(cookie.Value)
+}
+
+// CheckRefresh implements auth.RefreshChecker. It returns ErrSessionRevoked
+// for a session's refresh token once the session no longer exists or has
+// expired; refresh tokens issued outside a session are left to the provider.
+// Otherwise the token is revoked as it is redeemed, so a copy cannot be
+// replayed; Refresh continues the session under a new one. Of two concurrent
+// refreshes of a session only the first succeeds.
+func (m *Manager) CheckRefresh(refreshToken string, claims jwt.MapClaims) error {
+	if !internal.IsSessionRefreshToken(claims) {
+		return nil
+	}
+	current, err := m.find(refreshToken)
+	if err != nil {
+		return err
+	}
+	err = m.sessions.DeleteSession(current.ID)
+	if errors.Is(err, storage.ErrSessionNotFound) {
+		return ErrSessionRevoked
+	}
+	return err
+}
+
+// find returns the unexpired session holding the refresh token.
+func (m *Manager) find(refreshToken string) (*record.Session, error) {
+	current, err := m.sessions.FindSession(sessionID(refreshToken))
+	if errors.Is(err, storage.ErrSessionNotFound) {
+		return nil, ErrSessionRevoked
+	}
+	if err != nil {
+		return nil, err
+	}
+	if current.IsExpired(time.Now().Unix()) {
+		return nil, ErrSessionRevoked
+	}
+	return current, nil
+}
+
+// Sessions lists the named user's unexpired sessions, oldest first.
+func (m *Manager) Sessions(userName string) ([]*record.Session, error) {
+	all, err := m.sessions.FindSessionsByUser(userName)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().Unix()
+	active := make([]*record.Session, 0, len(all))
+	for _, s := range all {
+		if !s.IsExpired(now) {
+			active = append(active, s)
+		}
+	}
+	return active, nil
+}
+
+// Revoke logs the named user out of the session with the given ID. The
+// session's refresh token is refused from then on, whether it reaches the
+// manager or the provider's GrantRefreshToken. Sessions of other users are
+// reported as storage.ErrSessionNotFound, so a user cannot probe for, or end,
+// someone else's session.
+func (m *Manager) Revoke(userName, id string) error {
+	s, err := m.sessions.FindSession(id)
+	if err != nil {
+		return err
+	}
+	if s.UserName != userName {
+		return storage.ErrSessionNotFound
+	}
+	if err := m.sessions.DeleteSession(id); err != nil {
+		return err
+	}
+
+	m.auditRevoke(auth.ClientInfo{}, userName, "session revoked")
+	return nil
+}
+
+// RevokeOthers logs the user out of every session except the request's own
+// and returns how many sessions were ended.
+func (m *Manager) RevokeOthers(r *http.Request) (int, error) {
+	current, err := m.Current(r)
+	if err != nil {
+		return 0, err
+	}
+	n, err := m.sessions.DeleteSessionsByUser(current.UserName, current.ID)
+	if err != nil {
+		return n, err
+	}
+
+	m.auditRevoke(auth.ClientInfoFromRequest(r), current.UserName, "other sessions revoked")
+	return n, nil
+}
+
+// RevokeAll logs the named user out of every session, for example after a
+// password change, and returns how many sessions were ended.
+func (m *Manager) RevokeAll(userName string) (int, error) {
+	n, err := m.sessions.DeleteSessionsByUser(userName, "")
+	if err != nil {
+		return n, err
+	}
+
+	m.auditRevoke(auth.ClientInfo{}, userName, "all sessions revoked")
+	return n, nil
+}
+
+// auditRevoke records the end of the user's sessions with the options' AuditSink.
+func (m *Manager) auditRevoke(client auth.ClientInfo, userName, reason string) {
+	err := m.options.Audit(auth.AuditEvent{
+		Type:      auth.EventRevoke,
+		Success:   true,
+		Subject:   userName,
+		IP:        client.IP,
+		UserAgent: client.UserAgent,
+		Reason:    reason,
+	})
+	if err != nil {
+		m.options.Log().Error("failed to record audit event", "type", auth.EventRevoke, "subject", userName, "error", err)
+	}
+}
+
+// validate checks the access cookie.
+func (m *Manager) validate(r *http.Request) (*jwt.Token, error) {
+	cookie, err := r.Cookie(m.config.AccessCookie)
+	if err != nil || cookie.Value == "" {
+		return nil, ErrNoSession
+	}
+
+	token, err := m.provider.Validate(cookie.Value)
+	if err != nil || token == nil {
+		return nil, ErrNoSession
+	}
+	return token, nil
+}
+
+// pruneExpired deletes the user's expired sessions. Failures are ignored as
+// expired sessions are rejected and hidden from listings anyway.
+func (m *Manager) pruneExpired(userName string) {
+	all, err := m.sessions.FindSessionsByUser(userName)
+	if err != nil {
+		return
+	}
+	now := time.Now().Unix()
+	for _, s := range all {
+		if s.IsExpired(now) {
+			_ = m.sessions.DeleteSession(s.ID)
+		}
+	}
+}
+
+// setCookie writes a session cookie lasting CookieDuration, or until the
+// browser closes when CookieDuration is zero.
+func (m *Manager) setCookie(w http.ResponseWriter, name, value string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		Domain:   m.config.Domain,
+		MaxAge:   int(m.options.CookieDuration.Seconds()),
+		Secure:   !m.config.Insecure,
+		HttpOnly: true,
+		SameSite: m.config.SameSite,
+	})
+}
+
+// setCSRFCookie writes the CSRF token. Unlike the token cookies it is not
+// HttpOnly, because scripts must read it to send it back in a header.
+func (m *Manager) setCSRFCookie(w http.ResponseWriter, value string) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     m.config.CSRFCookie,
+		Value:    value,
+		Path:     "/",
+		Domain:   m.config.Domain,
+		MaxAge:   int(m.options.CookieDuration.Seconds()),
+		Secure:   !m.config.Insecure,
+		SameSite: m.config.SameSite,
+	})
+}
+
+// clear expires the session and CSRF cookies.
+func (m *Manager) clear(w http.ResponseWriter) {
+	for _, name := range []string{m.config.AccessCookie, m.config.RefreshCookie, m.config.CSRFCookie} {
+		http.SetCookie(w, &http.Cookie{
+			Name:     name,
+			Path:     "/",
+			Domain:   m.config.Domain,
+			MaxAge:   -1,
+			Secure:   !m.config.Insecure,
+			HttpOnly: name != m.config.CSRFCookie,
+			SameSite: m.config.SameSite,
+		})
+	}
+}
+
+// sessionID identifies the session holding a refresh token. Only the hash is
+// stored, so the storage never holds a usable token.
+func sessionID(refreshToken string) string {
+	sum := sha256.Sum256([]byte(refreshToken))
+	return hex.EncodeToString(sum[:])
+}
+
+func unauthorized(w http.ResponseWriter) {
+	http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+}
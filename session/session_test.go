@@ -0,0 +1,392 @@
+package session
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/responsible-api/responsible-auth/auth"
+	"github.com/responsible-api/responsible-auth/examples/memory"
+	"github.com/responsible-api/responsible-auth/internal"
+	"github.com/responsible-api/responsible-auth/middleware"
+	"github.com/responsible-api/responsible-auth/resource/access"
+	"github.com/responsible-api/responsible-auth/service"
+	"github.com/responsible-api/responsible-auth/storage"
+	"github.com/responsible-api/responsible-auth/testutils"
+)
+
+const (
+	windowsChrome = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Safari/537.36"
+	iPhoneSafari  = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_5 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.5 Mobile/15E148 Safari/604.1"
+)
+
+func newTestManager(t *testing.T, config Config) (*Manager, *auth.AuthWrapper) {
+	t.Helper()
+	storage := memory.NewInMemoryStorageWithUsers(testutils.TestUser())
+	wrapper := auth.NewAuth(service.NewBasicAuth(), storage, testutils.TestAuthOptions())
+	return NewManager(wrapper, memory.NewInMemorySessionStorage(), config), wrapper
+}
+
+// login issues tokens for the test user and starts a session, returning its cookies.
+func login(t *testing.T, m *Manager, wrapper *auth.AuthWrapper) []*http.Cookie {
+	t.Helper()
+	return loginFrom(t, m, wrapper, windowsChrome)
+}
+
+// loginFrom logs the test user in from a browser with the given user agent.
+func loginFrom(t *testing.T, m *Manager, wrapper *auth.AuthWrapper, userAgent string) []*http.Cookie {
+	t.Helper()
+	user := testutils.TestUser()
+	accessToken, err := wrapper.Provider.CreateAccessToken(user.Mail, user.Secret)
+	if err != nil {
+		t.Fatalf("CreateAccessToken() unexpected error = %v", err)
+	}
+	refreshToken, err := wrapper.Provider.CreateRefreshToken(user.Mail, user.Secret)
+	if err != nil {
+		t.Fatalf("CreateRefreshToken() unexpected error = %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/login", nil)
+	req.Header.Set("User-Agent", userAgent)
+	rec := httptest.NewRecorder()
+	if err := m.Start(rec, req, accessToken, refreshToken); err != nil {
+		t.Fatalf("Start() unexpected error = %v", err)
+	}
+	return rec.Result().Cookies()
+}
+
+func request(cookies ...*http.Cookie) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	for _, c := range cookies {
+		req.AddCookie(&http.Cookie{Name: c.Name, Value: c.Value})
+	}
+	return req
+}
+
+// rotated returns the cookies with those a response wrote replacing them.
+func rotated(cookies []*http.Cookie, rec *httptest.ResponseRecorder) []*http.Cookie {
+	written := rec.Result().Cookies()
+	next := append([]*http.Cookie(nil), written...)
+	for _, c := range cookies {
+		if cookieNamed(written, c.Name) == nil {
+			next = append(next, c)
+		}
+	}
+	return next
+}
+
+func cookieNamed(cookies []*http.Cookie, name string) *http.Cookie {
+	for _, c := range cookies {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+func TestManager_Start(t *testing.T) {
+	m, wrapper := newTestManager(t, Config{})
+	cookies := login(t, m, wrapper)
+
+	for _, name := range []string{DefaultAccessCookie, DefaultRefreshCookie} {
+		c := cookieNamed(cookies, name)
+		if c == nil {
+			t.Fatalf("Start() did not set %s", name)
+		}
+		if !c.HttpOnly || !c.Secure || c.SameSite != http.SameSiteLaxMode || c.Path != "/" {
+			t.Errorf("Start() %s = %+v, want HttpOnly, Secure, SameSite=Lax and Path=/", name, c)
+		}
+		if want := int(testutils.TestAuthOptions().CookieDuration.Seconds()); c.MaxAge != want {
+			t.Errorf("Start() %s MaxAge = %d, want CookieDuration %d", name, c.MaxAge, want)
+		}
+	}
+
+	insecure, wrapper := newTestManager(t, Config{AccessCookie: "access", RefreshCookie: "refresh", Insecure: true, SameSite: http.SameSiteStrictMode})
+	cookies = login(t, insecure, wrapper)
+	if c := cookieNamed(cookies, "access"); c == nil || c.Secure || c.SameSite != http.SameSiteStrictMode {
+		t.Errorf("Start() with Insecure and Strict = %+v, want a Strict cookie without Secure", c)
+	}
+}
+
+func TestManager_Authenticate(t *testing.T) {
+	m, wrapper := newTestManager(t, Config{})
+	cookies := login(t, m, wrapper)
+	accessCookie := cookieNamed(cookies, DefaultAccessCookie)
+	refreshCookie := cookieNamed(cookies, DefaultRefreshCookie)
+	// Refreshing redeems the refresh cookie, so each refresh case needs its own session
+	otherRefreshCookie := cookieNamed(login(t, m, wrapper), DefaultRefreshCookie)
+
+	expiredOptions := testutils.TestAuthOptions()
+	expiredOptions.TokenDuration = -time.Hour
+	expired, err := internal.CreateAccessToken(expiredOptions)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expiredCookie := &http.Cookie{Name: DefaultAccessCookie, Value: expired.GetToken()}
+
+	var accountID uint64
+	handler := m.Authenticate()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		claims, ok := middleware.ClaimsFromContext(r.Context())
+		if !ok {
+			t.Errorf("ClaimsFromContext() found no claims")
+			return
+		}
+		accountID = claims.AccountID
+	}))
+
+	tests := []struct {
+		name          string
+		cookies       []*http.Cookie
+		expectStatus  int
+		expectRefresh bool
+	}{
+		{name: "access cookie", cookies: []*http.Cookie{accessCookie, refreshCookie}, expectStatus: http.StatusOK},
+		{name: "expired access cookie", cookies: []*http.Cookie{expiredCookie, refreshCookie}, expectStatus: http.StatusOK, expectRefresh: true},
+		{name: "refresh cookie only", cookies: []*http.Cookie{otherRefreshCookie}, expectStatus: http.StatusOK, expectRefresh: true},
+		{name: "expired access cookie only", cookies: []*http.Cookie{expiredCookie}, expectStatus: http.StatusUnauthorized},
+		{name: "redeemed refresh cookie", cookies: []*http.Cookie{expiredCookie, refreshCookie}, expectStatus: http.StatusUnauthorized},
+		{name: "access cookie only", cookies: []*http.Cookie{accessCookie}, expectStatus: http.StatusUnauthorized},
+		{name: "no cookies", expectStatus: http.StatusUnauthorized},
+		{name: "forged refresh cookie", cookies: []*http.Cookie{{Name: DefaultRefreshCookie, Value: "not-a-token"}}, expectStatus: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			accountID = 0
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, request(tt.cookies...))
+
+			if rec.Code != tt.expectStatus {
+				t.Fatalf("Authenticate() status = %d, want %d", rec.Code, tt.expectStatus)
+			}
+			if tt.expectStatus == http.StatusOK && accountID != testutils.TestUser().AccountID {
+				t.Errorf("token account_id = %d, want %d", accountID, testutils.TestUser().AccountID)
+			}
+
+			refreshed := cookieNamed(rec.Result().Cookies(), DefaultAccessCookie)
+			if tt.expectRefresh {
+				if refreshed == nil || refreshed.Value == "" {
+					t.Fatalf("Authenticate() did not write a refreshed access cookie")
+				}
+				if _, err := wrapper.Provider.Validate(refreshed.Value); err != nil {
+					t.Errorf("refreshed access cookie is invalid: %v", err)
+				}
+				rotatedCookie := cookieNamed(rec.Result().Cookies(), DefaultRefreshCookie)
+				if rotatedCookie == nil || rotatedCookie.Value == "" || rotatedCookie.Value == refreshCookie.Value {
+					t.Errorf("Authenticate() did not rotate the refresh cookie")
+				} else if _, err := m.Current(request(rotatedCookie)); err != nil {
+					t.Errorf("Current() with the rotated refresh cookie error = %v", err)
+				}
+			} else if tt.expectStatus == http.StatusOK && refreshed != nil {
+				t.Errorf("Authenticate() rewrote a valid access cookie")
+			}
+		})
+	}
+}
+
+func TestManager_Logout(t *testing.T) {
+	m, wrapper := newTestManager(t, Config{})
+	cookies := login(t, m, wrapper)
+	refreshCookie := cookieNamed(cookies, DefaultRefreshCookie)
+
+	rec := httptest.NewRecorder()
+	if err := m.Logout(rec, request(cookies...)); err != nil {
+		t.Fatalf("Logout() unexpected error = %v", err)
+	}
+	for _, name := range []string{DefaultAccessCookie, DefaultRefreshCookie} {
+		if c := cookieNamed(rec.Result().Cookies(), name); c == nil || c.MaxAge >= 0 || c.Value != "" {
+			t.Errorf("Logout() %s = %+v, want an expired empty cookie", name, c)
+		}
+	}
+
+	// A copy of the refresh cookie no longer works
+	if _, err := m.Refresh(httptest.NewRecorder(), request(refreshCookie)); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("Refresh() after Logout() error = %v, want %v", err, ErrSessionRevoked)
+	}
+	if _, err := wrapper.Provider.GrantRefreshToken(refreshCookie.Value); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("GrantRefreshToken() after Logout() error = %v, want %v", err, ErrSessionRevoked)
+	}
+
+	// A provider the manager is not checking refuses session tokens outright
+	other := auth.NewAuth(service.NewBasicAuth(), memory.NewInMemoryStorageWithUsers(testutils.TestUser()), testutils.TestAuthOptions())
+	if _, err := other.Provider.GrantRefreshToken(refreshCookie.Value); !errors.Is(err, service.ErrSessionRefreshToken) {
+		t.Errorf("GrantRefreshToken() without a RefreshChecker error = %v, want %v", err, service.ErrSessionRefreshToken)
+	}
+
+	if err := m.Logout(httptest.NewRecorder(), request()); !errors.Is(err, ErrNoSession) {
+		t.Errorf("Logout() without cookies error = %v, want %v", err, ErrNoSession)
+	}
+}
+
+func TestManager_Sessions(t *testing.T) {
+	m, wrapper := newTestManager(t, Config{})
+	laptop := loginFrom(t, m, wrapper, windowsChrome)
+	phone := loginFrom(t, m, wrapper, iPhoneSafari)
+	userName := testutils.TestUser().Name
+
+	sessions, err := m.Sessions(userName)
+	if err != nil {
+		t.Fatalf("Sessions() unexpected error = %v", err)
+	}
+	devices := map[string]bool{}
+	for _, s := range sessions {
+		devices[s.DeviceName] = true
+		if s.UserAgent == "" || s.IP == "" || s.Created == 0 || s.ExpiresAt <= s.Created {
+			t.Errorf("Sessions() record = %+v, want the client and times filled in", s)
+		}
+	}
+	if len(sessions) != 2 || !devices["Chrome on Windows"] || !devices["Safari on iPhone"] {
+		t.Fatalf("Sessions() = %+v, want the laptop and phone sessions", sessions)
+	}
+
+	// Both sessions refresh independently, recording the client's address
+	refresh := request(cookieNamed(phone, DefaultRefreshCookie))
+	refresh.RemoteAddr = "198.51.100.7:4711"
+	rec := httptest.NewRecorder()
+	if _, err := m.Refresh(rec, refresh); err != nil {
+		t.Fatalf("Refresh() phone unexpected error = %v", err)
+	}
+	phone = rotated(phone, rec)
+	current, err := m.Current(request(phone...))
+	if err != nil {
+		t.Fatalf("Current() unexpected error = %v", err)
+	}
+	if current.IP != "198.51.100.7" || current.DeviceName != "Safari on iPhone" {
+		t.Errorf("Current() after Refresh() = %+v, want the phone session used from 198.51.100.7", current)
+	}
+	rec = httptest.NewRecorder()
+	if _, err := m.Refresh(rec, request(cookieNamed(laptop, DefaultRefreshCookie))); err != nil {
+		t.Fatalf("Refresh() laptop unexpected error = %v", err)
+	}
+	laptop = rotated(laptop, rec)
+
+	// Another user cannot end the phone session
+	if err := m.Revoke("someone-else", current.ID); !errors.Is(err, storage.ErrSessionNotFound) {
+		t.Errorf("Revoke() by another user error = %v, want %v", err, storage.ErrSessionNotFound)
+	}
+
+	// Logging out everywhere else from the laptop ends the phone session at once,
+	// although its access token has not expired
+	revoked, err := m.RevokeOthers(request(laptop...))
+	if err != nil || revoked != 1 {
+		t.Fatalf("RevokeOthers() = %d, %v, want 1", revoked, err)
+	}
+	handler := m.Authenticate()(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for name, cookies := range map[string][]*http.Cookie{"laptop": laptop, "phone": phone} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, request(cookies...))
+		want := http.StatusOK
+		if name == "phone" {
+			want = http.StatusUnauthorized
+		}
+		if rec.Code != want {
+			t.Errorf("Authenticate() %s status = %d, want %d", name, rec.Code, want)
+		}
+	}
+	// Nor can a copy of the phone's refresh cookie be redeemed with the provider
+	if _, err := wrapper.Provider.GrantRefreshToken(cookieNamed(phone, DefaultRefreshCookie).Value); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("GrantRefreshToken() after RevokeOthers() error = %v, want %v", err, ErrSessionRevoked)
+	}
+
+	laptopSession, err := m.Current(request(laptop...))
+	if err != nil {
+		t.Fatalf("Current() laptop unexpected error = %v", err)
+	}
+	if err := m.Revoke(userName, laptopSession.ID); err != nil {
+		t.Fatalf("Revoke() unexpected error = %v", err)
+	}
+	if _, err := m.Current(request(laptop...)); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("Current() after Revoke() error = %v, want %v", err, ErrSessionRevoked)
+	}
+	if _, err := wrapper.Provider.GrantRefreshToken(cookieNamed(laptop, DefaultRefreshCookie).Value); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("GrantRefreshToken() after Revoke() error = %v, want %v", err, ErrSessionRevoked)
+	}
+
+	laptop = loginFrom(t, m, wrapper, windowsChrome)
+	phone = loginFrom(t, m, wrapper, iPhoneSafari)
+	if _, err := wrapper.Provider.GrantRefreshToken(cookieNamed(laptop, DefaultRefreshCookie).Value); err != nil {
+		t.Fatalf("GrantRefreshToken() of a live session unexpected error = %v", err)
+	}
+	// Redeeming a session's refresh token revokes it, even outside the manager
+	if _, err := wrapper.Provider.GrantRefreshToken(cookieNamed(laptop, DefaultRefreshCookie).Value); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("GrantRefreshToken() replayed error = %v, want %v", err, ErrSessionRevoked)
+	}
+	if revoked, err := m.RevokeAll(userName); err != nil || revoked != 1 {
+		t.Errorf("RevokeAll() = %d, %v, want 1", revoked, err)
+	}
+	if _, err := wrapper.Provider.GrantRefreshToken(cookieNamed(phone, DefaultRefreshCookie).Value); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("GrantRefreshToken() after RevokeAll() error = %v, want %v", err, ErrSessionRevoked)
+	}
+	if sessions, _ := m.Sessions(userName); len(sessions) != 0 {
+		t.Errorf("Sessions() after RevokeAll() = %+v, want none", sessions)
+	}
+}
+
+func TestManager_ExpiredSession(t *testing.T) {
+	m, wrapper := newTestManager(t, Config{})
+	cookies := login(t, m, wrapper)
+
+	current, err := m.Current(request(cookies...))
+	if err != nil {
+		t.Fatalf("Current() unexpected error = %v", err)
+	}
+	expired := *current
+	expired.ExpiresAt = time.Now().Add(-time.Minute).Unix()
+	if err := m.sessions.DeleteSession(current.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.sessions.CreateSession(&expired); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m.Refresh(httptest.NewRecorder(), request(cookies...)); !errors.Is(err, ErrSessionRevoked) {
+		t.Errorf("Refresh() expired session error = %v, want %v", err, ErrSessionRevoked)
+	}
+	if sessions, _ := m.Sessions(testutils.TestUser().Name); len(sessions) != 0 {
+		t.Errorf("Sessions() = %+v, want expired sessions hidden", sessions)
+	}
+
+	// The next login removes the expired record
+	login(t, m, wrapper)
+	if _, err := m.sessions.FindSession(expired.ID); !errors.Is(err, storage.ErrSessionNotFound) {
+		t.Errorf("FindSession() expired session error = %v, want %v", err, storage.ErrSessionNotFound)
+	}
+}
+
+func TestDeviceName(t *testing.T) {
+	tests := []struct {
+		userAgent string
+		want      string
+	}{
+		{windowsChrome, "Chrome on Windows"},
+		{iPhoneSafari, "Safari on iPhone"},
+		{"Mozilla/5.0 (X11; Linux x86_64; rv:128.0) Gecko/20100101 Firefox/128.0", "Firefox on Linux"},
+		{"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Safari/537.36 Edg/126.0.0.0", "Edge on macOS"},
+		{"Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0.0.0 Mobile Safari/537.36", "Chrome on Android"},
+		{"curl/8.5.0", "curl/8.5.0"},
+		{"", "Unknown device"},
+	}
+
+	for _, tt := range tests {
+		if got := DeviceName(tt.userAgent); got != tt.want {
+			t.Errorf("DeviceName(%q) = %q, want %q", tt.userAgent, got, tt.want)
+		}
+	}
+}
+
+func TestManager_StartRejectsForeignToken(t *testing.T) {
+	m, _ := newTestManager(t, Config{})
+
+	other := testutils.TestAuthOptions()
+	other.SecretKey = "another-secret-key-32-characters"
+	refreshToken, err := internal.CreateRefreshToken(testutils.TestUser().Name, other)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.Start(httptest.NewRecorder(), request(), &access.RToken{}, refreshToken); err == nil {
+		t.Errorf("Start() accepted a refresh token signed with another key")
+	}
+}
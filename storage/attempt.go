@@ -0,0 +1,17 @@
+package storage
+
+import "github.com/responsible-api/responsible-auth/resource/attempt"
+
+// AttemptStorage persists failed login attempts for brute-force protection.
+// Implementations must be safe for concurrent use.
+type AttemptStorage interface {
+	// FindAttempt returns the record for key, or nil when no failures are tracked
+	FindAttempt(key string) (*attempt.Attempt, error)
+
+	// UpdateAttempt atomically loads the record for key (an empty record when none
+	// exists), applies update to it and stores the result
+	UpdateAttempt(key string, update func(a *attempt.Attempt)) (*attempt.Attempt, error)
+
+	// DeleteAttempt removes the record for key
+	DeleteAttempt(key string) error
+}
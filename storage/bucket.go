@@ -0,0 +1,15 @@
+package storage
+
+import "github.com/responsible-api/responsible-auth/resource/bucket"
+
+// BucketStorage persists token buckets for rate limiting, keyed by account or
+// by OAuth client. Implementations must be safe for concurrent use.
+type BucketStorage interface {
+	// UpdateBucket atomically loads the bucket with the key (a new, empty
+	// bucket when none exists), applies update to it and stores the result.
+	// Implementations may return ErrUserNotFound for accounts without users
+	UpdateBucket(key bucket.Key, update func(b *bucket.Bucket)) (*bucket.Bucket, error)
+
+	// DeleteBucket removes the bucket with the key, refilling it on next use
+	DeleteBucket(key bucket.Key) error
+}
@@ -0,0 +1,62 @@
+package storage
+
+import "errors"
+
+// Errors returned by storage implementations so callers can tell outcomes apart
+// regardless of the backend in use.
+var (
+	// ErrUserNotFound is returned when no user matches the lookup.
+	ErrUserNotFound = errors.New("user not found")
+
+	// ErrInvalidCredentials is returned when a user matches the login
+	// identifier but the secret does not.
+	ErrInvalidCredentials = errors.New("invalid credentials")
+
+	// ErrUserExists is returned when creating a user whose name or mail is taken.
+	ErrUserExists = errors.New("user already exists")
+
+	// ErrClientNotFound is returned when no OAuth client matches the client ID.
+	ErrClientNotFound = errors.New("client not found")
+
+	// ErrClientExists is returned when registering a client whose ID is taken.
+	ErrClientExists = errors.New("client already exists")
+
+	// ErrCodeNotFound is returned when an authorization code is unknown or already used.
+	ErrCodeNotFound = errors.New("authorization code not found")
+
+	// ErrDeviceCodeNotFound is returned when a device or user code is unknown or already used.
+	ErrDeviceCodeNotFound = errors.New("device code not found")
+
+	// ErrEnrolmentNotFound is returned when a user has no MFA enrolment.
+	ErrEnrolmentNotFound = errors.New("mfa enrolment not found")
+
+	// ErrEnrolmentExists is returned when creating an MFA enrolment for a user who has one.
+	ErrEnrolmentExists = errors.New("mfa enrolment already exists")
+
+	// ErrCredentialNotFound is returned when no WebAuthn credential matches the ID.
+	ErrCredentialNotFound = errors.New("credential not found")
+
+	// ErrCredentialExists is returned when registering a credential whose ID is taken.
+	ErrCredentialExists = errors.New("credential already exists")
+
+	// ErrChallengeNotFound is returned when a WebAuthn challenge is unknown or already used.
+	ErrChallengeNotFound = errors.New("challenge not found")
+
+	// ErrSessionNotFound is returned when no login session matches the ID.
+	ErrSessionNotFound = errors.New("session not found")
+
+	// ErrSessionExists is returned when creating a session whose ID is taken.
+	ErrSessionExists = errors.New("session already exists")
+
+	// ErrRoleNotFound is returned when no role matches the name.
+	ErrRoleNotFound = errors.New("role not found")
+
+	// ErrRoleExists is returned when creating a role whose name is taken.
+	ErrRoleExists = errors.New("role already exists")
+
+	// ErrPermissionNotFound is returned when revoking a permission the role does not have.
+	ErrPermissionNotFound = errors.New("permission not found")
+
+	// ErrAssignmentNotFound is returned when unassigning a role the user does not have.
+	ErrAssignmentNotFound = errors.New("role assignment not found")
+)
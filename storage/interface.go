@@ -8,7 +8,10 @@ import "github.com/responsible-api/responsible-auth/resource/user"
 type UserStorage interface {
 	// FindUserByCredentials retrieves a user by username/email and validates their credentials
 	// username can be either email or account_id
-	// credentials is the hash/secret/password depending on auth method
+	// credentials is the plaintext secret, checked with user.CheckSecret so both
+	// hashed and legacy plaintext secrets are supported
+	// Returns ErrUserNotFound when no user has that identifier and
+	// ErrInvalidCredentials when the secret does not match
 	FindUserByCredentials(username, credentials string) (*user.User, error)
 
 	// FindUserByAPIKey retrieves a user by their API key
@@ -19,4 +22,28 @@ type UserStorage interface {
 
 	// ValidateRefreshToken checks if a refresh token is valid for a user
 	ValidateRefreshToken(refreshToken string) (*user.User, error)
+
+	// FindUserByName retrieves a user by their unique name without checking credentials
+	// Returns ErrUserNotFound when no user has that name
+	FindUserByName(name string) (*user.User, error)
+
+	// FindUserByMail retrieves a user by their mail address without checking credentials
+	// Returns ErrUserNotFound when no user has that mail
+	FindUserByMail(mail string) (*user.User, error)
+
+	// CreateUser stores a new user
+	// Returns ErrUserExists when the name or mail is already taken
+	CreateUser(u *user.User) error
+
+	// UpdateUser replaces the stored profile, status and secrets of the user with u.Name
+	// Returns ErrUserNotFound when no user has that name
+	UpdateUser(u *user.User) error
+
+	// UpdateAccess records the unix time of the user's last successful authentication
+	// Returns ErrUserNotFound when no user has that name
+	UpdateAccess(name string, access uint64) error
+
+	// DeleteUser permanently removes the user with the given name
+	// Returns ErrUserNotFound when no user has that name
+	DeleteUser(name string) error
 }
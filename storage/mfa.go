@@ -0,0 +1,20 @@
+package storage
+
+import "github.com/responsible-api/responsible-auth/resource/totp"
+
+// MFAStorage persists TOTP enrolments for multi-factor authentication.
+// Implementations must be safe for concurrent use.
+type MFAStorage interface {
+	// FindEnrolment retrieves the enrolment of the named user or ErrEnrolmentNotFound
+	FindEnrolment(userName string) (*totp.Enrolment, error)
+
+	// CreateEnrolment stores a new enrolment, returning ErrEnrolmentExists if the user has one
+	CreateEnrolment(e *totp.Enrolment) error
+
+	// UpdateEnrolment atomically applies update to the enrolment of the named user,
+	// so a code or recovery code is accepted at most once
+	UpdateEnrolment(userName string, update func(e *totp.Enrolment)) (*totp.Enrolment, error)
+
+	// DeleteEnrolment removes the enrolment of the named user
+	DeleteEnrolment(userName string) error
+}
@@ -0,0 +1,75 @@
+package mysql
+
+import (
+	"errors"
+
+	"github.com/responsible-api/responsible-auth/resource/attempt"
+	"github.com/responsible-api/responsible-auth/storage"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const attemptsTable = "responsible_login_attempts"
+
+// MySQLAttemptStorage implements the AttemptStorage interface using MySQL/GORM
+type MySQLAttemptStorage struct {
+	db *gorm.DB
+}
+
+// NewMySQLAttemptStorage creates a new MySQL attempt storage implementation
+func NewMySQLAttemptStorage(db *gorm.DB) storage.AttemptStorage {
+	return &MySQLAttemptStorage{
+		db: db,
+	}
+}
+
+// FindAttempt returns the record for key, or nil when none exists
+func (m *MySQLAttemptStorage) FindAttempt(key string) (*attempt.Attempt, error) {
+	a := &attempt.Attempt{}
+	err := m.db.Table(attemptsTable).
+		Where("attempt_key = ?", key).
+		Limit(1).
+		First(a).Error
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// UpdateAttempt atomically applies update to the record for key.
+// The row is locked with SELECT ... FOR UPDATE so concurrent failures are not lost.
+func (m *MySQLAttemptStorage) UpdateAttempt(key string, update func(a *attempt.Attempt)) (*attempt.Attempt, error) {
+	a := &attempt.Attempt{}
+	err := m.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Table(attemptsTable).
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("attempt_key = ?", key).
+			Limit(1).
+			First(a).Error
+
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			a = &attempt.Attempt{Key: key}
+		} else if err != nil {
+			return err
+		}
+
+		update(a)
+		a.Key = key
+		return tx.Table(attemptsTable).Save(a).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// DeleteAttempt removes the record for key
+func (m *MySQLAttemptStorage) DeleteAttempt(key string) error {
+	return m.db.Table(attemptsTable).
+		Where("attempt_key = ?", key).
+		Delete(&attempt.Attempt{}).Error
+}
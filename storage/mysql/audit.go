@@ -0,0 +1,69 @@
+package mysql
+
+import (
+	"github.com/responsible-api/responsible-auth/auth"
+	"gorm.io/gorm"
+)
+
+const auditEventsTable = "responsible_audit_events"
+
+// auditEvent is the row stored for an auth.AuditEvent.
+type auditEvent struct {
+	ID        uint64 `gorm:"column:id;primaryKey;autoIncrement"`
+	Type      string `gorm:"column:type"`
+	Time      int64  `gorm:"column:time"`
+	Success   bool   `gorm:"column:success"`
+	Method    string `gorm:"column:method"`
+	Subject   string `gorm:"column:subject"`
+	Actor     string `gorm:"column:actor"`
+	ClientID  string `gorm:"column:client_id"`
+	TenantID  uint64 `gorm:"column:tenant_id"`
+	IP        string `gorm:"column:ip"`
+	UserAgent string `gorm:"column:user_agent"`
+	Reason    string `gorm:"column:reason"`
+	TokenID   string `gorm:"column:token_id"`
+}
+
+// MySQLAuditSink implements the AuditSink interface using MySQL/GORM
+type MySQLAuditSink struct {
+	db *gorm.DB
+}
+
+// NewMySQLAuditSink creates an audit sink appending events to the audit events table
+func NewMySQLAuditSink(db *gorm.DB) auth.AuditSink {
+	return &MySQLAuditSink{
+		db: db,
+	}
+}
+
+// Record appends the event. Values longer than their column are truncated
+// rather than rejected, so an oversized user agent cannot hide an event.
+func (m *MySQLAuditSink) Record(event auth.AuditEvent) error {
+	row := &auditEvent{
+		Type:      truncate(event.Type, 32),
+		Time:      event.Time.Unix(),
+		Success:   event.Success,
+		Method:    truncate(event.Method, 255),
+		Subject:   truncate(event.Subject, 255),
+		Actor:     truncate(event.Actor, 255),
+		ClientID:  truncate(event.ClientID, 255),
+		TenantID:  event.TenantID,
+		IP:        truncate(event.IP, 45),
+		UserAgent: truncate(event.UserAgent, 512),
+		Reason:    truncate(event.Reason, 512),
+		TokenID:   truncate(event.TokenID, 64),
+	}
+	return m.db.Table(auditEventsTable).Create(row).Error
+}
+
+// truncate shortens s to at most n runes.
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n])
+}
@@ -0,0 +1,102 @@
+package mysql
+
+import (
+	"errors"
+
+	"github.com/responsible-api/responsible-auth/resource/totp"
+	"github.com/responsible-api/responsible-auth/storage"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const mfaTable = "responsible_mfa_totp"
+
+// MySQLMFAStorage implements the MFAStorage interface using MySQL/GORM
+type MySQLMFAStorage struct {
+	db *gorm.DB
+}
+
+// NewMySQLMFAStorage creates a new MySQL MFA storage implementation
+func NewMySQLMFAStorage(db *gorm.DB) storage.MFAStorage {
+	return &MySQLMFAStorage{
+		db: db,
+	}
+}
+
+// FindEnrolment retrieves the enrolment of the named user
+func (m *MySQLMFAStorage) FindEnrolment(userName string) (*totp.Enrolment, error) {
+	e := &totp.Enrolment{}
+	err := m.db.Table(mfaTable).
+		Where("user_name = ?", userName).
+		Limit(1).
+		First(e).Error
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, storage.ErrEnrolmentNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// CreateEnrolment stores a new enrolment
+func (m *MySQLMFAStorage) CreateEnrolment(e *totp.Enrolment) error {
+	var count int64
+	err := m.db.Table(mfaTable).
+		Where("user_name = ?", e.UserName).
+		Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return storage.ErrEnrolmentExists
+	}
+
+	return m.db.Table(mfaTable).Create(e).Error
+}
+
+// UpdateEnrolment atomically applies update to the enrolment of the named user.
+// The row is locked so concurrent logins cannot both spend the same code.
+func (m *MySQLMFAStorage) UpdateEnrolment(userName string, update func(e *totp.Enrolment)) (*totp.Enrolment, error) {
+	e := &totp.Enrolment{}
+	err := m.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Table(mfaTable).
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("user_name = ?", userName).
+			Limit(1).
+			First(e).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return storage.ErrEnrolmentNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		update(e)
+		e.UserName = userName
+
+		return tx.Table(mfaTable).
+			Where("user_name = ?", userName).
+			Select("*").
+			Updates(e).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// DeleteEnrolment removes the enrolment of the named user
+func (m *MySQLMFAStorage) DeleteEnrolment(userName string) error {
+	result := m.db.Table(mfaTable).
+		Where("user_name = ?", userName).
+		Delete(&totp.Enrolment{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return storage.ErrEnrolmentNotFound
+	}
+	return nil
+}
@@ -1,11 +1,16 @@
 package mysql
 
 import (
+	"errors"
+	"strconv"
+
 	"github.com/responsible-api/responsible-auth/resource/user"
 	"github.com/responsible-api/responsible-auth/storage"
 	"gorm.io/gorm"
 )
 
+const usersTable = "responsible_api_users"
+
 // MySQLStorage implements the UserStorage interface using MySQL/GORM
 type MySQLStorage struct {
 	db *gorm.DB
@@ -20,22 +25,34 @@ func NewMySQLStorage(db *gorm.DB) storage.UserStorage {
 
 // FindUserByCredentials retrieves a user by username/email and validates their credentials
 func (m *MySQLStorage) FindUserByCredentials(username, credentials string) (*user.User, error) {
-	user := &user.User{}
-	query := m.db.Table("responsible_api_users").
-		Where(m.db.Where("mail = ?", username).Or("account_id = ?", username)).
-		Where("secret = ?", credentials).
-		Limit(1)
+	if username == "" {
+		return nil, storage.ErrUserNotFound
+	}
 
-	if err := query.First(&user).Error; err != nil {
+	// Secrets may be hashed, so match candidates by identifier and verify in Go.
+	var candidates []*user.User
+	if err := m.byIdentifier(username).Find(&candidates).Error; err != nil {
 		return nil, err
 	}
-	return user, nil
+	if len(candidates) == 0 {
+		return nil, storage.ErrUserNotFound
+	}
+	for _, candidate := range candidates {
+		if credentials != "" && candidate.CheckSecret(credentials) {
+			return candidate, nil
+		}
+	}
+	return nil, storage.ErrInvalidCredentials
 }
 
 // FindUserByAPIKey retrieves a user by their API key
 func (m *MySQLStorage) FindUserByAPIKey(apiKey string) (*user.User, error) {
+	if apiKey == "" {
+		return nil, gorm.ErrRecordNotFound
+	}
+
 	user := &user.User{}
-	query := m.db.Table("responsible_api_users").
+	query := m.db.Table(usersTable).
 		Where("apikey = ?", apiKey).
 		Limit(1)
 
@@ -45,17 +62,34 @@ func (m *MySQLStorage) FindUserByAPIKey(apiKey string) (*user.User, error) {
 	return user, nil
 }
 
-// UpdateRefreshToken stores a refresh token for a user
+// UpdateRefreshToken stores a refresh token for a user, replacing any previous one
 func (m *MySQLStorage) UpdateRefreshToken(userID string, refreshToken string) error {
-	return m.db.Table("responsible_api_users").
-		Where("account_id = ? OR mail = ?", userID, userID).
+	if userID == "" {
+		return gorm.ErrRecordNotFound
+	}
+
+	// MySQL reports changed rather than matched rows, so check existence first
+	// instead of relying on RowsAffected when the token is unchanged.
+	var count int64
+	if err := m.byIdentifier(userID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	return m.byIdentifier(userID).
 		Update("refresh_token", refreshToken).Error
 }
 
 // ValidateRefreshToken checks if a refresh token is valid for a user
 func (m *MySQLStorage) ValidateRefreshToken(refreshToken string) (*user.User, error) {
+	if refreshToken == "" {
+		return nil, gorm.ErrRecordNotFound
+	}
+
 	user := &user.User{}
-	query := m.db.Table("responsible_api_users").
+	query := m.db.Table(usersTable).
 		Where("refresh_token = ?", refreshToken).
 		Limit(1)
 
@@ -64,3 +98,127 @@ func (m *MySQLStorage) ValidateRefreshToken(refreshToken string) (*user.User, er
 	}
 	return user, nil
 }
+
+// FindUserByName retrieves a user by their unique name
+func (m *MySQLStorage) FindUserByName(name string) (*user.User, error) {
+	user := &user.User{}
+	query := m.db.Table(usersTable).
+		Where("name = ?", name).
+		Limit(1)
+
+	if err := query.First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, storage.ErrUserNotFound
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
+// FindUserByMail retrieves a user by their mail address
+func (m *MySQLStorage) FindUserByMail(mail string) (*user.User, error) {
+	if mail == "" {
+		return nil, storage.ErrUserNotFound
+	}
+
+	user := &user.User{}
+	query := m.db.Table(usersTable).
+		Where("mail = ?", mail).
+		Limit(1)
+
+	if err := query.First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, storage.ErrUserNotFound
+		}
+		return nil, err
+	}
+	return user, nil
+}
+
+// CreateUser stores a new user
+func (m *MySQLStorage) CreateUser(u *user.User) error {
+	var count int64
+	query := m.db.Table(usersTable).Where("name = ?", u.Name)
+	if u.Mail != "" {
+		query = query.Or("mail = ?", u.Mail)
+	}
+	if err := query.Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return storage.ErrUserExists
+	}
+
+	err := m.db.Table(usersTable).Create(u).Error
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return storage.ErrUserExists
+	}
+	return err
+}
+
+// UpdateUser replaces the stored user with the same name
+func (m *MySQLStorage) UpdateUser(u *user.User) error {
+	if _, err := m.FindUserByName(u.Name); err != nil {
+		return err
+	}
+
+	if u.Mail != "" {
+		var count int64
+		if err := m.db.Table(usersTable).Where("mail = ? AND name <> ?", u.Mail, u.Name).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return storage.ErrUserExists
+		}
+	}
+
+	return m.db.Table(usersTable).
+		Where("name = ?", u.Name).
+		Updates(map[string]interface{}{
+			"account_id":    u.AccountID,
+			"mail":          u.Mail,
+			"created":       u.Created,
+			"access":        u.Access,
+			"status":        u.Status,
+			"secret":        u.Secret,
+			"apikey":        u.APIKey,
+			"refresh_token": u.Refresh,
+		}).Error
+}
+
+// UpdateAccess records the user's last successful authentication
+func (m *MySQLStorage) UpdateAccess(name string, access uint64) error {
+	if _, err := m.FindUserByName(name); err != nil {
+		return err
+	}
+
+	return m.db.Table(usersTable).
+		Where("name = ?", name).
+		Update("access", access).Error
+}
+
+// DeleteUser permanently removes the user with the given name
+func (m *MySQLStorage) DeleteUser(name string) error {
+	result := m.db.Table(usersTable).
+		Where("name = ?", name).
+		Delete(&user.User{})
+
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return storage.ErrUserNotFound
+	}
+	return nil
+}
+
+// byIdentifier scopes a query to the user matching the given mail or account_id.
+// account_id is only compared for numeric identifiers; otherwise MySQL would
+// coerce the string to 0 and match every user without an account.
+func (m *MySQLStorage) byIdentifier(identifier string) *gorm.DB {
+	query := m.db.Table(usersTable)
+	if accountID, err := strconv.ParseUint(identifier, 10, 64); err == nil {
+		return query.Where(m.db.Where("mail = ?", identifier).Or("account_id = ?", accountID))
+	}
+	return query.Where("mail = ?", identifier)
+}
@@ -0,0 +1,240 @@
+package mysql
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/responsible-api/responsible-auth/auth"
+	"github.com/responsible-api/responsible-auth/migration"
+	"github.com/responsible-api/responsible-auth/resource/user"
+	"github.com/responsible-api/responsible-auth/storage"
+	"github.com/responsible-api/responsible-auth/storage/storagetest"
+
+	gormmysql "gorm.io/driver/mysql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// testDB connects to the database named by MYSQL_TEST_DSN, skipping the test
+// when it is unset so `go test ./...` works without a running MySQL server.
+// The database is wiped, so never point it at real data.
+// Example: MYSQL_TEST_DSN="root:pass@tcp(127.0.0.1:3306)/responsible_api_test"
+func testDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("MYSQL_TEST_DSN")
+	if dsn == "" {
+		t.Skip("MYSQL_TEST_DSN not set, skipping MySQL storage tests")
+	}
+
+	db, err := gorm.Open(gormmysql.Open(dsn), &gorm.Config{
+		Logger: logger.Default.LogMode(logger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("Failed to connect to MySQL: %v", err)
+	}
+
+	migrator, err := migration.New(db, migration.DialectMySQL)
+	if err != nil {
+		t.Fatalf("Failed to load migrations: %v", err)
+	}
+	if _, err := migrator.Up(migration.Options{}); err != nil {
+		t.Fatalf("Failed to migrate database: %v", err)
+	}
+	return db
+}
+
+func TestMySQLStorage_Conformance(t *testing.T) {
+	db := testDB(t)
+
+	storagetest.Run(t, func(t *testing.T, users ...*user.User) storage.UserStorage {
+		if err := db.Exec("DELETE FROM responsible_token_bucket").Error; err != nil {
+			t.Fatalf("Failed to reset token buckets: %v", err)
+		}
+		if err := db.Exec("DELETE FROM responsible_api_users").Error; err != nil {
+			t.Fatalf("Failed to reset users table: %v", err)
+		}
+		for _, u := range users {
+			if err := db.Table(usersTable).Create(u).Error; err != nil {
+				t.Fatalf("Failed to seed user %s: %v", u.Name, err)
+			}
+		}
+		return NewMySQLStorage(db)
+	})
+}
+
+func TestMySQLAttemptStorage_Conformance(t *testing.T) {
+	db := testDB(t)
+
+	storagetest.RunAttempts(t, func(t *testing.T) storage.AttemptStorage {
+		if err := db.Exec("DELETE FROM " + attemptsTable).Error; err != nil {
+			t.Fatalf("Failed to reset login attempts: %v", err)
+		}
+		return NewMySQLAttemptStorage(db)
+	})
+}
+
+func TestMySQLBucketStorage_Conformance(t *testing.T) {
+	db := testDB(t)
+
+	storagetest.RunBuckets(t, func(t *testing.T) storage.BucketStorage {
+		if err := db.Exec("DELETE FROM " + bucketsTable).Error; err != nil {
+			t.Fatalf("Failed to reset token buckets: %v", err)
+		}
+		if err := db.Exec("DELETE FROM " + usersTable).Error; err != nil {
+			t.Fatalf("Failed to reset users table: %v", err)
+		}
+		for _, u := range []*user.User{storagetest.Alice(), storagetest.Bob()} {
+			if err := db.Table(usersTable).Create(u).Error; err != nil {
+				t.Fatalf("Failed to seed user %s: %v", u.Name, err)
+			}
+		}
+		return NewMySQLBucketStorage(db)
+	})
+}
+
+func TestMySQLClientStorage_Conformance(t *testing.T) {
+	db := testDB(t)
+
+	storagetest.RunClients(t, func(t *testing.T) storage.ClientStorage {
+		if err := db.Exec("DELETE FROM " + clientsTable).Error; err != nil {
+			t.Fatalf("Failed to reset clients table: %v", err)
+		}
+		return NewMySQLClientStorage(db)
+	})
+}
+
+func TestMySQLAuthorizationCodeStorage_Conformance(t *testing.T) {
+	db := testDB(t)
+
+	storagetest.RunAuthorizationCodes(t, func(t *testing.T) storage.AuthorizationCodeStorage {
+		if err := db.Exec("DELETE FROM " + codesTable).Error; err != nil {
+			t.Fatalf("Failed to reset codes table: %v", err)
+		}
+		return NewMySQLAuthorizationCodeStorage(db)
+	})
+}
+
+func TestMySQLDeviceCodeStorage_Conformance(t *testing.T) {
+	db := testDB(t)
+
+	storagetest.RunDeviceCodes(t, func(t *testing.T) storage.DeviceCodeStorage {
+		if err := db.Exec("DELETE FROM " + deviceCodesTable).Error; err != nil {
+			t.Fatalf("Failed to reset device codes table: %v", err)
+		}
+		return NewMySQLDeviceCodeStorage(db)
+	})
+}
+
+func TestMySQLMFAStorage_Conformance(t *testing.T) {
+	db := testDB(t)
+
+	storagetest.RunMFA(t, func(t *testing.T) storage.MFAStorage {
+		if err := db.Exec("DELETE FROM " + mfaTable).Error; err != nil {
+			t.Fatalf("Failed to reset mfa table: %v", err)
+		}
+		if err := db.Exec("DELETE FROM " + bucketsTable).Error; err != nil {
+			t.Fatalf("Failed to reset token buckets: %v", err)
+		}
+		if err := db.Exec("DELETE FROM " + usersTable).Error; err != nil {
+			t.Fatalf("Failed to reset users table: %v", err)
+		}
+		for _, u := range []*user.User{storagetest.Alice(), storagetest.Bob()} {
+			if err := db.Table(usersTable).Create(u).Error; err != nil {
+				t.Fatalf("Failed to seed user %s: %v", u.Name, err)
+			}
+		}
+		return NewMySQLMFAStorage(db)
+	})
+}
+
+func TestMySQLCredentialStorage_Conformance(t *testing.T) {
+	db := testDB(t)
+
+	storagetest.RunCredentials(t, func(t *testing.T) storage.CredentialStorage {
+		for _, table := range []string{challengesTable, credentialsTable, mfaTable, bucketsTable, usersTable} {
+			if err := db.Exec("DELETE FROM " + table).Error; err != nil {
+				t.Fatalf("Failed to reset %s: %v", table, err)
+			}
+		}
+		for _, u := range []*user.User{storagetest.Alice(), storagetest.Bob()} {
+			if err := db.Table(usersTable).Create(u).Error; err != nil {
+				t.Fatalf("Failed to seed user %s: %v", u.Name, err)
+			}
+		}
+		return NewMySQLCredentialStorage(db)
+	})
+}
+
+func TestMySQLSessionStorage_Conformance(t *testing.T) {
+	db := testDB(t)
+
+	storagetest.RunSessions(t, func(t *testing.T) storage.SessionStorage {
+		for _, table := range []string{sessionsTable, challengesTable, credentialsTable, mfaTable, bucketsTable, usersTable} {
+			if err := db.Exec("DELETE FROM " + table).Error; err != nil {
+				t.Fatalf("Failed to reset %s: %v", table, err)
+			}
+		}
+		for _, u := range []*user.User{storagetest.Alice(), storagetest.Bob()} {
+			if err := db.Table(usersTable).Create(u).Error; err != nil {
+				t.Fatalf("Failed to seed user %s: %v", u.Name, err)
+			}
+		}
+		return NewMySQLSessionStorage(db)
+	})
+}
+
+func TestMySQLRoleStorage_Conformance(t *testing.T) {
+	db := testDB(t)
+
+	storagetest.RunRoles(t, func(t *testing.T) storage.RoleStorage {
+		for _, table := range []string{userRolesTable, permissionsTable, rolesTable, sessionsTable, challengesTable, credentialsTable, mfaTable, bucketsTable, usersTable} {
+			if err := db.Exec("DELETE FROM " + table).Error; err != nil {
+				t.Fatalf("Failed to reset %s: %v", table, err)
+			}
+		}
+		for _, u := range []*user.User{storagetest.Alice(), storagetest.Bob()} {
+			if err := db.Table(usersTable).Create(u).Error; err != nil {
+				t.Fatalf("Failed to seed user %s: %v", u.Name, err)
+			}
+		}
+		return NewMySQLRoleStorage(db)
+	})
+}
+
+func TestMySQLAuditSink(t *testing.T) {
+	db := testDB(t)
+	if err := db.Exec("DELETE FROM " + auditEventsTable).Error; err != nil {
+		t.Fatalf("Failed to reset audit events: %v", err)
+	}
+
+	sink := NewMySQLAuditSink(db)
+	event := auth.AuditEvent{
+		Type:      auth.EventLogin,
+		Time:      time.Unix(1700000000, 0),
+		Method:    auth.GrantPassword,
+		Subject:   "alice",
+		IP:        "192.0.2.1",
+		UserAgent: strings.Repeat("x", 600),
+		Reason:    "invalid credentials",
+	}
+	if err := sink.Record(event); err != nil {
+		t.Fatalf("Record() unexpected error = %v", err)
+	}
+
+	var rows []auditEvent
+	if err := db.Table(auditEventsTable).Find(&rows).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 {
+		t.Fatalf("stored %d events, want 1", len(rows))
+	}
+	row := rows[0]
+	if row.Type != auth.EventLogin || row.Time != 1700000000 || row.Success || row.Subject != "alice" || row.Reason != "invalid credentials" {
+		t.Errorf("stored event = %+v", row)
+	}
+	if len(row.UserAgent) != 512 {
+		t.Errorf("stored user agent length = %d, want 512", len(row.UserAgent))
+	}
+}
@@ -0,0 +1,220 @@
+package mysql
+
+import (
+	"errors"
+
+	"github.com/responsible-api/responsible-auth/resource/authcode"
+	"github.com/responsible-api/responsible-auth/resource/client"
+	"github.com/responsible-api/responsible-auth/resource/devicecode"
+	"github.com/responsible-api/responsible-auth/storage"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	clientsTable     = "responsible_oauth_clients"
+	codesTable       = "responsible_oauth_codes"
+	deviceCodesTable = "responsible_oauth_device_codes"
+)
+
+// MySQLClientStorage implements the ClientStorage interface using MySQL/GORM
+type MySQLClientStorage struct {
+	db *gorm.DB
+}
+
+// NewMySQLClientStorage creates a new MySQL client storage implementation
+func NewMySQLClientStorage(db *gorm.DB) storage.ClientStorage {
+	return &MySQLClientStorage{
+		db: db,
+	}
+}
+
+// FindClient retrieves a client by its client ID
+func (m *MySQLClientStorage) FindClient(clientID string) (*client.Client, error) {
+	if clientID == "" {
+		return nil, storage.ErrClientNotFound
+	}
+
+	c := &client.Client{}
+	err := m.db.Table(clientsTable).
+		Where("client_id = ?", clientID).
+		Limit(1).
+		First(c).Error
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, storage.ErrClientNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// CreateClient stores a new client
+func (m *MySQLClientStorage) CreateClient(c *client.Client) error {
+	var count int64
+	err := m.db.Table(clientsTable).
+		Where("client_id = ?", c.ID).
+		Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return storage.ErrClientExists
+	}
+
+	return m.db.Table(clientsTable).Create(c).Error
+}
+
+// UpdateClient replaces the stored client with the same ID
+func (m *MySQLClientStorage) UpdateClient(c *client.Client) error {
+	if _, err := m.FindClient(c.ID); err != nil {
+		return err
+	}
+
+	// Select("*") writes zero values too, and the struct form applies the JSON serializers
+	return m.db.Table(clientsTable).
+		Where("client_id = ?", c.ID).
+		Select("*").
+		Updates(c).Error
+}
+
+// DeleteClient removes the client with the given ID
+func (m *MySQLClientStorage) DeleteClient(clientID string) error {
+	if _, err := m.FindClient(clientID); err != nil {
+		return err
+	}
+
+	return m.db.Table(clientsTable).
+		Where("client_id = ?", clientID).
+		Delete(&client.Client{}).Error
+}
+
+// MySQLAuthorizationCodeStorage implements the AuthorizationCodeStorage interface using MySQL/GORM
+type MySQLAuthorizationCodeStorage struct {
+	db *gorm.DB
+}
+
+// NewMySQLAuthorizationCodeStorage creates a new MySQL authorization code storage implementation
+func NewMySQLAuthorizationCodeStorage(db *gorm.DB) storage.AuthorizationCodeStorage {
+	return &MySQLAuthorizationCodeStorage{
+		db: db,
+	}
+}
+
+// CreateAuthorizationCode stores a newly issued code
+func (m *MySQLAuthorizationCodeStorage) CreateAuthorizationCode(code *authcode.Code) error {
+	return m.db.Table(codesTable).Create(code).Error
+}
+
+// ConsumeAuthorizationCode removes and returns the code with the given hash.
+// The row is locked and deleted in one transaction so concurrent exchanges of
+// the same code cannot both succeed.
+func (m *MySQLAuthorizationCodeStorage) ConsumeAuthorizationCode(hash string) (*authcode.Code, error) {
+	code := &authcode.Code{}
+	err := m.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Table(codesTable).
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("code_hash = ?", hash).
+			Limit(1).
+			First(code).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return storage.ErrCodeNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		return tx.Table(codesTable).
+			Where("code_hash = ?", hash).
+			Delete(&authcode.Code{}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return code, nil
+}
+
+// MySQLDeviceCodeStorage implements the DeviceCodeStorage interface using MySQL/GORM
+type MySQLDeviceCodeStorage struct {
+	db *gorm.DB
+}
+
+// NewMySQLDeviceCodeStorage creates a new MySQL device code storage implementation
+func NewMySQLDeviceCodeStorage(db *gorm.DB) storage.DeviceCodeStorage {
+	return &MySQLDeviceCodeStorage{
+		db: db,
+	}
+}
+
+// CreateDeviceCode stores a new device authorization
+func (m *MySQLDeviceCodeStorage) CreateDeviceCode(code *devicecode.Code) error {
+	return m.db.Table(deviceCodesTable).Create(code).Error
+}
+
+// FindDeviceCodeByUserCode retrieves a device authorization by its user code
+func (m *MySQLDeviceCodeStorage) FindDeviceCodeByUserCode(userCode string) (*devicecode.Code, error) {
+	if userCode == "" {
+		return nil, storage.ErrDeviceCodeNotFound
+	}
+
+	code := &devicecode.Code{}
+	err := m.db.Table(deviceCodesTable).
+		Where("user_code = ?", userCode).
+		Limit(1).
+		First(code).Error
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, storage.ErrDeviceCodeNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return code, nil
+}
+
+// UpdateDeviceCode atomically applies update to the device authorization.
+// The row is locked so concurrent polls and approvals are serialised.
+func (m *MySQLDeviceCodeStorage) UpdateDeviceCode(hash string, update func(code *devicecode.Code)) (*devicecode.Code, error) {
+	code := &devicecode.Code{}
+	err := m.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Table(deviceCodesTable).
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("code_hash = ?", hash).
+			Limit(1).
+			First(code).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return storage.ErrDeviceCodeNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		userCode := code.UserCode
+		update(code)
+		code.Hash, code.UserCode = hash, userCode
+
+		return tx.Table(deviceCodesTable).
+			Where("code_hash = ?", hash).
+			Select("*").
+			Updates(code).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return code, nil
+}
+
+// DeleteDeviceCode removes the device authorization
+func (m *MySQLDeviceCodeStorage) DeleteDeviceCode(hash string) error {
+	result := m.db.Table(deviceCodesTable).
+		Where("code_hash = ?", hash).
+		Delete(&devicecode.Code{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return storage.ErrDeviceCodeNotFound
+	}
+	return nil
+}
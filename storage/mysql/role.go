@@ -0,0 +1,179 @@
+package mysql
+
+import (
+	"errors"
+
+	"github.com/responsible-api/responsible-auth/resource/role"
+	"github.com/responsible-api/responsible-auth/storage"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	rolesTable       = "responsible_roles"
+	permissionsTable = "responsible_role_permissions"
+	userRolesTable   = "responsible_user_roles"
+)
+
+// MySQLRoleStorage implements the RoleStorage interface using MySQL/GORM
+type MySQLRoleStorage struct {
+	db *gorm.DB
+}
+
+// NewMySQLRoleStorage creates a new MySQL role storage implementation
+func NewMySQLRoleStorage(db *gorm.DB) storage.RoleStorage {
+	return &MySQLRoleStorage{
+		db: db,
+	}
+}
+
+// CreateRole stores a new role
+func (m *MySQLRoleStorage) CreateRole(r *role.Role) error {
+	if err := m.requireRole(r.Name); err == nil {
+		return storage.ErrRoleExists
+	} else if !errors.Is(err, storage.ErrRoleNotFound) {
+		return err
+	}
+	if r.Inherits != "" {
+		if err := m.requireRole(r.Inherits); err != nil {
+			return err
+		}
+	}
+
+	return m.db.Table(rolesTable).Create(r).Error
+}
+
+// FindRole retrieves a role by name
+func (m *MySQLRoleStorage) FindRole(name string) (*role.Role, error) {
+	r := &role.Role{}
+	err := m.db.Table(rolesTable).
+		Where("name = ?", name).
+		Limit(1).
+		First(r).Error
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, storage.ErrRoleNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// FindRoles retrieves every role, ordered by name
+func (m *MySQLRoleStorage) FindRoles() ([]*role.Role, error) {
+	found := []*role.Role{}
+	if err := m.db.Table(rolesTable).Order("name").Find(&found).Error; err != nil {
+		return nil, err
+	}
+	return found, nil
+}
+
+// DeleteRole removes a role. Its permissions and assignments are removed by
+// the foreign keys' ON DELETE CASCADE.
+func (m *MySQLRoleStorage) DeleteRole(name string) error {
+	return m.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Table(rolesTable).
+			Where("name = ?", name).
+			Delete(&role.Role{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return storage.ErrRoleNotFound
+		}
+
+		return tx.Table(rolesTable).
+			Where("inherits = ?", name).
+			Update("inherits", "").Error
+	})
+}
+
+// GrantPermission adds a permission to its role
+func (m *MySQLRoleStorage) GrantPermission(p *role.Permission) error {
+	if err := m.requireRole(p.RoleName); err != nil {
+		return err
+	}
+	return m.db.Table(permissionsTable).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(p).Error
+}
+
+// RevokePermission removes a permission from its role
+func (m *MySQLRoleStorage) RevokePermission(p *role.Permission) error {
+	result := m.db.Table(permissionsTable).
+		Where("role_name = ? AND permission = ? AND resource = ?", p.RoleName, p.Permission, p.Resource).
+		Delete(&role.Permission{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return storage.ErrPermissionNotFound
+	}
+	return nil
+}
+
+// FindPermissions retrieves the permissions granted directly to the named role
+func (m *MySQLRoleStorage) FindPermissions(roleName string) ([]*role.Permission, error) {
+	found := []*role.Permission{}
+	err := m.db.Table(permissionsTable).
+		Where("role_name = ?", roleName).
+		Order("permission, resource").
+		Find(&found).Error
+	if err != nil {
+		return nil, err
+	}
+	return found, nil
+}
+
+// AssignRole gives the named user a role
+func (m *MySQLRoleStorage) AssignRole(userName string, roleName string) error {
+	if err := m.requireRole(roleName); err != nil {
+		return err
+	}
+	return m.db.Table(userRolesTable).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&role.Assignment{UserName: userName, RoleName: roleName}).Error
+}
+
+// UnassignRole takes a role from the named user
+func (m *MySQLRoleStorage) UnassignRole(userName string, roleName string) error {
+	result := m.db.Table(userRolesTable).
+		Where("user_name = ? AND role_name = ?", userName, roleName).
+		Delete(&role.Assignment{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return storage.ErrAssignmentNotFound
+	}
+	return nil
+}
+
+// FindUserRoles retrieves the names of the roles assigned to the named user
+func (m *MySQLRoleStorage) FindUserRoles(userName string) ([]string, error) {
+	found := []string{}
+	err := m.db.Table(userRolesTable).
+		Where("user_name = ?", userName).
+		Order("role_name").
+		Pluck("role_name", &found).Error
+	if err != nil {
+		return nil, err
+	}
+	return found, nil
+}
+
+// requireRole returns ErrRoleNotFound unless the named role exists
+func (m *MySQLRoleStorage) requireRole(name string) error {
+	var count int64
+	err := m.db.Table(rolesTable).
+		Where("name = ?", name).
+		Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count == 0 {
+		return storage.ErrRoleNotFound
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package mysql
+
+import (
+	"errors"
+
+	"github.com/responsible-api/responsible-auth/resource/session"
+	"github.com/responsible-api/responsible-auth/storage"
+	"gorm.io/gorm"
+)
+
+const sessionsTable = "responsible_sessions"
+
+// MySQLSessionStorage implements the SessionStorage interface using MySQL/GORM
+type MySQLSessionStorage struct {
+	db *gorm.DB
+}
+
+// NewMySQLSessionStorage creates a new MySQL session storage implementation
+func NewMySQLSessionStorage(db *gorm.DB) storage.SessionStorage {
+	return &MySQLSessionStorage{
+		db: db,
+	}
+}
+
+// CreateSession stores a new session
+func (m *MySQLSessionStorage) CreateSession(s *session.Session) error {
+	var count int64
+	err := m.db.Table(sessionsTable).
+		Where("session_id = ?", s.ID).
+		Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return storage.ErrSessionExists
+	}
+
+	return m.db.Table(sessionsTable).Create(s).Error
+}
+
+// FindSession retrieves a session by its ID
+func (m *MySQLSessionStorage) FindSession(id string) (*session.Session, error) {
+	s := &session.Session{}
+	err := m.db.Table(sessionsTable).
+		Where("session_id = ?", id).
+		Limit(1).
+		First(s).Error
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, storage.ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// FindSessionsByUser retrieves every session of the named user, oldest first
+func (m *MySQLSessionStorage) FindSessionsByUser(userName string) ([]*session.Session, error) {
+	found := []*session.Session{}
+	err := m.db.Table(sessionsTable).
+		Where("user_name = ?", userName).
+		Order("created, session_id").
+		Find(&found).Error
+	if err != nil {
+		return nil, err
+	}
+	return found, nil
+}
+
+// TouchSession records when and from where the session was last used
+func (m *MySQLSessionStorage) TouchSession(id string, lastUsed int64, ip string) error {
+	result := m.db.Table(sessionsTable).
+		Where("session_id = ?", id).
+		Updates(map[string]interface{}{"last_used": lastUsed, "ip": ip})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		// MySQL reports unchanged rows as unaffected, so tell them apart from missing ones
+		if _, err := m.FindSession(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// DeleteSession removes the session with the given ID
+func (m *MySQLSessionStorage) DeleteSession(id string) error {
+	result := m.db.Table(sessionsTable).
+		Where("session_id = ?", id).
+		Delete(&session.Session{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return storage.ErrSessionNotFound
+	}
+	return nil
+}
+
+// DeleteSessionsByUser removes the user's sessions other than except
+func (m *MySQLSessionStorage) DeleteSessionsByUser(userName string, except string) (int, error) {
+	result := m.db.Table(sessionsTable).
+		Where("user_name = ? AND session_id <> ?", userName, except).
+		Delete(&session.Session{})
+	if result.Error != nil {
+		return 0, result.Error
+	}
+	return int(result.RowsAffected), nil
+}
@@ -0,0 +1,150 @@
+package mysql
+
+import (
+	"errors"
+
+	"github.com/responsible-api/responsible-auth/resource/credential"
+	"github.com/responsible-api/responsible-auth/storage"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	credentialsTable = "responsible_webauthn_credentials"
+	challengesTable  = "responsible_webauthn_challenges"
+)
+
+// MySQLCredentialStorage implements the CredentialStorage interface using MySQL/GORM
+type MySQLCredentialStorage struct {
+	db *gorm.DB
+}
+
+// NewMySQLCredentialStorage creates a new MySQL credential storage implementation
+func NewMySQLCredentialStorage(db *gorm.DB) storage.CredentialStorage {
+	return &MySQLCredentialStorage{
+		db: db,
+	}
+}
+
+// CreateCredential stores a new credential
+func (m *MySQLCredentialStorage) CreateCredential(c *credential.Credential) error {
+	var count int64
+	err := m.db.Table(credentialsTable).
+		Where("credential_id = ?", c.ID).
+		Count(&count).Error
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		return storage.ErrCredentialExists
+	}
+
+	return m.db.Table(credentialsTable).Create(c).Error
+}
+
+// FindCredential retrieves a credential by its ID
+func (m *MySQLCredentialStorage) FindCredential(id string) (*credential.Credential, error) {
+	c := &credential.Credential{}
+	err := m.db.Table(credentialsTable).
+		Where("credential_id = ?", id).
+		Limit(1).
+		First(c).Error
+
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, storage.ErrCredentialNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// FindCredentialsByUser retrieves every credential of the named user, oldest first
+func (m *MySQLCredentialStorage) FindCredentialsByUser(userName string) ([]*credential.Credential, error) {
+	found := []*credential.Credential{}
+	err := m.db.Table(credentialsTable).
+		Where("user_name = ?", userName).
+		Order("created, credential_id").
+		Find(&found).Error
+	if err != nil {
+		return nil, err
+	}
+	return found, nil
+}
+
+// UpdateCredential atomically applies update to the credential with the given ID.
+// The row is locked so concurrent logins see each other's sign counter.
+func (m *MySQLCredentialStorage) UpdateCredential(id string, update func(c *credential.Credential)) (*credential.Credential, error) {
+	c := &credential.Credential{}
+	err := m.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Table(credentialsTable).
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("credential_id = ?", id).
+			Limit(1).
+			First(c).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return storage.ErrCredentialNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		update(c)
+		c.ID = id
+
+		return tx.Table(credentialsTable).
+			Where("credential_id = ?", id).
+			Select("*").
+			Updates(c).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// DeleteCredential removes the credential with the given ID
+func (m *MySQLCredentialStorage) DeleteCredential(id string) error {
+	result := m.db.Table(credentialsTable).
+		Where("credential_id = ?", id).
+		Delete(&credential.Credential{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return storage.ErrCredentialNotFound
+	}
+	return nil
+}
+
+// CreateChallenge stores the challenge of a new ceremony
+func (m *MySQLCredentialStorage) CreateChallenge(c *credential.Challenge) error {
+	return m.db.Table(challengesTable).Create(c).Error
+}
+
+// ConsumeChallenge removes and returns the challenge. The row is locked and
+// deleted in one transaction so a challenge completes at most one ceremony.
+func (m *MySQLCredentialStorage) ConsumeChallenge(challenge string) (*credential.Challenge, error) {
+	c := &credential.Challenge{}
+	err := m.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Table(challengesTable).
+			Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("challenge = ?", challenge).
+			Limit(1).
+			First(c).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return storage.ErrChallengeNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		return tx.Table(challengesTable).
+			Where("challenge = ?", challenge).
+			Delete(&credential.Challenge{}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
@@ -0,0 +1,48 @@
+package storage
+
+import (
+	"github.com/responsible-api/responsible-auth/resource/authcode"
+	"github.com/responsible-api/responsible-auth/resource/client"
+	"github.com/responsible-api/responsible-auth/resource/devicecode"
+)
+
+// ClientStorage persists OAuth clients registered with the authorization server.
+type ClientStorage interface {
+	// FindClient retrieves a client by its client ID
+	FindClient(clientID string) (*client.Client, error)
+
+	// CreateClient stores a new client, returning ErrClientExists when the ID is taken
+	CreateClient(c *client.Client) error
+
+	// UpdateClient replaces the stored client with the same ID
+	UpdateClient(c *client.Client) error
+
+	// DeleteClient removes the client with the given ID
+	DeleteClient(clientID string) error
+}
+
+// AuthorizationCodeStorage persists authorization codes between /authorize and the token exchange.
+type AuthorizationCodeStorage interface {
+	// CreateAuthorizationCode stores a newly issued code
+	CreateAuthorizationCode(code *authcode.Code) error
+
+	// ConsumeAuthorizationCode atomically removes and returns the code with the given hash,
+	// so a code can be exchanged at most once. Unknown codes return ErrCodeNotFound
+	ConsumeAuthorizationCode(hash string) (*authcode.Code, error)
+}
+
+// DeviceCodeStorage persists device authorizations while the user approves them.
+type DeviceCodeStorage interface {
+	// CreateDeviceCode stores a new device authorization
+	CreateDeviceCode(code *devicecode.Code) error
+
+	// FindDeviceCodeByUserCode retrieves a device authorization by its normalised user code
+	FindDeviceCodeByUserCode(userCode string) (*devicecode.Code, error)
+
+	// UpdateDeviceCode atomically applies update to the device authorization with the given hash
+	UpdateDeviceCode(hash string, update func(code *devicecode.Code)) (*devicecode.Code, error)
+
+	// DeleteDeviceCode removes the device authorization with the given hash.
+	// Deleting a missing code returns ErrDeviceCodeNotFound so only one poll can redeem it
+	DeleteDeviceCode(hash string) error
+}
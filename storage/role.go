@@ -0,0 +1,42 @@
+package storage
+
+import "github.com/responsible-api/responsible-auth/resource/role"
+
+// RoleStorage persists roles, their permissions and the users they are
+// assigned to. Implementations must be safe for concurrent use.
+type RoleStorage interface {
+	// CreateRole stores a new role, returning ErrRoleExists if the name is
+	// taken and ErrRoleNotFound if the role it inherits does not exist
+	CreateRole(r *role.Role) error
+
+	// FindRole retrieves a role by name or ErrRoleNotFound
+	FindRole(name string) (*role.Role, error)
+
+	// FindRoles retrieves every role, ordered by name
+	FindRoles() ([]*role.Role, error)
+
+	// DeleteRole removes a role with its permissions and assignments, returning
+	// ErrRoleNotFound for unknown roles. Roles inheriting it no longer inherit anything
+	DeleteRole(name string) error
+
+	// GrantPermission adds a permission to its role, returning ErrRoleNotFound
+	// for unknown roles. Granting a permission the role already has is not an error
+	GrantPermission(p *role.Permission) error
+
+	// RevokePermission removes a permission from its role or returns ErrPermissionNotFound
+	RevokePermission(p *role.Permission) error
+
+	// FindPermissions retrieves the permissions granted directly to the named
+	// role, ordered by permission and resource
+	FindPermissions(roleName string) ([]*role.Permission, error)
+
+	// AssignRole gives the named user a role, returning ErrRoleNotFound for
+	// unknown roles. Assigning a role the user already has is not an error
+	AssignRole(userName string, roleName string) error
+
+	// UnassignRole takes a role from the named user or returns ErrAssignmentNotFound
+	UnassignRole(userName string, roleName string) error
+
+	// FindUserRoles retrieves the names of the roles assigned to the named user, sorted
+	FindUserRoles(userName string) ([]string, error)
+}
@@ -0,0 +1,27 @@
+package storage
+
+import "github.com/responsible-api/responsible-auth/resource/session"
+
+// SessionStorage persists the login sessions of cookie-authenticated users.
+// Implementations must be safe for concurrent use.
+type SessionStorage interface {
+	// CreateSession stores a new session, returning ErrSessionExists if the ID is taken
+	CreateSession(s *session.Session) error
+
+	// FindSession retrieves a session by its ID or ErrSessionNotFound
+	FindSession(id string) (*session.Session, error)
+
+	// FindSessionsByUser retrieves every session of the named user, oldest first
+	FindSessionsByUser(userName string) ([]*session.Session, error)
+
+	// TouchSession records that the session was used at lastUsed from ip,
+	// returning ErrSessionNotFound for unknown sessions
+	TouchSession(id string, lastUsed int64, ip string) error
+
+	// DeleteSession removes the session with the given ID or returns ErrSessionNotFound
+	DeleteSession(id string) error
+
+	// DeleteSessionsByUser removes every session of the named user except the
+	// one with ID except, which may be empty, and returns how many were removed
+	DeleteSessionsByUser(userName string, except string) (int, error)
+}
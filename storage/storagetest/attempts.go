@@ -0,0 +1,118 @@
+package storagetest
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/responsible-api/responsible-auth/resource/attempt"
+	"github.com/responsible-api/responsible-auth/storage"
+)
+
+// AttemptFactory returns a fresh, empty attempt storage.
+type AttemptFactory func(t *testing.T) storage.AttemptStorage
+
+// RunAttempts executes the conformance suite for storage.AttemptStorage implementations.
+func RunAttempts(t *testing.T, newStorage AttemptFactory) {
+	t.Run("FindAttempt", func(t *testing.T) { testFindAttempt(t, newStorage) })
+	t.Run("UpdateAttempt", func(t *testing.T) { testUpdateAttempt(t, newStorage) })
+	t.Run("DeleteAttempt", func(t *testing.T) { testDeleteAttempt(t, newStorage) })
+	t.Run("ConcurrentUpdates", func(t *testing.T) { testConcurrentAttemptUpdates(t, newStorage) })
+}
+
+func testFindAttempt(t *testing.T, newStorage AttemptFactory) {
+	s := newStorage(t)
+
+	a, err := s.FindAttempt("account:nobody")
+	if err != nil {
+		t.Fatalf("FindAttempt() unexpected error = %v", err)
+	}
+	if a != nil {
+		t.Errorf("FindAttempt() for unknown key = %+v, want nil", a)
+	}
+}
+
+func testUpdateAttempt(t *testing.T, newStorage AttemptFactory) {
+	s := newStorage(t)
+
+	created, err := s.UpdateAttempt("account:alice", func(a *attempt.Attempt) {
+		if a.Failures != 0 {
+			t.Errorf("UpdateAttempt() new record failures = %d, want 0", a.Failures)
+		}
+		a.Failures = 1
+		a.WindowStart = 1700000000
+	})
+	if err != nil {
+		t.Fatalf("UpdateAttempt() unexpected error = %v", err)
+	}
+	if created.Key != "account:alice" || created.Failures != 1 {
+		t.Errorf("UpdateAttempt() = %+v, want key account:alice with 1 failure", created)
+	}
+
+	_, err = s.UpdateAttempt("account:alice", func(a *attempt.Attempt) {
+		a.Failures++
+		a.LockedUntil = 1700000060
+		a.Lockouts = 1
+	})
+	if err != nil {
+		t.Fatalf("UpdateAttempt() unexpected error = %v", err)
+	}
+
+	found, err := s.FindAttempt("account:alice")
+	if err != nil {
+		t.Fatalf("FindAttempt() unexpected error = %v", err)
+	}
+	want := attempt.Attempt{Key: "account:alice", Failures: 2, WindowStart: 1700000000, LockedUntil: 1700000060, Lockouts: 1}
+	if found == nil || *found != want {
+		t.Errorf("FindAttempt() = %+v, want %+v", found, want)
+	}
+
+	other, err := s.FindAttempt("ip:192.0.2.1")
+	if err != nil || other != nil {
+		t.Errorf("FindAttempt() for untouched key = %+v, %v, want nil", other, err)
+	}
+}
+
+func testDeleteAttempt(t *testing.T, newStorage AttemptFactory) {
+	s := newStorage(t)
+
+	if _, err := s.UpdateAttempt("ip:192.0.2.1", func(a *attempt.Attempt) { a.Failures = 3 }); err != nil {
+		t.Fatalf("UpdateAttempt() unexpected error = %v", err)
+	}
+
+	if err := s.DeleteAttempt("ip:192.0.2.1"); err != nil {
+		t.Fatalf("DeleteAttempt() unexpected error = %v", err)
+	}
+	if a, err := s.FindAttempt("ip:192.0.2.1"); err != nil || a != nil {
+		t.Errorf("FindAttempt() after DeleteAttempt() = %+v, %v, want nil", a, err)
+	}
+
+	// Deleting a missing key is not an error
+	if err := s.DeleteAttempt("ip:192.0.2.1"); err != nil {
+		t.Errorf("DeleteAttempt() for missing key error = %v", err)
+	}
+}
+
+func testConcurrentAttemptUpdates(t *testing.T, newStorage AttemptFactory) {
+	s := newStorage(t)
+
+	const workers = 20
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := s.UpdateAttempt("account:alice", func(a *attempt.Attempt) { a.Failures++ }); err != nil {
+				t.Errorf("UpdateAttempt() unexpected error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	found, err := s.FindAttempt("account:alice")
+	if err != nil {
+		t.Fatalf("FindAttempt() unexpected error = %v", err)
+	}
+	if found == nil || found.Failures != workers {
+		t.Errorf("FindAttempt() after concurrent updates = %+v, want %d failures", found, workers)
+	}
+}
@@ -0,0 +1,110 @@
+package storagetest
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/responsible-api/responsible-auth/resource/bucket"
+	"github.com/responsible-api/responsible-auth/storage"
+)
+
+// BucketFactory returns a fresh, empty bucket storage. Backends that enforce
+// referential integrity must make sure the Alice and Bob accounts exist.
+type BucketFactory func(t *testing.T) storage.BucketStorage
+
+// RunBuckets executes the conformance suite for storage.BucketStorage implementations.
+func RunBuckets(t *testing.T, newStorage BucketFactory) {
+	t.Run("UpdateBucket", func(t *testing.T) { testUpdateBucket(t, newStorage(t)) })
+	t.Run("DeleteBucket", func(t *testing.T) { testDeleteBucket(t, newStorage(t)) })
+	t.Run("ConcurrentUpdates", func(t *testing.T) { testConcurrentBucketUpdates(t, newStorage(t)) })
+}
+
+// Bucket keys as made by ratelimit.AccountKey and ratelimit.ClientKey
+var (
+	aliceBucket  = bucket.Key{AccountID: Alice().AccountID}
+	clientBucket = bucket.Key{ClientID: "client-1"}
+)
+
+func testUpdateBucket(t *testing.T, s storage.BucketStorage) {
+	created, err := s.UpdateBucket(aliceBucket, func(b *bucket.Bucket) {
+		if b.Updated != 0 || b.Tokens != 0 {
+			t.Errorf("UpdateBucket() new bucket = %+v, want empty", b)
+		}
+		b.Tokens = 9.5
+		b.Updated = 1700000000000000000
+	})
+	if err != nil {
+		t.Fatalf("UpdateBucket() unexpected error = %v", err)
+	}
+	if created.Key != aliceBucket || created.Tokens != 9.5 {
+		t.Errorf("UpdateBucket() = %+v, want bucket %+v with 9.5 tokens", created, aliceBucket)
+	}
+
+	var seen bucket.Bucket
+	if _, err := s.UpdateBucket(aliceBucket, func(b *bucket.Bucket) { seen = *b }); err != nil {
+		t.Fatalf("UpdateBucket() unexpected error = %v", err)
+	}
+	want := bucket.Bucket{Key: aliceBucket, Tokens: 9.5, Updated: 1700000000000000000}
+	if seen != want {
+		t.Errorf("UpdateBucket() stored bucket = %+v, want %+v", seen, want)
+	}
+
+	if _, err := s.UpdateBucket(clientBucket, func(b *bucket.Bucket) { seen = *b }); err != nil {
+		t.Fatalf("UpdateBucket() unexpected error = %v", err)
+	}
+	if seen.Updated != 0 || seen.Key != clientBucket {
+		t.Errorf("UpdateBucket() for a client = %+v, want a new bucket", seen)
+	}
+
+	// A client named like an account ID still gets its own bucket
+	clientNamedLikeAccount := bucket.Key{ClientID: strconv.FormatUint(Alice().AccountID, 10)}
+	if _, err := s.UpdateBucket(clientNamedLikeAccount, func(b *bucket.Bucket) { seen = *b }); err != nil {
+		t.Fatalf("UpdateBucket() unexpected error = %v", err)
+	}
+	if seen.Updated != 0 {
+		t.Errorf("UpdateBucket() for client %q = %+v, want a new bucket", clientNamedLikeAccount.ClientID, seen)
+	}
+}
+
+func testDeleteBucket(t *testing.T, s storage.BucketStorage) {
+
+	if _, err := s.UpdateBucket(aliceBucket, func(b *bucket.Bucket) { b.Updated = 1 }); err != nil {
+		t.Fatalf("UpdateBucket() unexpected error = %v", err)
+	}
+	if err := s.DeleteBucket(aliceBucket); err != nil {
+		t.Fatalf("DeleteBucket() unexpected error = %v", err)
+	}
+
+	var seen bucket.Bucket
+	if _, err := s.UpdateBucket(aliceBucket, func(b *bucket.Bucket) { seen = *b }); err != nil {
+		t.Fatalf("UpdateBucket() unexpected error = %v", err)
+	}
+	if seen.Updated != 0 {
+		t.Errorf("UpdateBucket() after DeleteBucket() = %+v, want a new bucket", seen)
+	}
+}
+
+func testConcurrentBucketUpdates(t *testing.T, s storage.BucketStorage) {
+
+	const workers = 20
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := s.UpdateBucket(aliceBucket, func(b *bucket.Bucket) { b.Tokens++ }); err != nil {
+				t.Errorf("UpdateBucket() unexpected error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	var seen bucket.Bucket
+	if _, err := s.UpdateBucket(aliceBucket, func(b *bucket.Bucket) { seen = *b }); err != nil {
+		t.Fatalf("UpdateBucket() unexpected error = %v", err)
+	}
+	if seen.Tokens != workers {
+		t.Errorf("UpdateBucket() after concurrent updates tokens = %v, want %d", seen.Tokens, workers)
+	}
+}
@@ -0,0 +1,137 @@
+package storagetest
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/responsible-api/responsible-auth/resource/totp"
+	"github.com/responsible-api/responsible-auth/storage"
+)
+
+// MFAFactory returns a fresh, empty MFA storage. Enrolments are created for
+// Alice and Bob, so storages that enforce user references must hold both.
+type MFAFactory func(t *testing.T) storage.MFAStorage
+
+// RunMFA executes the conformance suite for storage.MFAStorage implementations.
+func RunMFA(t *testing.T, newStorage MFAFactory) {
+	newEnrolment := func() *totp.Enrolment {
+		return &totp.Enrolment{
+			UserName:      Alice().Name,
+			Secret:        "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ",
+			RecoveryCodes: []string{"2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae"},
+			Created:       1700000000,
+		}
+	}
+
+	t.Run("CreateAndFindEnrolment", func(t *testing.T) {
+		s := newStorage(t)
+		want := newEnrolment()
+
+		if err := s.CreateEnrolment(want); err != nil {
+			t.Fatalf("CreateEnrolment() unexpected error = %v", err)
+		}
+
+		got, err := s.FindEnrolment(want.UserName)
+		if err != nil {
+			t.Fatalf("FindEnrolment() unexpected error = %v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("FindEnrolment() = %+v, want %+v", got, want)
+		}
+
+		if err := s.CreateEnrolment(newEnrolment()); !errors.Is(err, storage.ErrEnrolmentExists) {
+			t.Errorf("CreateEnrolment() duplicate error = %v, want %v", err, storage.ErrEnrolmentExists)
+		}
+		if _, err := s.FindEnrolment(Bob().Name); !errors.Is(err, storage.ErrEnrolmentNotFound) {
+			t.Errorf("FindEnrolment() unknown user error = %v, want %v", err, storage.ErrEnrolmentNotFound)
+		}
+	})
+
+	t.Run("UpdateEnrolment", func(t *testing.T) {
+		s := newStorage(t)
+		if err := s.CreateEnrolment(newEnrolment()); err != nil {
+			t.Fatalf("CreateEnrolment() unexpected error = %v", err)
+		}
+
+		want := newEnrolment()
+		want.Confirmed = true
+		want.LastStep = 56666666
+		want.RecoveryCodes = []string{"fcde2b2edba56bf408601fb721fe9b5c338d10ee429ea04fae5511b68fbf8fb9"}
+
+		got, err := s.UpdateEnrolment(want.UserName, func(e *totp.Enrolment) {
+			e.Confirmed = true
+			e.LastStep = want.LastStep
+			e.RecoveryCodes = []string{want.RecoveryCodes[0]}
+		})
+		if err != nil {
+			t.Fatalf("UpdateEnrolment() unexpected error = %v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("UpdateEnrolment() = %+v, want %+v", got, want)
+		}
+
+		stored, err := s.FindEnrolment(want.UserName)
+		if err != nil {
+			t.Fatalf("FindEnrolment() unexpected error = %v", err)
+		}
+		if !reflect.DeepEqual(stored, want) {
+			t.Errorf("FindEnrolment() after UpdateEnrolment() = %+v, want %+v", stored, want)
+		}
+
+		_, err = s.UpdateEnrolment(Bob().Name, func(e *totp.Enrolment) {})
+		if !errors.Is(err, storage.ErrEnrolmentNotFound) {
+			t.Errorf("UpdateEnrolment() unknown user error = %v, want %v", err, storage.ErrEnrolmentNotFound)
+		}
+	})
+
+	t.Run("DeleteEnrolment", func(t *testing.T) {
+		s := newStorage(t)
+		if err := s.CreateEnrolment(newEnrolment()); err != nil {
+			t.Fatalf("CreateEnrolment() unexpected error = %v", err)
+		}
+
+		if err := s.DeleteEnrolment(Alice().Name); err != nil {
+			t.Fatalf("DeleteEnrolment() unexpected error = %v", err)
+		}
+		if _, err := s.FindEnrolment(Alice().Name); !errors.Is(err, storage.ErrEnrolmentNotFound) {
+			t.Errorf("FindEnrolment() after DeleteEnrolment() error = %v, want %v", err, storage.ErrEnrolmentNotFound)
+		}
+		if err := s.DeleteEnrolment(Alice().Name); !errors.Is(err, storage.ErrEnrolmentNotFound) {
+			t.Errorf("DeleteEnrolment() twice error = %v, want %v", err, storage.ErrEnrolmentNotFound)
+		}
+	})
+
+	t.Run("ConcurrentUpdates", func(t *testing.T) {
+		s := newStorage(t)
+		if err := s.CreateEnrolment(newEnrolment()); err != nil {
+			t.Fatalf("CreateEnrolment() unexpected error = %v", err)
+		}
+
+		// Every update spends the same step; only one may see it unspent
+		var spent int32
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := s.UpdateEnrolment(Alice().Name, func(e *totp.Enrolment) {
+					if e.LastStep < 100 {
+						e.LastStep = 100
+						atomic.AddInt32(&spent, 1)
+					}
+				})
+				if err != nil {
+					t.Errorf("UpdateEnrolment() unexpected error = %v", err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		if spent != 1 {
+			t.Errorf("step spent %d times, want exactly once", spent)
+		}
+	})
+}
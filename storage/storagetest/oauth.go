@@ -0,0 +1,301 @@
+package storagetest
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/responsible-api/responsible-auth/resource/authcode"
+	"github.com/responsible-api/responsible-auth/resource/client"
+	"github.com/responsible-api/responsible-auth/resource/devicecode"
+	"github.com/responsible-api/responsible-auth/storage"
+)
+
+// ClientFactory returns a fresh, empty client storage.
+type ClientFactory func(t *testing.T) storage.ClientStorage
+
+// CodeFactory returns a fresh, empty authorization code storage.
+type CodeFactory func(t *testing.T) storage.AuthorizationCodeStorage
+
+// DeviceCodeFactory returns a fresh, empty device code storage.
+type DeviceCodeFactory func(t *testing.T) storage.DeviceCodeStorage
+
+// TestClient returns the fixture OAuth client used by the suites.
+func TestClient() *client.Client {
+	return &client.Client{
+		ID:           "test-client",
+		Name:         "Test Client",
+		SecretHash:   "$2a$10$Z0XfUpm1cKe1m0sKOqrjUOd4zTnWJqxdfIpVr1TJnb2Rjkbvc3Zla",
+		RedirectURIs: []string{"https://app.example.com/callback", "http://127.0.0.1:8080/callback"},
+		GrantTypes:   []string{"authorization_code", "refresh_token", "client_credentials"},
+		Scopes:       []string{"read", "write"},
+
+		AccessTokenLifetime:  300,
+		RefreshTokenLifetime: 86400,
+		Created:              1700000000,
+	}
+}
+
+// RunClients executes the conformance suite for storage.ClientStorage implementations.
+func RunClients(t *testing.T, newStorage ClientFactory) {
+	t.Run("CreateAndFindClient", func(t *testing.T) {
+		s := newStorage(t)
+		want := TestClient()
+
+		if err := s.CreateClient(want); err != nil {
+			t.Fatalf("CreateClient() unexpected error = %v", err)
+		}
+
+		got, err := s.FindClient(want.ID)
+		if err != nil {
+			t.Fatalf("FindClient() unexpected error = %v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("FindClient() = %+v, want %+v", got, want)
+		}
+
+		if err := s.CreateClient(TestClient()); !errors.Is(err, storage.ErrClientExists) {
+			t.Errorf("CreateClient() duplicate error = %v, want %v", err, storage.ErrClientExists)
+		}
+	})
+
+	t.Run("UpdateClient", func(t *testing.T) {
+		s := newStorage(t)
+		if err := s.CreateClient(TestClient()); err != nil {
+			t.Fatalf("CreateClient() unexpected error = %v", err)
+		}
+
+		want := TestClient()
+		want.SecretHash = ""
+		want.Scopes = []string{"read"}
+		want.AccessTokenLifetime = 0
+		if err := s.UpdateClient(want); err != nil {
+			t.Fatalf("UpdateClient() unexpected error = %v", err)
+		}
+
+		got, err := s.FindClient(want.ID)
+		if err != nil {
+			t.Fatalf("FindClient() unexpected error = %v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("FindClient() after UpdateClient() = %+v, want %+v", got, want)
+		}
+	})
+
+	t.Run("DeleteClient", func(t *testing.T) {
+		s := newStorage(t)
+		if err := s.CreateClient(TestClient()); err != nil {
+			t.Fatalf("CreateClient() unexpected error = %v", err)
+		}
+
+		if err := s.DeleteClient(TestClient().ID); err != nil {
+			t.Fatalf("DeleteClient() unexpected error = %v", err)
+		}
+		if _, err := s.FindClient(TestClient().ID); !errors.Is(err, storage.ErrClientNotFound) {
+			t.Errorf("FindClient() after DeleteClient() error = %v, want %v", err, storage.ErrClientNotFound)
+		}
+	})
+
+	t.Run("UnknownClient", func(t *testing.T) {
+		s := newStorage(t)
+		for _, id := range []string{"missing", ""} {
+			if _, err := s.FindClient(id); !errors.Is(err, storage.ErrClientNotFound) {
+				t.Errorf("FindClient(%q) error = %v, want %v", id, err, storage.ErrClientNotFound)
+			}
+		}
+		if err := s.UpdateClient(&client.Client{ID: "missing"}); !errors.Is(err, storage.ErrClientNotFound) {
+			t.Errorf("UpdateClient() unknown client error = %v, want %v", err, storage.ErrClientNotFound)
+		}
+		if err := s.DeleteClient("missing"); !errors.Is(err, storage.ErrClientNotFound) {
+			t.Errorf("DeleteClient() unknown client error = %v, want %v", err, storage.ErrClientNotFound)
+		}
+	})
+}
+
+// RunAuthorizationCodes executes the conformance suite for storage.AuthorizationCodeStorage implementations.
+func RunAuthorizationCodes(t *testing.T, newStorage CodeFactory) {
+	newCode := func() *authcode.Code {
+		return &authcode.Code{
+			Hash:        "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08",
+			ClientID:    "test-client",
+			UserName:    "alice",
+			RedirectURI: "https://app.example.com/callback",
+			Scopes:      []string{"read", "write"},
+			Challenge:   "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM",
+			Nonce:       "n-0S6_WzA2Mj",
+			AuthTime:    1700000000,
+			ExpiresAt:   1700000060,
+		}
+	}
+
+	t.Run("ConsumeAuthorizationCode", func(t *testing.T) {
+		s := newStorage(t)
+		want := newCode()
+
+		if err := s.CreateAuthorizationCode(want); err != nil {
+			t.Fatalf("CreateAuthorizationCode() unexpected error = %v", err)
+		}
+
+		got, err := s.ConsumeAuthorizationCode(want.Hash)
+		if err != nil {
+			t.Fatalf("ConsumeAuthorizationCode() unexpected error = %v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("ConsumeAuthorizationCode() = %+v, want %+v", got, want)
+		}
+
+		if _, err := s.ConsumeAuthorizationCode(want.Hash); !errors.Is(err, storage.ErrCodeNotFound) {
+			t.Errorf("ConsumeAuthorizationCode() second use error = %v, want %v", err, storage.ErrCodeNotFound)
+		}
+	})
+
+	t.Run("ConcurrentConsume", func(t *testing.T) {
+		s := newStorage(t)
+		code := newCode()
+		if err := s.CreateAuthorizationCode(code); err != nil {
+			t.Fatalf("CreateAuthorizationCode() unexpected error = %v", err)
+		}
+
+		var consumed int32
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if _, err := s.ConsumeAuthorizationCode(code.Hash); err == nil {
+					atomic.AddInt32(&consumed, 1)
+				}
+			}()
+		}
+		wg.Wait()
+
+		if consumed != 1 {
+			t.Errorf("code consumed %d times, want exactly once", consumed)
+		}
+	})
+}
+
+// RunDeviceCodes executes the conformance suite for storage.DeviceCodeStorage implementations.
+func RunDeviceCodes(t *testing.T, newStorage DeviceCodeFactory) {
+	newCode := func() *devicecode.Code {
+		return &devicecode.Code{
+			Hash:      "2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae",
+			UserCode:  "WDJBMJHT",
+			ClientID:  "test-client",
+			Scopes:    []string{"read"},
+			Status:    devicecode.StatusPending,
+			Interval:  5,
+			ExpiresAt: 1700000600,
+		}
+	}
+
+	t.Run("CreateAndFindDeviceCode", func(t *testing.T) {
+		s := newStorage(t)
+		want := newCode()
+
+		if err := s.CreateDeviceCode(want); err != nil {
+			t.Fatalf("CreateDeviceCode() unexpected error = %v", err)
+		}
+
+		got, err := s.FindDeviceCodeByUserCode(want.UserCode)
+		if err != nil {
+			t.Fatalf("FindDeviceCodeByUserCode() unexpected error = %v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("FindDeviceCodeByUserCode() = %+v, want %+v", got, want)
+		}
+
+		for _, userCode := range []string{"BCDFGHJK", ""} {
+			if _, err := s.FindDeviceCodeByUserCode(userCode); !errors.Is(err, storage.ErrDeviceCodeNotFound) {
+				t.Errorf("FindDeviceCodeByUserCode(%q) error = %v, want %v", userCode, err, storage.ErrDeviceCodeNotFound)
+			}
+		}
+	})
+
+	t.Run("UpdateDeviceCode", func(t *testing.T) {
+		s := newStorage(t)
+		code := newCode()
+		if err := s.CreateDeviceCode(code); err != nil {
+			t.Fatalf("CreateDeviceCode() unexpected error = %v", err)
+		}
+
+		want := newCode()
+		want.Status = devicecode.StatusApproved
+		want.UserName = "alice"
+		want.AuthTime = 1700000100
+		want.LastPoll = 1700000095
+		want.Interval = 10
+
+		got, err := s.UpdateDeviceCode(code.Hash, func(c *devicecode.Code) {
+			c.Status = want.Status
+			c.UserName = want.UserName
+			c.AuthTime = want.AuthTime
+			c.LastPoll = want.LastPoll
+			c.Interval = want.Interval
+		})
+		if err != nil {
+			t.Fatalf("UpdateDeviceCode() unexpected error = %v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("UpdateDeviceCode() = %+v, want %+v", got, want)
+		}
+
+		stored, err := s.FindDeviceCodeByUserCode(code.UserCode)
+		if err != nil {
+			t.Fatalf("FindDeviceCodeByUserCode() unexpected error = %v", err)
+		}
+		if !reflect.DeepEqual(stored, want) {
+			t.Errorf("FindDeviceCodeByUserCode() after UpdateDeviceCode() = %+v, want %+v", stored, want)
+		}
+
+		_, err = s.UpdateDeviceCode("missing", func(c *devicecode.Code) {})
+		if !errors.Is(err, storage.ErrDeviceCodeNotFound) {
+			t.Errorf("UpdateDeviceCode() unknown code error = %v, want %v", err, storage.ErrDeviceCodeNotFound)
+		}
+	})
+
+	t.Run("DeleteDeviceCode", func(t *testing.T) {
+		s := newStorage(t)
+		code := newCode()
+		if err := s.CreateDeviceCode(code); err != nil {
+			t.Fatalf("CreateDeviceCode() unexpected error = %v", err)
+		}
+
+		if err := s.DeleteDeviceCode(code.Hash); err != nil {
+			t.Fatalf("DeleteDeviceCode() unexpected error = %v", err)
+		}
+		if _, err := s.FindDeviceCodeByUserCode(code.UserCode); !errors.Is(err, storage.ErrDeviceCodeNotFound) {
+			t.Errorf("FindDeviceCodeByUserCode() after DeleteDeviceCode() error = %v, want %v", err, storage.ErrDeviceCodeNotFound)
+		}
+		if err := s.DeleteDeviceCode(code.Hash); !errors.Is(err, storage.ErrDeviceCodeNotFound) {
+			t.Errorf("DeleteDeviceCode() second delete error = %v, want %v", err, storage.ErrDeviceCodeNotFound)
+		}
+	})
+
+	t.Run("ConcurrentDelete", func(t *testing.T) {
+		s := newStorage(t)
+		code := newCode()
+		if err := s.CreateDeviceCode(code); err != nil {
+			t.Fatalf("CreateDeviceCode() unexpected error = %v", err)
+		}
+
+		var deleted int32
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				if err := s.DeleteDeviceCode(code.Hash); err == nil {
+					atomic.AddInt32(&deleted, 1)
+				}
+			}()
+		}
+		wg.Wait()
+
+		if deleted != 1 {
+			t.Errorf("device code deleted %d times, want exactly once", deleted)
+		}
+	})
+}
@@ -0,0 +1,168 @@
+package storagetest
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/responsible-api/responsible-auth/resource/role"
+	"github.com/responsible-api/responsible-auth/storage"
+)
+
+// RoleFactory returns a fresh, empty role storage. Roles are assigned to Alice
+// and Bob, so storages that enforce user references must hold both.
+type RoleFactory func(t *testing.T) storage.RoleStorage
+
+// RunRoles executes the conformance suite for storage.RoleStorage implementations.
+func RunRoles(t *testing.T, newStorage RoleFactory) {
+	viewer := func() *role.Role {
+		return &role.Role{Name: "viewer", Description: "Read access", Created: 1700000000}
+	}
+	editor := func() *role.Role {
+		return &role.Role{Name: "editor", Description: "Read and write access", Inherits: "viewer", Created: 1700000001}
+	}
+	create := func(t *testing.T, s storage.RoleStorage, roles ...*role.Role) {
+		t.Helper()
+		for _, r := range roles {
+			if err := s.CreateRole(r); err != nil {
+				t.Fatalf("CreateRole(%s) unexpected error = %v", r.Name, err)
+			}
+		}
+	}
+
+	t.Run("CreateAndFindRole", func(t *testing.T) {
+		s := newStorage(t)
+		create(t, s, viewer(), editor())
+
+		got, err := s.FindRole("editor")
+		if err != nil {
+			t.Fatalf("FindRole() unexpected error = %v", err)
+		}
+		if want := editor(); !reflect.DeepEqual(got, want) {
+			t.Errorf("FindRole() = %+v, want %+v", got, want)
+		}
+
+		all, err := s.FindRoles()
+		if err != nil {
+			t.Fatalf("FindRoles() unexpected error = %v", err)
+		}
+		if want := []*role.Role{editor(), viewer()}; !reflect.DeepEqual(all, want) {
+			t.Errorf("FindRoles() = %+v, want %+v", all, want)
+		}
+
+		if err := s.CreateRole(viewer()); !errors.Is(err, storage.ErrRoleExists) {
+			t.Errorf("CreateRole() duplicate error = %v, want %v", err, storage.ErrRoleExists)
+		}
+		if err := s.CreateRole(&role.Role{Name: "orphan", Inherits: "unknown"}); !errors.Is(err, storage.ErrRoleNotFound) {
+			t.Errorf("CreateRole() with unknown parent error = %v, want %v", err, storage.ErrRoleNotFound)
+		}
+		if _, err := s.FindRole("unknown"); !errors.Is(err, storage.ErrRoleNotFound) {
+			t.Errorf("FindRole() unknown error = %v, want %v", err, storage.ErrRoleNotFound)
+		}
+	})
+
+	t.Run("Permissions", func(t *testing.T) {
+		s := newStorage(t)
+		create(t, s, viewer(), editor())
+
+		write := &role.Permission{RoleName: "editor", Permission: "orders:write", Resource: "orders/*"}
+		read := &role.Permission{RoleName: "editor", Permission: "orders:read"}
+		for _, p := range []*role.Permission{write, read, write} {
+			if err := s.GrantPermission(p); err != nil {
+				t.Fatalf("GrantPermission() unexpected error = %v", err)
+			}
+		}
+		if err := s.GrantPermission(&role.Permission{RoleName: "unknown", Permission: "orders:read"}); !errors.Is(err, storage.ErrRoleNotFound) {
+			t.Errorf("GrantPermission() unknown role error = %v, want %v", err, storage.ErrRoleNotFound)
+		}
+
+		got, err := s.FindPermissions("editor")
+		if err != nil {
+			t.Fatalf("FindPermissions() unexpected error = %v", err)
+		}
+		if want := []*role.Permission{read, write}; !reflect.DeepEqual(got, want) {
+			t.Errorf("FindPermissions() = %+v, want %+v", got, want)
+		}
+		if got, _ := s.FindPermissions("viewer"); len(got) != 0 {
+			t.Errorf("FindPermissions() of another role = %+v, want none", got)
+		}
+
+		if err := s.RevokePermission(write); err != nil {
+			t.Fatalf("RevokePermission() unexpected error = %v", err)
+		}
+		if err := s.RevokePermission(write); !errors.Is(err, storage.ErrPermissionNotFound) {
+			t.Errorf("RevokePermission() twice error = %v, want %v", err, storage.ErrPermissionNotFound)
+		}
+		if got, _ := s.FindPermissions("editor"); !reflect.DeepEqual(got, []*role.Permission{read}) {
+			t.Errorf("FindPermissions() after RevokePermission() = %+v, want %+v", got, []*role.Permission{read})
+		}
+	})
+
+	t.Run("Assignments", func(t *testing.T) {
+		s := newStorage(t)
+		create(t, s, viewer(), editor())
+
+		for _, name := range []string{"viewer", "editor", "viewer"} {
+			if err := s.AssignRole(Alice().Name, name); err != nil {
+				t.Fatalf("AssignRole(%s) unexpected error = %v", name, err)
+			}
+		}
+		if err := s.AssignRole(Bob().Name, "viewer"); err != nil {
+			t.Fatalf("AssignRole() unexpected error = %v", err)
+		}
+		if err := s.AssignRole(Alice().Name, "unknown"); !errors.Is(err, storage.ErrRoleNotFound) {
+			t.Errorf("AssignRole() unknown role error = %v, want %v", err, storage.ErrRoleNotFound)
+		}
+
+		got, err := s.FindUserRoles(Alice().Name)
+		if err != nil {
+			t.Fatalf("FindUserRoles() unexpected error = %v", err)
+		}
+		if want := []string{"editor", "viewer"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("FindUserRoles() = %v, want %v", got, want)
+		}
+
+		if err := s.UnassignRole(Alice().Name, "viewer"); err != nil {
+			t.Fatalf("UnassignRole() unexpected error = %v", err)
+		}
+		if err := s.UnassignRole(Alice().Name, "viewer"); !errors.Is(err, storage.ErrAssignmentNotFound) {
+			t.Errorf("UnassignRole() twice error = %v, want %v", err, storage.ErrAssignmentNotFound)
+		}
+		if got, _ := s.FindUserRoles(Alice().Name); !reflect.DeepEqual(got, []string{"editor"}) {
+			t.Errorf("FindUserRoles() after UnassignRole() = %v, want [editor]", got)
+		}
+		if got, _ := s.FindUserRoles("nobody"); len(got) != 0 {
+			t.Errorf("FindUserRoles() unknown user = %v, want none", got)
+		}
+	})
+
+	t.Run("DeleteRole", func(t *testing.T) {
+		s := newStorage(t)
+		create(t, s, viewer(), editor())
+		if err := s.GrantPermission(&role.Permission{RoleName: "viewer", Permission: "orders:read"}); err != nil {
+			t.Fatalf("GrantPermission() unexpected error = %v", err)
+		}
+		if err := s.AssignRole(Bob().Name, "viewer"); err != nil {
+			t.Fatalf("AssignRole() unexpected error = %v", err)
+		}
+
+		if err := s.DeleteRole("viewer"); err != nil {
+			t.Fatalf("DeleteRole() unexpected error = %v", err)
+		}
+		if _, err := s.FindRole("viewer"); !errors.Is(err, storage.ErrRoleNotFound) {
+			t.Errorf("FindRole() after DeleteRole() error = %v, want %v", err, storage.ErrRoleNotFound)
+		}
+		if got, _ := s.FindPermissions("viewer"); len(got) != 0 {
+			t.Errorf("FindPermissions() after DeleteRole() = %+v, want none", got)
+		}
+		if got, _ := s.FindUserRoles(Bob().Name); len(got) != 0 {
+			t.Errorf("FindUserRoles() after DeleteRole() = %v, want none", got)
+		}
+		if got, err := s.FindRole("editor"); err != nil || got.Inherits != "" {
+			t.Errorf("FindRole() child after DeleteRole() = %+v, %v, want it to inherit nothing", got, err)
+		}
+		if err := s.DeleteRole("viewer"); !errors.Is(err, storage.ErrRoleNotFound) {
+			t.Errorf("DeleteRole() twice error = %v, want %v", err, storage.ErrRoleNotFound)
+		}
+	})
+}
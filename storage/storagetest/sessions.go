@@ -0,0 +1,161 @@
+package storagetest
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/responsible-api/responsible-auth/resource/session"
+	"github.com/responsible-api/responsible-auth/storage"
+)
+
+// SessionFactory returns a fresh, empty session storage. Sessions are created
+// for Alice and Bob, so storages that enforce user references must hold both.
+type SessionFactory func(t *testing.T) storage.SessionStorage
+
+// RunSessions executes the conformance suite for storage.SessionStorage implementations.
+func RunSessions(t *testing.T, newStorage SessionFactory) {
+	newSession := func(id, userName string, created int64) *session.Session {
+		return &session.Session{
+			ID:         id,
+			UserName:   userName,
+			DeviceName: "Firefox on Linux",
+			UserAgent:  "Mozilla/5.0 (X11; Linux x86_64; rv:128.0) Gecko/20100101 Firefox/128.0",
+			IP:         "192.0.2.10",
+			Created:    created,
+			LastUsed:   created,
+			ExpiresAt:  created + 86400,
+		}
+	}
+	create := func(t *testing.T, s storage.SessionStorage, sessions ...*session.Session) {
+		t.Helper()
+		for _, sess := range sessions {
+			if err := s.CreateSession(sess); err != nil {
+				t.Fatalf("CreateSession() unexpected error = %v", err)
+			}
+		}
+	}
+
+	t.Run("CreateAndFindSession", func(t *testing.T) {
+		s := newStorage(t)
+		want := newSession("session-alice-1", Alice().Name, 1700000000)
+		create(t, s, want)
+
+		got, err := s.FindSession(want.ID)
+		if err != nil {
+			t.Fatalf("FindSession() unexpected error = %v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("FindSession() = %+v, want %+v", got, want)
+		}
+
+		if err := s.CreateSession(newSession(want.ID, Bob().Name, 1700000001)); !errors.Is(err, storage.ErrSessionExists) {
+			t.Errorf("CreateSession() duplicate error = %v, want %v", err, storage.ErrSessionExists)
+		}
+		if _, err := s.FindSession("unknown"); !errors.Is(err, storage.ErrSessionNotFound) {
+			t.Errorf("FindSession() unknown error = %v, want %v", err, storage.ErrSessionNotFound)
+		}
+	})
+
+	t.Run("FindSessionsByUser", func(t *testing.T) {
+		s := newStorage(t)
+		second := newSession("session-alice-2", Alice().Name, 1700000002)
+		first := newSession("session-alice-1", Alice().Name, 1700000001)
+		create(t, s, second, first, newSession("session-bob", Bob().Name, 1700000000))
+
+		got, err := s.FindSessionsByUser(Alice().Name)
+		if err != nil {
+			t.Fatalf("FindSessionsByUser() unexpected error = %v", err)
+		}
+		if want := []*session.Session{first, second}; !reflect.DeepEqual(got, want) {
+			t.Errorf("FindSessionsByUser() = %+v, want %+v", got, want)
+		}
+
+		got, err = s.FindSessionsByUser("nobody")
+		if err != nil {
+			t.Fatalf("FindSessionsByUser() unknown user unexpected error = %v", err)
+		}
+		if len(got) != 0 {
+			t.Errorf("FindSessionsByUser() unknown user = %+v, want none", got)
+		}
+	})
+
+	t.Run("TouchSession", func(t *testing.T) {
+		s := newStorage(t)
+		create(t, s, newSession("session-alice-1", Alice().Name, 1700000000))
+
+		want := newSession("session-alice-1", Alice().Name, 1700000000)
+		want.LastUsed = 1700000100
+		want.IP = "198.51.100.7"
+		if err := s.TouchSession(want.ID, want.LastUsed, want.IP); err != nil {
+			t.Fatalf("TouchSession() unexpected error = %v", err)
+		}
+		// Touching again with the same values is not an error
+		if err := s.TouchSession(want.ID, want.LastUsed, want.IP); err != nil {
+			t.Fatalf("TouchSession() unchanged unexpected error = %v", err)
+		}
+
+		got, err := s.FindSession(want.ID)
+		if err != nil {
+			t.Fatalf("FindSession() unexpected error = %v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("FindSession() after TouchSession() = %+v, want %+v", got, want)
+		}
+
+		if err := s.TouchSession("unknown", 1700000100, "198.51.100.7"); !errors.Is(err, storage.ErrSessionNotFound) {
+			t.Errorf("TouchSession() unknown error = %v, want %v", err, storage.ErrSessionNotFound)
+		}
+	})
+
+	t.Run("DeleteSession", func(t *testing.T) {
+		s := newStorage(t)
+		create(t, s, newSession("session-alice-1", Alice().Name, 1700000000))
+
+		if err := s.DeleteSession("session-alice-1"); err != nil {
+			t.Fatalf("DeleteSession() unexpected error = %v", err)
+		}
+		if _, err := s.FindSession("session-alice-1"); !errors.Is(err, storage.ErrSessionNotFound) {
+			t.Errorf("FindSession() after DeleteSession() error = %v, want %v", err, storage.ErrSessionNotFound)
+		}
+		if err := s.DeleteSession("session-alice-1"); !errors.Is(err, storage.ErrSessionNotFound) {
+			t.Errorf("DeleteSession() twice error = %v, want %v", err, storage.ErrSessionNotFound)
+		}
+	})
+
+	t.Run("DeleteSessionsByUser", func(t *testing.T) {
+		s := newStorage(t)
+		bob := newSession("session-bob", Bob().Name, 1700000000)
+		kept := newSession("session-alice-2", Alice().Name, 1700000002)
+		create(t, s,
+			newSession("session-alice-1", Alice().Name, 1700000001),
+			kept,
+			newSession("session-alice-3", Alice().Name, 1700000003),
+			bob,
+		)
+
+		deleted, err := s.DeleteSessionsByUser(Alice().Name, kept.ID)
+		if err != nil {
+			t.Fatalf("DeleteSessionsByUser() unexpected error = %v", err)
+		}
+		if deleted != 2 {
+			t.Errorf("DeleteSessionsByUser() = %d, want 2", deleted)
+		}
+
+		got, err := s.FindSessionsByUser(Alice().Name)
+		if err != nil {
+			t.Fatalf("FindSessionsByUser() unexpected error = %v", err)
+		}
+		if want := []*session.Session{kept}; !reflect.DeepEqual(got, want) {
+			t.Errorf("FindSessionsByUser() after DeleteSessionsByUser() = %+v, want %+v", got, want)
+		}
+
+		deleted, err = s.DeleteSessionsByUser(Alice().Name, "")
+		if err != nil || deleted != 1 {
+			t.Errorf("DeleteSessionsByUser() without exception = %d, %v, want 1", deleted, err)
+		}
+		if _, err := s.FindSession(bob.ID); err != nil {
+			t.Errorf("DeleteSessionsByUser() removed another user's session: %v", err)
+		}
+	})
+}
@@ -0,0 +1,658 @@
+// Package storagetest provides a reusable conformance suite for
+// storage.UserStorage implementations.
+//
+// A backend opts in by calling Run from one of its own tests with a Factory
+// that returns a fresh, empty storage seeded with the supplied users:
+//
+//	func TestConformance(t *testing.T) {
+//		storagetest.Run(t, func(t *testing.T, users ...*user.User) storage.UserStorage {
+//			return NewMyStorage(users...)
+//		})
+//	}
+//
+// The suite pins down the semantics every backend must agree on: users are
+// looked up by mail or by account_id (never by name), empty secrets, API keys
+// and refresh tokens never match, storing a refresh token replaces the
+// previous one, secrets may be bcrypt hashed, and user management keys on the
+// unique name.
+package storagetest
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/responsible-api/responsible-auth/resource/user"
+	"github.com/responsible-api/responsible-auth/storage"
+)
+
+// Factory returns a fresh storage containing exactly the given users.
+// It is called once per subtest so implementations do not need to reset state.
+type Factory func(t *testing.T, users ...*user.User) storage.UserStorage
+
+// Alice returns the primary fixture user used by the suite.
+func Alice() *user.User {
+	return &user.User{
+		AccountID: 1001,
+		Name:      "alice",
+		Mail:      "alice@example.com",
+		Created:   1700000000,
+		Access:    1700000000,
+		Status:    1,
+		Secret:    "alice-secret",
+		APIKey:    "alice-api-key",
+	}
+}
+
+// Bob returns a second fixture user so lookups can prove they return the right row.
+func Bob() *user.User {
+	return &user.User{
+		AccountID: 1002,
+		Name:      "bob",
+		Mail:      "bob@example.com",
+		Created:   1700000000,
+		Access:    1700000000,
+		Status:    1,
+		Secret:    "bob-secret",
+		APIKey:    "bob-api-key",
+	}
+}
+
+// Run executes the full conformance suite against the storage built by newStorage.
+func Run(t *testing.T, newStorage Factory) {
+	t.Run("FindUserByCredentials", func(t *testing.T) { testFindUserByCredentials(t, newStorage) })
+	t.Run("FindUserByAPIKey", func(t *testing.T) { testFindUserByAPIKey(t, newStorage) })
+	t.Run("UpdateRefreshToken", func(t *testing.T) { testUpdateRefreshToken(t, newStorage) })
+	t.Run("ValidateRefreshToken", func(t *testing.T) { testValidateRefreshToken(t, newStorage) })
+	t.Run("FindUserByName", func(t *testing.T) { testFindUserByName(t, newStorage) })
+	t.Run("FindUserByMail", func(t *testing.T) { testFindUserByMail(t, newStorage) })
+	t.Run("CreateUser", func(t *testing.T) { testCreateUser(t, newStorage) })
+	t.Run("UpdateUser", func(t *testing.T) { testUpdateUser(t, newStorage) })
+	t.Run("UpdateAccess", func(t *testing.T) { testUpdateAccess(t, newStorage) })
+	t.Run("DeleteUser", func(t *testing.T) { testDeleteUser(t, newStorage) })
+	t.Run("HashedSecret", func(t *testing.T) { testHashedSecret(t, newStorage) })
+	t.Run("Concurrency", func(t *testing.T) { testConcurrency(t, newStorage) })
+}
+
+func testFindUserByCredentials(t *testing.T, newStorage Factory) {
+	alice, bob := Alice(), Bob()
+	accountID := strconv.FormatUint(alice.AccountID, 10)
+
+	tests := []struct {
+		name        string
+		username    string
+		credentials string
+		expectUser  *user.User
+		expectError error
+	}{
+		{
+			name:        "by mail",
+			username:    alice.Mail,
+			credentials: alice.Secret,
+			expectUser:  alice,
+		},
+		{
+			name:        "by account id",
+			username:    accountID,
+			credentials: alice.Secret,
+			expectUser:  alice,
+		},
+		{
+			name:        "second user by mail",
+			username:    bob.Mail,
+			credentials: bob.Secret,
+			expectUser:  bob,
+		},
+		{
+			name:        "name is not a login identifier",
+			username:    alice.Name,
+			credentials: alice.Secret,
+			expectError: storage.ErrUserNotFound,
+		},
+		{
+			name:        "wrong secret",
+			username:    alice.Mail,
+			credentials: "wrong-secret",
+			expectError: storage.ErrInvalidCredentials,
+		},
+		{
+			name:        "another user's secret",
+			username:    alice.Mail,
+			credentials: bob.Secret,
+			expectError: storage.ErrInvalidCredentials,
+		},
+		{
+			name:        "empty secret",
+			username:    alice.Mail,
+			credentials: "",
+			expectError: storage.ErrInvalidCredentials,
+		},
+		{
+			name:        "wrong secret by account id",
+			username:    accountID,
+			credentials: "wrong-secret",
+			expectError: storage.ErrInvalidCredentials,
+		},
+		{
+			name:        "unknown mail",
+			username:    "nobody@example.com",
+			credentials: alice.Secret,
+			expectError: storage.ErrUserNotFound,
+		},
+		{
+			name:        "unknown account id",
+			username:    "999999",
+			credentials: alice.Secret,
+			expectError: storage.ErrUserNotFound,
+		},
+		{
+			name:        "empty username",
+			username:    "",
+			credentials: alice.Secret,
+			expectError: storage.ErrUserNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newStorage(t, Alice(), Bob())
+			got, err := s.FindUserByCredentials(tt.username, tt.credentials)
+
+			if tt.expectUser == nil {
+				if !errors.Is(err, tt.expectError) {
+					t.Errorf("FindUserByCredentials() error = %v, want %v", err, tt.expectError)
+				}
+				if got != nil {
+					t.Errorf("FindUserByCredentials() expected nil user but got %v", got)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("FindUserByCredentials() unexpected error = %v", err)
+			}
+			assertSameUser(t, "FindUserByCredentials()", got, tt.expectUser)
+		})
+	}
+}
+
+func testFindUserByAPIKey(t *testing.T, newStorage Factory) {
+	alice, bob := Alice(), Bob()
+
+	tests := []struct {
+		name       string
+		apiKey     string
+		expectUser *user.User
+	}{
+		{
+			name:       "known key",
+			apiKey:     alice.APIKey,
+			expectUser: alice,
+		},
+		{
+			name:       "second user's key",
+			apiKey:     bob.APIKey,
+			expectUser: bob,
+		},
+		{
+			name:   "unknown key",
+			apiKey: "unknown-api-key",
+		},
+		{
+			name:   "empty key",
+			apiKey: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keyless := &user.User{AccountID: 1003, Name: "carol", Mail: "carol@example.com", Secret: "carol-secret", Status: 1}
+			s := newStorage(t, Alice(), Bob(), keyless)
+			got, err := s.FindUserByAPIKey(tt.apiKey)
+
+			if tt.expectUser == nil {
+				if err == nil {
+					t.Errorf("FindUserByAPIKey() expected error but got none")
+				}
+				if got != nil {
+					t.Errorf("FindUserByAPIKey() expected nil user but got %v", got)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("FindUserByAPIKey() unexpected error = %v", err)
+			}
+			assertSameUser(t, "FindUserByAPIKey()", got, tt.expectUser)
+		})
+	}
+}
+
+func testUpdateRefreshToken(t *testing.T, newStorage Factory) {
+	alice := Alice()
+
+	tests := []struct {
+		name        string
+		userID      string
+		expectError bool
+	}{
+		{
+			name:   "by mail",
+			userID: alice.Mail,
+		},
+		{
+			name:   "by account id",
+			userID: strconv.FormatUint(alice.AccountID, 10),
+		},
+		{
+			name:        "name is not an identifier",
+			userID:      alice.Name,
+			expectError: true,
+		},
+		{
+			name:        "unknown user",
+			userID:      "nobody@example.com",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newStorage(t, Alice(), Bob())
+			err := s.UpdateRefreshToken(tt.userID, "refresh-token-1")
+
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("UpdateRefreshToken() expected error but got none")
+				}
+				if _, err := s.ValidateRefreshToken("refresh-token-1"); err == nil {
+					t.Errorf("UpdateRefreshToken() stored a token for an unknown user")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("UpdateRefreshToken() unexpected error = %v", err)
+			}
+
+			got, err := s.ValidateRefreshToken("refresh-token-1")
+			if err != nil {
+				t.Fatalf("UpdateRefreshToken() token not stored properly: %v", err)
+			}
+			assertSameUser(t, "ValidateRefreshToken()", got, alice)
+		})
+	}
+}
+
+func testValidateRefreshToken(t *testing.T, newStorage Factory) {
+	alice, bob := Alice(), Bob()
+
+	t.Run("replacement invalidates previous token", func(t *testing.T) {
+		s := newStorage(t, Alice(), Bob())
+		mustUpdate(t, s, alice.Mail, "first-token")
+		mustUpdate(t, s, alice.Mail, "second-token")
+
+		if got, err := s.ValidateRefreshToken("first-token"); err == nil {
+			t.Errorf("ValidateRefreshToken() replaced token still valid for %v", got)
+		}
+
+		got, err := s.ValidateRefreshToken("second-token")
+		if err != nil {
+			t.Fatalf("ValidateRefreshToken() unexpected error = %v", err)
+		}
+		assertSameUser(t, "ValidateRefreshToken()", got, alice)
+	})
+
+	t.Run("tokens are per user", func(t *testing.T) {
+		s := newStorage(t, Alice(), Bob())
+		mustUpdate(t, s, alice.Mail, "alice-token")
+		mustUpdate(t, s, bob.Mail, "bob-token")
+
+		got, err := s.ValidateRefreshToken("alice-token")
+		if err != nil {
+			t.Fatalf("ValidateRefreshToken() unexpected error = %v", err)
+		}
+		assertSameUser(t, "ValidateRefreshToken()", got, alice)
+
+		got, err = s.ValidateRefreshToken("bob-token")
+		if err != nil {
+			t.Fatalf("ValidateRefreshToken() unexpected error = %v", err)
+		}
+		assertSameUser(t, "ValidateRefreshToken()", got, bob)
+	})
+
+	t.Run("unknown token", func(t *testing.T) {
+		s := newStorage(t, Alice(), Bob())
+		mustUpdate(t, s, alice.Mail, "alice-token")
+
+		if got, err := s.ValidateRefreshToken("unknown-token"); err == nil {
+			t.Errorf("ValidateRefreshToken() expected error but got user %v", got)
+		}
+	})
+
+	t.Run("empty token", func(t *testing.T) {
+		s := newStorage(t, Alice(), Bob())
+
+		if got, err := s.ValidateRefreshToken(""); err == nil {
+			t.Errorf("ValidateRefreshToken() expected error but got user %v", got)
+		}
+	})
+}
+
+func testFindUserByName(t *testing.T, newStorage Factory) {
+	s := newStorage(t, Alice(), Bob())
+
+	got, err := s.FindUserByName("bob")
+	if err != nil {
+		t.Fatalf("FindUserByName() unexpected error = %v", err)
+	}
+	assertSameUser(t, "FindUserByName()", got, Bob())
+
+	if _, err := s.FindUserByName("bob@example.com"); !errors.Is(err, storage.ErrUserNotFound) {
+		t.Errorf("FindUserByName() by mail error = %v, want %v", err, storage.ErrUserNotFound)
+	}
+
+	if _, err := s.FindUserByName("nobody"); !errors.Is(err, storage.ErrUserNotFound) {
+		t.Errorf("FindUserByName() unknown error = %v, want %v", err, storage.ErrUserNotFound)
+	}
+}
+
+func testFindUserByMail(t *testing.T, newStorage Factory) {
+	s := newStorage(t, Alice(), Bob())
+
+	got, err := s.FindUserByMail("bob@example.com")
+	if err != nil {
+		t.Fatalf("FindUserByMail() unexpected error = %v", err)
+	}
+	assertSameUser(t, "FindUserByMail()", got, Bob())
+
+	// Account IDs are login identifiers but not mail addresses
+	accountID := strconv.FormatUint(Bob().AccountID, 10)
+	for _, mail := range []string{"bob", "nobody@example.com", "", accountID} {
+		if _, err := s.FindUserByMail(mail); !errors.Is(err, storage.ErrUserNotFound) {
+			t.Errorf("FindUserByMail(%q) error = %v, want %v", mail, err, storage.ErrUserNotFound)
+		}
+	}
+}
+
+func testCreateUser(t *testing.T, newStorage Factory) {
+	carol := &user.User{AccountID: 1003, Name: "carol", Mail: "carol@example.com", Secret: "carol-secret", APIKey: "carol-api-key", Status: 1}
+
+	tests := []struct {
+		name        string
+		user        *user.User
+		expectError error
+	}{
+		{
+			name: "new user",
+			user: carol,
+		},
+		{
+			name:        "duplicate name",
+			user:        &user.User{AccountID: 1004, Name: "alice", Mail: "other@example.com", Secret: "x"},
+			expectError: storage.ErrUserExists,
+		},
+		{
+			name:        "duplicate mail",
+			user:        &user.User{AccountID: 1004, Name: "other", Mail: "alice@example.com", Secret: "x"},
+			expectError: storage.ErrUserExists,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newStorage(t, Alice())
+			err := s.CreateUser(tt.user)
+
+			if tt.expectError != nil {
+				if !errors.Is(err, tt.expectError) {
+					t.Errorf("CreateUser() error = %v, want %v", err, tt.expectError)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("CreateUser() unexpected error = %v", err)
+			}
+
+			got, err := s.FindUserByCredentials(tt.user.Mail, tt.user.Secret)
+			if err != nil {
+				t.Fatalf("FindUserByCredentials() after CreateUser() error = %v", err)
+			}
+			assertSameUser(t, "FindUserByCredentials()", got, tt.user)
+
+			got, err = s.FindUserByAPIKey(tt.user.APIKey)
+			if err != nil {
+				t.Fatalf("FindUserByAPIKey() after CreateUser() error = %v", err)
+			}
+			assertSameUser(t, "FindUserByAPIKey()", got, tt.user)
+		})
+	}
+}
+
+func testUpdateUser(t *testing.T, newStorage Factory) {
+	t.Run("changes are persisted", func(t *testing.T) {
+		s := newStorage(t, Alice(), Bob())
+
+		updated := Alice()
+		updated.Mail = "alice@new.example.com"
+		updated.Status = 2
+		updated.Secret = "new-secret"
+		updated.APIKey = "new-api-key"
+		if err := s.UpdateUser(updated); err != nil {
+			t.Fatalf("UpdateUser() unexpected error = %v", err)
+		}
+
+		got, err := s.FindUserByName("alice")
+		if err != nil {
+			t.Fatalf("FindUserByName() unexpected error = %v", err)
+		}
+		assertSameUser(t, "FindUserByName()", got, updated)
+		if got.Status != updated.Status {
+			t.Errorf("FindUserByName() user.Status = %v, want %v", got.Status, updated.Status)
+		}
+
+		if _, err := s.FindUserByCredentials("alice@example.com", "new-secret"); err == nil {
+			t.Errorf("FindUserByCredentials() old mail still matches after UpdateUser()")
+		}
+		if _, err := s.FindUserByCredentials(updated.Mail, Alice().Secret); err == nil {
+			t.Errorf("FindUserByCredentials() old secret still matches after UpdateUser()")
+		}
+		if _, err := s.FindUserByCredentials(updated.Mail, "new-secret"); err != nil {
+			t.Errorf("FindUserByCredentials() new credentials error = %v", err)
+		}
+		if _, err := s.FindUserByAPIKey(Alice().APIKey); err == nil {
+			t.Errorf("FindUserByAPIKey() old key still matches after UpdateUser()")
+		}
+		if _, err := s.FindUserByAPIKey("new-api-key"); err != nil {
+			t.Errorf("FindUserByAPIKey() new key error = %v", err)
+		}
+	})
+
+	t.Run("mail taken by another user", func(t *testing.T) {
+		s := newStorage(t, Alice(), Bob())
+
+		updated := Alice()
+		updated.Mail = Bob().Mail
+		if err := s.UpdateUser(updated); !errors.Is(err, storage.ErrUserExists) {
+			t.Errorf("UpdateUser() error = %v, want %v", err, storage.ErrUserExists)
+		}
+	})
+
+	t.Run("unknown user", func(t *testing.T) {
+		s := newStorage(t, Alice())
+
+		if err := s.UpdateUser(Bob()); !errors.Is(err, storage.ErrUserNotFound) {
+			t.Errorf("UpdateUser() error = %v, want %v", err, storage.ErrUserNotFound)
+		}
+	})
+}
+
+func testUpdateAccess(t *testing.T, newStorage Factory) {
+	s := newStorage(t, Alice(), Bob())
+
+	if err := s.UpdateAccess("alice", 1800000000); err != nil {
+		t.Fatalf("UpdateAccess() unexpected error = %v", err)
+	}
+
+	got, err := s.FindUserByName("alice")
+	if err != nil {
+		t.Fatalf("FindUserByName() unexpected error = %v", err)
+	}
+	if got.Access != 1800000000 {
+		t.Errorf("UpdateAccess() user.Access = %v, want %v", got.Access, 1800000000)
+	}
+
+	// Only the access time changes
+	if got.Status != Alice().Status || !got.CheckSecret(Alice().Secret) {
+		t.Errorf("UpdateAccess() changed other fields: %+v", got)
+	}
+
+	other, err := s.FindUserByName("bob")
+	if err != nil {
+		t.Fatalf("FindUserByName() unexpected error = %v", err)
+	}
+	if other.Access != Bob().Access {
+		t.Errorf("UpdateAccess() changed another user's access to %v", other.Access)
+	}
+
+	if err := s.UpdateAccess("nobody", 1800000000); !errors.Is(err, storage.ErrUserNotFound) {
+		t.Errorf("UpdateAccess() unknown user error = %v, want %v", err, storage.ErrUserNotFound)
+	}
+}
+
+func testDeleteUser(t *testing.T, newStorage Factory) {
+	t.Run("removes every lookup", func(t *testing.T) {
+		s := newStorage(t, Alice(), Bob())
+		mustUpdate(t, s, Alice().Mail, "alice-token")
+
+		if err := s.DeleteUser("alice"); err != nil {
+			t.Fatalf("DeleteUser() unexpected error = %v", err)
+		}
+
+		if _, err := s.FindUserByName("alice"); !errors.Is(err, storage.ErrUserNotFound) {
+			t.Errorf("FindUserByName() after DeleteUser() error = %v, want %v", err, storage.ErrUserNotFound)
+		}
+		if _, err := s.FindUserByCredentials(Alice().Mail, Alice().Secret); err == nil {
+			t.Errorf("FindUserByCredentials() still matches after DeleteUser()")
+		}
+		if _, err := s.FindUserByAPIKey(Alice().APIKey); err == nil {
+			t.Errorf("FindUserByAPIKey() still matches after DeleteUser()")
+		}
+		if _, err := s.ValidateRefreshToken("alice-token"); err == nil {
+			t.Errorf("ValidateRefreshToken() still matches after DeleteUser()")
+		}
+		if _, err := s.FindUserByName("bob"); err != nil {
+			t.Errorf("DeleteUser() removed the wrong user: %v", err)
+		}
+	})
+
+	t.Run("unknown user", func(t *testing.T) {
+		s := newStorage(t, Alice())
+
+		if err := s.DeleteUser("nobody"); !errors.Is(err, storage.ErrUserNotFound) {
+			t.Errorf("DeleteUser() error = %v, want %v", err, storage.ErrUserNotFound)
+		}
+	})
+}
+
+func testHashedSecret(t *testing.T, newStorage Factory) {
+	hashed := Alice()
+	secret, err := user.HashSecret(hashed.Secret)
+	if err != nil {
+		t.Fatalf("HashSecret() unexpected error = %v", err)
+	}
+	hashed.Secret = secret
+
+	s := newStorage(t, hashed)
+
+	got, err := s.FindUserByCredentials(hashed.Mail, Alice().Secret)
+	if err != nil {
+		t.Fatalf("FindUserByCredentials() with hashed secret error = %v", err)
+	}
+	assertSameUser(t, "FindUserByCredentials()", got, hashed)
+
+	if _, err := s.FindUserByCredentials(hashed.Mail, secret); err == nil {
+		t.Errorf("FindUserByCredentials() accepted the hash itself as the password")
+	}
+}
+
+func testConcurrency(t *testing.T, newStorage Factory) {
+	const workers = 16
+	const rounds = 25
+
+	alice, bob := Alice(), Bob()
+	s := newStorage(t, Alice(), Bob())
+
+	var wg sync.WaitGroup
+	errs := make(chan error, workers*rounds*3)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			fixture := alice
+			if w%2 == 1 {
+				fixture = bob
+			}
+			for r := 0; r < rounds; r++ {
+				if _, err := s.FindUserByCredentials(fixture.Mail, fixture.Secret); err != nil {
+					errs <- fmt.Errorf("FindUserByCredentials(%s): %w", fixture.Mail, err)
+				}
+				if _, err := s.FindUserByAPIKey(fixture.APIKey); err != nil {
+					errs <- fmt.Errorf("FindUserByAPIKey(%s): %w", fixture.APIKey, err)
+				}
+				token := fmt.Sprintf("%s-%d-%d", fixture.Name, w, r)
+				if err := s.UpdateRefreshToken(fixture.Mail, token); err != nil {
+					errs <- fmt.Errorf("UpdateRefreshToken(%s): %w", fixture.Mail, err)
+				}
+				// Another worker may already have replaced the token, so only
+				// check that a successful lookup never returns the wrong user.
+				if got, err := s.ValidateRefreshToken(token); err == nil && got.Mail != fixture.Mail {
+					errs <- fmt.Errorf("ValidateRefreshToken(%s) returned %s", token, got.Mail)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Error(err)
+	}
+
+	// After the dust settles each user must still own exactly one working token.
+	mustUpdate(t, s, alice.Mail, "final-alice")
+	got, err := s.ValidateRefreshToken("final-alice")
+	if err != nil {
+		t.Fatalf("ValidateRefreshToken() unexpected error = %v", err)
+	}
+	assertSameUser(t, "ValidateRefreshToken()", got, alice)
+}
+
+func mustUpdate(t *testing.T, s storage.UserStorage, userID, token string) {
+	t.Helper()
+	if err := s.UpdateRefreshToken(userID, token); err != nil {
+		t.Fatalf("UpdateRefreshToken(%q) unexpected error = %v", userID, err)
+	}
+}
+
+func assertSameUser(t *testing.T, method string, got, want *user.User) {
+	t.Helper()
+	if got == nil {
+		t.Fatalf("%s expected user but got nil", method)
+	}
+	if got.Mail != want.Mail {
+		t.Errorf("%s user.Mail = %v, want %v", method, got.Mail, want.Mail)
+	}
+	if got.AccountID != want.AccountID {
+		t.Errorf("%s user.AccountID = %v, want %v", method, got.AccountID, want.AccountID)
+	}
+	if got.Name != want.Name {
+		t.Errorf("%s user.Name = %v, want %v", method, got.Name, want.Name)
+	}
+	if got.APIKey != want.APIKey {
+		t.Errorf("%s user.APIKey = %v, want %v", method, got.APIKey, want.APIKey)
+	}
+}
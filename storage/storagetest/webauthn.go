@@ -0,0 +1,224 @@
+package storagetest
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/responsible-api/responsible-auth/resource/credential"
+	"github.com/responsible-api/responsible-auth/storage"
+)
+
+// CredentialFactory returns a fresh, empty credential storage. Credentials are
+// created for Alice and Bob, so storages that enforce user references must hold both.
+type CredentialFactory func(t *testing.T) storage.CredentialStorage
+
+// RunCredentials executes the conformance suite for storage.CredentialStorage implementations.
+func RunCredentials(t *testing.T, newStorage CredentialFactory) {
+	newCredential := func(id, userName string, created int64) *credential.Credential {
+		return &credential.Credential{
+			ID:         id,
+			UserName:   userName,
+			PublicKey:  []byte{0xa5, 0x01, 0x02, 0x03, 0x26},
+			Algorithm:  -7,
+			SignCount:  1,
+			Transports: []string{"internal", "hybrid"},
+			Created:    created,
+			LastUsed:   created,
+		}
+	}
+
+	t.Run("CreateAndFindCredential", func(t *testing.T) {
+		s := newStorage(t)
+		want := newCredential("cred-alice-1", Alice().Name, 1700000000)
+
+		if err := s.CreateCredential(want); err != nil {
+			t.Fatalf("CreateCredential() unexpected error = %v", err)
+		}
+
+		got, err := s.FindCredential(want.ID)
+		if err != nil {
+			t.Fatalf("FindCredential() unexpected error = %v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("FindCredential() = %+v, want %+v", got, want)
+		}
+
+		if err := s.CreateCredential(newCredential(want.ID, Bob().Name, 1700000001)); !errors.Is(err, storage.ErrCredentialExists) {
+			t.Errorf("CreateCredential() duplicate error = %v, want %v", err, storage.ErrCredentialExists)
+		}
+		if _, err := s.FindCredential("unknown"); !errors.Is(err, storage.ErrCredentialNotFound) {
+			t.Errorf("FindCredential() unknown error = %v, want %v", err, storage.ErrCredentialNotFound)
+		}
+	})
+
+	t.Run("FindCredentialsByUser", func(t *testing.T) {
+		s := newStorage(t)
+		second := newCredential("cred-alice-2", Alice().Name, 1700000002)
+		first := newCredential("cred-alice-1", Alice().Name, 1700000001)
+		for _, c := range []*credential.Credential{second, first, newCredential("cred-bob", Bob().Name, 1700000000)} {
+			if err := s.CreateCredential(c); err != nil {
+				t.Fatalf("CreateCredential() unexpected error = %v", err)
+			}
+		}
+
+		got, err := s.FindCredentialsByUser(Alice().Name)
+		if err != nil {
+			t.Fatalf("FindCredentialsByUser() unexpected error = %v", err)
+		}
+		if want := []*credential.Credential{first, second}; !reflect.DeepEqual(got, want) {
+			t.Errorf("FindCredentialsByUser() = %+v, want %+v", got, want)
+		}
+
+		got, err = s.FindCredentialsByUser("nobody")
+		if err != nil {
+			t.Fatalf("FindCredentialsByUser() unknown user unexpected error = %v", err)
+		}
+		if len(got) != 0 {
+			t.Errorf("FindCredentialsByUser() unknown user = %+v, want none", got)
+		}
+	})
+
+	t.Run("UpdateCredential", func(t *testing.T) {
+		s := newStorage(t)
+		if err := s.CreateCredential(newCredential("cred-alice-1", Alice().Name, 1700000000)); err != nil {
+			t.Fatalf("CreateCredential() unexpected error = %v", err)
+		}
+
+		want := newCredential("cred-alice-1", Alice().Name, 1700000000)
+		want.SignCount = 7
+		want.LastUsed = 1700000100
+
+		got, err := s.UpdateCredential(want.ID, func(c *credential.Credential) {
+			c.SignCount = 7
+			c.LastUsed = 1700000100
+		})
+		if err != nil {
+			t.Fatalf("UpdateCredential() unexpected error = %v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("UpdateCredential() = %+v, want %+v", got, want)
+		}
+
+		stored, err := s.FindCredential(want.ID)
+		if err != nil {
+			t.Fatalf("FindCredential() unexpected error = %v", err)
+		}
+		if !reflect.DeepEqual(stored, want) {
+			t.Errorf("FindCredential() after UpdateCredential() = %+v, want %+v", stored, want)
+		}
+
+		_, err = s.UpdateCredential("unknown", func(c *credential.Credential) {})
+		if !errors.Is(err, storage.ErrCredentialNotFound) {
+			t.Errorf("UpdateCredential() unknown error = %v, want %v", err, storage.ErrCredentialNotFound)
+		}
+	})
+
+	t.Run("DeleteCredential", func(t *testing.T) {
+		s := newStorage(t)
+		if err := s.CreateCredential(newCredential("cred-alice-1", Alice().Name, 1700000000)); err != nil {
+			t.Fatalf("CreateCredential() unexpected error = %v", err)
+		}
+
+		if err := s.DeleteCredential("cred-alice-1"); err != nil {
+			t.Fatalf("DeleteCredential() unexpected error = %v", err)
+		}
+		if _, err := s.FindCredential("cred-alice-1"); !errors.Is(err, storage.ErrCredentialNotFound) {
+			t.Errorf("FindCredential() after DeleteCredential() error = %v, want %v", err, storage.ErrCredentialNotFound)
+		}
+		if err := s.DeleteCredential("cred-alice-1"); !errors.Is(err, storage.ErrCredentialNotFound) {
+			t.Errorf("DeleteCredential() twice error = %v, want %v", err, storage.ErrCredentialNotFound)
+		}
+	})
+
+	t.Run("ConcurrentSignCounts", func(t *testing.T) {
+		s := newStorage(t)
+		if err := s.CreateCredential(newCredential("cred-alice-1", Alice().Name, 1700000000)); err != nil {
+			t.Fatalf("CreateCredential() unexpected error = %v", err)
+		}
+
+		// Every login presents the same counter; only one may accept it
+		var accepted int32
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := s.UpdateCredential("cred-alice-1", func(c *credential.Credential) {
+					if c.SignCount < 2 {
+						c.SignCount = 2
+						atomic.AddInt32(&accepted, 1)
+					}
+				})
+				if err != nil {
+					t.Errorf("UpdateCredential() unexpected error = %v", err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		if accepted != 1 {
+			t.Errorf("counter accepted %d times, want exactly once", accepted)
+		}
+	})
+
+	t.Run("ConsumeChallenge", func(t *testing.T) {
+		s := newStorage(t)
+		want := &credential.Challenge{
+			Challenge: "dGVzdC1jaGFsbGVuZ2U",
+			Type:      credential.TypeGet,
+			UserName:  Alice().Name,
+			ExpiresAt: 1700000300,
+		}
+		if err := s.CreateChallenge(want); err != nil {
+			t.Fatalf("CreateChallenge() unexpected error = %v", err)
+		}
+
+		got, err := s.ConsumeChallenge(want.Challenge)
+		if err != nil {
+			t.Fatalf("ConsumeChallenge() unexpected error = %v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("ConsumeChallenge() = %+v, want %+v", got, want)
+		}
+
+		if _, err := s.ConsumeChallenge(want.Challenge); !errors.Is(err, storage.ErrChallengeNotFound) {
+			t.Errorf("ConsumeChallenge() twice error = %v, want %v", err, storage.ErrChallengeNotFound)
+		}
+	})
+
+	t.Run("ConcurrentConsume", func(t *testing.T) {
+		s := newStorage(t)
+		if err := s.CreateChallenge(&credential.Challenge{
+			Challenge: "c2luZ2xlLXVzZQ",
+			Type:      credential.TypeCreate,
+			UserName:  Bob().Name,
+			ExpiresAt: 1700000300,
+		}); err != nil {
+			t.Fatalf("CreateChallenge() unexpected error = %v", err)
+		}
+
+		var consumed int32
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				_, err := s.ConsumeChallenge("c2luZ2xlLXVzZQ")
+				switch {
+				case err == nil:
+					atomic.AddInt32(&consumed, 1)
+				case !errors.Is(err, storage.ErrChallengeNotFound):
+					t.Errorf("ConsumeChallenge() unexpected error = %v", err)
+				}
+			}()
+		}
+		wg.Wait()
+
+		if consumed != 1 {
+			t.Errorf("challenge consumed %d times, want exactly once", consumed)
+		}
+	})
+}
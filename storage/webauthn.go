@@ -0,0 +1,30 @@
+package storage
+
+import "github.com/responsible-api/responsible-auth/resource/credential"
+
+// CredentialStorage persists WebAuthn credentials and the challenges of
+// ongoing ceremonies. Implementations must be safe for concurrent use.
+type CredentialStorage interface {
+	// CreateCredential stores a new credential, returning ErrCredentialExists if the ID is taken
+	CreateCredential(c *credential.Credential) error
+
+	// FindCredential retrieves a credential by its ID or ErrCredentialNotFound
+	FindCredential(id string) (*credential.Credential, error)
+
+	// FindCredentialsByUser retrieves every credential of the named user
+	FindCredentialsByUser(userName string) ([]*credential.Credential, error)
+
+	// UpdateCredential atomically applies update to the credential with the given ID,
+	// so concurrent logins cannot both accept the same sign counter
+	UpdateCredential(id string, update func(c *credential.Credential)) (*credential.Credential, error)
+
+	// DeleteCredential removes the credential with the given ID
+	DeleteCredential(id string) error
+
+	// CreateChallenge stores the challenge of a new ceremony
+	CreateChallenge(c *credential.Challenge) error
+
+	// ConsumeChallenge atomically retrieves and deletes a challenge so it can
+	// complete at most one ceremony. Unknown challenges return ErrChallengeNotFound
+	ConsumeChallenge(challenge string) (*credential.Challenge, error)
+}
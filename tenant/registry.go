@@ -0,0 +1,114 @@
+package tenant
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/responsible-api/responsible-auth/auth"
+	"github.com/responsible-api/responsible-auth/middleware"
+	"github.com/responsible-api/responsible-auth/storage"
+)
+
+// OptionsFunc returns the token options of a tenant, or ErrUnknownTenant.
+// Each tenant should have its own SecretKey, so a leaked key exposes only
+// the tenant it belongs to.
+type OptionsFunc func(id uint64) (auth.AuthOptions, error)
+
+// DerivedOptions gives every tenant the base options with a signing key
+// derived from the base key and the tenant ID, so per-tenant keys need no
+// storage. Rotating the base key rotates the keys of every tenant.
+func DerivedOptions(base auth.AuthOptions) OptionsFunc {
+	return func(id uint64) (auth.AuthOptions, error) {
+		opts := base
+		mac := hmac.New(sha256.New, []byte(base.SecretKey))
+		mac.Write([]byte("tenant:" + strconv.FormatUint(id, 10)))
+		opts.SecretKey = hex.EncodeToString(mac.Sum(nil))
+		return opts, nil
+	}
+}
+
+// Registry keeps one auth wrapper per tenant, created on first use.
+type Registry struct {
+	newProvider func() auth.AuthInterface
+	storage     storage.UserStorage
+	options     OptionsFunc
+
+	mu    sync.Mutex
+	auths map[uint64]*auth.AuthWrapper
+}
+
+// NewRegistry creates a registry whose tenants authenticate with providers
+// made by newProvider, such as service.NewBasicAuth, against the users of
+// storage that belong to them and with the options returned by options.
+func NewRegistry(newProvider func() auth.AuthInterface, storage storage.UserStorage, options OptionsFunc) *Registry {
+	return &Registry{
+		newProvider: newProvider,
+		storage:     storage,
+		options:     options,
+		auths:       make(map[uint64]*auth.AuthWrapper),
+	}
+}
+
+// Auth returns the auth wrapper of the tenant. Its tokens carry the tenant ID
+// in the tid claim, and its provider only accepts tokens that do.
+func (g *Registry) Auth(id uint64) (*auth.AuthWrapper, error) {
+	if id == 0 {
+		return nil, ErrUnknownTenant
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if a, ok := g.auths[id]; ok {
+		return a, nil
+	}
+
+	opts, err := g.options(id)
+	if err != nil {
+		return nil, err
+	}
+	opts.TenantID = id
+
+	a := auth.NewAuth(g.newProvider(), Storage(g.storage, id), opts)
+	g.auths[id] = a
+	return a, nil
+}
+
+// Forget drops the cached wrapper of the tenant, so the next call to Auth
+// picks up changed options such as a rotated signing key.
+func (g *Registry) Forget(id uint64) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	delete(g.auths, id)
+}
+
+// Authenticate is middleware.Authenticate with the provider of the request's
+// tenant, so tokens of other tenants are rejected. It must run after Resolve.
+func (g *Registry) Authenticate() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id, ok := FromContext(r.Context())
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+
+			a, err := g.Auth(id)
+			if err != nil {
+				if errors.Is(err, ErrUnknownTenant) {
+					http.NotFound(w, r)
+					return
+				}
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+
+			middleware.Authenticate(a.Provider)(next).ServeHTTP(w, r)
+		})
+	}
+}
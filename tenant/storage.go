@@ -0,0 +1,106 @@
+package tenant
+
+import (
+	"strconv"
+
+	"github.com/responsible-api/responsible-auth/resource/user"
+	"github.com/responsible-api/responsible-auth/storage"
+)
+
+// scopedStorage limits a UserStorage to the users of one tenant.
+type scopedStorage struct {
+	storage.UserStorage
+	tenant uint64
+}
+
+// Storage returns s limited to the users whose AccountID is the tenant's.
+// Users of other tenants are reported as storage.ErrUserNotFound, so a
+// tenant cannot tell them apart from users that do not exist, and new users
+// are created in the tenant.
+func Storage(s storage.UserStorage, id uint64) storage.UserStorage {
+	return &scopedStorage{UserStorage: s, tenant: id}
+}
+
+// own returns u if it belongs to the tenant.
+func (s *scopedStorage) own(u *user.User, err error) (*user.User, error) {
+	if err != nil {
+		return nil, err
+	}
+	if u.AccountID != s.tenant {
+		return nil, storage.ErrUserNotFound
+	}
+	return u, nil
+}
+
+// owns checks that the user with the given name belongs to the tenant.
+func (s *scopedStorage) owns(name string) error {
+	_, err := s.FindUserByName(name)
+	return err
+}
+
+func (s *scopedStorage) FindUserByCredentials(username, credentials string) (*user.User, error) {
+	return s.own(s.UserStorage.FindUserByCredentials(username, credentials))
+}
+
+func (s *scopedStorage) FindUserByAPIKey(apiKey string) (*user.User, error) {
+	return s.own(s.UserStorage.FindUserByAPIKey(apiKey))
+}
+
+func (s *scopedStorage) ValidateRefreshToken(refreshToken string) (*user.User, error) {
+	return s.own(s.UserStorage.ValidateRefreshToken(refreshToken))
+}
+
+func (s *scopedStorage) FindUserByName(name string) (*user.User, error) {
+	return s.own(s.UserStorage.FindUserByName(name))
+}
+
+func (s *scopedStorage) FindUserByMail(mail string) (*user.User, error) {
+	return s.own(s.UserStorage.FindUserByMail(mail))
+}
+
+// UpdateRefreshToken accepts the same mail or account ID as FindUserByCredentials.
+func (s *scopedStorage) UpdateRefreshToken(userID string, refreshToken string) error {
+	if userID != strconv.FormatUint(s.tenant, 10) {
+		if _, err := s.FindUserByMail(userID); err != nil {
+			return err
+		}
+	}
+	return s.UserStorage.UpdateRefreshToken(userID, refreshToken)
+}
+
+// CreateUser creates u in the tenant. Users without an AccountID are given
+// the tenant's; users of another tenant are rejected with ErrWrongTenant.
+func (s *scopedStorage) CreateUser(u *user.User) error {
+	if u.AccountID == 0 {
+		u.AccountID = s.tenant
+	}
+	if u.AccountID != s.tenant {
+		return ErrWrongTenant
+	}
+	return s.UserStorage.CreateUser(u)
+}
+
+// UpdateUser updates a user of the tenant, which cannot be moved to another tenant.
+func (s *scopedStorage) UpdateUser(u *user.User) error {
+	if u.AccountID != s.tenant {
+		return ErrWrongTenant
+	}
+	if err := s.owns(u.Name); err != nil {
+		return err
+	}
+	return s.UserStorage.UpdateUser(u)
+}
+
+func (s *scopedStorage) UpdateAccess(name string, access uint64) error {
+	if err := s.owns(name); err != nil {
+		return err
+	}
+	return s.UserStorage.UpdateAccess(name, access)
+}
+
+func (s *scopedStorage) DeleteUser(name string) error {
+	if err := s.owns(name); err != nil {
+		return err
+	}
+	return s.UserStorage.DeleteUser(name)
+}
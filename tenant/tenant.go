@@ -0,0 +1,127 @@
+// Package tenant isolates the users and tokens of separate accounts.
+//
+// A tenant is an account, identified by user.User.AccountID. A Resolver finds
+// the tenant of each request from a header, subdomain or path segment, and a
+// Registry keeps one provider per tenant, each with its own signing key and
+// options and with storage lookups limited to that tenant's users. Tokens
+// carry their tenant in the tid claim, so a token issued for one tenant fails
+// validation on the routes of another.
+package tenant
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrNoTenant is returned when a request does not name a tenant.
+	ErrNoTenant = errors.New("no tenant in request")
+
+	// ErrUnknownTenant is returned when the named tenant does not exist.
+	ErrUnknownTenant = errors.New("unknown tenant")
+
+	// ErrWrongTenant is returned when storing a user that belongs to another tenant.
+	ErrWrongTenant = errors.New("user belongs to another tenant")
+)
+
+// Resolver returns the tenant a request is made to.
+type Resolver func(r *http.Request) (uint64, error)
+
+// Lookup maps the tenant key found in a request, such as a subdomain, to the
+// tenant's account ID. It returns ErrUnknownTenant for keys it does not know.
+type Lookup func(key string) (uint64, error)
+
+// ParseID is a Lookup for requests that name the tenant by its decimal account ID.
+func ParseID(key string) (uint64, error) {
+	id, err := strconv.ParseUint(key, 10, 64)
+	if err != nil || id == 0 {
+		return 0, ErrUnknownTenant
+	}
+	return id, nil
+}
+
+// FromHeader resolves the tenant from the named request header, such as "X-Tenant-ID".
+func FromHeader(name string, lookup Lookup) Resolver {
+	return func(r *http.Request) (uint64, error) {
+		key := strings.TrimSpace(r.Header.Get(name))
+		if key == "" {
+			return 0, ErrNoTenant
+		}
+		return lookup(key)
+	}
+}
+
+// FromSubdomain resolves the tenant from the subdomain of domain the request
+// is made to, so with domain "example.com" a request to acme.example.com
+// looks up "acme". Only a single label is accepted as the subdomain.
+func FromSubdomain(domain string, lookup Lookup) Resolver {
+	suffix := "." + strings.ToLower(strings.Trim(domain, "."))
+	return func(r *http.Request) (uint64, error) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		host = strings.ToLower(strings.TrimSuffix(host, "."))
+
+		key, ok := strings.CutSuffix(host, suffix)
+		if !ok || key == "" || strings.Contains(key, ".") {
+			return 0, ErrNoTenant
+		}
+		return lookup(key)
+	}
+}
+
+// FromPath resolves the tenant from the path segment following prefix, so
+// with prefix "/tenants/" a request to /tenants/acme/orders looks up "acme".
+func FromPath(prefix string, lookup Lookup) Resolver {
+	return func(r *http.Request) (uint64, error) {
+		rest, ok := strings.CutPrefix(r.URL.Path, prefix)
+		if !ok {
+			return 0, ErrNoTenant
+		}
+		key, _, _ := strings.Cut(rest, "/")
+		if key == "" {
+			return 0, ErrNoTenant
+		}
+		return lookup(key)
+	}
+}
+
+type contextKey int
+
+const tenantKey contextKey = iota
+
+// WithTenant returns a copy of ctx carrying the tenant ID.
+func WithTenant(ctx context.Context, id uint64) context.Context {
+	return context.WithValue(ctx, tenantKey, id)
+}
+
+// FromContext returns the tenant stored by Resolve.
+func FromContext(ctx context.Context) (uint64, bool) {
+	id, ok := ctx.Value(tenantKey).(uint64)
+	return id, ok
+}
+
+// Resolve stores the tenant of each request in its context and rejects
+// requests whose tenant cannot be resolved with 404 Not Found, so routes of
+// tenants that do not exist look like routes that do not exist.
+func Resolve(resolver Resolver) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			id, err := resolver(r)
+			if err != nil {
+				if errors.Is(err, ErrNoTenant) || errors.Is(err, ErrUnknownTenant) {
+					http.NotFound(w, r)
+					return
+				}
+				http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(WithTenant(r.Context(), id)))
+		})
+	}
+}
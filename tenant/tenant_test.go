@@ -0,0 +1,261 @@
+package tenant
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/responsible-api/responsible-auth/auth"
+	"github.com/responsible-api/responsible-auth/concerns"
+	"github.com/responsible-api/responsible-auth/examples/memory"
+	"github.com/responsible-api/responsible-auth/resource/user"
+	"github.com/responsible-api/responsible-auth/service"
+	"github.com/responsible-api/responsible-auth/storage"
+	"github.com/responsible-api/responsible-auth/testutils"
+)
+
+const (
+	acme   = 1001
+	globex = 1002
+)
+
+// lookup knows the tenants acme and globex by name.
+func lookup(key string) (uint64, error) {
+	switch key {
+	case "acme":
+		return acme, nil
+	case "globex":
+		return globex, nil
+	}
+	return 0, ErrUnknownTenant
+}
+
+// newTestStorage returns alice of acme and bob of globex.
+func newTestStorage() storage.UserStorage {
+	return memory.NewInMemoryStorageWithUsers(
+		&user.User{AccountID: acme, Name: "alice", Mail: "alice@example.com", Secret: "alice-secret", Status: user.StatusActive},
+		&user.User{AccountID: globex, Name: "bob", Mail: "bob@example.com", Secret: "bob-secret", Status: user.StatusActive},
+	)
+}
+
+// sharedKey gives every tenant the same options, so only the tid claim keeps tokens apart.
+func sharedKey(uint64) (auth.AuthOptions, error) {
+	return testutils.TestAuthOptions(), nil
+}
+
+func TestResolvers(t *testing.T) {
+	tests := []struct {
+		name     string
+		resolver Resolver
+		setup    func(r *http.Request)
+		want     uint64
+		wantErr  error
+	}{
+		{
+			name:     "header ID",
+			resolver: FromHeader("X-Tenant-ID", ParseID),
+			setup:    func(r *http.Request) { r.Header.Set("X-Tenant-ID", "1001") },
+			want:     acme,
+		},
+		{
+			name:     "header not an ID",
+			resolver: FromHeader("X-Tenant-ID", ParseID),
+			setup:    func(r *http.Request) { r.Header.Set("X-Tenant-ID", "acme") },
+			wantErr:  ErrUnknownTenant,
+		},
+		{
+			name:     "header missing",
+			resolver: FromHeader("X-Tenant-ID", ParseID),
+			wantErr:  ErrNoTenant,
+		},
+		{
+			name:     "subdomain",
+			resolver: FromSubdomain("example.com", lookup),
+			setup:    func(r *http.Request) { r.Host = "Globex.example.com:8443" },
+			want:     globex,
+		},
+		{
+			name:     "bare domain",
+			resolver: FromSubdomain("example.com", lookup),
+			setup:    func(r *http.Request) { r.Host = "example.com" },
+			wantErr:  ErrNoTenant,
+		},
+		{
+			name:     "nested subdomain",
+			resolver: FromSubdomain("example.com", lookup),
+			setup:    func(r *http.Request) { r.Host = "a.acme.example.com" },
+			wantErr:  ErrNoTenant,
+		},
+		{
+			name:     "unknown subdomain",
+			resolver: FromSubdomain("example.com", lookup),
+			setup:    func(r *http.Request) { r.Host = "initech.example.com" },
+			wantErr:  ErrUnknownTenant,
+		},
+		{
+			name:     "path",
+			resolver: FromPath("/tenants/", lookup),
+			setup:    func(r *http.Request) { r.URL.Path = "/tenants/acme/orders" },
+			want:     acme,
+		},
+		{
+			name:     "path without tenant",
+			resolver: FromPath("/tenants/", lookup),
+			setup:    func(r *http.Request) { r.URL.Path = "/orders" },
+			wantErr:  ErrNoTenant,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.setup != nil {
+				tt.setup(r)
+			}
+			got, err := tt.resolver(r)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("resolver() error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("resolver() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRegistry_Isolation(t *testing.T) {
+	registry := NewRegistry(service.NewBasicAuth, newTestStorage(), sharedKey)
+	acmeAuth, err := registry.Auth(acme)
+	if err != nil {
+		t.Fatal(err)
+	}
+	globexAuth, err := registry.Auth(globex)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := acmeAuth.Provider.CreateAccessToken("alice@example.com", "alice-secret")
+	if err != nil {
+		t.Fatalf("CreateAccessToken() unexpected error = %v", err)
+	}
+	validated, err := acmeAuth.Provider.Validate(token.Raw)
+	if err != nil {
+		t.Fatalf("Validate() on own tenant unexpected error = %v", err)
+	}
+	if tid := validated.Claims.(*concerns.ClaimsGeneric).TenantID; tid != acme {
+		t.Errorf("tid = %d, want %d", tid, acme)
+	}
+
+	if _, err := globexAuth.Provider.Validate(token.Raw); err == nil {
+		t.Error("Validate() accepted a token issued for another tenant")
+	}
+
+	if _, err := globexAuth.Provider.CreateAccessToken("alice@example.com", "alice-secret"); !errors.Is(err, storage.ErrUserNotFound) {
+		t.Errorf("CreateAccessToken() for a user of another tenant error = %v, want %v", err, storage.ErrUserNotFound)
+	}
+
+	refresh, err := acmeAuth.Provider.CreateRefreshToken("alice@example.com", "alice-secret")
+	if err != nil {
+		t.Fatalf("CreateRefreshToken() unexpected error = %v", err)
+	}
+	if _, err := globexAuth.Provider.GrantRefreshToken(refresh.Raw); err == nil {
+		t.Error("GrantRefreshToken() accepted a refresh token issued for another tenant")
+	}
+	if _, err := acmeAuth.Provider.GrantRefreshToken(refresh.Raw); err != nil {
+		t.Errorf("GrantRefreshToken() on own tenant unexpected error = %v", err)
+	}
+
+	if again, _ := registry.Auth(acme); again != acmeAuth {
+		t.Error("Auth() did not reuse the tenant's wrapper")
+	}
+	if _, err := registry.Auth(0); !errors.Is(err, ErrUnknownTenant) {
+		t.Errorf("Auth(0) error = %v, want %v", err, ErrUnknownTenant)
+	}
+}
+
+func TestDerivedOptions(t *testing.T) {
+	options := DerivedOptions(testutils.TestAuthOptions())
+	a, _ := options(acme)
+	b, _ := options(globex)
+	again, _ := options(acme)
+
+	if a.SecretKey == b.SecretKey {
+		t.Error("tenants share a signing key")
+	}
+	if a.SecretKey != again.SecretKey {
+		t.Error("derived key is not stable")
+	}
+	if a.SecretKey == testutils.TestAuthOptions().SecretKey {
+		t.Error("derived key equals the base key")
+	}
+}
+
+func TestStorage(t *testing.T) {
+	base := newTestStorage()
+	s := Storage(base, acme)
+
+	if _, err := s.FindUserByName("bob"); !errors.Is(err, storage.ErrUserNotFound) {
+		t.Errorf("FindUserByName() of another tenant error = %v, want %v", err, storage.ErrUserNotFound)
+	}
+	if _, err := s.FindUserByMail("alice@example.com"); err != nil {
+		t.Errorf("FindUserByMail() of own tenant unexpected error = %v", err)
+	}
+	if err := s.DeleteUser("bob"); !errors.Is(err, storage.ErrUserNotFound) {
+		t.Errorf("DeleteUser() of another tenant error = %v, want %v", err, storage.ErrUserNotFound)
+	}
+	if _, err := base.FindUserByName("bob"); err != nil {
+		t.Errorf("bob was deleted through another tenant: %v", err)
+	}
+
+	bob, _ := base.FindUserByName("bob")
+	bob.AccountID = acme
+	if err := s.UpdateUser(bob); !errors.Is(err, storage.ErrUserNotFound) {
+		t.Errorf("UpdateUser() moving a user in error = %v, want %v", err, storage.ErrUserNotFound)
+	}
+
+	if err := s.CreateUser(&user.User{AccountID: globex, Name: "eve", Mail: "eve@example.com"}); !errors.Is(err, ErrWrongTenant) {
+		t.Errorf("CreateUser() in another tenant error = %v, want %v", err, ErrWrongTenant)
+	}
+}
+
+func TestRegistry_Authenticate(t *testing.T) {
+	registry := NewRegistry(service.NewBasicAuth, newTestStorage(), DerivedOptions(testutils.TestAuthOptions()))
+	acmeAuth, _ := registry.Auth(acme)
+	token, err := acmeAuth.Provider.CreateAccessToken("alice@example.com", "alice-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	handler := Resolve(FromSubdomain("example.com", lookup))(registry.Authenticate()(ok))
+
+	tests := []struct {
+		name   string
+		host   string
+		bearer string
+		want   int
+	}{
+		{name: "own tenant", host: "acme.example.com", bearer: token.Raw, want: http.StatusOK},
+		{name: "other tenant", host: "globex.example.com", bearer: token.Raw, want: http.StatusUnauthorized},
+		{name: "no token", host: "acme.example.com", want: http.StatusUnauthorized},
+		{name: "unknown tenant", host: "initech.example.com", bearer: token.Raw, want: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Host = tt.host
+			if tt.bearer != "" {
+				r.Header.Set("Authorization", "Bearer "+tt.bearer)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+			if w.Code != tt.want {
+				t.Errorf("status = %d, want %d", w.Code, tt.want)
+			}
+		})
+	}
+}
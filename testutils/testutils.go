@@ -1,10 +1,13 @@
 package testutils
 
 import (
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/responsible-api/responsible-auth/auth"
 	"github.com/responsible-api/responsible-auth/resource/user"
+	"github.com/responsible-api/responsible-auth/storage"
 )
 
 // TestAuthOptions returns a standard set of auth options for testing
@@ -69,6 +72,7 @@ func InvalidBasicAuthCredentials() []string {
 
 // MockStorage creates a simple mock storage for testing
 type MockStorage struct {
+	mu            sync.Mutex
 	Users         map[string]*user.User
 	APIKeys       map[string]*user.User
 	RefreshTokens map[string]*user.User
@@ -92,17 +96,23 @@ func NewMockStorage() *MockStorage {
 }
 
 func (m *MockStorage) FindUserByCredentials(username, credentials string) (*user.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	if m.ShouldError {
 		return nil, &TestError{Message: m.ErrorMessage}
 	}
 
-	if user, exists := m.Users[username]; exists && user.Secret == credentials {
+	if user, exists := m.Users[username]; exists && user.CheckSecret(credentials) {
 		return user, nil
 	}
 	return nil, &TestError{Message: "user not found"}
 }
 
 func (m *MockStorage) FindUserByAPIKey(apiKey string) (*user.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	if m.ShouldError {
 		return nil, &TestError{Message: m.ErrorMessage}
 	}
@@ -114,6 +124,9 @@ func (m *MockStorage) FindUserByAPIKey(apiKey string) (*user.User, error) {
 }
 
 func (m *MockStorage) UpdateRefreshToken(userID string, refreshToken string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	if m.ShouldError {
 		return &TestError{Message: m.ErrorMessage}
 	}
@@ -130,6 +143,9 @@ func (m *MockStorage) UpdateRefreshToken(userID string, refreshToken string) err
 }
 
 func (m *MockStorage) ValidateRefreshToken(refreshToken string) (*user.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	if m.ShouldError {
 		return nil, &TestError{Message: m.ErrorMessage}
 	}
@@ -140,8 +156,126 @@ func (m *MockStorage) ValidateRefreshToken(refreshToken string) (*user.User, err
 	return nil, &TestError{Message: "refresh token not found"}
 }
 
+func (m *MockStorage) FindUserByName(name string) (*user.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.ShouldError {
+		return nil, &TestError{Message: m.ErrorMessage}
+	}
+
+	for _, user := range m.Users {
+		if user.Name == name {
+			found := *user
+			return &found, nil
+		}
+	}
+	return nil, storage.ErrUserNotFound
+}
+
+func (m *MockStorage) FindUserByMail(mail string) (*user.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.ShouldError {
+		return nil, &TestError{Message: m.ErrorMessage}
+	}
+
+	for _, user := range m.Users {
+		if user.Mail == mail && mail != "" {
+			found := *user
+			return &found, nil
+		}
+	}
+	return nil, storage.ErrUserNotFound
+}
+
+func (m *MockStorage) CreateUser(u *user.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.ShouldError {
+		return &TestError{Message: m.ErrorMessage}
+	}
+
+	for _, existing := range m.Users {
+		if existing.Name == u.Name || existing.Mail == u.Mail {
+			return storage.ErrUserExists
+		}
+	}
+	m.Users[u.Mail] = u
+	m.Users[strconv.FormatUint(u.AccountID, 10)] = u
+	if u.APIKey != "" {
+		m.APIKeys[u.APIKey] = u
+	}
+	return nil
+}
+
+func (m *MockStorage) UpdateUser(u *user.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.ShouldError {
+		return &TestError{Message: m.ErrorMessage}
+	}
+
+	for _, existing := range m.Users {
+		if existing.Name == u.Name {
+			*existing = *u
+			return nil
+		}
+	}
+	return storage.ErrUserNotFound
+}
+
+func (m *MockStorage) UpdateAccess(name string, access uint64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.ShouldError {
+		return &TestError{Message: m.ErrorMessage}
+	}
+
+	for _, user := range m.Users {
+		if user.Name == name {
+			user.Access = access
+			return nil
+		}
+	}
+	return storage.ErrUserNotFound
+}
+
+func (m *MockStorage) DeleteUser(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.ShouldError {
+		return &TestError{Message: m.ErrorMessage}
+	}
+
+	found := false
+	for key, existing := range m.Users {
+		if existing.Name == name {
+			delete(m.Users, key)
+			found = true
+		}
+	}
+	for key, existing := range m.APIKeys {
+		if existing.Name == name {
+			delete(m.APIKeys, key)
+		}
+	}
+	if !found {
+		return storage.ErrUserNotFound
+	}
+	return nil
+}
+
 // SetError configures the mock to return errors
 func (m *MockStorage) SetError(shouldError bool, message string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	m.ShouldError = shouldError
 	m.ErrorMessage = message
 }
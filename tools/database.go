@@ -2,7 +2,7 @@ package tools
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
 	"os/signal"
 	"syscall"
@@ -17,15 +17,25 @@ import (
 const fmtMysqlDBString = "%s:%s@tcp(%s:%d)/%s"
 
 func NewDatabase() (*gorm.DB, error) {
-	db, err := DBCon()
+	return NewDatabaseWithLogger(slog.Default())
+}
+
+// NewDatabaseWithLogger is NewDatabase logging to the given logger.
+func NewDatabaseWithLogger(log *slog.Logger) (*gorm.DB, error) {
+	db, err := DBConWithLogger(log)
 	if err != nil {
-		log.Fatalf("DB connection start failure error")
 		return nil, err
 	}
 	return db, nil
 }
 
 func DBCon() (*gorm.DB, error) {
+	return DBConWithLogger(slog.Default())
+}
+
+// DBConWithLogger is DBCon logging to the given logger. The DSN is never
+// logged, as it holds the database password.
+func DBConWithLogger(log *slog.Logger) (*gorm.DB, error) {
 	c := config.ConfigDB()
 	l := logger.Default.LogMode(logger.Silent)
 	if c.Debug {
@@ -33,12 +43,12 @@ func DBCon() (*gorm.DB, error) {
 	}
 
 	dsn := fmt.Sprintf(fmtMysqlDBString, c.Username, c.Password, c.Host, c.Port, c.DBName)
-	fmt.Println("Connecting to DB with DSN:", dsn)
+	log.Info("connecting to database", "host", c.Host, "port", c.Port, "database", c.DBName, "user", c.Username)
 	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{
 		Logger: l,
 	})
 	if err != nil {
-		log.Printf("DB connection start failure error: %v", err)
+		log.Error("database connection failed", "host", c.Host, "database", c.DBName, "error", err)
 		return nil, err
 	}
 
@@ -48,7 +58,7 @@ func DBCon() (*gorm.DB, error) {
 		<-sigint
 		sqlDB, err := db.DB()
 		if err != nil {
-			log.Fatalf("DB connection start failure error")
+			log.Error("failed to close database connection", "error", err)
 			return
 		}
 		sqlDB.Close()
@@ -0,0 +1,72 @@
+package webauthn
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// Authenticator data flags
+const (
+	flagUserPresent  = 0x01
+	flagUserVerified = 0x04
+	flagAttestedData = 0x40
+	flagExtensions   = 0x80
+)
+
+// authenticatorData is the parsed authData signed by the authenticator.
+type authenticatorData struct {
+	rpIDHash  []byte
+	flags     byte
+	signCount uint32
+
+	// Only present during registration
+	credentialID []byte
+	publicKey    []byte // COSE_Key
+}
+
+// parseAuthenticatorData decodes authData. Extension outputs are skipped.
+func parseAuthenticatorData(b []byte) (*authenticatorData, error) {
+	if len(b) < 37 {
+		return nil, fmt.Errorf("%w: authenticator data too short", ErrInvalidResponse)
+	}
+	data := &authenticatorData{
+		rpIDHash:  b[:32],
+		flags:     b[32],
+		signCount: binary.BigEndian.Uint32(b[33:37]),
+	}
+	rest := b[37:]
+
+	if data.flags&flagAttestedData != 0 {
+		// AAGUID followed by the credential ID length
+		if len(rest) < 18 {
+			return nil, fmt.Errorf("%w: attested credential data too short", ErrInvalidResponse)
+		}
+		idLength := int(binary.BigEndian.Uint16(rest[16:18]))
+		rest = rest[18:]
+		if idLength == 0 || idLength > 1023 || len(rest) < idLength {
+			return nil, fmt.Errorf("%w: invalid credential ID", ErrInvalidResponse)
+		}
+		data.credentialID = rest[:idLength]
+		rest = rest[idLength:]
+
+		_, after, err := decodeCBOR(rest)
+		if err != nil {
+			return nil, fmt.Errorf("%w: malformed public key", ErrInvalidResponse)
+		}
+		data.publicKey = rest[:len(rest)-len(after)]
+		rest = after
+	}
+
+	if data.flags&flagExtensions != 0 {
+		_, after, err := decodeCBOR(rest)
+		if err != nil {
+			return nil, fmt.Errorf("%w: malformed extensions", ErrInvalidResponse)
+		}
+		rest = after
+	}
+
+	if len(rest) != 0 {
+		return nil, fmt.Errorf("%w: trailing authenticator data", ErrInvalidResponse)
+	}
+	return data, nil
+}
@@ -0,0 +1,123 @@
+package webauthn
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+)
+
+var errInvalidCBOR = errors.New("invalid cbor")
+
+// maxCBORDepth bounds nesting so hostile input cannot exhaust the stack
+const maxCBORDepth = 16
+
+// decodeCBOR decodes the first CBOR data item in b and returns it with the
+// bytes that follow it. Only the subset WebAuthn uses is supported: integers
+// (as int64), byte strings, text strings, arrays, maps keyed by integers or
+// text, tags (which are skipped), booleans and null. Indefinite lengths and
+// floating point numbers are rejected.
+func decodeCBOR(b []byte) (interface{}, []byte, error) {
+	return decodeItem(b, 0)
+}
+
+func decodeItem(b []byte, depth int) (interface{}, []byte, error) {
+	if depth > maxCBORDepth || len(b) == 0 {
+		return nil, nil, errInvalidCBOR
+	}
+	major, info := b[0]>>5, b[0]&0x1f
+
+	if major == 7 {
+		switch info {
+		case 20:
+			return false, b[1:], nil
+		case 21:
+			return true, b[1:], nil
+		case 22, 23:
+			return nil, b[1:], nil
+		}
+		return nil, nil, errInvalidCBOR
+	}
+
+	n, rest, err := readLength(info, b[1:])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	switch major {
+	case 0:
+		if n > math.MaxInt64 {
+			return nil, nil, errInvalidCBOR
+		}
+		return int64(n), rest, nil
+	case 1:
+		if n > math.MaxInt64 {
+			return nil, nil, errInvalidCBOR
+		}
+		return -1 - int64(n), rest, nil
+	case 2, 3:
+		if n > uint64(len(rest)) {
+			return nil, nil, errInvalidCBOR
+		}
+		if major == 3 {
+			return string(rest[:n]), rest[n:], nil
+		}
+		return append([]byte(nil), rest[:n]...), rest[n:], nil
+	case 4:
+		// Every item takes at least one byte, which bounds the allocation
+		if n > uint64(len(rest)) {
+			return nil, nil, errInvalidCBOR
+		}
+		items := make([]interface{}, 0, n)
+		for i := uint64(0); i < n; i++ {
+			var item interface{}
+			if item, rest, err = decodeItem(rest, depth+1); err != nil {
+				return nil, nil, err
+			}
+			items = append(items, item)
+		}
+		return items, rest, nil
+	case 5:
+		if n > uint64(len(rest))/2 {
+			return nil, nil, errInvalidCBOR
+		}
+		m := make(map[interface{}]interface{}, n)
+		for i := uint64(0); i < n; i++ {
+			var key, value interface{}
+			if key, rest, err = decodeItem(rest, depth+1); err != nil {
+				return nil, nil, err
+			}
+			switch key.(type) {
+			case int64, string:
+			default:
+				return nil, nil, errInvalidCBOR
+			}
+			if value, rest, err = decodeItem(rest, depth+1); err != nil {
+				return nil, nil, err
+			}
+			if _, duplicate := m[key]; duplicate {
+				return nil, nil, errInvalidCBOR
+			}
+			m[key] = value
+		}
+		return m, rest, nil
+	default: // 6, a tag on the following item
+		return decodeItem(rest, depth+1)
+	}
+}
+
+// readLength decodes the argument of a data item header.
+func readLength(info byte, b []byte) (uint64, []byte, error) {
+	switch {
+	case info < 24:
+		return uint64(info), b, nil
+	case info == 24 && len(b) >= 1:
+		return uint64(b[0]), b[1:], nil
+	case info == 25 && len(b) >= 2:
+		return uint64(binary.BigEndian.Uint16(b)), b[2:], nil
+	case info == 26 && len(b) >= 4:
+		return uint64(binary.BigEndian.Uint32(b)), b[4:], nil
+	case info == 27 && len(b) >= 8:
+		return binary.BigEndian.Uint64(b), b[8:], nil
+	}
+	return 0, nil, errInvalidCBOR
+}
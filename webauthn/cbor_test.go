@@ -0,0 +1,55 @@
+package webauthn
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+)
+
+func TestDecodeCBOR(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       []byte
+		expected    interface{}
+		expectError bool
+	}{
+		{name: "small uint", input: []byte{0x17}, expected: int64(23)},
+		{name: "uint16", input: []byte{0x19, 0x01, 0x00}, expected: int64(256)},
+		{name: "negative", input: []byte{0x38, 0x63}, expected: int64(-100)},
+		{name: "bytes", input: []byte{0x42, 0x01, 0x02}, expected: []byte{1, 2}},
+		{name: "text", input: []byte{0x63, 'f', 'm', 't'}, expected: "fmt"},
+		{name: "array", input: []byte{0x82, 0x01, 0xf5}, expected: []interface{}{int64(1), true}},
+		{name: "map", input: []byte{0xa1, 0x20, 0xf6}, expected: map[interface{}]interface{}{int64(-1): nil}},
+		{name: "tag", input: []byte{0xc1, 0x01}, expected: int64(1)},
+		{name: "truncated bytes", input: []byte{0x45, 0x01}, expectError: true},
+		{name: "indefinite length", input: []byte{0x5f, 0x41, 0x01, 0xff}, expectError: true},
+		{name: "float", input: []byte{0xf9, 0x3c, 0x00}, expectError: true},
+		{name: "byte string key", input: []byte{0xa1, 0x41, 0x01, 0x01}, expectError: true},
+		{name: "duplicate key", input: []byte{0xa2, 0x01, 0x01, 0x01, 0x02}, expectError: true},
+		{name: "huge array", input: []byte{0x9b, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, expectError: true},
+		{name: "deep nesting", input: bytes.Repeat([]byte{0x81}, 100), expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, rest, err := decodeCBOR(append(tt.input, 0xff))
+
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("decodeCBOR() = %v, want error", got)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("decodeCBOR() unexpected error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("decodeCBOR() = %#v, want %#v", got, tt.expected)
+			}
+			if !bytes.Equal(rest, []byte{0xff}) {
+				t.Errorf("decodeCBOR() rest = %x, want ff", rest)
+			}
+		})
+	}
+}
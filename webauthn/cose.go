@@ -0,0 +1,111 @@
+package webauthn
+
+import (
+	"crypto"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// COSE algorithm identifiers of the supported credential key types.
+const (
+	AlgES256 int64 = -7   // ECDSA with P-256 and SHA-256
+	AlgEdDSA int64 = -8   // Ed25519
+	AlgRS256 int64 = -257 // RSASSA-PKCS1-v1_5 with SHA-256
+)
+
+// SupportedAlgorithms are offered to authenticators in order of preference.
+var SupportedAlgorithms = []int64{AlgES256, AlgEdDSA, AlgRS256}
+
+// ErrUnsupportedAlgorithm is returned for credential keys of other types.
+var ErrUnsupportedAlgorithm = errors.New("unsupported credential algorithm")
+
+// COSE key parameters (RFC 9053)
+const (
+	coseKty = 1
+	coseAlg = 3
+	coseCrv = -1
+	coseX   = -2 // also the RSA modulus
+	coseY   = -3 // also the RSA exponent
+
+	coseKtyOKP = 1
+	coseKtyEC2 = 2
+	coseKtyRSA = 3
+
+	coseCrvP256    = 1
+	coseCrvEd25519 = 6
+)
+
+// parsePublicKey decodes a COSE_Key into its algorithm and public key.
+func parsePublicKey(coseKey []byte) (int64, crypto.PublicKey, error) {
+	decoded, rest, err := decodeCBOR(coseKey)
+	if err != nil || len(rest) != 0 {
+		return 0, nil, fmt.Errorf("%w: malformed public key", ErrInvalidResponse)
+	}
+	m, ok := decoded.(map[interface{}]interface{})
+	if !ok {
+		return 0, nil, fmt.Errorf("%w: malformed public key", ErrInvalidResponse)
+	}
+
+	kty, _ := m[int64(coseKty)].(int64)
+	alg, _ := m[int64(coseAlg)].(int64)
+	crv, _ := m[int64(coseCrv)].(int64)
+	x, _ := m[int64(coseX)].([]byte)
+	y, _ := m[int64(coseY)].([]byte)
+
+	switch {
+	case alg == AlgES256 && kty == coseKtyEC2 && crv == coseCrvP256:
+		if len(x) != 32 || len(y) != 32 {
+			break
+		}
+		// crypto/ecdh rejects points that are not on the curve
+		point := append(append([]byte{4}, x...), y...)
+		if _, err := ecdh.P256().NewPublicKey(point); err != nil {
+			break
+		}
+		return alg, &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}, nil
+
+	case alg == AlgEdDSA && kty == coseKtyOKP && crv == coseCrvEd25519:
+		if len(x) != ed25519.PublicKeySize {
+			break
+		}
+		return alg, ed25519.PublicKey(x), nil
+
+	case alg == AlgRS256 && kty == coseKtyRSA:
+		n := new(big.Int).SetBytes(x)
+		e := new(big.Int).SetBytes(y)
+		if n.BitLen() < 2048 || !e.IsInt64() || e.Int64() < 3 || e.Int64() > 1<<31-1 {
+			break
+		}
+		return alg, &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	default:
+		return 0, nil, fmt.Errorf("%w: %d", ErrUnsupportedAlgorithm, alg)
+	}
+	return 0, nil, fmt.Errorf("%w: invalid key for algorithm %d", ErrInvalidResponse, alg)
+}
+
+// verifySignature checks sig over message with a key returned by parsePublicKey.
+func verifySignature(alg int64, key crypto.PublicKey, message, sig []byte) bool {
+	digest := sha256.Sum256(message)
+
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		return alg == AlgES256 && ecdsa.VerifyASN1(k, digest[:], sig)
+	case ed25519.PublicKey:
+		return alg == AlgEdDSA && ed25519.Verify(k, message, sig)
+	case *rsa.PublicKey:
+		return alg == AlgRS256 && rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], sig) == nil
+	}
+	return false
+}
@@ -0,0 +1,462 @@
+// Package webauthn implements the relying party side of WebAuthn (passkey)
+// registration and login ceremonies.
+//
+// A RelyingParty hands out creation and request options for the browser,
+// keeps each challenge in a storage.CredentialStorage until it is answered,
+// and verifies the authenticator's responses. Registration stores the
+// credential's public key; every login checks the signature against it and
+// advances the stored sign counter, rejecting counters that go backwards as
+// a sign of a cloned authenticator. Only the "none" attestation format is
+// accepted, so no authenticator metadata needs to be maintained.
+package webauthn
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/responsible-api/responsible-auth/mfa"
+	"github.com/responsible-api/responsible-auth/resource/credential"
+	"github.com/responsible-api/responsible-auth/storage"
+)
+
+// DefaultTimeout is how long a ceremony may take when Config.Timeout is zero.
+const DefaultTimeout = 5 * time.Minute
+
+// User verification requirements.
+const (
+	UserVerificationRequired    = "required"
+	UserVerificationPreferred   = "preferred"
+	UserVerificationDiscouraged = "discouraged"
+)
+
+// Authentication method references (RFC 8176) recorded in the amr claim.
+const (
+	MethodHardwareKey = "hwk"
+	MethodUser        = "user"
+)
+
+const publicKeyType = "public-key"
+
+var (
+	// ErrInvalidResponse is returned for malformed or mismatching authenticator responses.
+	ErrInvalidResponse = errors.New("invalid webauthn response")
+
+	// ErrInvalidChallenge is returned for unknown, expired or already used challenges.
+	ErrInvalidChallenge = errors.New("invalid webauthn challenge")
+
+	// ErrUnknownCredential is returned when a login uses a credential that is not registered to the user.
+	ErrUnknownCredential = errors.New("unknown webauthn credential")
+
+	// ErrInvalidSignature is returned when the assertion signature does not verify.
+	ErrInvalidSignature = errors.New("invalid webauthn signature")
+
+	// ErrSignCount is returned when the sign counter did not increase, which
+	// suggests the authenticator has been cloned.
+	ErrSignCount = errors.New("webauthn sign counter did not increase")
+
+	// ErrUserVerification is returned when user verification is required but was not performed.
+	ErrUserVerification = errors.New("webauthn user verification required")
+)
+
+// Config describes the relying party.
+type Config struct {
+	// RPID is the domain credentials are scoped to, e.g. "example.com"
+	RPID string
+
+	// RPName is shown to the user by the authenticator
+	RPName string
+
+	// Origins lists the origins ceremonies may run on, e.g. "https://example.com"
+	Origins []string
+
+	// Timeout bounds each ceremony; DefaultTimeout when zero
+	Timeout time.Duration
+
+	// UserVerification is one of the UserVerification* constants; preferred when empty.
+	// Only "required" rejects responses without user verification.
+	UserVerification string
+}
+
+// Assertion is the outcome of a successful login.
+type Assertion struct {
+	Credential   *credential.Credential
+	UserVerified bool
+}
+
+// AMR returns the authentication methods of the login for the amr claim.
+func (a *Assertion) AMR() []string {
+	if a.UserVerified {
+		// Possession of the key plus a PIN or biometric
+		return []string{MethodHardwareKey, MethodUser, mfa.MethodMFA}
+	}
+	return []string{MethodHardwareKey}
+}
+
+// RelyingParty runs registration and login ceremonies.
+type RelyingParty struct {
+	store  storage.CredentialStorage
+	config Config
+	now    func() time.Time
+}
+
+// NewRelyingParty creates a relying party that keeps credentials and challenges in store.
+func NewRelyingParty(store storage.CredentialStorage, config Config) *RelyingParty {
+	if config.Timeout == 0 {
+		config.Timeout = DefaultTimeout
+	}
+	if config.UserVerification == "" {
+		config.UserVerification = UserVerificationPreferred
+	}
+	if config.RPName == "" {
+		config.RPName = config.RPID
+	}
+	return &RelyingParty{
+		store:  store,
+		config: config,
+		now:    time.Now,
+	}
+}
+
+// UserHandle returns the opaque user ID authenticators store for the named
+// user. It is derived from the name so it never reveals it.
+func UserHandle(userName string) []byte {
+	sum := sha256.Sum256([]byte(userName))
+	return sum[:16]
+}
+
+// BeginRegistration starts registering a new credential for the named user.
+// Credentials the user already has are excluded so an authenticator is not
+// registered twice.
+func (rp *RelyingParty) BeginRegistration(userName string) (*CreationOptions, error) {
+	existing, err := rp.store.FindCredentialsByUser(userName)
+	if err != nil {
+		return nil, err
+	}
+
+	challenge, err := rp.newChallenge(credential.TypeCreate, userName)
+	if err != nil {
+		return nil, err
+	}
+
+	params := make([]CredentialParameter, 0, len(SupportedAlgorithms))
+	for _, alg := range SupportedAlgorithms {
+		params = append(params, CredentialParameter{Type: publicKeyType, Alg: alg})
+	}
+
+	return &CreationOptions{
+		Challenge: challenge,
+		RP:        RelyingPartyEntity{ID: rp.config.RPID, Name: rp.config.RPName},
+		User: UserEntity{
+			ID:          encode(UserHandle(userName)),
+			Name:        userName,
+			DisplayName: userName,
+		},
+		PubKeyCredParams:   params,
+		Timeout:            rp.config.Timeout.Milliseconds(),
+		ExcludeCredentials: descriptors(existing),
+		AuthenticatorSelection: AuthenticatorSelection{
+			ResidentKey:      "preferred",
+			UserVerification: rp.config.UserVerification,
+		},
+		Attestation: "none",
+	}, nil
+}
+
+// FinishRegistration verifies the response to BeginRegistration and stores
+// the new credential for the named user.
+func (rp *RelyingParty) FinishRegistration(userName string, response *AttestationResponse) (*credential.Credential, error) {
+	if response.Type != publicKeyType {
+		return nil, fmt.Errorf("%w: unexpected type %q", ErrInvalidResponse, response.Type)
+	}
+
+	rawClientData, err := decode(response.Response.ClientDataJSON)
+	if err != nil {
+		return nil, err
+	}
+	challenge, err := rp.verifyClientData(rawClientData, credential.TypeCreate)
+	if err != nil {
+		return nil, err
+	}
+	if challenge.UserName != userName {
+		return nil, ErrInvalidChallenge
+	}
+
+	rawAttestation, err := decode(response.Response.AttestationObject)
+	if err != nil {
+		return nil, err
+	}
+	decoded, rest, err := decodeCBOR(rawAttestation)
+	attestation, ok := decoded.(map[interface{}]interface{})
+	if err != nil || len(rest) != 0 || !ok {
+		return nil, fmt.Errorf("%w: malformed attestation object", ErrInvalidResponse)
+	}
+	if format, _ := attestation["fmt"].(string); format != "none" {
+		return nil, fmt.Errorf("%w: unsupported attestation format %q", ErrInvalidResponse, format)
+	}
+	rawAuthData, _ := attestation["authData"].([]byte)
+
+	data, err := rp.verifyAuthenticatorData(rawAuthData)
+	if err != nil {
+		return nil, err
+	}
+	if data.credentialID == nil {
+		return nil, fmt.Errorf("%w: no attested credential", ErrInvalidResponse)
+	}
+	id := encode(data.credentialID)
+	if response.RawID != "" && strings.TrimRight(response.RawID, "=") != id {
+		return nil, fmt.Errorf("%w: credential ID mismatch", ErrInvalidResponse)
+	}
+
+	alg, _, err := parsePublicKey(data.publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	now := rp.now().Unix()
+	c := &credential.Credential{
+		ID:         id,
+		UserName:   userName,
+		PublicKey:  data.publicKey,
+		Algorithm:  alg,
+		SignCount:  data.signCount,
+		Transports: response.Response.Transports,
+		Created:    now,
+		LastUsed:   now,
+	}
+	if err := rp.store.CreateCredential(c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// BeginLogin starts a login. With a user name only that user's credentials
+// are allowed; with an empty name the authenticator offers any discoverable
+// credential (passkey) it holds for the relying party. Unknown users get
+// options too, so the response does not reveal which accounts exist.
+func (rp *RelyingParty) BeginLogin(userName string) (*RequestOptions, error) {
+	var allowed []*credential.Credential
+	if userName != "" {
+		var err error
+		if allowed, err = rp.store.FindCredentialsByUser(userName); err != nil {
+			return nil, err
+		}
+	}
+
+	challenge, err := rp.newChallenge(credential.TypeGet, userName)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RequestOptions{
+		Challenge:        challenge,
+		Timeout:          rp.config.Timeout.Milliseconds(),
+		RPID:             rp.config.RPID,
+		AllowCredentials: descriptors(allowed),
+		UserVerification: rp.config.UserVerification,
+	}, nil
+}
+
+// FinishLogin verifies the response to BeginLogin and advances the
+// credential's sign counter. The returned credential names the user.
+func (rp *RelyingParty) FinishLogin(response *AssertionResponse) (*Assertion, error) {
+	if response.Type != publicKeyType {
+		return nil, fmt.Errorf("%w: unexpected type %q", ErrInvalidResponse, response.Type)
+	}
+
+	rawClientData, err := decode(response.Response.ClientDataJSON)
+	if err != nil {
+		return nil, err
+	}
+	challenge, err := rp.verifyClientData(rawClientData, credential.TypeGet)
+	if err != nil {
+		return nil, err
+	}
+
+	stored, err := rp.Credential(response.RawID)
+	if err != nil {
+		return nil, err
+	}
+	if challenge.UserName != "" && challenge.UserName != stored.UserName {
+		return nil, ErrUnknownCredential
+	}
+	if response.Response.UserHandle != "" {
+		handle, err := decode(response.Response.UserHandle)
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(handle, UserHandle(stored.UserName)) {
+			return nil, ErrUnknownCredential
+		}
+	}
+
+	rawAuthData, err := decode(response.Response.AuthenticatorData)
+	if err != nil {
+		return nil, err
+	}
+	data, err := rp.verifyAuthenticatorData(rawAuthData)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := decode(response.Response.Signature)
+	if err != nil {
+		return nil, err
+	}
+	alg, key, err := parsePublicKey(stored.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+	clientDataHash := sha256.Sum256(rawClientData)
+	signed := append(append([]byte(nil), rawAuthData...), clientDataHash[:]...)
+	if !verifySignature(alg, key, signed, signature) {
+		return nil, ErrInvalidSignature
+	}
+
+	// Authenticators without a counter always report zero
+	var countErr error
+	updated, err := rp.store.UpdateCredential(stored.ID, func(c *credential.Credential) {
+		if (data.signCount != 0 || c.SignCount != 0) && data.signCount <= c.SignCount {
+			countErr = ErrSignCount
+			return
+		}
+		c.SignCount = data.signCount
+		c.LastUsed = rp.now().Unix()
+	})
+	if err != nil {
+		return nil, err
+	}
+	if countErr != nil {
+		return nil, countErr
+	}
+
+	return &Assertion{
+		Credential:   updated,
+		UserVerified: data.flags&flagUserVerified != 0,
+	}, nil
+}
+
+// Credential retrieves a registered credential by its base64url ID.
+func (rp *RelyingParty) Credential(id string) (*credential.Credential, error) {
+	c, err := rp.store.FindCredential(strings.TrimRight(id, "="))
+	if errors.Is(err, storage.ErrCredentialNotFound) {
+		return nil, ErrUnknownCredential
+	}
+	return c, err
+}
+
+// Credentials lists the credentials registered to the named user.
+func (rp *RelyingParty) Credentials(userName string) ([]*credential.Credential, error) {
+	return rp.store.FindCredentialsByUser(userName)
+}
+
+// RemoveCredential deletes one of the named user's credentials.
+func (rp *RelyingParty) RemoveCredential(userName, id string) error {
+	c, err := rp.store.FindCredential(id)
+	if errors.Is(err, storage.ErrCredentialNotFound) || (err == nil && c.UserName != userName) {
+		return ErrUnknownCredential
+	}
+	if err != nil {
+		return err
+	}
+	return rp.store.DeleteCredential(id)
+}
+
+// newChallenge stores a random challenge for a new ceremony.
+func (rp *RelyingParty) newChallenge(ceremony, userName string) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	challenge := encode(b)
+	err := rp.store.CreateChallenge(&credential.Challenge{
+		Challenge: challenge,
+		Type:      ceremony,
+		UserName:  userName,
+		ExpiresAt: rp.now().Add(rp.config.Timeout).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+	return challenge, nil
+}
+
+// verifyClientData checks the client data of a ceremony and consumes its challenge.
+func (rp *RelyingParty) verifyClientData(raw []byte, ceremony string) (*credential.Challenge, error) {
+	var data clientData
+	if err := json.Unmarshal(raw, &data); err != nil {
+		return nil, fmt.Errorf("%w: malformed client data", ErrInvalidResponse)
+	}
+	if data.Type != ceremony {
+		return nil, fmt.Errorf("%w: unexpected ceremony %q", ErrInvalidResponse, data.Type)
+	}
+	if !slices.Contains(rp.config.Origins, data.Origin) || data.CrossOrigin {
+		return nil, fmt.Errorf("%w: origin %q not allowed", ErrInvalidResponse, data.Origin)
+	}
+
+	challenge, err := rp.store.ConsumeChallenge(strings.TrimRight(data.Challenge, "="))
+	if errors.Is(err, storage.ErrChallengeNotFound) {
+		return nil, ErrInvalidChallenge
+	}
+	if err != nil {
+		return nil, err
+	}
+	if challenge.Type != ceremony || challenge.IsExpired(rp.now().Unix()) {
+		return nil, ErrInvalidChallenge
+	}
+	return challenge, nil
+}
+
+// verifyAuthenticatorData parses authData and checks it was produced for
+// this relying party with the user present.
+func (rp *RelyingParty) verifyAuthenticatorData(raw []byte) (*authenticatorData, error) {
+	data, err := parseAuthenticatorData(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	rpIDHash := sha256.Sum256([]byte(rp.config.RPID))
+	if !bytes.Equal(data.rpIDHash, rpIDHash[:]) {
+		return nil, fmt.Errorf("%w: relying party ID mismatch", ErrInvalidResponse)
+	}
+	if data.flags&flagUserPresent == 0 {
+		return nil, fmt.Errorf("%w: user not present", ErrInvalidResponse)
+	}
+	if rp.config.UserVerification == UserVerificationRequired && data.flags&flagUserVerified == 0 {
+		return nil, ErrUserVerification
+	}
+	return data, nil
+}
+
+// descriptors lists credentials for the allow and exclude lists.
+func descriptors(credentials []*credential.Credential) []CredentialDescriptor {
+	if len(credentials) == 0 {
+		return nil
+	}
+	list := make([]CredentialDescriptor, 0, len(credentials))
+	for _, c := range credentials {
+		list = append(list, CredentialDescriptor{Type: publicKeyType, ID: c.ID, Transports: c.Transports})
+	}
+	return list
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// decode accepts base64url with or without padding, as browsers differ.
+func decode(s string) ([]byte, error) {
+	b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid base64url", ErrInvalidResponse)
+	}
+	return b, nil
+}
@@ -0,0 +1,268 @@
+package webauthn_test
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/responsible-api/responsible-auth/examples/memory"
+	"github.com/responsible-api/responsible-auth/storage"
+	"github.com/responsible-api/responsible-auth/webauthn"
+	"github.com/responsible-api/responsible-auth/webauthn/webauthntest"
+)
+
+const (
+	rpID   = "example.com"
+	origin = "https://example.com"
+)
+
+func newRelyingParty(t *testing.T, config webauthn.Config) (*webauthn.RelyingParty, storage.CredentialStorage) {
+	t.Helper()
+	config.RPID = rpID
+	config.Origins = []string{origin}
+	store := memory.NewInMemoryCredentialStorage()
+	return webauthn.NewRelyingParty(store, config), store
+}
+
+// register enrols a new software authenticator for the named user.
+func register(t *testing.T, rp *webauthn.RelyingParty, userName string) *webauthntest.Authenticator {
+	t.Helper()
+	authenticator := webauthntest.New(rpID, origin)
+
+	options, err := rp.BeginRegistration(userName)
+	if err != nil {
+		t.Fatalf("BeginRegistration() unexpected error = %v", err)
+	}
+	response, err := authenticator.Register(options)
+	if err != nil {
+		t.Fatalf("Register() unexpected error = %v", err)
+	}
+	if _, err := rp.FinishRegistration(userName, response); err != nil {
+		t.Fatalf("FinishRegistration() unexpected error = %v", err)
+	}
+	return authenticator
+}
+
+func login(t *testing.T, rp *webauthn.RelyingParty, authenticator *webauthntest.Authenticator, userName string) (*webauthn.Assertion, error) {
+	t.Helper()
+	options, err := rp.BeginLogin(userName)
+	if err != nil {
+		t.Fatalf("BeginLogin() unexpected error = %v", err)
+	}
+	response, err := authenticator.Login(options)
+	if err != nil {
+		t.Fatalf("Login() unexpected error = %v", err)
+	}
+	return rp.FinishLogin(response)
+}
+
+func TestRelyingParty_Ceremonies(t *testing.T) {
+	rp, store := newRelyingParty(t, webauthn.Config{})
+	authenticator := register(t, rp, "alice")
+
+	credentials, err := rp.Credentials("alice")
+	if err != nil || len(credentials) != 1 {
+		t.Fatalf("Credentials() = %v, %v, want one credential", credentials, err)
+	}
+	if credentials[0].Algorithm != webauthn.AlgES256 || credentials[0].SignCount != 0 {
+		t.Errorf("Credentials() = %+v, want ES256 with counter 0", credentials[0])
+	}
+
+	for _, userName := range []string{"alice", ""} {
+		assertion, err := login(t, rp, authenticator, userName)
+		if err != nil {
+			t.Fatalf("FinishLogin() user %q unexpected error = %v", userName, err)
+		}
+		if assertion.Credential.UserName != "alice" {
+			t.Errorf("FinishLogin() user = %v, want alice", assertion.Credential.UserName)
+		}
+		if want := []string{"hwk", "user", "mfa"}; !slices.Equal(assertion.AMR(), want) {
+			t.Errorf("AMR() = %v, want %v", assertion.AMR(), want)
+		}
+	}
+
+	stored, err := store.FindCredential(credentials[0].ID)
+	if err != nil {
+		t.Fatalf("FindCredential() unexpected error = %v", err)
+	}
+	if stored.SignCount != 2 {
+		t.Errorf("SignCount = %d, want 2", stored.SignCount)
+	}
+
+	// Registering the same authenticator again is refused by the exclude list
+	options, err := rp.BeginRegistration("alice")
+	if err != nil {
+		t.Fatalf("BeginRegistration() unexpected error = %v", err)
+	}
+	if _, err := authenticator.Register(options); !errors.Is(err, webauthntest.ErrExcluded) {
+		t.Errorf("Register() again error = %v, want %v", err, webauthntest.ErrExcluded)
+	}
+
+	if err := rp.RemoveCredential("bob", credentials[0].ID); !errors.Is(err, webauthn.ErrUnknownCredential) {
+		t.Errorf("RemoveCredential() other user error = %v, want %v", err, webauthn.ErrUnknownCredential)
+	}
+	if err := rp.RemoveCredential("alice", credentials[0].ID); err != nil {
+		t.Fatalf("RemoveCredential() unexpected error = %v", err)
+	}
+	if _, err := login(t, rp, authenticator, ""); !errors.Is(err, webauthn.ErrUnknownCredential) {
+		t.Errorf("FinishLogin() removed credential error = %v, want %v", err, webauthn.ErrUnknownCredential)
+	}
+}
+
+func TestRelyingParty_FinishLoginRejects(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      webauthn.Config
+		userName    string
+		client      func(a *webauthntest.Authenticator)
+		tamper      func(r *webauthn.AssertionResponse)
+		expectError error
+	}{
+		{
+			name:        "wrong origin",
+			client:      func(a *webauthntest.Authenticator) { a.Origin = "https://evil.example" },
+			expectError: webauthn.ErrInvalidResponse,
+		},
+		{
+			name:        "wrong relying party",
+			client:      func(a *webauthntest.Authenticator) { a.RPID = "evil.example" },
+			expectError: webauthn.ErrInvalidResponse,
+		},
+		{
+			name: "bad signature",
+			tamper: func(r *webauthn.AssertionResponse) {
+				r.Response.Signature = "MAYCAQECAQE"
+			},
+			expectError: webauthn.ErrInvalidSignature,
+		},
+		{
+			name:        "user verification required",
+			config:      webauthn.Config{UserVerification: webauthn.UserVerificationRequired},
+			client:      func(a *webauthntest.Authenticator) { a.UserVerified = false },
+			expectError: webauthn.ErrUserVerification,
+		},
+		{
+			name:        "credential of another user",
+			userName:    "bob",
+			expectError: webauthn.ErrUnknownCredential,
+		},
+		{
+			name:        "mismatching user handle",
+			tamper:      func(r *webauthn.AssertionResponse) { r.Response.UserHandle = "Ym9i" },
+			expectError: webauthn.ErrUnknownCredential,
+		},
+		{
+			name:        "expired challenge",
+			config:      webauthn.Config{Timeout: time.Nanosecond},
+			expectError: webauthn.ErrInvalidChallenge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Register with the defaults, then log in with the tested config
+			rp, store := newRelyingParty(t, webauthn.Config{})
+			authenticator := register(t, rp, "alice")
+			tt.config.RPID = rpID
+			tt.config.Origins = []string{origin}
+			rp = webauthn.NewRelyingParty(store, tt.config)
+
+			options, err := rp.BeginLogin(tt.userName)
+			if err != nil {
+				t.Fatalf("BeginLogin() unexpected error = %v", err)
+			}
+			// Offer alice's credential even when another user was named
+			options.AllowCredentials = nil
+
+			if tt.client != nil {
+				tt.client(authenticator)
+			}
+			response, err := authenticator.Login(options)
+			if err != nil {
+				t.Fatalf("Login() unexpected error = %v", err)
+			}
+			if tt.tamper != nil {
+				tt.tamper(response)
+			}
+
+			if _, err := rp.FinishLogin(response); !errors.Is(err, tt.expectError) {
+				t.Errorf("FinishLogin() error = %v, want %v", err, tt.expectError)
+			}
+		})
+	}
+}
+
+func TestRelyingParty_Replay(t *testing.T) {
+	rp, _ := newRelyingParty(t, webauthn.Config{})
+	authenticator := register(t, rp, "alice")
+
+	options, err := rp.BeginLogin("alice")
+	if err != nil {
+		t.Fatalf("BeginLogin() unexpected error = %v", err)
+	}
+	response, err := authenticator.Login(options)
+	if err != nil {
+		t.Fatalf("Login() unexpected error = %v", err)
+	}
+
+	if _, err := rp.FinishLogin(response); err != nil {
+		t.Fatalf("FinishLogin() unexpected error = %v", err)
+	}
+	if _, err := rp.FinishLogin(response); !errors.Is(err, webauthn.ErrInvalidChallenge) {
+		t.Errorf("FinishLogin() replay error = %v, want %v", err, webauthn.ErrInvalidChallenge)
+	}
+}
+
+func TestRelyingParty_ClonedAuthenticator(t *testing.T) {
+	rp, _ := newRelyingParty(t, webauthn.Config{})
+	authenticator := register(t, rp, "alice")
+	clone := authenticator.Clone()
+
+	for i := 0; i < 2; i++ {
+		if _, err := login(t, rp, authenticator, "alice"); err != nil {
+			t.Fatalf("FinishLogin() unexpected error = %v", err)
+		}
+	}
+
+	// The clone's counter lags behind the original's
+	if _, err := login(t, rp, clone, "alice"); !errors.Is(err, webauthn.ErrSignCount) {
+		t.Errorf("FinishLogin() clone error = %v, want %v", err, webauthn.ErrSignCount)
+	}
+}
+
+func TestRelyingParty_FinishRegistrationRejects(t *testing.T) {
+	rp, _ := newRelyingParty(t, webauthn.Config{})
+	authenticator := webauthntest.New(rpID, origin)
+
+	// A challenge issued to alice cannot register a credential for bob
+	options, err := rp.BeginRegistration("alice")
+	if err != nil {
+		t.Fatalf("BeginRegistration() unexpected error = %v", err)
+	}
+	response, err := authenticator.Register(options)
+	if err != nil {
+		t.Fatalf("Register() unexpected error = %v", err)
+	}
+	if _, err := rp.FinishRegistration("bob", response); !errors.Is(err, webauthn.ErrInvalidChallenge) {
+		t.Errorf("FinishRegistration() other user error = %v, want %v", err, webauthn.ErrInvalidChallenge)
+	}
+
+	// Login challenges cannot complete a registration
+	loginOptions, err := rp.BeginLogin("alice")
+	if err != nil {
+		t.Fatalf("BeginLogin() unexpected error = %v", err)
+	}
+	options.Challenge = loginOptions.Challenge
+	options.ExcludeCredentials = nil
+	if response, err = authenticator.Register(options); err != nil {
+		t.Fatalf("Register() unexpected error = %v", err)
+	}
+	if _, err := rp.FinishRegistration("alice", response); !errors.Is(err, webauthn.ErrInvalidChallenge) {
+		t.Errorf("FinishRegistration() login challenge error = %v, want %v", err, webauthn.ErrInvalidChallenge)
+	}
+
+	if credentials, _ := rp.Credentials("alice"); len(credentials) != 0 {
+		t.Errorf("Credentials() = %+v, want none", credentials)
+	}
+}
@@ -0,0 +1,251 @@
+// Package webauthntest provides a software authenticator for testing
+// WebAuthn relying parties without a browser or security key.
+package webauthntest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+
+	"github.com/responsible-api/responsible-auth/webauthn"
+)
+
+var (
+	// ErrExcluded is returned when registering while holding an excluded credential.
+	ErrExcluded = errors.New("authenticator already holds an excluded credential")
+
+	// ErrNoCredential is returned when logging in without a matching credential.
+	ErrNoCredential = errors.New("authenticator holds no matching credential")
+)
+
+// Authenticator is a platform authenticator holding ES256 passkeys in memory.
+// It reports user presence on every ceremony and user verification when
+// UserVerified is set. Fields may be changed between ceremonies to simulate
+// misbehaving clients.
+type Authenticator struct {
+	RPID         string
+	Origin       string
+	UserVerified bool
+
+	credentials []*softCredential
+}
+
+type softCredential struct {
+	id         []byte
+	key        *ecdsa.PrivateKey
+	userHandle []byte
+	signCount  uint32
+}
+
+// New creates an authenticator for the relying party ID, running on origin.
+func New(rpID, origin string) *Authenticator {
+	return &Authenticator{
+		RPID:         rpID,
+		Origin:       origin,
+		UserVerified: true,
+	}
+}
+
+// Clone returns an authenticator holding copies of the same keys and
+// counters, as an attacker who extracted them would.
+func (a *Authenticator) Clone() *Authenticator {
+	clone := *a
+	clone.credentials = make([]*softCredential, 0, len(a.credentials))
+	for _, c := range a.credentials {
+		copied := *c
+		clone.credentials = append(clone.credentials, &copied)
+	}
+	return &clone
+}
+
+// Register creates a credential in response to creation options.
+func (a *Authenticator) Register(options *webauthn.CreationOptions) (*webauthn.AttestationResponse, error) {
+	for _, excluded := range options.ExcludeCredentials {
+		if a.find(excluded.ID) != nil {
+			return nil, ErrExcluded
+		}
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	userHandle, err := base64.RawURLEncoding.DecodeString(options.User.ID)
+	if err != nil {
+		return nil, err
+	}
+	c := &softCredential{id: id, key: key, userHandle: userHandle}
+	a.credentials = append(a.credentials, c)
+
+	// Attested credential data: zero AAGUID, ID length, ID and COSE key
+	attested := make([]byte, 16, 16+2+len(id))
+	attested = binary.BigEndian.AppendUint16(attested, uint16(len(id)))
+	attested = append(attested, id...)
+	attested = append(attested, coseKey(&key.PublicKey)...)
+	authData := a.authData(0x40, c.signCount, attested)
+
+	// {"fmt": "none", "attStmt": {}, "authData": authData}
+	attestation := appendHeader(nil, 5, 3)
+	attestation = appendText(attestation, "fmt")
+	attestation = appendText(attestation, "none")
+	attestation = appendText(attestation, "attStmt")
+	attestation = appendHeader(attestation, 5, 0)
+	attestation = appendText(attestation, "authData")
+	attestation = appendBytes(attestation, authData)
+
+	clientDataJSON, err := a.clientData("webauthn.create", options.Challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	encodedID := encode(id)
+	return &webauthn.AttestationResponse{
+		ID:    encodedID,
+		RawID: encodedID,
+		Type:  "public-key",
+		Response: webauthn.AuthenticatorAttestationResponse{
+			ClientDataJSON:    encode(clientDataJSON),
+			AttestationObject: encode(attestation),
+			Transports:        []string{"internal"},
+		},
+	}, nil
+}
+
+// Login signs the challenge of request options with the first allowed
+// credential, or with the first credential it holds when none are listed.
+func (a *Authenticator) Login(options *webauthn.RequestOptions) (*webauthn.AssertionResponse, error) {
+	var c *softCredential
+	if len(options.AllowCredentials) == 0 && len(a.credentials) > 0 {
+		c = a.credentials[0]
+	}
+	for _, allowed := range options.AllowCredentials {
+		if c = a.find(allowed.ID); c != nil {
+			break
+		}
+	}
+	if c == nil {
+		return nil, ErrNoCredential
+	}
+
+	c.signCount++
+	authData := a.authData(0, c.signCount, nil)
+
+	clientDataJSON, err := a.clientData("webauthn.get", options.Challenge)
+	if err != nil {
+		return nil, err
+	}
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	digest := sha256.Sum256(append(append([]byte(nil), authData...), clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, c.key, digest[:])
+	if err != nil {
+		return nil, err
+	}
+
+	encodedID := encode(c.id)
+	return &webauthn.AssertionResponse{
+		ID:    encodedID,
+		RawID: encodedID,
+		Type:  "public-key",
+		Response: webauthn.AuthenticatorAssertionResponse{
+			ClientDataJSON:    encode(clientDataJSON),
+			AuthenticatorData: encode(authData),
+			Signature:         encode(signature),
+			UserHandle:        encode(c.userHandle),
+		},
+	}, nil
+}
+
+func (a *Authenticator) find(id string) *softCredential {
+	for _, c := range a.credentials {
+		if encode(c.id) == id {
+			return c
+		}
+	}
+	return nil
+}
+
+// authData builds authenticator data with the presence and verification
+// flags set as configured.
+func (a *Authenticator) authData(flags byte, signCount uint32, attested []byte) []byte {
+	flags |= 0x01
+	if a.UserVerified {
+		flags |= 0x04
+	}
+	rpIDHash := sha256.Sum256([]byte(a.RPID))
+
+	data := append(rpIDHash[:], flags)
+	data = binary.BigEndian.AppendUint32(data, signCount)
+	return append(data, attested...)
+}
+
+func (a *Authenticator) clientData(ceremony, challenge string) ([]byte, error) {
+	return json.Marshal(map[string]interface{}{
+		"type":        ceremony,
+		"challenge":   challenge,
+		"origin":      a.Origin,
+		"crossOrigin": false,
+	})
+}
+
+// coseKey encodes an ES256 public key as a COSE_Key.
+func coseKey(key *ecdsa.PublicKey) []byte {
+	x := make([]byte, 32)
+	y := make([]byte, 32)
+	key.X.FillBytes(x)
+	key.Y.FillBytes(y)
+
+	b := appendHeader(nil, 5, 5)
+	b = appendInt(b, 1) // kty: EC2
+	b = appendInt(b, 2)
+	b = appendInt(b, 3) // alg: ES256
+	b = appendInt(b, webauthn.AlgES256)
+	b = appendInt(b, -1) // crv: P-256
+	b = appendInt(b, 1)
+	b = appendInt(b, -2)
+	b = appendBytes(b, x)
+	b = appendInt(b, -3)
+	return appendBytes(b, y)
+}
+
+func appendHeader(b []byte, major byte, n uint64) []byte {
+	major <<= 5
+	switch {
+	case n < 24:
+		return append(b, major|byte(n))
+	case n <= 0xff:
+		return append(b, major|24, byte(n))
+	case n <= 0xffff:
+		return binary.BigEndian.AppendUint16(append(b, major|25), uint16(n))
+	case n <= 0xffffffff:
+		return binary.BigEndian.AppendUint32(append(b, major|26), uint32(n))
+	}
+	return binary.BigEndian.AppendUint64(append(b, major|27), n)
+}
+
+func appendInt(b []byte, v int64) []byte {
+	if v < 0 {
+		return appendHeader(b, 1, uint64(-1-v))
+	}
+	return appendHeader(b, 0, uint64(v))
+}
+
+func appendBytes(b []byte, v []byte) []byte {
+	return append(appendHeader(b, 2, uint64(len(v))), v...)
+}
+
+func appendText(b []byte, v string) []byte {
+	return append(appendHeader(b, 3, uint64(len(v))), v...)
+}
+
+func encode(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}